@@ -0,0 +1,45 @@
+package api
+
+import "errors"
+
+// Kinds of errors, both APIs answer every kind with its own status code.
+// Errors of no kind are internal errors.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("unavailable")
+	ErrInvalid     = errors.New("invalid")
+)
+
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// NewError returns an error of the given kind, which keeps its message
+// rather than the message of the kind.
+func NewError(kind error, message string) error {
+	return &kindError{err: errors.New(message), kind: kind}
+}
+
+// WrapError marks err as an error of the given kind, err is still found by
+// errors.Is and errors.As.
+func WrapError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &kindError{err: err, kind: kind}
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// byteOrderMark is written ahead of CSV files by some spreadsheets.
+var byteOrderMark = []byte("\xef\xbb\xbf")
+
+// SkipByteOrderMark drops the byte order mark at the start of the reader,
+// when there is one, so the first header of a CSV file is read without it.
+func SkipByteOrderMark(reader *bufio.Reader) {
+	if head, _ := reader.Peek(len(byteOrderMark)); bytes.Equal(head, byteOrderMark) {
+		_, _ = reader.Discard(len(byteOrderMark))
+	}
+}
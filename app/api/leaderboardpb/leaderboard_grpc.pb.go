@@ -0,0 +1,341 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package leaderboardpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// LeaderboardClient is the client API for Leaderboard service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LeaderboardClient interface {
+	SubmitScore(ctx context.Context, in *SubmitScoreRequest, opts ...grpc.CallOption) (*SubmitScoreResponse, error)
+	BatchSubmit(ctx context.Context, in *BatchSubmitRequest, opts ...grpc.CallOption) (*BatchSubmitResponse, error)
+	GetPage(ctx context.Context, in *GetPageRequest, opts ...grpc.CallOption) (*GetPageResponse, error)
+	GetAroundMe(ctx context.Context, in *GetAroundMeRequest, opts ...grpc.CallOption) (*GetPageResponse, error)
+	GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*UserProfile, error)
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*UserProfile, error)
+	SubscribeRank(ctx context.Context, in *SubscribeRankRequest, opts ...grpc.CallOption) (Leaderboard_SubscribeRankClient, error)
+}
+
+type leaderboardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLeaderboardClient(cc grpc.ClientConnInterface) LeaderboardClient {
+	return &leaderboardClient{cc}
+}
+
+func (c *leaderboardClient) SubmitScore(ctx context.Context, in *SubmitScoreRequest, opts ...grpc.CallOption) (*SubmitScoreResponse, error) {
+	out := new(SubmitScoreResponse)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/SubmitScore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) BatchSubmit(ctx context.Context, in *BatchSubmitRequest, opts ...grpc.CallOption) (*BatchSubmitResponse, error) {
+	out := new(BatchSubmitResponse)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/BatchSubmit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) GetPage(ctx context.Context, in *GetPageRequest, opts ...grpc.CallOption) (*GetPageResponse, error) {
+	out := new(GetPageResponse)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/GetPage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) GetAroundMe(ctx context.Context, in *GetAroundMeRequest, opts ...grpc.CallOption) (*GetPageResponse, error) {
+	out := new(GetPageResponse)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/GetAroundMe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*UserProfile, error) {
+	out := new(UserProfile)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/GetProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*UserProfile, error) {
+	out := new(UserProfile)
+	err := c.cc.Invoke(ctx, "/leaderboard.v1.Leaderboard/CreateUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *leaderboardClient) SubscribeRank(ctx context.Context, in *SubscribeRankRequest, opts ...grpc.CallOption) (Leaderboard_SubscribeRankClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Leaderboard_serviceDesc.Streams[0], "/leaderboard.v1.Leaderboard/SubscribeRank", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &leaderboardSubscribeRankClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Leaderboard_SubscribeRankClient interface {
+	Recv() (*RankUpdate, error)
+	grpc.ClientStream
+}
+
+type leaderboardSubscribeRankClient struct {
+	grpc.ClientStream
+}
+
+func (x *leaderboardSubscribeRankClient) Recv() (*RankUpdate, error) {
+	m := new(RankUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LeaderboardServer is the server API for Leaderboard service.
+// All implementations must embed UnimplementedLeaderboardServer
+// for forward compatibility
+type LeaderboardServer interface {
+	SubmitScore(context.Context, *SubmitScoreRequest) (*SubmitScoreResponse, error)
+	BatchSubmit(context.Context, *BatchSubmitRequest) (*BatchSubmitResponse, error)
+	GetPage(context.Context, *GetPageRequest) (*GetPageResponse, error)
+	GetAroundMe(context.Context, *GetAroundMeRequest) (*GetPageResponse, error)
+	GetProfile(context.Context, *GetProfileRequest) (*UserProfile, error)
+	CreateUser(context.Context, *CreateUserRequest) (*UserProfile, error)
+	SubscribeRank(*SubscribeRankRequest, Leaderboard_SubscribeRankServer) error
+	mustEmbedUnimplementedLeaderboardServer()
+}
+
+// UnimplementedLeaderboardServer must be embedded to have forward compatible implementations.
+type UnimplementedLeaderboardServer struct {
+}
+
+func (UnimplementedLeaderboardServer) SubmitScore(context.Context, *SubmitScoreRequest) (*SubmitScoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitScore not implemented")
+}
+func (UnimplementedLeaderboardServer) BatchSubmit(context.Context, *BatchSubmitRequest) (*BatchSubmitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSubmit not implemented")
+}
+func (UnimplementedLeaderboardServer) GetPage(context.Context, *GetPageRequest) (*GetPageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPage not implemented")
+}
+func (UnimplementedLeaderboardServer) GetAroundMe(context.Context, *GetAroundMeRequest) (*GetPageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAroundMe not implemented")
+}
+func (UnimplementedLeaderboardServer) GetProfile(context.Context, *GetProfileRequest) (*UserProfile, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProfile not implemented")
+}
+func (UnimplementedLeaderboardServer) CreateUser(context.Context, *CreateUserRequest) (*UserProfile, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+func (UnimplementedLeaderboardServer) SubscribeRank(*SubscribeRankRequest, Leaderboard_SubscribeRankServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeRank not implemented")
+}
+func (UnimplementedLeaderboardServer) mustEmbedUnimplementedLeaderboardServer() {}
+
+// UnsafeLeaderboardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LeaderboardServer will
+// result in compilation errors.
+type UnsafeLeaderboardServer interface {
+	mustEmbedUnimplementedLeaderboardServer()
+}
+
+func RegisterLeaderboardServer(s grpc.ServiceRegistrar, srv LeaderboardServer) {
+	s.RegisterService(&_Leaderboard_serviceDesc, srv)
+}
+
+func _Leaderboard_SubmitScore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).SubmitScore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/SubmitScore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).SubmitScore(ctx, req.(*SubmitScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_BatchSubmit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSubmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).BatchSubmit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/BatchSubmit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).BatchSubmit(ctx, req.(*BatchSubmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_GetPage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).GetPage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/GetPage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).GetPage(ctx, req.(*GetPageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_GetAroundMe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAroundMeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).GetAroundMe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/GetAroundMe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).GetAroundMe(ctx, req.(*GetAroundMeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).GetProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/GetProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).GetProfile(ctx, req.(*GetProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaderboardServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/leaderboard.v1.Leaderboard/CreateUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaderboardServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Leaderboard_SubscribeRank_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRankRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LeaderboardServer).SubscribeRank(m, &leaderboardSubscribeRankServer{stream})
+}
+
+type Leaderboard_SubscribeRankServer interface {
+	Send(*RankUpdate) error
+	grpc.ServerStream
+}
+
+type leaderboardSubscribeRankServer struct {
+	grpc.ServerStream
+}
+
+func (x *leaderboardSubscribeRankServer) Send(m *RankUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Leaderboard_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "leaderboard.v1.Leaderboard",
+	HandlerType: (*LeaderboardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitScore",
+			Handler:    _Leaderboard_SubmitScore_Handler,
+		},
+		{
+			MethodName: "BatchSubmit",
+			Handler:    _Leaderboard_BatchSubmit_Handler,
+		},
+		{
+			MethodName: "GetPage",
+			Handler:    _Leaderboard_GetPage_Handler,
+		},
+		{
+			MethodName: "GetAroundMe",
+			Handler:    _Leaderboard_GetAroundMe_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _Leaderboard_GetProfile_Handler,
+		},
+		{
+			MethodName: "CreateUser",
+			Handler:    _Leaderboard_CreateUser_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeRank",
+			Handler:       _Leaderboard_SubscribeRank_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "app/api/leaderboardpb/leaderboard.proto",
+}
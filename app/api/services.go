@@ -1,26 +1,66 @@
 package api
 
 import (
+	"context"
 	"github.com/go-redis/redis/v8"
+	"net/http"
+	"time"
 )
 
 type RedisService interface {
-	Set(key string, value string)
-	Get(key string) (string, error)
-	Add(sortedSetName string, z ...*redis.Z)
-	FlushAll()
-	GetSortedSetSize(sortedSetName string) (int64, error)
-	GetRank(sortedSetName string, key string) (int64, error)
-	GetScore(sortedSetName string, key string) (float64, error)
-	GetPage(sortedSetName string, startIndex int64, endIndex int64) ([]redis.Z, error)
-	GetProfile(id string) (*UserProfile, error)
-	SetProfile(profile *UserProfile) (err error)
-	HSet(key string, values ...interface{}) *redis.IntCmd
-	HGetAll(key string) *redis.StringStringMapCmd
-	Exists(key string) (bool, error)
-	GetOrDefault(key string, defaultVal string) string
+	Set(ctx context.Context, key string, value string) error
+	Get(ctx context.Context, key string) (string, error)
+	Add(ctx context.Context, sortedSetName string, z ...*redis.Z) error
+	FlushAll(ctx context.Context) error
+	GetSortedSetSize(ctx context.Context, sortedSetName string) (int64, error)
+	GetRank(ctx context.Context, sortedSetName string, key string) (int64, error)
+	GetScore(ctx context.Context, sortedSetName string, key string) (float64, error)
+	GetPage(ctx context.Context, sortedSetName string, startIndex int64, endIndex int64) ([]redis.Z, error)
+	GetProfile(ctx context.Context, id string) (*UserProfile, error)
+	SetProfile(ctx context.Context, profile *UserProfile) (err error)
+	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
+	Exists(ctx context.Context, key string) (bool, error)
+	GetOrDefault(ctx context.Context, key string, defaultVal string) string
+	GetBoardNames(ctx context.Context) ([]string, error)
+	AddRankHistory(ctx context.Context, sortedSetName string, ttl time.Duration, entries map[string]*RankHistoryEntry) error
+	GetRankHistory(ctx context.Context, sortedSetName string, key string, from int64, to int64) ([]*RankHistoryEntry, error)
+	Publish(ctx context.Context, channel string, message interface{}) error
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	AddToStream(ctx context.Context, stream string, maxLen int64, entries ...map[string]interface{}) error
+	ReadStream(ctx context.Context, stream string, lastId string, count int64, block time.Duration) ([]redis.XMessage, error)
+	RangeStream(ctx context.Context, stream string, start string, end string, count int64) ([]redis.XMessage, error)
+	GetLastStreamId(ctx context.Context, stream string) (string, error)
+	HGet(ctx context.Context, key string, field string) (string, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+	Del(ctx context.Context, keys ...string) error
+	PushToList(ctx context.Context, key string, maxLen int64, values ...interface{}) error
+	GetList(ctx context.Context, key string, start int64, end int64) ([]string, error)
+	RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error)
+	WriteScore(ctx context.Context, write *ScoreWrite) ([]*ScoreEvent, error)
+	UpdateProfile(ctx context.Context, previousCountry string, profile *UserProfile) error
+	DeleteProfile(ctx context.Context, profile *UserProfile) error
+	DeleteBoard(ctx context.Context, name string) (bool, error)
+	ArchiveBoard(ctx context.Context, name string, label string) error
+	GetArchiveLabels(ctx context.Context, name string) ([]string, error)
+	DeleteArchive(ctx context.Context, name string, label string) error
+	WriteImportBatch(ctx context.Context, batch *ImportBatch) error
+	GetImport(ctx context.Context, id string) (*ImportReport, error)
+	Ping(ctx context.Context) error
 }
 
 type LeaderboardService interface {
-	GetPage(boardName string, page int64, pageSize int64) ([]*LeaderboardRow, error)
+	GetPage(ctx context.Context, boardName string, page int64, pageSize int64) ([]*LeaderboardRow, error)
+	GetAround(ctx context.Context, boardName string, guid string, radius int64) ([]*LeaderboardRow, error)
+}
+
+type ScoreListener interface {
+	OnScoreEvents(ctx context.Context, events []*ScoreEvent)
+}
+
+// Authenticator resolves the principal of a request from its headers. It
+// returns neither a principal nor an error when the request carries no
+// credentials it understands, so authenticators can be chained.
+type Authenticator interface {
+	Authenticate(header http.Header) (*Principal, error)
 }
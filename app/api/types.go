@@ -1,11 +1,9 @@
 package api
 
-import (
-	"regexp"
-	"strings"
-)
+import "time"
 
 type LeaderboardRow struct {
+	UserId      string `json:"user_id"`
 	Rank        int64  `json:"rank"`
 	Points      int64  `json:"points"`
 	DisplayName string `json:"display_name"`
@@ -18,6 +16,47 @@ type ScoreSubmission struct {
 	Timestamp int64   `json:"timestamp" validate:"required"`
 }
 
+// ScoreWrite is a submission resolved to the boards it is written to. It is
+// written only while the profile of the user is still from Country, and a
+// rank history sample is recorded along unless HistoryTimestamp is 0.
+type ScoreWrite struct {
+	UserId           string
+	Country          string
+	Boards           []string
+	Score            float64
+	Mode             string
+	HistoryTimestamp int64
+	HistoryTTL       time.Duration
+}
+
+type BatchScoreSubmission struct {
+	Submissions []*ScoreSubmission `json:"submissions" validate:"required,min=1,max=1000,dive,required"`
+}
+
+type BatchScoreResult struct {
+	Events []*ScoreEvent `json:"events,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type ScoreEvent struct {
+	UserId       string  `json:"user_id"`
+	Board        string  `json:"board"`
+	Score        float64 `json:"score"`
+	PreviousRank int64   `json:"previous_rank"`
+	Rank         int64   `json:"rank"`
+	Timestamp    int64   `json:"timestamp"`
+}
+
+type StreamEvent struct {
+	Id    string      `json:"id"`
+	Type  string      `json:"type"`
+	Event *ScoreEvent `json:"event"`
+}
+
+type ScoreStreamQuery struct {
+	Board string `json:"board" query:"board"`
+}
+
 type UserProfile struct {
 	UserId      string  `json:"user_id"`
 	DisplayName string  `json:"display_name" validate:"required"`
@@ -26,52 +65,302 @@ type UserProfile struct {
 	Country     string  `json:"country" validate:"required"`
 }
 
+// UserUpdate changes the display name or the country of a user, fields
+// which are not given are left as they are.
+type UserUpdate struct {
+	DisplayName string `json:"display_name,omitempty" validate:"required_without=Country"`
+	Country     string `json:"country,omitempty"`
+}
+
+// Board reports a board, the scores and the archives are only reported for
+// a single board.
+type Board struct {
+	Name        string   `json:"name"`
+	Members     int64    `json:"members"`
+	TopScore    float64  `json:"top_score,omitempty"`
+	BottomScore float64  `json:"bottom_score,omitempty"`
+	Archives    []string `json:"archives,omitempty"`
+}
+
+// BoardArchive labels an archive of a board, a timestamp when not given.
+type BoardArchive struct {
+	Label string `json:"label,omitempty" validate:"omitempty,max=64,excludesall=:*?[]{}"`
+}
+
 type LeaderboardQuery struct {
 	Country  string `json:"country" query:"country"`
 	Page     int64  `json:"page" query:"page"`
 	PageSize int64  `json:"page_size" query:"page_size"`
 }
 
-type ValidationError struct {
+type AroundQuery struct {
+	Board  string `json:"board" query:"board"`
+	Radius int64  `json:"radius" query:"radius" validate:"gte=0,lte=100"`
+}
+
+type RankHistoryQuery struct {
+	Board string `json:"board" query:"board"`
+	From  int64  `json:"from" query:"from"`
+	To    int64  `json:"to" query:"to"`
+}
+
+type RankHistoryEntry struct {
+	Timestamp int64 `json:"timestamp"`
+	Rank      int64 `json:"rank"`
+}
+
+type LiveSubscriptionRequest struct {
+	Action         string `json:"action" validate:"required,oneof=subscribe unsubscribe"`
+	SubscriptionId string `json:"subscription_id" validate:"required_if=Action unsubscribe"`
+	Board          string `json:"board"`
+	UserId         string `json:"user_id"`
+	Page           int64  `json:"page" validate:"gte=0"`
+	PageSize       int64  `json:"page_size" validate:"gte=0,lte=100"`
+}
+
+type LiveUpdate struct {
+	Type           string            `json:"type"`
+	SubscriptionId string            `json:"subscription_id,omitempty"`
+	Board          string            `json:"board,omitempty"`
+	Page           int64             `json:"page,omitempty"`
+	PageSize       int64             `json:"page_size,omitempty"`
+	Snapshot       bool              `json:"snapshot,omitempty"`
+	Rows           []*LeaderboardRow `json:"rows,omitempty"`
+	RemovedRanks   []int64           `json:"removed_ranks,omitempty"`
+	UserId         string            `json:"user_id,omitempty"`
+	Rank           int64             `json:"rank,omitempty"`
+	Points         int64             `json:"points,omitempty"`
+	Message        string            `json:"message,omitempty"`
+}
+
+type Webhook struct {
+	Id      string   `json:"id"`
+	Url     string   `json:"url" validate:"required,url"`
+	Secret  string   `json:"secret,omitempty" validate:"required"`
+	Trigger string   `json:"trigger" validate:"required,oneof=top_n overtaken new_leader"`
+	Board   string   `json:"board"`
+	TopN    int64    `json:"top_n" validate:"required_if=Trigger top_n,gte=0"`
+	UserId  string   `json:"user_id" validate:"required_if=Trigger overtaken"`
+	Friends []string `json:"friends" validate:"required_if=Trigger overtaken"`
+}
+
+type WebhookPayload struct {
+	Id           string `json:"id"`
+	WebhookId    string `json:"webhook_id"`
+	Trigger      string `json:"trigger"`
+	Board        string `json:"board"`
+	UserId       string `json:"user_id"`
+	Rank         int64  `json:"rank"`
+	PreviousRank int64  `json:"previous_rank"`
+	OvertakenId  string `json:"overtaken_user_id,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+type WebhookDelivery struct {
+	Payload    *WebhookPayload `json:"payload"`
+	Attempt    int             `json:"attempt"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Success    bool            `json:"success"`
+	Timestamp  int64           `json:"timestamp"`
+}
+
+type Principal struct {
+	Subject string   `json:"subject"`
+	Role    string   `json:"role"`
+	Scopes  []string `json:"scopes,omitempty"`
+}
+
+// FieldError describes a field of a request which fails a rule, the path
+// of the field is given as it is in the request, e.g. submissions[0].score.
+type FieldError struct {
 	Path    string `json:"path"`
+	Rule    string `json:"rule"`
 	Message string `json:"message"`
 }
 
-type ValidationErrorResponse struct {
-	Errors []ValidationError `json:"errors"`
+// ErrorResponse is the body of every error. Code is stable for clients to
+// match, Message is for humans and Fields lists the failing fields of an
+// invalid request.
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestId string       `json:"request_id,omitempty"`
 }
 
-func NewValidationErrorResponse(rawMessage string) *ValidationErrorResponse {
-	rows := strings.Split(rawMessage, "\n")
-	r, err := regexp.Compile("Key:\\s+'(?P<Key>.+)'\\s+Error:(?P<Message>.+)")
-	if err != nil {
-		panic(err)
-	}
+// HealthStatus reports the instance as a whole, and the result of every
+// dependency check when readiness is reported.
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
 
-	var errors []ValidationError
-	for _, row := range rows {
-		matches := r.FindStringSubmatch(row)
-		errors = append(errors, ValidationError{
-			Path:    matches[1],
-			Message: matches[2],
-		})
-	}
+// Job is a kind of background work the instances run, LastRun is its
+// latest run.
+type Job struct {
+	Name      string  `json:"name"`
+	Singleton bool    `json:"singleton"`
+	LastRun   *JobRun `json:"last_run,omitempty"`
+}
 
-	return &ValidationErrorResponse{Errors: errors}
+// JobRun reports a run of a job. Done and Total are its progress, Total is
+// 0 while it is unknown. Times are unix timestamps.
+type JobRun struct {
+	Id         string                 `json:"id"`
+	Job        string                 `json:"job"`
+	State      string                 `json:"state"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Done       int64                  `json:"done"`
+	Total      int64                  `json:"total"`
+	Error      string                 `json:"error,omitempty"`
+	Owner      string                 `json:"owner,omitempty"`
+	QueuedAt   int64                  `json:"queued_at"`
+	StartedAt  int64                  `json:"started_at,omitempty"`
+	UpdatedAt  int64                  `json:"updated_at,omitempty"`
+	FinishedAt int64                  `json:"finished_at,omitempty"`
 }
 
-type UserNotFound struct {
-	Message string `json:"message"`
+// SchedulerStatus lists the schedules of the scheduler along with the
+// instance leading it, empty while no instance does.
+type SchedulerStatus struct {
+	Leader    string      `json:"leader,omitempty"`
+	TimeZone  string      `json:"time_zone"`
+	Schedules []*Schedule `json:"schedules"`
+}
+
+// Schedule reports a schedule of a job. LastAt is the last time of the cron
+// expression the scheduler handled, LastRunAt the time the last run was
+// started, by the schedule or by a trigger. Times are unix timestamps.
+type Schedule struct {
+	Name      string `json:"name"`
+	Job       string `json:"job"`
+	Cron      string `json:"cron"`
+	CatchUp   string `json:"catch_up"`
+	LastAt    int64  `json:"last_at,omitempty"`
+	NextAt    int64  `json:"next_at,omitempty"`
+	LastRunAt int64  `json:"last_run_at,omitempty"`
+	LastRunId string `json:"last_run_id,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// GenerateUsersParameters configure a run of the generate-users job. A run
+// with the same seed generates the same users and submissions.
+type GenerateUsersParameters struct {
+	Users       uint64 `json:"users" validate:"required,min=1"`
+	Concurrency uint64 `json:"concurrency" validate:"required,min=1,max=64"`
+	// Seed of the run, the run id is hashed into one when it is 0.
+	Seed int64 `json:"seed,omitempty"`
+	// Countries weighs the countries of the users, e.g. {"TR": 3, "US": 1}.
+	// Seven countries are weighed alike when not given.
+	Countries map[string]float64 `json:"countries,omitempty" validate:"omitempty,dive,keys,len=2,endkeys,gt=0"`
+	// Scores draws the points of the users and the scores of the
+	// submissions, uniform between 0 and 100000 when not given.
+	Scores *ScoreDistribution `json:"scores,omitempty"`
+	// Stream submits scores of the generated users once they are created.
+	Stream *ScoreStreamParameters `json:"stream,omitempty"`
+}
+
+// ScoreDistribution is one of the distributions below, its draws are
+// rounded and clamped to Min and Max, Max is unbounded when 0.
+//
+//	uniform  between Min and Max
+//	normal   around Mean by StdDev
+//	bimodal  around Mean by StdDev for Weight of the draws, 0.5 when not
+//	         given, and around SecondMean by SecondStdDev for the others
+//	pareto   from Scale up, smaller Shapes have longer tails
+type ScoreDistribution struct {
+	Kind         string  `json:"kind" validate:"required,oneof=uniform normal bimodal pareto"`
+	Min          float64 `json:"min" validate:"gte=0"`
+	Max          float64 `json:"max" validate:"required_if=Kind uniform,omitempty,gtfield=Min"`
+	Mean         float64 `json:"mean,omitempty"`
+	StdDev       float64 `json:"std_dev,omitempty" validate:"required_if=Kind normal,required_if=Kind bimodal,gte=0"`
+	SecondMean   float64 `json:"second_mean,omitempty"`
+	SecondStdDev float64 `json:"second_std_dev,omitempty" validate:"required_if=Kind bimodal,gte=0"`
+	Weight       float64 `json:"weight,omitempty" validate:"omitempty,gt=0,lt=1"`
+	Scale        float64 `json:"scale,omitempty" validate:"required_if=Kind pareto,gte=0"`
+	Shape        float64 `json:"shape,omitempty" validate:"required_if=Kind pareto,gte=0"`
+}
+
+// ScoreStreamParameters paces the submissions of a generate-users run.
+type ScoreStreamParameters struct {
+	// Rate is the target of submissions per second.
+	Rate float64 `json:"rate" validate:"gt=0,max=10000"`
+	// Seconds the stream lasts, it lasts until the run is stopped when 0.
+	Seconds uint64 `json:"seconds,omitempty"`
+}
+
+// ImportParameters configure a run of the import job.
+type ImportParameters struct {
+	// Path of the file, relative to the import directory of the instances.
+	Path string `json:"path" validate:"required"`
+	// Format of the file, csv or ndjson, told by the extension of the path
+	// when not given.
+	Format    string `json:"format,omitempty" validate:"omitempty,oneof=csv ndjson"`
+	BatchSize uint64 `json:"batch_size,omitempty" validate:"omitempty,max=5000"`
+	// Remove the file once every row of it is imported, uploads are.
+	Remove bool `json:"remove,omitempty"`
+}
+
+// BoardRolloverParameters configure a run of the board rollover job.
+type BoardRolloverParameters struct {
+	// Boards to archive, every board when not given.
+	Boards []string `json:"boards,omitempty" validate:"omitempty,dive,required"`
+	// Keep the latest archives of a board labeled by the time they were
+	// archived at and delete the older ones, every one is kept when 0.
+	Keep uint64 `json:"keep,omitempty"`
+}
+
+// SnapshotParameters configure a run of the snapshot job.
+type SnapshotParameters struct {
+	Compress bool `json:"compress,omitempty"`
+	// Keep the latest snapshots of the snapshot directory and delete the
+	// older ones, every one is kept when 0.
+	Keep uint64 `json:"keep,omitempty"`
+}
+
+// ImportUpload configures the run of the import job an upload is imported
+// by, the format is told by the upload when not given.
+type ImportUpload struct {
+	Format    string `json:"format" query:"format" validate:"omitempty,oneof=csv ndjson"`
+	BatchSize uint64 `json:"batch_size" query:"batch_size" validate:"omitempty,max=5000"`
+}
+
+// ImportRow is a user of an imported file along with its points. Users
+// without an id are given one.
+type ImportRow struct {
+	UserId      string  `json:"user_id"`
+	DisplayName string  `json:"display_name" validate:"required"`
+	Country     string  `json:"country" validate:"required,len=2,alpha"`
+	Points      float64 `json:"points"`
+}
+
+// ImportBatch is a batch of rows of a run of the import job, the profiles of
+// the rows which passed and the rejections of the others. Rows is the number
+// of rows the run read up to the end of the batch, Fence the fencing token
+// of the lease of the run.
+type ImportBatch struct {
+	RunId      string
+	Fence      int64
+	Rows       int64
+	Profiles   []*UserProfile
+	Rejections []*ImportRejection
 }
 
-type GenerateUserTaskStatus struct {
-	Status         string `json:"status"`
-	Concurrency    uint64 `json:"concurrency"`
-	StartedAt      string `json:"started_at"`
-	RemainingUsers uint64 `json:"remaining_users"`
+// ImportRejection is a row of an imported file which was not imported, Row
+// counts the rows of the file from 1, the header of a CSV file aside.
+type ImportRejection struct {
+	Row    int64  `json:"row"`
+	UserId string `json:"user_id,omitempty"`
+	Error  string `json:"error"`
 }
 
-type GenerateUserTaskConfiguration struct {
-	NumberOfUsers uint64 `json:"nUsers" validate:"required"`
-	Concurrency   uint64 `json:"concurrency" validate:"required"`
+// ImportReport reports a run of the import job along with the rows it read,
+// imported and rejected, and the first of the rejected rows.
+type ImportReport struct {
+	Run        *JobRun            `json:"run"`
+	Rows       int64              `json:"rows"`
+	Imported   int64              `json:"imported"`
+	Rejected   int64              `json:"rejected"`
+	Rejections []*ImportRejection `json:"rejections,omitempty"`
 }
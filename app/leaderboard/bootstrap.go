@@ -1,74 +1,394 @@
 package leaderboard
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/config"
 	"leaderboard/app/leaderboard/handlers"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/rpc"
 	"leaderboard/app/leaderboard/services"
 	"leaderboard/app/leaderboard/tasks"
+	"leaderboard/app/leaderboard/tracing"
 	_ "leaderboard/docs"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func Run() {
-	properties, err := LoadProperties()
+	properties, options, err := LoadProperties(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if options.PrintConfig {
+		if err := config.Print(os.Stdout, properties); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(options.Args) > 0 {
+		if err := runCommand(properties, options.Args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// tracing
+	shutdownTracing, err := tracing.Setup(properties.TracingExporter, properties.TracingOTLPEndpoint, properties.TracingServiceName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing()
+
 	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
+	e.Use(middlewares.Tracing())
+	e.Use(middlewares.Metrics())
 	e.Use(middleware.Recover())
+	e.Use(middlewares.Timeout(
+		properties.RequestTimeout,
+		// streams stay open for as long as the client listens
+		&middlewares.TimeoutRule{Match: middlewares.PathPrefix("/events")},
+		&middlewares.TimeoutRule{Match: middlewares.Path("/leaderboard/live")},
+		// uploads take as long as the file takes to send
+		&middlewares.TimeoutRule{Match: middlewares.Path("/_actuator/imports")},
+		&middlewares.TimeoutRule{Timeout: properties.RequestTimeoutSubmit, Match: middlewares.PathPrefix("/score")},
+		&middlewares.TimeoutRule{Timeout: properties.RequestTimeoutRead, Match: middlewares.Method(http.MethodGet)},
+	))
+
+	// authentication
+	authenticator, err := buildAuthenticator(properties)
+	if err != nil {
+		log.Fatal(err)
+	}
+	e.Use(middlewares.Authenticate(authenticator))
 
 	// validation
-	e.Validator = services.NewStructValidator(validator.New())
+	structValidator := services.NewStructValidator(validator.New())
+	e.Validator = structValidator
 
 	// services
 	// TODO: move services into echo context
-	redisService := buildRedisService(properties)
+	redisClient, err := buildRedisClient(properties)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redisService := services.NewRedisService(redisClient, properties.KeyNamespace)
+	healthService, err := buildHealthService(properties, redisService)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if properties.RateLimitEnabled {
+		e.Use(middlewares.RateLimit(
+			services.NewRateLimiter(redisService),
+			// probes are never limited
+			&middlewares.RateLimitRule{Match: middlewares.Path("/healthz")},
+			&middlewares.RateLimitRule{Match: middlewares.Path("/readyz")},
+			&middlewares.RateLimitRule{
+				Group: "submit",
+				Budget: &services.RateLimitBudget{
+					Limit:  int64(properties.RateLimitSubmit),
+					Period: properties.RateLimitSubmitPeriod,
+				},
+				Match: middlewares.PathPrefix("/score"),
+			},
+			&middlewares.RateLimitRule{
+				Group: "read",
+				Budget: &services.RateLimitBudget{
+					Limit:  int64(properties.RateLimitRead),
+					Period: properties.RateLimitReadPeriod,
+				},
+				Match: middlewares.Method(http.MethodGet),
+			},
+		))
+	}
 	userService := services.NewUserService(redisService, properties.LeaderboardKeyPrefix)
 	leaderboardService := services.NewLeaderboardService(userService, redisService, properties.LeaderboardKeyPrefix)
+	boardService := services.NewBoardService(redisService)
+	rankHistoryService := services.NewRankHistoryService(redisService, properties.RankHistoryInterval, properties.RankHistoryTTL)
+	scoreService := services.NewScoreService(userService, redisService, properties.ScoreMode, rankHistoryService)
+	liveFeedService := services.NewLiveFeedService(leaderboardService, redisService, properties.LiveFeedInterval)
+	scoreStreamService := services.NewScoreStreamService(redisService, int64(properties.ScoreStreamMaxLen))
+	webhookService := services.NewWebhookService(redisService, &services.WebhookConfiguration{
+		Workers:      properties.WebhookWorkers,
+		MaxAttempts:  properties.WebhookMaxAttempts,
+		RetryBackoff: properties.WebhookRetryBackoff,
+		Timeout:      properties.WebhookTimeout,
+	})
+	scoreService.AddListener(liveFeedService)
+	scoreService.AddListener(scoreStreamService)
+	scoreService.AddListener(webhookService)
+	liveFeedService.Start()
+	webhookService.Start()
 
-	tasks.NewGenerateUsersSingletonTask(userService, redisService).Initialize()
+	jobService := services.NewJobService(redisService, services.NewKeySchema(properties.KeyNamespace), &services.JobConfiguration{
+		Workers:      properties.JobWorkers,
+		LeaseTTL:     properties.JobLeaseTTL,
+		PollInterval: properties.JobPollInterval,
+	})
+	importService := services.NewImportService(redisService, properties.ImportDirectory)
+	jobService.Register(
+		tasks.NewGenerateUsersJob(userService, scoreService),
+		tasks.NewImportJob(redisService, importService, structValidator),
+		tasks.NewBoardRolloverJob(boardService),
+		tasks.NewSnapshotJob(services.NewSnapshotter(redisClient, properties.KeyNamespace), properties.SnapshotDirectory),
+	)
+	jobService.Start()
+	schedulerService, err := buildSchedulerService(properties, redisService, jobService, structValidator)
+	if err != nil {
+		log.Fatal(err)
+	}
+	schedulerService.Start()
+	rankHistorySampler := tasks.NewRankHistorySamplerTask(rankHistoryService)
+	rankHistorySampler.Start()
 
 	// handlers
-	userHandler := handlers.NewUserHandler(userService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	healthHandler.Register(e)
+
+	userHandler := handlers.NewUserHandler(userService, rankHistoryService)
 	userHandler.Register(e)
 
 	leaderboardHandler := handlers.NewLeaderboardHandler(leaderboardService)
 	leaderboardHandler.Register(e)
 
-	scoreHandler := handlers.NewScoreHandler(userService, redisService, properties.LeaderboardKeyPrefix)
+	liveHandler := handlers.NewLiveHandler(liveFeedService)
+	liveHandler.Register(e)
+
+	eventHandler := handlers.NewEventHandler(scoreStreamService)
+	eventHandler.Register(e)
+
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	webhookHandler.Register(e)
+
+	scoreHandler := handlers.NewScoreHandler(scoreService)
 	scoreHandler.Register(e)
 
-	actuator := handlers.NewActuatorHandler(redisService, userService)
+	actuator := handlers.NewActuatorHandler(redisService)
 	actuator.Register(e)
 
-	e.Logger.Fatal(e.Start(":1323"))
+	boardHandler := handlers.NewBoardHandler(boardService)
+	boardHandler.Register(e)
+
+	jobHandler := handlers.NewJobHandler(jobService)
+	jobHandler.Register(e)
+
+	importHandler := handlers.NewImportHandler(jobService, importService, int64(properties.ImportMaxUploadSize))
+	importHandler.Register(e)
+
+	scheduleHandler := handlers.NewScheduleHandler(schedulerService)
+	scheduleHandler.Register(e)
+
+	// metrics
+	registerMetrics(metrics.DefaultRegistry, redisService, jobService)
+	metricsHandler := handlers.NewMetricsHandler(metrics.DefaultRegistry)
+	metricsHandler.Register(e)
+
+	// gRPC
+	leaderboardServer := rpc.NewLeaderboardServer(structValidator, userService, scoreService, leaderboardService, liveFeedService)
+	grpcServer := rpc.NewServer(leaderboardServer, authenticator)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", properties.GrpcPort))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	go func() {
+		if err := e.Start(fmt.Sprintf(":%d", properties.HttpPort)); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+	<-quit
+
+	// shutdown
+	log.Printf("shutting down, in-flight work has %s to complete", properties.ShutdownGracePeriod)
+	healthService.Drain()
+
+	ctx, cancel := context.WithTimeout(context.Background(), properties.ShutdownGracePeriod)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// streams never complete on their own, the servers would wait for
+		// them until the grace period is over
+		scoreStreamService.Stop()
+		leaderboardServer.Stop()
+
+		var servers sync.WaitGroup
+		servers.Add(2)
+		go func() {
+			defer servers.Done()
+			if err := e.Shutdown(ctx); err != nil {
+				log.Print(err)
+			}
+		}()
+		go func() {
+			defer servers.Done()
+			grpcServer.GracefulStop()
+		}()
+		servers.Wait()
+
+		// background tasks are stopped once no request can reach them
+		rankHistorySampler.Stop()
+		liveFeedService.Stop()
+		webhookService.Stop()
+		schedulerService.Stop()
+		jobService.Stop()
+	}()
+
+	select {
+	case <-done:
+		log.Print("shut down gracefully")
+	case <-ctx.Done():
+		grpcServer.Stop()
+		log.Print("grace period is over, exiting with work in flight")
+	}
 }
 
-func buildRedisService(properties *Properties) api.RedisService {
-	var client redis.UniversalClient
-	if properties.RedisCluster {
-		client = redis.NewClusterClient(&redis.ClusterOptions{
-			Addrs:    []string{properties.RedisHost},
-			PoolSize: 64,
-			Password: properties.RedisPassword,
-		})
-	} else {
-		client = redis.NewClient(&redis.Options{
-			Addr:     properties.RedisHost,
-			PoolSize: 64,
-			Password: properties.RedisPassword,
+func buildRedisClient(properties *Properties) (redis.UniversalClient, error) {
+	options := &redis.UniversalOptions{
+		Addrs:            properties.RedisHost,
+		DB:               properties.RedisDB,
+		Username:         properties.RedisUsername,
+		Password:         properties.RedisPassword,
+		SentinelPassword: properties.RedisSentinelPassword,
+		MasterName:       properties.RedisSentinelMaster,
+		PoolSize:         properties.RedisPoolSize,
+		MinIdleConns:     properties.RedisMinIdleConns,
+		DialTimeout:      properties.RedisDialTimeout,
+		ReadTimeout:      properties.RedisReadTimeout,
+		WriteTimeout:     properties.RedisWriteTimeout,
+		PoolTimeout:      properties.RedisPoolTimeout,
+	}
+
+	if properties.RedisTLS {
+		tlsConfig, err := services.LoadRedisTLSConfig(&services.RedisTLSFiles{
+			CAFile:             properties.RedisTLSCAFile,
+			CertFile:           properties.RedisTLSCertFile,
+			KeyFile:            properties.RedisTLSKeyFile,
+			ServerName:         properties.RedisTLSServerName,
+			InsecureSkipVerify: properties.RedisTLSSkipVerify,
 		})
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	return services.NewRedisClient(options, properties.RedisCluster), nil
+}
+
+// buildHealthService checks redis, and mysql when a connection string is
+// configured for it.
+func buildHealthService(properties *Properties, redisService api.RedisService) (*services.HealthService, error) {
+	healthService := services.NewHealthService()
+	healthService.AddCheck("redis", redisService.Ping)
+
+	if len(properties.MysqlConnectionString) > 0 {
+		db, err := sql.Open("mysql", properties.MysqlConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		healthService.AddCheck("mysql", db.PingContext)
+	}
+
+	return healthService, nil
+}
+
+// buildSchedulerService starts the jobs of the schedules file on their
+// times, in the configured time zone.
+func buildSchedulerService(properties *Properties, redisService api.RedisService, jobService *services.JobService, structValidator *services.StructValidator) (*services.SchedulerService, error) {
+	var schedules []*services.Schedule
+	if len(properties.SchedulesFile) > 0 {
+		var err error
+		if schedules, err = services.LoadSchedules(properties.SchedulesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	location, err := time.LoadLocation(properties.SchedulerTimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NewSchedulerService(redisService, services.NewKeySchema(properties.KeyNamespace), jobService, structValidator, schedules, &services.SchedulerConfiguration{
+		Location: location,
+		Interval: properties.SchedulerInterval,
+		LeaseTTL: properties.SchedulerLeaseTTL,
+	})
+}
+
+// buildAuthenticator chains the configured providers in order. Providers
+// without their key files are skipped, so protected routes stay closed
+// until credentials are configured.
+func buildAuthenticator(properties *Properties) (api.Authenticator, error) {
+	var chain services.AuthenticatorChain
+	for _, provider := range properties.AuthProviders {
+		switch strings.TrimSpace(provider) {
+		case "none":
+			log.Println("WARNING: authentication is disabled, every request is granted the admin role")
+			chain = append(chain, services.InsecureAuthenticator{})
+		case "api_key":
+			if len(properties.AuthAPIKeysFile) == 0 {
+				continue
+			}
+
+			authenticator, err := services.LoadAPIKeyAuthenticator(properties.AuthAPIKeysFile)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, authenticator)
+		case "jwt":
+			if len(properties.AuthJWKSFile) == 0 {
+				continue
+			}
+
+			authenticator, err := services.LoadJWTAuthenticator(properties.AuthJWKSFile, properties.AuthJWTIssuer, properties.AuthJWTAudience)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, authenticator)
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", provider)
+		}
 	}
 
-	return services.NewRedisService(client, properties.LeaderboardKeyPrefix)
+	return chain, nil
 }
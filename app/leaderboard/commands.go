@@ -0,0 +1,136 @@
+package leaderboard
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"leaderboard/app/leaderboard/services"
+	"log"
+	"os"
+	"strings"
+)
+
+const snapshotUsage = "usage: snapshot export [-gzip] FILE | snapshot restore [-namespace NAMESPACE] [-merge] FILE, - for stdout or stdin"
+
+// runCommand runs a maintenance command given after the flags, instead of
+// serving:
+//
+//	leaderboard [flags] migrate-keys copy|sync|cleanup
+//	leaderboard [flags] snapshot export [-gzip] FILE
+//	leaderboard [flags] snapshot restore [-namespace NAMESPACE] [-merge] FILE
+func runCommand(properties *Properties, args []string) error {
+	switch args[0] {
+	case "migrate-keys":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate-keys copy|sync|cleanup")
+		}
+
+		return migrateKeys(properties, args[1])
+	case "snapshot":
+		if len(args) < 2 {
+			return fmt.Errorf(snapshotUsage)
+		}
+
+		return snapshot(properties, args[1], args[2:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// migrateKeys moves the boards and the profiles of the legacy key layout
+// to the key namespace, see services.KeyMigrator for the rollout.
+func migrateKeys(properties *Properties, step string) error {
+	client, err := buildRedisClient(properties)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	migrator := services.NewKeyMigrator(client, properties.LeaderboardKeyPrefix, services.NewKeySchema(properties.KeyNamespace))
+
+	var report *services.KeyMigrationReport
+	switch step {
+	case "copy":
+		report, err = migrator.Copy(context.Background())
+	case "sync":
+		report, err = migrator.Sync(context.Background())
+	case "cleanup":
+		report, err = migrator.Cleanup(context.Background())
+	default:
+		return fmt.Errorf("unknown migrate-keys step %q, expected copy, sync or cleanup", step)
+	}
+
+	if report != nil && step == "sync" {
+		log.Printf("migrate-keys %s: %d boards, %d members, %d profiles, %d removed", step, report.Boards, report.Members, report.Profiles, report.Removed)
+	} else if report != nil {
+		log.Printf("migrate-keys %s: %d boards, %d members, %d profiles", step, report.Boards, report.Members, report.Profiles)
+	}
+
+	return err
+}
+
+// snapshot exports the key namespace to a snapshot file, or restores one to
+// the key namespace or to the given one, see services.Snapshotter. Files
+// named *.gz are exported compressed, compressed snapshots are restored
+// whatever their name.
+func snapshot(properties *Properties, step string, args []string) error {
+	flags := flag.NewFlagSet("snapshot "+step, flag.ContinueOnError)
+	compress := flags.Bool("gzip", false, "compress the snapshot, files named *.gz are compressed anyway")
+	namespace := flags.String("namespace", properties.KeyNamespace, "key namespace the snapshot is restored to")
+	merge := flags.Bool("merge", false, "restore to a namespace which has boards or profiles, writing over them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf(snapshotUsage)
+	}
+	path := flags.Arg(0)
+
+	if len(*namespace) == 0 || strings.ContainsAny(*namespace, "{}") {
+		return fmt.Errorf("namespace %q is not a key namespace", *namespace)
+	}
+
+	client, err := buildRedisClient(properties)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var report *services.SnapshotReport
+	switch step {
+	case "export":
+		output := io.WriteCloser(os.Stdout)
+		if path != "-" {
+			if output, err = os.Create(path); err != nil {
+				return err
+			}
+		}
+
+		snapshotter := services.NewSnapshotter(client, properties.KeyNamespace)
+		report, err = snapshotter.Export(context.Background(), output, *compress || strings.HasSuffix(path, ".gz"))
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+	case "restore":
+		input := io.ReadCloser(os.Stdin)
+		if path != "-" {
+			if input, err = os.Open(path); err != nil {
+				return err
+			}
+		}
+		defer input.Close()
+
+		snapshotter := services.NewSnapshotter(client, *namespace)
+		report, err = snapshotter.Restore(context.Background(), input, *merge)
+	default:
+		return fmt.Errorf("unknown snapshot step %q, expected export or restore", step)
+	}
+
+	if report != nil {
+		log.Printf("snapshot %s: %d profiles, %d boards, %d archives, %d members", step, report.Profiles, report.Boards, report.Archives, report.Members)
+	}
+
+	return err
+}
@@ -0,0 +1,362 @@
+// Package config fills a struct of properties from their defaults, a YAML or
+// TOML file, environment variables and command line flags. Every source
+// overrides the ones before it.
+//
+// Properties are described by the tags of the struct fields:
+//
+//	name     key of the property in files, it is upper-cased for its
+//	         environment variable and dashed for its flag
+//	default  value of the property when no source sets it
+//	usage    help text of the flag
+//	secret   "true" masks the value when the configuration is printed
+//	validate rules checked once every source is applied
+//
+// Lists are given as comma separated values in the environment and in
+// flags, and as lists in files. Nested tables of a file are flattened by
+// joining their keys with underscores, so redis.pool_size sets
+// redis_pool_size.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const EnvConfigFile = "CONFIG_FILE"
+
+const maskedValue = "******"
+
+// Options are the flags handled by the loader itself rather than being
+// properties, and the arguments left after the flags.
+type Options struct {
+	ConfigFile  string
+	PrintConfig bool
+	Args        []string
+}
+
+type field struct {
+	name         string
+	structField  string
+	value        reflect.Value
+	defaultValue string
+	usage        string
+	secret       bool
+}
+
+// Load applies every source to target, which must be a pointer to a struct,
+// and validates the result. Targets implementing Validate() error are
+// validated with it as well, for rules spanning multiple properties.
+func Load(target interface{}, args []string, lookupEnv func(string) (string, bool)) (*Options, error) {
+	fields, err := getFields(target)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		if len(f.defaultValue) == 0 {
+			continue
+		}
+
+		if err := set(f, f.defaultValue); err != nil {
+			return nil, fmt.Errorf("default of %s: %w", f.name, err)
+		}
+	}
+
+	options := new(Options)
+	flags := flag.NewFlagSet("leaderboard", flag.ContinueOnError)
+	flags.StringVar(&options.ConfigFile, "config", "", "YAML or TOML file to read the configuration from, "+EnvConfigFile+" is read when not given")
+	flags.BoolVar(&options.PrintConfig, "print-config", false, "print the configuration and exit")
+
+	flagValues := map[string]*string{}
+	for _, f := range fields {
+		flagValues[f.name] = flags.String(flagName(f.name), f.defaultValue, f.usage)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	options.Args = flags.Args()
+
+	if len(options.ConfigFile) == 0 {
+		options.ConfigFile, _ = lookupEnv(EnvConfigFile)
+	}
+
+	if len(options.ConfigFile) > 0 {
+		if err := loadFile(fields, options.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		value, ok := lookupEnv(envName(f.name))
+		if !ok || len(value) == 0 {
+			continue
+		}
+
+		if err := set(f, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", envName(f.name), err)
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if flagErr == nil && flagName(f.name) == fl.Name {
+				if err := set(f, *flagValues[f.name]); err != nil {
+					flagErr = fmt.Errorf("--%s: %w", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	return options, validate(target)
+}
+
+// Print writes the configuration as YAML, which can be loaded back as a
+// configuration file. Secrets are masked.
+func Print(w io.Writer, target interface{}) error {
+	fields, err := getFields(target)
+	if err != nil {
+		return err
+	}
+
+	values := yaml.MapSlice{}
+	for _, f := range fields {
+		var value interface{} = f.value.Interface()
+		if duration, ok := value.(time.Duration); ok {
+			value = duration.String()
+		}
+
+		if f.secret && !f.value.IsZero() {
+			value = maskedValue
+		}
+
+		values = append(values, yaml.MapItem{Key: f.name, Value: value})
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func getFields(target interface{}) ([]*field, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("configuration target must be a pointer to a struct")
+	}
+
+	value = value.Elem()
+	var fields []*field
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		name, ok := structField.Tag.Lookup("name")
+		if !ok {
+			continue
+		}
+
+		fields = append(fields, &field{
+			name:         name,
+			structField:  structField.Name,
+			value:        value.Field(i),
+			defaultValue: structField.Tag.Get("default"),
+			usage:        structField.Tag.Get("usage"),
+			secret:       structField.Tag.Get("secret") == "true",
+		})
+	}
+
+	return fields, nil
+}
+
+func loadFile(fields []*field, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("configuration file %s is neither YAML nor TOML", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	flattened := map[string]interface{}{}
+	flatten("", values, flattened)
+
+	byName := map[string]*field{}
+	for _, f := range fields {
+		byName[f.name] = f
+	}
+
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f, ok := byName[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown property %s", path, key)
+		}
+
+		if err := set(f, flattened[key]); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+func flatten(prefix string, values interface{}, flattened map[string]interface{}) {
+	switch table := values.(type) {
+	case map[string]interface{}:
+		for key, value := range table {
+			flatten(joinKey(prefix, key), value, flattened)
+		}
+	case map[interface{}]interface{}:
+		for key, value := range table {
+			flatten(joinKey(prefix, fmt.Sprint(key)), value, flattened)
+		}
+	default:
+		flattened[prefix] = values
+	}
+}
+
+func joinKey(prefix string, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+
+	return prefix + "_" + key
+}
+
+// set converts the raw value of a source, a string or a value decoded from
+// a file, to the type of the field.
+func set(f *field, raw interface{}) error {
+	if f.value.Kind() == reflect.Slice {
+		var items []string
+		switch list := raw.(type) {
+		case []interface{}:
+			for _, item := range list {
+				items = append(items, fmt.Sprint(item))
+			}
+		case string:
+			for _, item := range strings.Split(list, ",") {
+				if item = strings.TrimSpace(item); len(item) > 0 {
+					items = append(items, item)
+				}
+			}
+		default:
+			return fmt.Errorf("expected a list, got %v", raw)
+		}
+
+		f.value.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	value := fmt.Sprint(raw)
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(parsed)
+	case int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(parsed))
+	case time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(parsed))
+	default:
+		return fmt.Errorf("unsupported property type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+func validate(target interface{}) error {
+	if err := validator.New().Struct(target); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			return err
+		}
+
+		// report properties by the names they are configured with
+		fields, _ := getFields(target)
+		names := map[string]string{}
+		for _, f := range fields {
+			names[f.structField] = f.name
+		}
+
+		messages := make([]string, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			name, ok := names[fieldError.StructField()]
+			if !ok {
+				name = fieldError.StructField()
+			}
+			messages = append(messages, fmt.Sprintf("%s fails the %s rule", name, ruleOf(fieldError)))
+		}
+
+		return fmt.Errorf("invalid configuration: %s", strings.Join(messages, ", "))
+	}
+
+	if validatable, ok := target.(interface{ Validate() error }); ok {
+		if err := validatable.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func ruleOf(fieldError validator.FieldError) string {
+	if len(fieldError.Param()) == 0 {
+		return fieldError.Tag()
+	}
+
+	return fieldError.Tag() + "=" + fieldError.Param()
+}
+
+func envName(name string) string {
+	return strings.ToUpper(name)
+}
+
+func flagName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
@@ -0,0 +1,146 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"leaderboard/app/leaderboard/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+func TestConfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "config")
+}
+
+type testProperties struct {
+	Port     int           `name:"port" default:"1323" validate:"min=1,max=65535"`
+	Hosts    []string      `name:"redis_host" default:"localhost:6379" validate:"min=1"`
+	Password string        `name:"redis_password" secret:"true"`
+	Timeout  time.Duration `name:"timeout" default:"3s" validate:"gt=0"`
+	Cluster  bool          `name:"cluster"`
+}
+
+func (p *testProperties) Validate() error {
+	if !p.Cluster && len(p.Hosts) > 1 {
+		return errors.New("multiple hosts need cluster mode")
+	}
+
+	return nil
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+var dir string
+
+var _ = BeforeSuite(func() {
+	var err error
+	dir, err = ioutil.TempDir("", "config")
+	if err != nil {
+		panic(err)
+	}
+})
+
+var _ = AfterSuite(func() {
+	_ = os.RemoveAll(dir)
+})
+
+func writeFile(name string, content string) string {
+	path := filepath.Join(dir, name)
+	Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+	return path
+}
+
+var _ = Describe("config.Load()", func() {
+	var p *testProperties
+
+	BeforeEach(func() {
+		p = new(testProperties)
+	})
+
+	When("no source sets a property", func() {
+		It("applies the defaults", func() {
+			_, err := config.Load(p, nil, env(nil))
+			Expect(err).To(BeNil())
+			Expect(p.Port).To(Equal(1323))
+			Expect(p.Hosts).To(Equal([]string{"localhost:6379"}))
+			Expect(p.Timeout).To(Equal(3 * time.Second))
+		})
+	})
+
+	When("every source sets a property", func() {
+		It("lets flags override the environment and the environment override the file", func() {
+			path := writeFile("leaderboard.yaml", "port: 8080\ntimeout: 1s\nredis:\n  password: secret\n")
+			_, err := config.Load(p, []string{"--config", path, "--port", "9090"}, env(map[string]string{
+				"PORT":    "8000",
+				"TIMEOUT": "2s",
+			}))
+			Expect(err).To(BeNil())
+			Expect(p.Port).To(Equal(9090))
+			Expect(p.Timeout).To(Equal(2 * time.Second))
+			Expect(p.Password).To(Equal("secret"))
+		})
+	})
+
+	When("given a TOML file through the environment", func() {
+		It("reads lists and nested tables", func() {
+			path := writeFile("leaderboard.toml", "cluster = true\n[redis]\nhost = [\"a:6379\", \"b:6379\"]\n")
+			options, err := config.Load(p, nil, env(map[string]string{config.EnvConfigFile: path}))
+			Expect(err).To(BeNil())
+			Expect(options.ConfigFile).To(Equal(path))
+			Expect(p.Hosts).To(Equal([]string{"a:6379", "b:6379"}))
+		})
+	})
+
+	When("the file has an unknown property", func() {
+		It("returns error", func() {
+			path := writeFile("leaderboard.yaml", "prot: 8080\n")
+			_, err := config.Load(p, []string{"--config", path}, env(nil))
+			Expect(err).To(MatchError(ContainSubstring("unknown property prot")))
+		})
+	})
+
+	When("a property breaks a rule", func() {
+		It("reports the property by its name", func() {
+			_, err := config.Load(p, nil, env(map[string]string{"PORT": "70000"}))
+			Expect(err).To(MatchError(ContainSubstring("port fails the max=65535 rule")))
+		})
+
+		It("runs the validation of the target", func() {
+			_, err := config.Load(p, nil, env(map[string]string{"REDIS_HOST": "a:6379,b:6379"}))
+			Expect(err).To(MatchError(ContainSubstring("multiple hosts need cluster mode")))
+		})
+	})
+
+	When("a value can not be parsed", func() {
+		It("returns error", func() {
+			_, err := config.Load(p, []string{"--timeout", "soon"}, env(nil))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
+
+var _ = Describe("config.Print()", func() {
+	It("masks secrets", func() {
+		p := new(testProperties)
+		options, err := config.Load(p, []string{"--print-config"}, env(map[string]string{"REDIS_PASSWORD": "secret"}))
+		Expect(err).To(BeNil())
+		Expect(options.PrintConfig).To(BeTrue())
+
+		var out bytes.Buffer
+		Expect(config.Print(&out, p)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("redis_password: '******'"))
+		Expect(out.String()).To(ContainSubstring("timeout: 3s"))
+		Expect(out.String()).NotTo(ContainSubstring("secret"))
+	})
+})
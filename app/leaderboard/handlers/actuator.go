@@ -1,29 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/labstack/echo/v4"
 	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
 	"leaderboard/app/leaderboard/services"
-	"leaderboard/app/leaderboard/tasks"
 	"net/http"
 )
 
 type ActuatorHandler struct {
 	redisService api.RedisService
-	userService  *services.UserService
 }
 
-func NewActuatorHandler(redisService api.RedisService, userService *services.UserService) *ActuatorHandler {
-	return &ActuatorHandler{redisService: redisService, userService: userService}
+func NewActuatorHandler(redisService api.RedisService) *ActuatorHandler {
+	return &ActuatorHandler{redisService: redisService}
 }
 
 func (a *ActuatorHandler) Register(echo *echo.Echo) {
-	group := echo.Group("/_actuator")
+	group := echo.Group("/_actuator", middlewares.RequireRole(services.ScopeActuator, services.RoleAdmin))
 
 	group.DELETE("/flush-all", a.FlushAll)
-	group.GET("/bulk-generate", a.QueryBulkGeneration)
-	group.POST("/bulk-generate", a.GenerateBulk)
-	group.DELETE("/bulk-generate", a.StopGenerateBulk)
 	group.GET("/user-count", a.GetUserCount)
 }
 
@@ -32,11 +29,20 @@ func (a *ActuatorHandler) Register(echo *echo.Echo) {
 // @Description Get total number of users
 // @Produce  plain
 // @Success 200
-// @Failure 500
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags actuator
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /_actuator/user-count [get]
 func (a *ActuatorHandler) GetUserCount(c echo.Context) (err error) {
-	size, err := a.redisService.GetSortedSetSize("GLOBAL")
+	size, err := a.redisService.GetSortedSetSize(c.Request().Context(), "GLOBAL")
+	if err != nil && !errors.Is(err, api.ErrNotFound) {
+		return err
+	}
 
 	return c.JSON(http.StatusOK, map[string]int64{
 		"count": size,
@@ -49,78 +55,19 @@ func (a *ActuatorHandler) GetUserCount(c echo.Context) (err error) {
 // @Accept  json
 // @Produce  json
 // @Success 200
-// @Failure 500
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags actuator
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /_actuator/flush-all [delete]
 func (a *ActuatorHandler) FlushAll(c echo.Context) error {
-	a.redisService.FlushAll()
-	return c.NoContent(http.StatusOK)
-}
-
-// GenerateBulk godoc
-// @Summary Generate users
-// @Description Generate users
-// @Accept json
-// @Produce json
-// @Success 200
-// @Failure 500
-// @Tags actuator
-// @Param taskConfig body api.GenerateUserTaskConfiguration true "how many users to generate" minimum(1)
-// @Router /_actuator/bulk-generate [post]
-func (a *ActuatorHandler) GenerateBulk(c echo.Context) error {
-	config := new(api.GenerateUserTaskConfiguration)
-	if err := c.Bind(config); err != nil {
-		return err
-	}
-
-	if err := c.Validate(config); err != nil {
-		return c.JSON(http.StatusBadRequest, api.NewValidationErrorResponse(err.Error()))
-	}
-
-	task := a.getUserGenerateTask()
-	taskStatus, err := task.Start(config.NumberOfUsers, config.Concurrency)
-	if err != nil {
+	if err := a.redisService.FlushAll(c.Request().Context()); err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, taskStatus)
-}
-
-// QueryBulkGeneration godoc
-// @Summary Query user generation task status
-// @Description Query user generation task status
-// @Produce  json
-// @Success 200
-// @Failure 500
-// @Tags actuator
-// @Router /_actuator/bulk-generate [get]
-func (a *ActuatorHandler) QueryBulkGeneration(c echo.Context) error {
-	status, err := a.getUserGenerateTask().Status()
-	if err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, status)
-}
-
-func (a *ActuatorHandler) getUserGenerateTask() *tasks.GenerateUsersSingletonTask {
-	return tasks.NewGenerateUsersSingletonTask(a.userService, a.redisService)
-}
-
-// StopGenerateBulk godoc
-// @Summary Stop user generation
-// @Description Stop user generation
-// @Success 200
-// @Failure 500
-// @Tags actuator
-// @Router /_actuator/bulk-generate [delete]
-func (a *ActuatorHandler) StopGenerateBulk(c echo.Context) error {
-	err := a.getUserGenerateTask().Stop()
-	if err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, map[string]string{
-		"status": "ok",
-	})
+	return c.NoContent(http.StatusOK)
 }
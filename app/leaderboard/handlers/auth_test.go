@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the authorization of the routes", func() {
+	var s *server
+
+	apiKey := func(key string) http.Header {
+		return http.Header{services.HeaderAPIKey: {key}}
+	}
+
+	submission := func(userId string) string {
+		return fmt.Sprintf(`{"user_id": %q, "score": 10, "timestamp": %d}`, userId, time.Now().Unix())
+	}
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "admin", KeySha256: services.HashAPIKey("admin"), Role: services.RoleAdmin},
+			{Id: "scorer", KeySha256: services.HashAPIKey("scorer"), Role: services.RoleAdmin, Scopes: []string{services.ScopeScoresWrite}},
+			{Id: "server", KeySha256: services.HashAPIKey("server"), Role: services.RoleGameServer},
+			{Id: "alice", KeySha256: services.HashAPIKey("alice"), Role: services.RolePlayer},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator)
+
+		for _, userId := range []string{"alice", "bob"} {
+			_, err := s.userService.Create(ctx, &api.UserProfile{UserId: userId, DisplayName: userId, Country: "TR"})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	DescribeTable("the admin routes",
+		func(method string, path string) {
+			response := s.serve(method, path, "", nil)
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			Expect(response.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal("Bearer"))
+
+			for _, key := range []string{"server", "alice", "scorer"} {
+				Expect(s.serve(method, path, "", apiKey(key)).Code).To(Equal(http.StatusForbidden), key)
+			}
+
+			Expect(s.serve(method, path, "", apiKey("admin")).Code).NotTo(BeElementOf(http.StatusUnauthorized, http.StatusForbidden))
+		},
+		Entry("user count", http.MethodGet, "/_actuator/user-count"),
+		Entry("flush", http.MethodDelete, "/_actuator/flush-all"),
+		Entry("boards", http.MethodGet, "/_actuator/boards"),
+		Entry("jobs", http.MethodGet, "/_actuator/jobs"),
+		Entry("imports", http.MethodGet, "/_actuator/imports/missing"),
+		Entry("an unknown actuator route", http.MethodGet, "/_actuator/unknown"),
+		Entry("webhooks", http.MethodGet, "/webhooks"),
+		Entry("dead letters", http.MethodGet, "/webhooks/dead-letters"),
+		Entry("a webhook", http.MethodDelete, "/webhooks/missing"),
+		Entry("a profile update", http.MethodPatch, "/user/profile/alice"),
+		Entry("a profile removal", http.MethodDelete, "/user/profile/alice"),
+	)
+
+	Context("the score submissions", func() {
+		It("let a player submit only their own scores", func() {
+			Expect(s.serve(http.MethodPost, "/score/submit", submission("bob"), apiKey("alice")).Code).To(Equal(http.StatusForbidden))
+			Expect(s.serve(http.MethodPost, "/score/submit", submission("alice"), apiKey("alice")).Code).To(Equal(http.StatusCreated))
+
+			batch := fmt.Sprintf(`{"submissions": [%s, %s]}`, submission("alice"), submission("bob"))
+			Expect(s.serve(http.MethodPost, "/score/submit-batch", batch, apiKey("alice")).Code).To(Equal(http.StatusForbidden))
+
+			profile, err := s.userService.GetByID(ctx, "bob")
+			Expect(err).To(BeNil())
+			Expect(profile.Points).To(BeZero())
+		})
+
+		It("let a game server submit the scores of any user", func() {
+			Expect(s.serve(http.MethodPost, "/score/submit", submission("bob"), apiKey("server")).Code).To(Equal(http.StatusCreated))
+		})
+
+		It("refuse callers without credentials", func() {
+			Expect(s.serve(http.MethodPost, "/score/submit", submission("bob"), nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	It("refuses invalid keys", func() {
+		Expect(s.serve(http.MethodGet, "/webhooks", "", apiKey("wrong")).Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	When("no provider is configured", func() {
+		BeforeEach(func() {
+			s = newServer(services.AuthenticatorChain{})
+		})
+
+		It("refuses every protected route", func() {
+			for _, key := range []string{"", "admin"} {
+				Expect(s.serve(http.MethodGet, "/webhooks", "", apiKey(key)).Code).To(Equal(http.StatusUnauthorized))
+				Expect(s.serve(http.MethodGet, "/_actuator/user-count", "", apiKey(key)).Code).To(Equal(http.StatusUnauthorized))
+				Expect(s.serve(http.MethodPost, "/score/submit", submission("alice"), apiKey(key)).Code).To(Equal(http.StatusUnauthorized))
+			}
+		})
+
+		It("keeps serving the public routes", func() {
+			Expect(s.serve(http.MethodGet, "/user/profile/alice", "", nil).Code).To(Equal(http.StatusOK))
+		})
+	})
+})
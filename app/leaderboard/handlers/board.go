@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"strings"
+)
+
+type BoardHandler struct {
+	boardService *services.BoardService
+}
+
+func NewBoardHandler(boardService *services.BoardService) *BoardHandler {
+	return &BoardHandler{boardService: boardService}
+}
+
+func (b *BoardHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/_actuator/boards", middlewares.RequireRole(services.ScopeActuator, services.RoleAdmin))
+
+	group.GET("", b.GetBoards)
+	group.GET("/:name", b.GetBoard)
+	group.DELETE("/:name", b.ResetBoard)
+	group.POST("/:name/archive", b.ArchiveBoard)
+}
+
+// GetBoards godoc
+// @Summary List the boards
+// @Description List the boards with their number of members
+// @Produce json
+// @Success 200 {array} api.Board
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/boards [get]
+func (b *BoardHandler) GetBoards(c echo.Context) error {
+	boards, err := b.boardService.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, boards)
+}
+
+// GetBoard godoc
+// @Summary Inspect a board
+// @Description Report a board with its number of members, its top and bottom scores and its archives
+// @Produce json
+// @Success 200 {object} api.Board
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "board name, GLOBAL or a country code"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/boards/{name} [get]
+func (b *BoardHandler) GetBoard(c echo.Context) error {
+	board, err := b.boardService.Get(c.Request().Context(), strings.ToUpper(c.Param("name")))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, board)
+}
+
+// ResetBoard godoc
+// @Summary Reset a board
+// @Description Remove every member of a board, their profiles are kept
+// @Success 204
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "board name, GLOBAL or a country code"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/boards/{name} [delete]
+func (b *BoardHandler) ResetBoard(c echo.Context) error {
+	if err := b.boardService.Reset(c.Request().Context(), strings.ToUpper(c.Param("name"))); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ArchiveBoard godoc
+// @Summary Archive a board
+// @Description Move the members of a board to an archive under a label, the current time when not given, and leave the board empty
+// @Accept json
+// @Produce json
+// @Success 201 {object} api.BoardArchive
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "board name, GLOBAL or a country code"
+// @Param archive body api.BoardArchive false "label of the archive"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/boards/{name}/archive [post]
+func (b *BoardHandler) ArchiveBoard(c echo.Context) (err error) {
+	archive := new(api.BoardArchive)
+	if err = c.Bind(archive); err != nil {
+		return
+	}
+
+	if err = c.Validate(archive); err != nil {
+		return err
+	}
+
+	archive, err = b.boardService.Archive(c.Request().Context(), strings.ToUpper(c.Param("name")), archive.Label)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, archive)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrorHandler answers the errors returned by handlers and middlewares with
+// an api.ErrorResponse. Errors of a kind are answered with the status code
+// of their kind and their message, errors of no kind are logged and
+// answered as internal server errors without their message, which may
+// expose internals. Invalid requests list their failing fields in the
+// language the client accepts.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, body := errorResponse(err, acceptedLocales(c.Request().Header.Get("Accept-Language")))
+	body.RequestId = c.Response().Header().Get(echo.HeaderXRequestID)
+	if status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, body)
+	}
+
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func errorResponse(err error, locales []string) (int, *api.ErrorResponse) {
+	var validationError *services.ValidationError
+	if errors.As(err, &validationError) {
+		return http.StatusBadRequest, &api.ErrorResponse{
+			Code:    "validation_failed",
+			Message: validationError.Message(locales...),
+			Fields:  validationError.Fields(locales...),
+		}
+	}
+
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		switch message := httpError.Message.(type) {
+		case *api.ErrorResponse:
+			response := *message
+			return httpError.Code, &response
+		case string:
+			return httpError.Code, &api.ErrorResponse{Code: statusCode(httpError.Code), Message: message}
+		default:
+			return httpError.Code, &api.ErrorResponse{Code: statusCode(httpError.Code), Message: fmt.Sprint(message)}
+		}
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, api.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, api.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, api.ErrUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, &api.ErrorResponse{Code: "deadline_exceeded", Message: err.Error()}
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = http.StatusText(status)
+	}
+
+	return status, &api.ErrorResponse{Code: statusCode(status), Message: message}
+}
+
+// statusCode names the status for ErrorResponse.Code, e.g. not_found.
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if len(text) == 0 {
+		return fmt.Sprint(status)
+	}
+
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// acceptedLocales lists the locales of an Accept-Language header by their
+// weight, a regional locale is followed by its language, e.g. pt-BR by pt.
+func acceptedLocales(header string) []string {
+	type weighted struct {
+		locale string
+		weight float64
+	}
+
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.ReplaceAll(strings.TrimSpace(fields[0]), "-", "_")
+		if len(locale) == 0 || locale == "*" {
+			continue
+		}
+
+		weight := 1.0
+		for _, parameter := range fields[1:] {
+			if q := strings.TrimSpace(parameter); strings.HasPrefix(q, "q=") {
+				if parsed, err := strconv.ParseFloat(q[2:], 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+
+		accepted = append(accepted, weighted{locale: locale, weight: weight})
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].weight > accepted[j].weight
+	})
+
+	locales := make([]string, 0, len(accepted))
+	for _, a := range accepted {
+		locales = append(locales, a.locale)
+		if i := strings.Index(a.locale, "_"); i > 0 {
+			locales = append(locales, a.locale[:i])
+		}
+	}
+
+	return locales
+}
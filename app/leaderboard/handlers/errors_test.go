@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/handlers"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"net/http/httptest"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the error handler", func() {
+	// answer answers a request to a route which fails with err.
+	answer := func(method string, err error) (*httptest.ResponseRecorder, *api.ErrorResponse) {
+		e := echo.New()
+		e.HTTPErrorHandler = handlers.ErrorHandler
+		e.Use(middleware.RequestID())
+		e.Add(method, "/fail", func(echo.Context) error {
+			return err
+		})
+
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(method, "/fail", nil))
+
+		response := new(api.ErrorResponse)
+		if recorder.Body.Len() > 0 {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), response)).To(Succeed())
+		}
+
+		return recorder, response
+	}
+
+	DescribeTable("the kinds of errors",
+		func(kind error, status int, code string) {
+			recorder, response := answer(http.MethodGet, api.NewError(kind, "board TR is missing"))
+			Expect(recorder.Code).To(Equal(status))
+			Expect(response.Code).To(Equal(code))
+			Expect(response.Message).To(Equal("board TR is missing"))
+		},
+		Entry("not found", api.ErrNotFound, http.StatusNotFound, "not_found"),
+		Entry("conflict", api.ErrConflict, http.StatusConflict, "conflict"),
+		Entry("invalid", api.ErrInvalid, http.StatusBadRequest, "bad_request"),
+		Entry("unavailable", api.ErrUnavailable, http.StatusServiceUnavailable, "service_unavailable"),
+	)
+
+	It("finds the kind of wrapped errors", func() {
+		recorder, _ := answer(http.MethodGet, fmt.Errorf("reading the board: %w", api.NewError(api.ErrUnavailable, "redis is down")))
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("answers errors of no kind without their message", func() {
+		recorder, response := answer(http.MethodGet, errors.New("dial tcp 10.0.0.1:6379: connection refused"))
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(response.Code).To(Equal("internal_server_error"))
+		Expect(response.Message).To(Equal(http.StatusText(http.StatusInternalServerError)))
+	})
+
+	It("passes the echo errors through", func() {
+		recorder, response := answer(http.MethodGet, echo.NewHTTPError(http.StatusTooManyRequests, "slow down"))
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(response.Code).To(Equal("too_many_requests"))
+		Expect(response.Message).To(Equal("slow down"))
+
+		recorder, response = answer(http.MethodGet, echo.ErrNotFound)
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(response.Code).To(Equal("not_found"))
+		Expect(response.Message).To(Equal(http.StatusText(http.StatusNotFound)))
+	})
+
+	It("keeps the envelope of echo errors which carry one", func() {
+		envelope := &api.ErrorResponse{Code: "quota_exceeded", Message: "the quota is exceeded"}
+		recorder, response := answer(http.MethodGet, echo.NewHTTPError(http.StatusForbidden, envelope))
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(response.Code).To(Equal("quota_exceeded"))
+		Expect(response.Message).To(Equal("the quota is exceeded"))
+		Expect(envelope.RequestId).To(BeEmpty())
+	})
+
+	It("answers the id of the request", func() {
+		recorder, response := answer(http.MethodGet, api.NewError(api.ErrNotFound, "missing"))
+		Expect(response.RequestId).NotTo(BeEmpty())
+		Expect(response.RequestId).To(Equal(recorder.Header().Get(echo.HeaderXRequestID)))
+	})
+
+	It("answers HEAD requests without a body", func() {
+		recorder, _ := answer(http.MethodHead, api.NewError(api.ErrNotFound, "missing"))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(recorder.Body.Len()).To(BeZero())
+	})
+
+	It("answers the storage errors of the routes rather than an empty page", func() {
+		s := newServer(services.AuthenticatorChain{})
+		mRedis.Close()
+		defer func() {
+			Expect(mRedis.Restart()).To(Succeed())
+		}()
+
+		response := s.serve(http.MethodGet, "/leaderboard?page=1&page_size=10", "", nil)
+		Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(response.Body.String()).To(ContainSubstring(`"code":"service_unavailable"`))
+	})
+})
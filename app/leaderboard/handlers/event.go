@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const eventsHeartbeatInterval = 15 * time.Second
+
+type EventHandler struct {
+	scoreStreamService *services.ScoreStreamService
+}
+
+func NewEventHandler(scoreStreamService *services.ScoreStreamService) *EventHandler {
+	return &EventHandler{scoreStreamService: scoreStreamService}
+}
+
+func (ev *EventHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/events")
+
+	group.GET("/scores", ev.StreamScores)
+}
+
+// StreamScores godoc
+// @Summary Stream score events
+// @Description Stream accepted submissions and rank changes of a leaderboard as Server-Sent Events.
+// @Description Reconnecting clients resume after the event given in the Last-Event-ID header.
+// @Produce text/event-stream
+// @Success 200
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Tags leaderboard,score
+// @Param board query string false "leaderboard name, GLOBAL or ISO standard country code"
+// @Param Last-Event-ID header string false "id of the last received event"
+// @Router /events/scores [get]
+func (ev *EventHandler) StreamScores(c echo.Context) (err error) {
+	q := new(api.ScoreStreamQuery)
+	if err = c.Bind(q); err != nil {
+		return
+	}
+
+	q.Board = strings.ToUpper(q.Board)
+	if len(q.Board) == 0 {
+		q.Board = "GLOBAL"
+	}
+
+	subscription, err := ev.scoreStreamService.Subscribe(c.Request().Context(), q.Board, c.Request().Header.Get("Last-Event-ID"))
+	if err != nil {
+		return err
+	}
+	defer subscription.Close()
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(response, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return nil
+			}
+
+			data, err := json.Marshal(event.Event)
+			if err != nil {
+				return err
+			}
+
+			if _, err = fmt.Fprintf(response, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+				return nil
+			}
+		}
+
+		response.Flush()
+	}
+}
@@ -0,0 +1,119 @@
+package handlers_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"io"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/handlers"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tasks"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+const (
+	namespace = "handlers"
+	keyPrefix = "LB_"
+
+	// maxUploadSize is the most bytes of an uploaded import file
+	maxUploadSize = 1024
+)
+
+var mRedis *miniredis.Miniredis
+
+var ctx = context.Background()
+
+func TestHandlers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "handlers")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	mRedis, err = miniredis.Run()
+	Expect(err).To(BeNil())
+})
+
+var _ = AfterSuite(func() {
+	mRedis.Close()
+})
+
+var _ = AfterEach(func() {
+	mRedis.FlushAll()
+})
+
+// server is the HTTP API as bootstrap builds it, behind the given
+// authenticator, along with the services its routes use.
+type server struct {
+	*echo.Echo
+	userService *services.UserService
+}
+
+func newServer(authenticator api.Authenticator) *server {
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+	e.Validator = services.NewStructValidator(validator.New())
+	e.Use(middleware.RequestID())
+	e.Use(middlewares.Authenticate(authenticator))
+
+	redisService := services.NewRedisService(redis.NewClient(&redis.Options{Addr: mRedis.Addr()}), namespace)
+	userService := services.NewUserService(redisService, keyPrefix)
+	leaderboardService := services.NewLeaderboardService(userService, redisService, keyPrefix)
+	rankHistoryService := services.NewRankHistoryService(redisService, time.Minute, time.Hour)
+	scoreService := services.NewScoreService(userService, redisService, services.ScoreModeReplace, rankHistoryService)
+	webhookService := services.NewWebhookService(redisService, &services.WebhookConfiguration{Workers: 1, MaxAttempts: 1})
+	jobService := services.NewJobService(redisService, services.NewKeySchema(namespace), &services.JobConfiguration{
+		Workers:      1,
+		LeaseTTL:     time.Second,
+		PollInterval: time.Second,
+	})
+
+	handlers.NewUserHandler(userService, rankHistoryService).Register(e)
+	handlers.NewLeaderboardHandler(leaderboardService).Register(e)
+	handlers.NewWebhookHandler(webhookService).Register(e)
+	handlers.NewScoreHandler(scoreService).Register(e)
+	handlers.NewActuatorHandler(redisService).Register(e)
+	handlers.NewBoardHandler(services.NewBoardService(redisService)).Register(e)
+	handlers.NewJobHandler(jobService).Register(e)
+	importService := services.NewImportService(redisService, os.TempDir())
+	jobService.Register(tasks.NewImportJob(redisService, importService, services.NewStructValidator(validator.New())))
+	handlers.NewImportHandler(jobService, importService, maxUploadSize).Register(e)
+
+	return &server{Echo: e, userService: userService}
+}
+
+// serve answers a request with a JSON body, or without a body when it is
+// empty.
+func (s *server) serve(method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = strings.NewReader(body)
+	}
+
+	request := httptest.NewRequest(method, path, reader)
+	for name, values := range header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if len(body) > 0 {
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, request)
+
+	return recorder
+}
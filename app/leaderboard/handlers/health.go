@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+func (h *HealthHandler) Register(echo *echo.Echo) {
+	echo.GET("/healthz", h.Liveness)
+	echo.GET("/readyz", h.Readiness)
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Report that the process is up, without checking its dependencies
+// @Produce json
+// @Success 200 {object} api.HealthStatus
+// @Tags health
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, &api.HealthStatus{Status: services.HealthStatusOk})
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Check redis and the configured secondary stores, the instance is not ready while it drains for shutdown
+// @Produce json
+// @Success 200 {object} api.HealthStatus
+// @Failure 503 {object} api.HealthStatus
+// @Tags health
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	checks, ready := h.healthService.Ready(c.Request().Context())
+	if ready {
+		return c.JSON(http.StatusOK, &api.HealthStatus{Status: services.HealthStatusOk, Checks: checks})
+	}
+
+	status := services.HealthStatusUnavailable
+	if h.healthService.Draining() {
+		status = services.HealthStatusDraining
+	}
+
+	return c.JSON(http.StatusServiceUnavailable, &api.HealthStatus{Status: status, Checks: checks})
+}
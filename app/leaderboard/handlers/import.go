@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tasks"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errBodyTooLarge is the error of a body read past the limit of
+// http.MaxBytesReader, which has no type of its own.
+const errBodyTooLarge = "http: request body too large"
+
+type ImportHandler struct {
+	jobService    *services.JobService
+	importService *services.ImportService
+	maxUploadSize int64
+}
+
+// NewImportHandler takes uploads of maxUploadSize bytes at most.
+func NewImportHandler(jobService *services.JobService, importService *services.ImportService, maxUploadSize int64) *ImportHandler {
+	return &ImportHandler{jobService: jobService, importService: importService, maxUploadSize: maxUploadSize}
+}
+
+func (i *ImportHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/_actuator/imports", middlewares.RequireRole(services.ScopeActuator, services.RoleAdmin))
+
+	group.POST("", i.Upload)
+	group.GET("/:id", i.GetImport)
+}
+
+// Upload godoc
+// @Summary Import an uploaded file
+// @Description Store a CSV or NDJSON file of users and their points and start a run of the import job on it, the file is removed once it is imported. The file is the body of the request, or the file field of a multipart form.
+// @Accept text/csv,application/x-ndjson,multipart/form-data
+// @Produce json
+// @Success 202 {object} api.ImportReport
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 413 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Tags actuator
+// @Param format query string false "csv or ndjson, told by the content type or the file name when not given"
+// @Param batch_size query int false "rows written at once, 500 when not given"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/imports [post]
+func (i *ImportHandler) Upload(c echo.Context) error {
+	upload := &api.ImportUpload{Format: c.QueryParam("format")}
+	if batchSize := c.QueryParam("batch_size"); len(batchSize) > 0 {
+		var err error
+		if upload.BatchSize, err = strconv.ParseUint(batchSize, 10, 64); err != nil {
+			return api.NewError(api.ErrInvalid, "batch_size is not a number")
+		}
+	}
+
+	if err := c.Validate(upload); err != nil {
+		return err
+	}
+
+	// the file is stored as it is read, a larger one is not read through
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, i.maxUploadSize)
+	body, format, err := uploadedFile(c.Request())
+	if err != nil {
+		return i.uploadError(err)
+	}
+	if len(upload.Format) == 0 {
+		upload.Format = format
+	}
+
+	path, err := i.importService.Store(body, upload.Format)
+	if err != nil {
+		return i.uploadError(err)
+	}
+
+	run, err := i.jobService.StartRun(c.Request().Context(), tasks.JobImport, &api.ImportParameters{
+		Path:      path,
+		Format:    upload.Format,
+		BatchSize: upload.BatchSize,
+		Remove:    true,
+	})
+	if err != nil {
+		_ = i.importService.Remove(path)
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, &api.ImportReport{Run: run})
+}
+
+// GetImport godoc
+// @Summary Get an import
+// @Description Report a run of the import job with the rows it read, imported and rejected, and the first thousand rejected rows
+// @Produce json
+// @Success 200 {object} api.ImportReport
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param id path string true "run id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/imports/{id} [get]
+func (i *ImportHandler) GetImport(c echo.Context) error {
+	run, err := i.jobService.GetRun(c.Request().Context(), tasks.JobImport, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	report, err := i.importService.Report(c.Request().Context(), run)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// uploadError answers an upload which is larger than the limit as too
+// large, and other errors as they are.
+func (i *ImportHandler) uploadError(err error) error {
+	if strings.Contains(err.Error(), errBodyTooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("the upload is larger than %d bytes", i.maxUploadSize))
+	}
+
+	return err
+}
+
+// uploadedFile returns the file of an upload along with the format its
+// content type or its file name tells, if any. The file of a multipart form
+// is streamed from its part rather than parsed ahead.
+func uploadedFile(request *http.Request) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEMultipartForm {
+		return request.Body, formatOf(mediaType, ""), nil
+	}
+
+	reader, err := request.MultipartReader()
+	if err != nil {
+		return nil, "", api.NewError(api.ErrInvalid, err.Error())
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", api.NewError(api.ErrInvalid, "the form has no file field")
+		}
+		if err != nil {
+			return nil, "", api.NewError(api.ErrInvalid, err.Error())
+		}
+
+		if part.FormName() == "file" {
+			mediaType, _, _ := mime.ParseMediaType(part.Header.Get(echo.HeaderContentType))
+			return part, formatOf(mediaType, part.FileName()), nil
+		}
+	}
+}
+
+func formatOf(mediaType string, fileName string) string {
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl":
+		return "ndjson"
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return "csv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+
+	return ""
+}
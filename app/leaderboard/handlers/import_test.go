@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the uploads of imports", func() {
+	var s *server
+
+	// upload posts body of the content type as the admin.
+	upload := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/_actuator/imports", bytes.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		request.Header.Set(services.HeaderAPIKey, "admin")
+
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	// uploads lists the uploads stored in the import directory.
+	uploads := func() []string {
+		paths, err := filepath.Glob(filepath.Join(os.TempDir(), "upload-*"))
+		Expect(err).To(BeNil())
+		return paths
+	}
+
+	csv := func(size int) []byte {
+		body := []byte("user_id,display_name,country,points\n")
+		for i := 0; len(body) < size; i++ {
+			body = append(body, fmt.Sprintf("user-%d,user,TR,%d\n", i, i)...)
+		}
+
+		return body[:size]
+	}
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "admin", KeySha256: services.HashAPIKey("admin"), Role: services.RoleAdmin},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator)
+	})
+
+	It("stores an upload up to the limit and starts an import of it", func() {
+		response := upload("text/csv", csv(maxUploadSize))
+		Expect(response.Code).To(Equal(http.StatusAccepted))
+
+		report := new(api.ImportReport)
+		Expect(json.Unmarshal(response.Body.Bytes(), report)).To(Succeed())
+		path := filepath.Join(os.TempDir(), fmt.Sprint(report.Run.Parameters["path"]))
+		defer os.Remove(path)
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).To(BeNil())
+		Expect(data).To(Equal(csv(maxUploadSize)))
+	})
+
+	It("refuses a body larger than the limit and keeps nothing of it", func() {
+		before := uploads()
+
+		response := upload("text/csv", csv(maxUploadSize+1))
+		Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(response.Body.String()).To(ContainSubstring(`"code":"request_entity_too_large"`))
+		Expect(response.Body.String()).To(ContainSubstring(fmt.Sprintf("larger than %d bytes", maxUploadSize)))
+		Expect(uploads()).To(Equal(before))
+	})
+
+	It("refuses a form larger than the limit", func() {
+		body := new(bytes.Buffer)
+		form := multipart.NewWriter(body)
+		part, err := form.CreateFormFile("file", "users.csv")
+		Expect(err).To(BeNil())
+		_, err = part.Write(csv(2 * maxUploadSize))
+		Expect(err).To(BeNil())
+		Expect(form.Close()).To(Succeed())
+
+		response := upload(form.FormDataContentType(), body.Bytes())
+		Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(response.Body.String()).To(ContainSubstring(`"code":"request_entity_too_large"`))
+	})
+})
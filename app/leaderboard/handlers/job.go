@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+func (j *JobHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/_actuator/jobs", middlewares.RequireRole(services.ScopeActuator, services.RoleAdmin))
+
+	group.GET("", j.GetJobs)
+	group.GET("/:name/runs", j.GetRuns)
+	group.POST("/:name/runs", j.StartRun)
+	group.GET("/:name/runs/:id", j.GetRun)
+	group.DELETE("/:name/runs/:id", j.StopRun)
+	group.POST("/:name/runs/:id/pause", j.PauseRun)
+	group.POST("/:name/runs/:id/resume", j.ResumeRun)
+}
+
+// GetJobs godoc
+// @Summary List jobs
+// @Description List the background jobs along with their latest runs
+// @Produce json
+// @Success 200 {array} api.Job
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs [get]
+func (j *JobHandler) GetJobs(c echo.Context) error {
+	jobs, err := j.jobService.Jobs(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, jobs)
+}
+
+// GetRuns godoc
+// @Summary List runs of a job
+// @Description List the latest runs of a job, newest first
+// @Produce json
+// @Success 200 {array} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs [get]
+func (j *JobHandler) GetRuns(c echo.Context) error {
+	runs, err := j.jobService.Runs(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, runs)
+}
+
+// StartRun godoc
+// @Summary Start a job
+// @Description Queue a run of a job with the parameters of the job, e.g. api.GenerateUsersParameters for generate-users.
+// @Description A singleton job is refused while another run of it is not finished.
+// @Accept json
+// @Produce json
+// @Success 202 {object} api.JobRun
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Param parameters body object false "parameters of the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs [post]
+func (j *JobHandler) StartRun(c echo.Context) error {
+	name := c.Param("name")
+	parameters, err := j.jobService.Parameters(name)
+	if err != nil {
+		return err
+	}
+
+	if parameters != nil {
+		if err := c.Bind(parameters); err != nil {
+			return err
+		}
+
+		if err := c.Validate(parameters); err != nil {
+			return err
+		}
+	}
+
+	run, err := j.jobService.StartRun(c.Request().Context(), name, parameters)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, run)
+}
+
+// GetRun godoc
+// @Summary Get a run of a job
+// @Description Get the state and the progress of a run
+// @Produce json
+// @Success 200 {object} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Param id path string true "run id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs/{id} [get]
+func (j *JobHandler) GetRun(c echo.Context) error {
+	run, err := j.jobService.GetRun(c.Request().Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+// StopRun godoc
+// @Summary Stop a run of a job
+// @Description Cancel a queued, running or paused run
+// @Produce json
+// @Success 200 {object} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Param id path string true "run id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs/{id} [delete]
+func (j *JobHandler) StopRun(c echo.Context) error {
+	run, err := j.jobService.StopRun(c.Request().Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+// PauseRun godoc
+// @Summary Pause a run of a job
+// @Description Pause a running run until it is resumed
+// @Produce json
+// @Success 200 {object} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Param id path string true "run id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs/{id}/pause [post]
+func (j *JobHandler) PauseRun(c echo.Context) error {
+	run, err := j.jobService.PauseRun(c.Request().Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+// ResumeRun godoc
+// @Summary Resume a run of a job
+// @Description Resume a paused run
+// @Produce json
+// @Success 200 {object} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "job name"
+// @Param id path string true "run id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/jobs/{name}/runs/{id}/resume [post]
+func (j *JobHandler) ResumeRun(c echo.Context) error {
+	run, err := j.jobService.ResumeRun(c.Request().Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
@@ -20,6 +20,7 @@ func (l *LeaderboardHandler) Register(echo *echo.Echo) {
 
 	group.GET("", l.GetLeaderboard)
 	group.GET("/:country_iso_code", l.GetLeaderboard)
+	group.GET("/around/:guid", l.GetAroundMe)
 }
 
 // GetLeaderboard godoc
@@ -27,7 +28,9 @@ func (l *LeaderboardHandler) Register(echo *echo.Echo) {
 // @Description Get leaderboard
 // @Produce  json
 // @Success 200 {array} api.LeaderboardRow
-// @Failure 500
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags leaderboard
 // @Param page query int false "page number" minimum(1)
 // @Param page_size query int false "number of records in a page" minimum(1)
@@ -42,7 +45,9 @@ func (l *LeaderboardHandler) GetLeaderboard(c echo.Context) error {
 // @Description Get leaderboard
 // @Produce  json
 // @Success 200 {array} api.LeaderboardRow
-// @Failure 500
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags leaderboard
 // @Param page query int false "page number" minimum(1)
 // @Param page_size query int false "number of records in a page" minimum(1)
@@ -68,7 +73,7 @@ func (l *LeaderboardHandler) handleLeaderboardRequest(c echo.Context) (err error
 	}
 
 	if err = c.Validate(q); err != nil {
-		return c.JSON(http.StatusBadRequest, api.NewValidationErrorResponse(err.Error()))
+		return err
 	}
 
 	countryParam := c.Param("country_iso_code")
@@ -80,10 +85,55 @@ func (l *LeaderboardHandler) handleLeaderboardRequest(c echo.Context) (err error
 		q.Country = "GLOBAL"
 	}
 
-	page, err := l.leaderboardService.GetPage(q.Country, q.Page, q.PageSize)
-	if err != nil || page == nil {
+	page, err := l.leaderboardService.GetPage(c.Request().Context(), q.Country, q.Page, q.PageSize)
+	if err != nil {
+		return err
+	}
+
+	if page == nil {
 		page = []*api.LeaderboardRow{}
 	}
 
 	return c.JSON(http.StatusOK, page)
 }
+
+// GetAroundMe godoc
+// @Summary Get leaderboard around a user
+// @Description Get the rows ranked within radius of a user
+// @Produce  json
+// @Success 200 {array} api.LeaderboardRow
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags leaderboard
+// @Param guid path string true "user GUID"
+// @Param board query string false "leaderboard name, GLOBAL or ISO standard country code"
+// @Param radius query int false "number of rows above and below the user" minimum(0) maximum(100)
+// @Router /leaderboard/around/{guid} [get]
+func (l *LeaderboardHandler) GetAroundMe(c echo.Context) (err error) {
+	q := new(api.AroundQuery)
+	if err = c.Bind(q); err != nil {
+		return
+	}
+
+	if q.Radius <= 0 {
+		q.Radius = 5
+	}
+
+	if err = c.Validate(q); err != nil {
+		return err
+	}
+
+	q.Board = strings.ToUpper(q.Board)
+	if len(q.Board) == 0 {
+		q.Board = "GLOBAL"
+	}
+
+	rows, err := l.leaderboardService.GetAround(c.Request().Context(), q.Board, c.Param("guid"), q.Radius)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rows)
+}
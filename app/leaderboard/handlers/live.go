@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+)
+
+const liveUpdatesBufferSize = 64
+
+type LiveHandler struct {
+	liveFeedService *services.LiveFeedService
+}
+
+func NewLiveHandler(liveFeedService *services.LiveFeedService) *LiveHandler {
+	return &LiveHandler{liveFeedService: liveFeedService}
+}
+
+func (l *LiveHandler) Register(echo *echo.Echo) {
+	echo.GET("/leaderboard/live", l.Live)
+}
+
+// Live godoc
+// @Summary Live leaderboard feed
+// @Description Upgrades to a WebSocket connection. Clients send subscription requests
+// @Description to follow a leaderboard page or the rank of a single user, and the server
+// @Description pushes a snapshot followed by diffs whenever scores change.
+// @Success 101
+// @Tags leaderboard
+// @Router /leaderboard/live [get]
+func (l *LiveHandler) Live(c echo.Context) error {
+	server := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			l.serve(c, conn)
+		},
+	}
+
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+func (l *LiveHandler) serve(c echo.Context, conn *websocket.Conn) {
+	defer conn.Close()
+
+	updates := make(chan *api.LiveUpdate, liveUpdatesBufferSize)
+	done := make(chan struct{})
+	defer close(done)
+
+	writerDone := make(chan struct{})
+	reply := func(update *api.LiveUpdate) {
+		select {
+		case updates <- update:
+		case <-writerDone:
+		}
+	}
+
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case <-done:
+				return
+			case update := <-updates:
+				if err := websocket.JSON.Send(conn, update); err != nil {
+					_ = conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	subscriptions := map[string]bool{}
+	defer func() {
+		for id := range subscriptions {
+			l.liveFeedService.Unsubscribe(id)
+		}
+	}()
+
+	for {
+		request := new(api.LiveSubscriptionRequest)
+		if err := websocket.JSON.Receive(conn, request); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				reply(&api.LiveUpdate{Type: "error", Message: err.Error()})
+				continue
+			}
+
+			return
+		}
+
+		if err := c.Validate(request); err != nil {
+			reply(&api.LiveUpdate{Type: "error", Message: err.Error()})
+			continue
+		}
+
+		switch request.Action {
+		case "subscribe":
+			id := l.liveFeedService.Subscribe(request, updates)
+			subscriptions[id] = true
+			reply(&api.LiveUpdate{Type: "subscribed", SubscriptionId: id})
+		case "unsubscribe":
+			if !subscriptions[request.SubscriptionId] {
+				reply(&api.LiveUpdate{Type: "error", Message: "subscription is not found"})
+				continue
+			}
+
+			l.liveFeedService.Unsubscribe(request.SubscriptionId)
+			delete(subscriptions, request.SubscriptionId)
+			reply(&api.LiveUpdate{Type: "unsubscribed", SubscriptionId: request.SubscriptionId})
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/metrics"
+)
+
+type MetricsHandler struct {
+	registry *metrics.Registry
+}
+
+func NewMetricsHandler(registry *metrics.Registry) *MetricsHandler {
+	return &MetricsHandler{registry: registry}
+}
+
+func (m *MetricsHandler) Register(echo *echo.Echo) {
+	echo.GET("/metrics", m.GetMetrics)
+}
+
+// GetMetrics godoc
+// @Summary Get metrics
+// @Description Get metrics in the Prometheus text exposition format
+// @Produce plain
+// @Success 200
+// @Tags metrics
+// @Router /metrics [get]
+func (m *MetricsHandler) GetMetrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	_, err := m.registry.WriteTo(c.Response())
+
+	return err
+}
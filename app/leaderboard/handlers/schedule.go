@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+type ScheduleHandler struct {
+	schedulerService *services.SchedulerService
+}
+
+func NewScheduleHandler(schedulerService *services.SchedulerService) *ScheduleHandler {
+	return &ScheduleHandler{schedulerService: schedulerService}
+}
+
+func (s *ScheduleHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/_actuator/schedules", middlewares.RequireRole(services.ScopeActuator, services.RoleAdmin))
+
+	group.GET("", s.GetSchedules)
+	group.POST("/:name/trigger", s.Trigger)
+}
+
+// GetSchedules godoc
+// @Summary List schedules
+// @Description List the schedules of the jobs along with their last and next times, and the instance leading the scheduler
+// @Produce json
+// @Success 200 {object} api.SchedulerStatus
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/schedules [get]
+func (s *ScheduleHandler) GetSchedules(c echo.Context) error {
+	status, err := s.schedulerService.Schedules(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+// Trigger godoc
+// @Summary Trigger a schedule
+// @Description Start a run of the job of a schedule right away, the times of the schedule are not affected
+// @Produce json
+// @Success 202 {object} api.JobRun
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags actuator
+// @Param name path string true "schedule name"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /_actuator/schedules/{name}/trigger [post]
+func (s *ScheduleHandler) Trigger(c echo.Context) error {
+	run, err := s.schedulerService.Trigger(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, run)
+}
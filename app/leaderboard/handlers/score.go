@@ -1,38 +1,46 @@
 package handlers
 
 import (
-	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/middlewares"
 	"leaderboard/app/leaderboard/services"
 	"net/http"
 )
 
 type ScoreHandler struct {
-	userService          *services.UserService
-	redisService         api.RedisService
-	leaderboardKeyPrefix string
+	scoreService *services.ScoreService
 }
 
-func NewScoreHandler(userService *services.UserService, redisService api.RedisService, leaderboardKeyPrefix string) *ScoreHandler {
-	return &ScoreHandler{userService: userService, redisService: redisService, leaderboardKeyPrefix: leaderboardKeyPrefix}
+func NewScoreHandler(scoreService *services.ScoreService) *ScoreHandler {
+	return &ScoreHandler{scoreService: scoreService}
 }
 
 func (s *ScoreHandler) Register(echo *echo.Echo) {
-	group := echo.Group("/score")
+	group := echo.Group("/score", middlewares.RequireRole(services.ScopeScoresWrite, services.RoleGameServer, services.RolePlayer))
 
 	group.POST("/submit", s.Submit)
+	group.POST("/submit-batch", s.SubmitBatch)
 }
 
 // Submit godoc
 // @Summary submit a new score
-// @Description submit a new score
+// @Description submit a new score, it is written to the global and the country boards at once according to the scoring mode
 // @Accept json
 // @Produce json
 // @Success 200
-// @Failure 500
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 409 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags leaderboard,score
 // @Param score body api.ScoreSubmission true "score submission"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /score/submit [post]
 func (s *ScoreHandler) Submit(c echo.Context) (err error) {
 	submission := new(api.ScoreSubmission)
@@ -41,24 +49,62 @@ func (s *ScoreHandler) Submit(c echo.Context) (err error) {
 	}
 
 	if err = c.Validate(submission); err != nil {
-		return c.JSON(http.StatusBadRequest, api.NewValidationErrorResponse(err.Error()))
+		metrics.ScoreSubmissionsRejected.WithLabelValues("invalid").Inc()
+		return err
 	}
 
-	// send to redis
-	user, err := s.userService.GetByID(submission.UserId)
-	if err != nil {
-		return echo.ErrNotFound
+	if err = services.AuthorizeSubmission(middlewares.Principal(c), submission.UserId); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("unauthorized").Inc()
+		return middlewares.NewAuthError(c, err)
 	}
 
-	s.redisService.Add("GLOBAL", &redis.Z{
-		Score:  submission.Score,
-		Member: submission.UserId,
-	})
-
-	s.redisService.Add(user.Country, &redis.Z{
-		Score:  submission.Score,
-		Member: submission.UserId,
-	})
+	if _, err = s.scoreService.Submit(c.Request().Context(), submission); err != nil {
+		return err
+	}
 
 	return c.NoContent(http.StatusCreated)
 }
+
+// SubmitBatch godoc
+// @Summary submit scores in batch
+// @Description submit up to 1000 scores at once, each submission succeeds or fails on its own. The submissions left when the request times out fail with the deadline error, the ones before stay written.
+// @Accept json
+// @Produce json
+// @Success 200 {array} api.BatchScoreResult
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Tags leaderboard,score
+// @Param scores body api.BatchScoreSubmission true "score submissions"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /score/submit-batch [post]
+func (s *ScoreHandler) SubmitBatch(c echo.Context) (err error) {
+	batch := new(api.BatchScoreSubmission)
+	if err = c.Bind(batch); err != nil {
+		return
+	}
+
+	if err = c.Validate(batch); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("invalid").Add(float64(len(batch.Submissions)))
+		return err
+	}
+
+	userIds := make([]string, 0, len(batch.Submissions))
+	for _, submission := range batch.Submissions {
+		userIds = append(userIds, submission.UserId)
+	}
+
+	if err = services.AuthorizeSubmission(middlewares.Principal(c), userIds...); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("unauthorized").Add(float64(len(userIds)))
+		return middlewares.NewAuthError(c, err)
+	}
+
+	// a batch which runs out of time is answered with the results of every
+	// submission rather than 504, the ones written before the deadline stay
+	results := s.scoreService.SubmitBatch(c.Request().Context(), batch.Submissions)
+
+	return c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,45 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the score routes", func() {
+	var s *server
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "server", KeySha256: services.HashAPIKey("server"), Role: services.RoleGameServer},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator)
+
+		_, err = s.userService.Create(ctx, &api.UserProfile{UserId: "alice", DisplayName: "alice", Country: "TR"})
+		Expect(err).To(BeNil())
+	})
+
+	When("a batch outruns the submit budget", func() {
+		BeforeEach(func() {
+			s.Use(middlewares.Timeout(time.Nanosecond))
+		})
+
+		It("answers the result of every submission rather than 504", func() {
+			batch := fmt.Sprintf(`{"submissions": [{"user_id": "alice", "score": 10, "timestamp": %d}]}`, time.Now().Unix())
+			response := s.serve(http.MethodPost, "/score/submit-batch", batch, http.Header{services.HeaderAPIKey: {"server"}})
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			var results []*api.BatchScoreResult
+			Expect(json.Unmarshal(response.Body.Bytes(), &results)).To(Succeed())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Error).To(Equal("context deadline exceeded"))
+		})
+	})
+})
@@ -1,19 +1,22 @@
 package handlers
 
 import (
-	"fmt"
 	"github.com/labstack/echo/v4"
 	api2 "leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
 	"leaderboard/app/leaderboard/services"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService        *services.UserService
+	rankHistoryService *services.RankHistoryService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, rankHistoryService *services.RankHistoryService) *UserHandler {
+	return &UserHandler{userService: userService, rankHistoryService: rankHistoryService}
 }
 
 func (h *UserHandler) Register(e *echo.Echo) {
@@ -21,6 +24,11 @@ func (h *UserHandler) Register(e *echo.Echo) {
 
 	group.POST("/create", h.CreateUser)
 	group.GET("/profile/:guid", h.GetUserById)
+	group.GET("/profile/:guid/rank-history", h.GetRankHistory)
+
+	admin := middlewares.RequireRole(services.ScopeUsersWrite, services.RoleAdmin)
+	group.PATCH("/profile/:guid", h.UpdateUser, admin)
+	group.DELETE("/profile/:guid", h.DeleteUser, admin)
 }
 
 // CreateUser godoc
@@ -28,7 +36,9 @@ func (h *UserHandler) Register(e *echo.Echo) {
 // @Description Create a new user
 // @Produce  json
 // @Success 200 {array} api.UserProfile
-// @Failure 500
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags user
 // @Param profile body api.UserProfile true "user info"
 // @Router /user/create [post]
@@ -41,15 +51,15 @@ func (h *UserHandler) CreateUser(c echo.Context) (err error) {
 	}
 
 	if err = c.Validate(u); err != nil {
-		return c.JSON(http.StatusBadRequest, api2.NewValidationErrorResponse(err.Error()))
+		return err
 	}
 
-	guid, err := h.userService.Create(u)
+	guid, err := h.userService.Create(c.Request().Context(), u)
 	if err != nil {
 		return err
 	}
 
-	ranked, err := h.userService.GetByIDWithRank(guid, "GLOBAL")
+	ranked, err := h.userService.GetByIDWithRank(c.Request().Context(), guid, "GLOBAL")
 	if err != nil {
 		return err
 	}
@@ -62,16 +72,133 @@ func (h *UserHandler) CreateUser(c echo.Context) (err error) {
 // @Description Get user details by ID
 // @Produce  json
 // @Success 200 {array} api.UserProfile
-// @Failure 500
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
 // @Tags user
 // @Param id path string true "user GUID"
 // @Router /user/profile/{id} [get]
 func (h *UserHandler) GetUserById(c echo.Context) (err error) {
 	guid := c.Param("guid")
-	profile, err := h.userService.GetByIDWithRank(guid, "GLOBAL")
-	if profile == nil || err != nil {
-		return c.JSON(http.StatusNotFound, api2.UserNotFound{Message: fmt.Sprintf("User with ID(%s) is not found.", guid)})
+	profile, err := h.userService.GetByIDWithRank(c.Request().Context(), guid, "GLOBAL")
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, profile)
 }
+
+// GetRankHistory godoc
+// @Summary Get rank history of a user
+// @Description Get rank samples of a user on a leaderboard within a time range
+// @Produce  json
+// @Success 200 {array} api.RankHistoryEntry
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags user
+// @Param id path string true "user GUID"
+// @Param board query string false "leaderboard name, GLOBAL or ISO standard country code"
+// @Param from query int false "range start as unix timestamp"
+// @Param to query int false "range end as unix timestamp"
+// @Router /user/profile/{id}/rank-history [get]
+func (h *UserHandler) GetRankHistory(c echo.Context) (err error) {
+	q := new(api2.RankHistoryQuery)
+	if err = c.Bind(q); err != nil {
+		return
+	}
+
+	if err = c.Validate(q); err != nil {
+		return err
+	}
+
+	guid := c.Param("guid")
+	if _, err = h.userService.GetByID(c.Request().Context(), guid); err != nil {
+		return err
+	}
+
+	q.Board = strings.ToUpper(q.Board)
+	if len(q.Board) == 0 {
+		q.Board = "GLOBAL"
+	}
+
+	to := time.Now()
+	if q.To > 0 {
+		to = time.Unix(q.To, 0)
+	}
+
+	history, err := h.rankHistoryService.GetHistory(c.Request().Context(), guid, q.Board, time.Unix(q.From, 0), to)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
+// UpdateUser godoc
+// @Summary Update a user
+// @Description Change the display name or the country of a user, the score of a user moving to another country moves along
+// @Accept json
+// @Produce json
+// @Success 200 {object} api.UserProfile
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags user
+// @Param id path string true "user GUID"
+// @Param update body api.UserUpdate true "fields to change"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/profile/{id} [patch]
+func (h *UserHandler) UpdateUser(c echo.Context) (err error) {
+	update := new(api2.UserUpdate)
+	if err = c.Bind(update); err != nil {
+		return
+	}
+
+	if err = c.Validate(update); err != nil {
+		return err
+	}
+
+	update.Country = strings.ToUpper(update.Country)
+	guid := c.Param("guid")
+	if _, err = h.userService.Update(c.Request().Context(), guid, update); err != nil {
+		return err
+	}
+
+	ranked, err := h.userService.GetByIDWithRank(c.Request().Context(), guid, "GLOBAL")
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ranked)
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Remove a user from its boards and drop its profile and rank histories
+// @Success 204
+// @Failure 401
+// @Failure 403
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags user
+// @Param id path string true "user GUID"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/profile/{id} [delete]
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	if err := h.userService.Delete(c.Request().Context(), c.Param("guid")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
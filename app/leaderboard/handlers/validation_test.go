@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the envelope of invalid requests", func() {
+	var s *server
+
+	envelope := func(response *httptest.ResponseRecorder) *api.ErrorResponse {
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+		body := new(api.ErrorResponse)
+		Expect(json.Unmarshal(response.Body.Bytes(), body)).To(Succeed())
+		Expect(body.Code).To(Equal("validation_failed"))
+		Expect(body.RequestId).To(Equal(response.Header().Get(echo.HeaderXRequestID)))
+
+		return body
+	}
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "admin", KeySha256: services.HashAPIKey("admin"), Role: services.RoleAdmin},
+			{Id: "server", KeySha256: services.HashAPIKey("server"), Role: services.RoleGameServer},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator)
+	})
+
+	DescribeTable("the languages the client accepts",
+		func(acceptLanguage string, message string, field string) {
+			response := s.serve(http.MethodPost, "/user/create", `{"display_name": "alice"}`, http.Header{"Accept-Language": {acceptLanguage}})
+
+			body := envelope(response)
+			Expect(body.Message).To(Equal(message))
+			Expect(body.Fields).To(Equal([]api.FieldError{{Path: "country", Rule: "required", Message: field}}))
+		},
+		Entry("english", "en-US", "the request is invalid", "country is a required field"),
+		Entry("turkish", "tr-TR,en;q=0.5", "istek geçersiz", "country zorunlu bir alandır"),
+		Entry("spanish by weight", "en;q=0.2,es;q=0.9", "la solicitud no es válida", "country es un campo requerido"),
+		Entry("french", "fr", "la requête n'est pas valide", "country est un champ obligatoire"),
+		Entry("english for unsupported languages", "de-DE,ja", "the request is invalid", "country is a required field"),
+		Entry("english without a header", "", "the request is invalid", "country is a required field"),
+	)
+
+	It("reports the path of nested fields", func() {
+		batch := fmt.Sprintf(`{"submissions": [{"user_id": "alice", "score": 10, "timestamp": %d}, {"score": 10, "timestamp": %d}]}`, time.Now().Unix(), time.Now().Unix())
+		response := s.serve(http.MethodPost, "/score/submit-batch", batch, http.Header{services.HeaderAPIKey: {"server"}, "Accept-Language": {"tr"}})
+
+		Expect(envelope(response).Fields).To(Equal([]api.FieldError{
+			{Path: "submissions[1].user_id", Rule: "required", Message: "user_id zorunlu bir alandır"},
+		}))
+	})
+
+	It("names the rules which have no translation", func() {
+		response := s.serve(http.MethodPatch, "/user/profile/alice", `{}`, http.Header{services.HeaderAPIKey: {"admin"}, "Accept-Language": {"fr"}})
+
+		Expect(envelope(response).Fields).To(Equal([]api.FieldError{
+			{Path: "display_name", Rule: "required_without", Message: "display_name ne respecte pas la règle required_without"},
+		}))
+	})
+})
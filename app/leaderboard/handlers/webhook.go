@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+const webhookDeliveriesLimit = 100
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+func (w *WebhookHandler) Register(echo *echo.Echo) {
+	group := echo.Group("/webhooks", middlewares.RequireRole(services.ScopeWebhooks, services.RoleAdmin))
+
+	group.POST("", w.CreateWebhook)
+	group.GET("", w.GetWebhooks)
+	group.GET("/dead-letters", w.GetDeadLetters)
+	group.GET("/:id", w.GetWebhook)
+	group.DELETE("/:id", w.DeleteWebhook)
+	group.GET("/:id/deliveries", w.GetDeliveries)
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook
+// @Description Register a webhook which is called when its trigger fires on a score submission
+// @Accept json
+// @Produce json
+// @Success 201 {object} api.Webhook
+// @Failure 400 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Param webhook body api.Webhook true "webhook"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (w *WebhookHandler) CreateWebhook(c echo.Context) (err error) {
+	webhook := new(api.Webhook)
+	if err = c.Bind(webhook); err != nil {
+		return
+	}
+
+	if err = c.Validate(webhook); err != nil {
+		return err
+	}
+
+	if _, err = w.webhookService.Create(c.Request().Context(), webhook); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Description List webhooks
+// @Produce json
+// @Success 200 {array} api.Webhook
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (w *WebhookHandler) GetWebhooks(c echo.Context) error {
+	webhooks, err := w.webhookService.GetAll(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook
+// @Description Get a webhook
+// @Produce json
+// @Success 200 {object} api.Webhook
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Param id path string true "webhook id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func (w *WebhookHandler) GetWebhook(c echo.Context) error {
+	webhook, err := w.webhookService.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	webhook.Secret = ""
+
+	return c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook
+// @Success 204
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Param id path string true "webhook id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func (w *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	if err := w.webhookService.Delete(c.Request().Context(), c.Param("id")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary Get delivery log of a webhook
+// @Description Get the latest delivery attempts of a webhook, newest first
+// @Produce json
+// @Success 200 {array} api.WebhookDelivery
+// @Failure 404 {object} api.ErrorResponse
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Param id path string true "webhook id"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (w *WebhookHandler) GetDeliveries(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := w.webhookService.GetByID(c.Request().Context(), id); err != nil {
+		return err
+	}
+
+	deliveries, err := w.webhookService.GetDeliveries(c.Request().Context(), id, webhookDeliveriesLimit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDeadLetters godoc
+// @Summary Get failed deliveries
+// @Description Get the latest deliveries which failed on every attempt, newest first
+// @Produce json
+// @Success 200 {array} api.WebhookDelivery
+// @Failure 401
+// @Failure 403
+// @Failure 500 {object} api.ErrorResponse
+// @Failure 503 {object} api.ErrorResponse
+// @Failure 504 {object} api.ErrorResponse
+// @Tags webhook
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/dead-letters [get]
+func (w *WebhookHandler) GetDeadLetters(c echo.Context) error {
+	deliveries, err := w.webhookService.GetDeadLetters(c.Request().Context(), webhookDeliveriesLimit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,84 @@
+package leaderboard
+
+import (
+	"context"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/services"
+)
+
+// registerMetrics registers the gauges which are read from redis on every
+// scrape, rather than being updated by the instance.
+func registerMetrics(registry *metrics.Registry, redisService api.RedisService, jobService *services.JobService) {
+	registry.MustRegister(
+		metrics.NewGaugeFunc(
+			"leaderboard_board_size",
+			"Number of users ranked on a leaderboard.",
+			[]string{"board"},
+			func() ([]*metrics.GaugeSample, error) {
+				ctx := context.Background()
+				boards, err := redisService.GetBoardNames(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				samples := make([]*metrics.GaugeSample, 0, len(boards))
+				for _, board := range boards {
+					size, err := redisService.GetSortedSetSize(ctx, board)
+					if err != nil {
+						return nil, err
+					}
+					samples = append(samples, &metrics.GaugeSample{LabelValues: []string{board}, Value: float64(size)})
+				}
+
+				return samples, nil
+			},
+		),
+		metrics.NewGaugeFunc(
+			"leaderboard_job_remaining",
+			"Work the latest run of a job has yet to do, while it is not finished.",
+			[]string{"job"},
+			func() ([]*metrics.GaugeSample, error) {
+				jobs, err := jobService.Jobs(context.Background())
+				if err != nil {
+					return nil, err
+				}
+
+				samples := make([]*metrics.GaugeSample, 0, len(jobs))
+				for _, job := range jobs {
+					remaining := 0.0
+					if run := job.LastRun; run != nil && run.FinishedAt == 0 && run.Total > run.Done {
+						remaining = float64(run.Total - run.Done)
+					}
+					samples = append(samples, &metrics.GaugeSample{LabelValues: []string{job.Name}, Value: remaining})
+				}
+
+				return samples, nil
+			},
+		),
+		metrics.NewGaugeFunc(
+			"leaderboard_job_state",
+			"State of the latest run of a job, the current state is 1.",
+			[]string{"job", "state"},
+			func() ([]*metrics.GaugeSample, error) {
+				jobs, err := jobService.Jobs(context.Background())
+				if err != nil {
+					return nil, err
+				}
+
+				samples := make([]*metrics.GaugeSample, 0, len(jobs)*len(services.JobStates))
+				for _, job := range jobs {
+					for _, state := range services.JobStates {
+						value := 0.0
+						if job.LastRun != nil && job.LastRun.State == state {
+							value = 1
+						}
+						samples = append(samples, &metrics.GaugeSample{LabelValues: []string{job.Name, state}, Value: value})
+					}
+				}
+
+				return samples, nil
+			},
+		),
+	)
+}
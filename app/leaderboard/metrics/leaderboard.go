@@ -0,0 +1,55 @@
+package metrics
+
+var (
+	HTTPRequestDuration = NewHistogramVec(
+		"leaderboard_http_request_duration_seconds",
+		"Latency of HTTP requests by route.",
+		DefaultBuckets,
+		"method", "route", "status",
+	)
+	RedisCommandDuration = NewHistogramVec(
+		"leaderboard_redis_command_duration_seconds",
+		"Latency of redis commands, pipelines are observed as a whole.",
+		DefaultBuckets,
+		"command",
+	)
+	RedisCommandErrors = NewCounterVec(
+		"leaderboard_redis_command_errors_total",
+		"Redis commands which failed, missing keys are not counted.",
+		"command",
+	)
+	ScoreSubmissionsAccepted = NewCounterVec(
+		"leaderboard_score_submissions_accepted_total",
+		"Score submissions written to the leaderboards.",
+	)
+	ScoreSubmissionsRejected = NewCounterVec(
+		"leaderboard_score_submissions_rejected_total",
+		"Score submissions which were not written, by reason.",
+		"reason",
+	)
+	UsersGenerated = NewCounterVec(
+		"leaderboard_user_generation_users_total",
+		"Users created by the user generation task.",
+	)
+	RowsImported = NewCounterVec(
+		"leaderboard_import_rows_total",
+		"Rows read by the import job, by outcome.",
+		"outcome",
+	)
+)
+
+func init() {
+	DefaultRegistry.MustRegister(
+		HTTPRequestDuration,
+		RedisCommandDuration,
+		RedisCommandErrors,
+		ScoreSubmissionsAccepted,
+		ScoreSubmissionsRejected,
+		UsersGenerated,
+		RowsImported,
+	)
+
+	// series without labels are exposed from the start
+	ScoreSubmissionsAccepted.WithLabelValues()
+	UsersGenerated.WithLabelValues()
+}
@@ -0,0 +1,354 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets suit latencies in seconds, from half a millisecond to ten
+// seconds.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Collector interface {
+	Name() string
+	Write(w io.Writer) error
+}
+
+type desc struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+	return err
+}
+
+// value is a float64 which is safe to update concurrently.
+type value struct {
+	bits uint64
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, updated) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// series holds one child per combination of label values.
+type series struct {
+	mux      sync.RWMutex
+	children map[string]interface{}
+	labels   map[string][]string
+}
+
+func (s *series) get(labelNames []string, labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(labelNames) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	s.mux.RLock()
+	child, ok := s.children[key]
+	s.mux.RUnlock()
+	if ok {
+		return child
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if child, ok := s.children[key]; ok {
+		return child
+	}
+
+	if s.children == nil {
+		s.children = map[string]interface{}{}
+		s.labels = map[string][]string{}
+	}
+
+	child = create()
+	s.children[key] = child
+	s.labels[key] = append([]string(nil), labelValues...)
+
+	return child
+}
+
+// each visits the children sorted by their label values, so the output is
+// stable between scrapes.
+func (s *series) each(fn func(labelValues []string, child interface{}) error) error {
+	s.mux.RLock()
+	keys := make([]string, 0, len(s.children))
+	for key := range s.children {
+		keys = append(keys, key)
+	}
+	s.mux.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.mux.RLock()
+		child, labelValues := s.children[key], s.labels[key]
+		s.mux.RUnlock()
+
+		if err := fn(labelValues, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type CounterVec struct {
+	desc
+	series series
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{desc: desc{name: name, help: help, kind: "counter", labelNames: labelNames}}
+}
+
+func (c *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return c.series.get(c.labelNames, labelValues, func() interface{} { return new(Counter) }).(*Counter)
+}
+
+func (c *CounterVec) Write(w io.Writer) error {
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+
+	return c.series.each(func(labelValues []string, child interface{}) error {
+		return writeSample(w, c.name, c.labelNames, labelValues, child.(*Counter).Get())
+	})
+}
+
+type Counter struct {
+	value
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+type GaugeVec struct {
+	desc
+	series series
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{desc: desc{name: name, help: help, kind: "gauge", labelNames: labelNames}}
+}
+
+func (g *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return g.series.get(g.labelNames, labelValues, func() interface{} { return new(Gauge) }).(*Gauge)
+}
+
+func (g *GaugeVec) Write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+
+	return g.series.each(func(labelValues []string, child interface{}) error {
+		return writeSample(w, g.name, g.labelNames, labelValues, child.(*Gauge).Get())
+	})
+}
+
+type Gauge struct {
+	value
+}
+
+// GaugeSample is a single value reported by a GaugeFunc.
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc collects its samples on every scrape, which suits values that
+// are already kept somewhere else, such as the size of a sorted set.
+type GaugeFunc struct {
+	desc
+	collect func() ([]*GaugeSample, error)
+}
+
+func NewGaugeFunc(name string, help string, labelNames []string, collect func() ([]*GaugeSample, error)) *GaugeFunc {
+	return &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge", labelNames: labelNames}, collect: collect}
+}
+
+func (g *GaugeFunc) Write(w io.Writer) error {
+	samples, err := g.collect()
+	if err != nil {
+		return fmt.Errorf("collecting %s: %w", g.name, err)
+	}
+
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+
+	for _, sample := range samples {
+		if err := writeSample(w, g.name, g.labelNames, sample.LabelValues, sample.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type HistogramVec struct {
+	desc
+	buckets []float64
+	series  series
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labelNames: labelNames},
+		buckets: buckets,
+	}
+}
+
+func (h *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return h.series.get(h.labelNames, labelValues, func() interface{} {
+		return &Histogram{upperBounds: h.buckets, counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+func (h *HistogramVec) Write(w io.Writer) error {
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+
+	labelNames := append(append([]string(nil), h.labelNames...), "le")
+
+	return h.series.each(func(labelValues []string, child interface{}) error {
+		histogram := child.(*Histogram)
+		counts, count, sum := histogram.snapshot()
+
+		var cumulative uint64
+		for i, upperBound := range histogram.upperBounds {
+			cumulative += counts[i]
+			le := append(append([]string(nil), labelValues...), formatFloat(upperBound))
+			if err := writeSample(w, h.name+"_bucket", labelNames, le, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+
+		le := append(append([]string(nil), labelValues...), "+Inf")
+		if err := writeSample(w, h.name+"_bucket", labelNames, le, float64(count)); err != nil {
+			return err
+		}
+
+		if err := writeSample(w, h.name+"_sum", h.labelNames, labelValues, sum); err != nil {
+			return err
+		}
+
+		return writeSample(w, h.name+"_count", h.labelNames, labelValues, float64(count))
+	})
+}
+
+type Histogram struct {
+	mux         sync.Mutex
+	upperBounds []float64
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for i, upperBound := range h.upperBounds {
+		if v <= upperBound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	return append([]uint64(nil), h.counts...), h.count, h.sum
+}
+
+func writeSample(w io.Writer, name string, labelNames []string, labelValues []string, v float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+
+	if len(labelNames) > 0 {
+		b.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labelName)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabelValue(labelValues[i]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(labelValue string) string {
+	return labelValueReplacer.Replace(labelValue)
+}
@@ -0,0 +1,81 @@
+package metrics_test
+
+import (
+	"bytes"
+	"leaderboard/app/leaderboard/metrics"
+	"testing"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+func TestMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "metrics")
+}
+
+// write answers what the collector writes.
+func write(collector metrics.Collector) string {
+	var buffer bytes.Buffer
+	Expect(collector.Write(&buffer)).To(Succeed())
+
+	return buffer.String()
+}
+
+var _ = Describe("the exposition format", func() {
+	It("writes counters sorted by their label values", func() {
+		counter := metrics.NewCounterVec("test_events_total", "Events seen.", "kind")
+		counter.WithLabelValues("b").Inc()
+		counter.WithLabelValues("a").Add(2.5)
+
+		Expect(write(counter)).To(Equal(
+			"# HELP test_events_total Events seen.\n" +
+				"# TYPE test_events_total counter\n" +
+				`test_events_total{kind="a"} 2.5` + "\n" +
+				`test_events_total{kind="b"} 1` + "\n"))
+	})
+
+	It("writes samples without labels without braces", func() {
+		gauge := metrics.NewGaugeVec("test_size", "Size.")
+		gauge.WithLabelValues().Set(3)
+
+		Expect(write(gauge)).To(HaveSuffix("\ntest_size 3\n"))
+	})
+
+	It("escapes backslashes, quotes and newlines in label values", func() {
+		counter := metrics.NewCounterVec("test_paths_total", "Paths.", "path")
+		counter.WithLabelValues(`C:\dir "quoted"` + "\nnext").Inc()
+
+		Expect(write(counter)).To(HaveSuffix(`test_paths_total{path="C:\\dir \"quoted\"\nnext"} 1` + "\n"))
+	})
+
+	It("escapes backslashes and newlines in the help, but not quotes", func() {
+		counter := metrics.NewCounterVec("test_help_total", `a \ "b"`+"\nc")
+
+		Expect(write(counter)).To(HavePrefix(`# HELP test_help_total a \\ "b"\nc` + "\n"))
+	})
+
+	It("writes cumulative buckets up to +Inf along with the sum and the count", func() {
+		histogram := metrics.NewHistogramVec("test_seconds", "Durations.", []float64{1, 0.125, 0.5}, "route")
+		for _, v := range []float64{0.0625, 0.25, 0.25, 0.75, 2} {
+			histogram.WithLabelValues("/a").Observe(v)
+		}
+
+		Expect(write(histogram)).To(Equal(
+			"# HELP test_seconds Durations.\n" +
+				"# TYPE test_seconds histogram\n" +
+				`test_seconds_bucket{route="/a",le="0.125"} 1` + "\n" +
+				`test_seconds_bucket{route="/a",le="0.5"} 3` + "\n" +
+				`test_seconds_bucket{route="/a",le="1"} 4` + "\n" +
+				`test_seconds_bucket{route="/a",le="+Inf"} 5` + "\n" +
+				`test_seconds_sum{route="/a"} 3.3125` + "\n" +
+				`test_seconds_count{route="/a"} 5` + "\n"))
+	})
+
+	It("writes the samples a gauge func collects", func() {
+		gauge := metrics.NewGaugeFunc("test_members", "Members.", []string{"board"}, func() ([]*metrics.GaugeSample, error) {
+			return []*metrics.GaugeSample{{LabelValues: []string{"GLOBAL"}, Value: 2}}, nil
+		})
+
+		Expect(write(gauge)).To(HaveSuffix("\n" + `test_members{board="GLOBAL"} 2` + "\n"))
+	})
+})
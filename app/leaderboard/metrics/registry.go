@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefaultRegistry = NewRegistry()
+
+type Registry struct {
+	collectors    map[string]Collector
+	collectorsMux sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]Collector{}}
+}
+
+func (r *Registry) Register(collector Collector) error {
+	r.collectorsMux.Lock()
+	defer r.collectorsMux.Unlock()
+
+	if _, ok := r.collectors[collector.Name()]; ok {
+		return fmt.Errorf("metric %s is already registered", collector.Name())
+	}
+
+	r.collectors[collector.Name()] = collector
+
+	return nil
+}
+
+func (r *Registry) MustRegister(collectors ...Collector) {
+	for _, collector := range collectors {
+		if err := r.Register(collector); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WriteTo writes every metric sorted by name. A collector which fails is
+// left out of the output, so the rest of the metrics are still exposed.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.collectorsMux.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.collectorsMux.RUnlock()
+
+	sort.Strings(names)
+
+	var written int64
+	var buffer bytes.Buffer
+	for _, name := range names {
+		r.collectorsMux.RLock()
+		collector := r.collectors[name]
+		r.collectorsMux.RUnlock()
+
+		buffer.Reset()
+		if err := collector.Write(&buffer); err != nil {
+			log.Error(err)
+			continue
+		}
+
+		n, err := buffer.WriteTo(w)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func Handler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		if _, err := registry.WriteTo(w); err != nil {
+			log.Error(err)
+		}
+	})
+}
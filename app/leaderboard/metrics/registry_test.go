@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"leaderboard/app/leaderboard/metrics"
+	"net/http"
+	"net/http/httptest"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the registry", func() {
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		registry = metrics.NewRegistry()
+	})
+
+	It("writes the metrics sorted by name", func() {
+		second := metrics.NewGaugeVec("test_b", "B.")
+		second.WithLabelValues().Set(2)
+		first := metrics.NewGaugeVec("test_a", "A.")
+		first.WithLabelValues().Set(1)
+		registry.MustRegister(second, first)
+
+		var buffer bytes.Buffer
+		written, err := registry.WriteTo(&buffer)
+		Expect(err).To(BeNil())
+		Expect(written).To(BeEquivalentTo(buffer.Len()))
+		Expect(buffer.String()).To(Equal(
+			"# HELP test_a A.\n# TYPE test_a gauge\ntest_a 1\n" +
+				"# HELP test_b B.\n# TYPE test_b gauge\ntest_b 2\n"))
+	})
+
+	It("refuses a second metric of a name", func() {
+		registry.MustRegister(metrics.NewGaugeVec("test_a", "A."))
+
+		Expect(registry.Register(metrics.NewCounterVec("test_a", "A."))).To(MatchError("metric test_a is already registered"))
+	})
+
+	It("leaves out the metrics which fail to collect", func() {
+		registry.MustRegister(
+			metrics.NewGaugeFunc("test_failing", "Failing.", nil, func() ([]*metrics.GaugeSample, error) {
+				return nil, errors.New("redis is down")
+			}),
+			metrics.NewGaugeFunc("test_working", "Working.", nil, func() ([]*metrics.GaugeSample, error) {
+				return []*metrics.GaugeSample{{Value: 1}}, nil
+			}),
+		)
+
+		var buffer bytes.Buffer
+		_, err := registry.WriteTo(&buffer)
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(Equal("# HELP test_working Working.\n# TYPE test_working gauge\ntest_working 1\n"))
+	})
+
+	It("serves the metrics in the text format", func() {
+		registry.MustRegister(metrics.NewCounterVec("test_total", "Total."))
+
+		response := httptest.NewRecorder()
+		metrics.Handler(registry).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(response.Header().Get("Content-Type")).To(Equal(metrics.ContentType))
+		Expect(response.Body.String()).To(Equal("# HELP test_total Total.\n# TYPE test_total counter\n"))
+	})
+})
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+// Authenticate resolves the principal of every request and keeps it in the
+// request context. Requests without credentials pass as anonymous, routes
+// that need a principal are guarded with RequireRole.
+func Authenticate(authenticator api.Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := authenticator.Authenticate(c.Request().Header)
+			if err != nil {
+				return NewAuthError(c, err)
+			}
+
+			if principal != nil {
+				request := c.Request()
+				c.SetRequest(request.WithContext(services.WithPrincipal(request.Context(), principal)))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func RequireRole(scope string, roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := services.Authorize(Principal(c), scope, roles...); err != nil {
+				return NewAuthError(c, err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func Principal(c echo.Context) *api.Principal {
+	return services.PrincipalFromContext(c.Request().Context())
+}
+
+// NewAuthError maps authentication and authorization errors to 401 and 403.
+func NewAuthError(c echo.Context, err error) error {
+	if errors.Is(err, services.ErrForbidden) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+
+	if errors.Is(err, services.ErrUnauthenticated) || errors.Is(err, services.ErrInvalidCredentials) {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	return err
+}
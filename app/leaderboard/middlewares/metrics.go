@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics observes the latency of every request. Routes are labelled with
+// their pattern rather than the requested path, to keep the number of
+// series bounded.
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			// the error is answered here, so its status is the one answered
+			if err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+
+			route := c.Path()
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+
+			metrics.HTTPRequestDuration.
+				WithLabelValues(c.Request().Method, route, strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
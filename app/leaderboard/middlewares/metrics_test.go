@@ -0,0 +1,74 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/middlewares"
+	"net/http"
+	"net/http/httptest"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the metrics middleware", func() {
+	var e *echo.Echo
+
+	// observed answers how many requests to the route were observed with
+	// the status
+	observed := func(route string, status int) string {
+		output := new(bytes.Buffer)
+		Expect(metrics.HTTPRequestDuration.Write(output)).To(Succeed())
+
+		series := fmt.Sprintf(`leaderboard_http_request_duration_seconds_count{method="GET",route="%s",status="%d"} `, route, status)
+		for _, line := range bytes.Split(output.Bytes(), []byte("\n")) {
+			if bytes.HasPrefix(line, []byte(series)) {
+				return string(bytes.TrimPrefix(line, []byte(series)))
+			}
+		}
+
+		return "0"
+	}
+
+	BeforeEach(func() {
+		e = newEcho()
+		e.Use(middlewares.Metrics())
+		e.GET("/metrics-test/:kind", func(c echo.Context) error {
+			switch c.Param("kind") {
+			case "missing":
+				return fmt.Errorf("reading the user: %w", api.NewError(api.ErrNotFound, "user alice is missing"))
+			case "conflict":
+				return api.NewError(api.ErrConflict, "the profile changed")
+			case "slow":
+				return fmt.Errorf("reading the board: %w", context.DeadlineExceeded)
+			case "echo":
+				return echo.NewHTTPError(http.StatusTooManyRequests, "slow down")
+			case "failed":
+				return errors.New("dial tcp: connection refused")
+			default:
+				return c.NoContent(http.StatusNoContent)
+			}
+		})
+	})
+
+	DescribeTable("the status of the errors",
+		func(kind string, status int) {
+			before := observed("/metrics-test/:kind", status)
+
+			response := serve(e, httptest.NewRequest(http.MethodGet, "/metrics-test/"+kind, nil))
+			Expect(response.Code).To(Equal(status))
+			Expect(observed("/metrics-test/:kind", status)).NotTo(Equal(before))
+		},
+		Entry("a typed not found error", "missing", http.StatusNotFound),
+		Entry("a conflict", "conflict", http.StatusConflict),
+		Entry("a deadline exceeded", "slow", http.StatusGatewayTimeout),
+		Entry("an echo error", "echo", http.StatusTooManyRequests),
+		Entry("an error of no kind", "failed", http.StatusInternalServerError),
+		Entry("no error", "ok", http.StatusNoContent),
+	)
+})
@@ -0,0 +1,57 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/handlers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var mRedis *miniredis.Miniredis
+
+func TestMiddlewares(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "middlewares")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	mRedis, err = miniredis.Run()
+	Expect(err).To(BeNil())
+})
+
+var _ = AfterSuite(func() {
+	mRedis.Close()
+})
+
+var _ = AfterEach(func() {
+	mRedis.FlushAll()
+})
+
+// newEcho answers errors the way the service does.
+func newEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.ErrorHandler
+
+	return e
+}
+
+func serve(e *echo.Echo, request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func errorResponse(recorder *httptest.ResponseRecorder) *api.ErrorResponse {
+	response := new(api.ErrorResponse)
+	Expect(json.Unmarshal(recorder.Body.Bytes(), response)).To(Succeed())
+
+	return response
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/leaderboard/services"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimitRule gives the requests it matches a budget of their own, a rule
+// without a budget leaves them unlimited.
+type RateLimitRule struct {
+	Group  string
+	Budget *services.RateLimitBudget
+	Match  func(c echo.Context) bool
+}
+
+// RateLimit spends a token from the budget of the first rule matching the
+// request, requests matching no rule are not limited. Limits are kept per
+// API key, per authenticated user or per client IP, in that order.
+func RateLimit(limiter *services.RateLimiter, rules ...*RateLimitRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rule := matchRule(c, rules)
+			if rule == nil || rule.Budget == nil {
+				return next(c)
+			}
+
+			result, err := limiter.Take(c.Request().Context(), rule.Group, rateLimitKey(c), rule.Budget)
+			if err != nil {
+				// an unavailable limiter must not take the service down with it
+				log.Error(err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.FormatInt(result.Limit, 10))
+			header.Set(HeaderRateLimitRemaining, strconv.FormatInt(result.Remaining, 10))
+			header.Set(HeaderRateLimitReset, strconv.FormatInt(ceilSeconds(result.Reset), 10))
+			header.Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d", rule.Budget.Limit, ceilSeconds(rule.Budget.Period)))
+
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func PathPrefix(prefix string) func(c echo.Context) bool {
+	return func(c echo.Context) bool {
+		return strings.HasPrefix(c.Path(), prefix)
+	}
+}
+
+func Method(method string) func(c echo.Context) bool {
+	return func(c echo.Context) bool {
+		return c.Request().Method == method
+	}
+}
+
+func matchRule(c echo.Context, rules []*RateLimitRule) *RateLimitRule {
+	for _, rule := range rules {
+		if rule.Match(c) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// rateLimitKey never keeps the API key itself in redis, only its hash.
+func rateLimitKey(c echo.Context) string {
+	if key := c.Request().Header.Get(services.HeaderAPIKey); len(key) > 0 {
+		return "key:" + services.HashAPIKey(key)
+	}
+
+	if principal := Principal(c); principal != nil {
+		return "user:" + principal.Subject
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,116 @@
+package middlewares_test
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/middlewares"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the rate limit middleware", func() {
+	var e *echo.Echo
+
+	limit := func(address string) {
+		redisService := services.NewRedisService(redis.NewClient(&redis.Options{Addr: address, MaxRetries: -1}), "middlewares")
+
+		e = newEcho()
+		e.Use(middlewares.RateLimit(
+			services.NewRateLimiter(redisService),
+			&middlewares.RateLimitRule{Match: middlewares.Path("/healthz")},
+			&middlewares.RateLimitRule{
+				Group:  "submit",
+				Budget: &services.RateLimitBudget{Limit: 2, Period: time.Hour},
+				Match:  middlewares.PathPrefix("/score"),
+			},
+		))
+
+		ok := func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		}
+		e.GET("/healthz", ok)
+		e.POST("/score/submit", ok)
+	}
+
+	submit := func(key string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/score/submit", nil)
+		if len(key) > 0 {
+			request.Header.Set(services.HeaderAPIKey, key)
+		}
+
+		return serve(e, request)
+	}
+
+	BeforeEach(func() {
+		limit(mRedis.Addr())
+	})
+
+	It("reports the budget on every response", func() {
+		for remaining := 1; remaining >= 0; remaining-- {
+			response := submit("k3y")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get(middlewares.HeaderRateLimitLimit)).To(Equal("2"))
+			Expect(response.Header().Get(middlewares.HeaderRateLimitRemaining)).To(Equal(strconv.Itoa(remaining)))
+			Expect(response.Header().Get(middlewares.HeaderRateLimitPolicy)).To(Equal("2;w=3600"))
+			Expect(response.Header().Get("Retry-After")).To(BeEmpty())
+		}
+	})
+
+	It("answers 429 with Retry-After once the budget is spent", func() {
+		submit("k3y")
+		submit("k3y")
+
+		response := submit("k3y")
+		Expect(response.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(response.Header().Get(middlewares.HeaderRateLimitRemaining)).To(Equal("0"))
+		Expect(response.Header().Get("Retry-After")).To(Equal("1800"))
+		Expect(response.Header().Get(middlewares.HeaderRateLimitReset)).To(Equal("3600"))
+		Expect(errorResponse(response).Code).To(Equal("too_many_requests"))
+
+		// the budget is kept per key
+		Expect(submit("other").Code).To(Equal(http.StatusOK))
+		Expect(submit("").Code).To(Equal(http.StatusOK))
+	})
+
+	It("refills the budget on the clock of redis", func() {
+		submit("k3y")
+		submit("k3y")
+		Expect(submit("k3y").Code).To(Equal(http.StatusTooManyRequests))
+
+		mRedis.SetTime(time.Now().Add(31 * time.Minute))
+		defer mRedis.SetTime(time.Time{})
+
+		Expect(submit("k3y").Code).To(Equal(http.StatusOK))
+	})
+
+	It("leaves the requests of rules without a budget alone", func() {
+		for i := 0; i < 3; i++ {
+			response := serve(e, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get(middlewares.HeaderRateLimitLimit)).To(BeEmpty())
+		}
+	})
+
+	When("redis is unavailable", func() {
+		BeforeEach(func() {
+			unavailable := mRedis.Addr()
+			mRedis.Close()
+			limit(unavailable)
+		})
+
+		AfterEach(func() {
+			Expect(mRedis.Restart()).To(Succeed())
+		})
+
+		It("lets the requests through", func() {
+			response := submit("k3y")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get(middlewares.HeaderRateLimitLimit)).To(BeEmpty())
+		})
+	})
+})
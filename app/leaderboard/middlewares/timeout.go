@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/api"
+	"net/http"
+	"time"
+)
+
+// TimeoutRule gives the requests it matches a deadline of their own, a zero
+// timeout leaves the request without a deadline.
+type TimeoutRule struct {
+	Timeout time.Duration
+	Match   func(c echo.Context) bool
+}
+
+// Timeout puts a deadline on the context of the request, from the first rule
+// matching it or the default timeout. Every redis call made for the request
+// is cancelled once the deadline passes, and the request is answered with
+// 504 unless the handler has already written a response.
+func Timeout(defaultTimeout time.Duration, rules ...*TimeoutRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			timeout := defaultTimeout
+			for _, rule := range rules {
+				if rule.Match(c) {
+					timeout = rule.Timeout
+					break
+				}
+			}
+
+			if timeout <= 0 {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if ctx.Err() == context.DeadlineExceeded && !c.Response().Committed {
+				return echo.NewHTTPError(http.StatusGatewayTimeout, &api.ErrorResponse{
+					Code:    "deadline_exceeded",
+					Message: fmt.Sprintf("request did not complete within %s", timeout),
+				})
+			}
+
+			return err
+		}
+	}
+}
+
+func Path(path string) func(c echo.Context) bool {
+	return func(c echo.Context) bool {
+		return c.Path() == path
+	}
+}
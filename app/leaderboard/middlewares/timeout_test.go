@@ -0,0 +1,112 @@
+package middlewares_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"leaderboard/app/leaderboard/middlewares"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the timeout middleware", func() {
+	const (
+		defaultBudget = 100 * time.Millisecond
+		submitBudget  = time.Second
+		readBudget    = 50 * time.Millisecond
+	)
+
+	var e *echo.Echo
+
+	// budget answers the time the request has left, or -1 without a
+	// deadline
+	budget := func(c echo.Context) error {
+		deadline, ok := c.Request().Context().Deadline()
+		if !ok {
+			return c.JSON(http.StatusOK, -1)
+		}
+
+		return c.JSON(http.StatusOK, time.Until(deadline))
+	}
+
+	// wait outruns every budget unless its context is done
+	wait := func(c echo.Context) error {
+		select {
+		case <-c.Request().Context().Done():
+			return c.Request().Context().Err()
+		case <-time.After(10 * time.Second):
+			return c.NoContent(http.StatusOK)
+		}
+	}
+
+	remaining := func(method string, path string) time.Duration {
+		response := serve(e, httptest.NewRequest(method, path, nil))
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var remaining time.Duration
+		Expect(json.Unmarshal(response.Body.Bytes(), &remaining)).To(Succeed())
+		return remaining
+	}
+
+	BeforeEach(func() {
+		e = newEcho()
+		e.Use(middlewares.Timeout(
+			defaultBudget,
+			&middlewares.TimeoutRule{Match: middlewares.PathPrefix("/events")},
+			&middlewares.TimeoutRule{Timeout: submitBudget, Match: middlewares.PathPrefix("/score")},
+			&middlewares.TimeoutRule{Timeout: readBudget, Match: middlewares.Method(http.MethodGet)},
+		))
+
+		e.GET("/events/:board", budget)
+		e.POST("/score/submit", budget)
+		e.GET("/leaderboard", budget)
+		e.POST("/user/create", budget)
+		e.GET("/slow", wait)
+		e.GET("/written", func(c echo.Context) error {
+			if err := c.NoContent(http.StatusNoContent); err != nil {
+				return err
+			}
+
+			<-c.Request().Context().Done()
+			return c.Request().Context().Err()
+		})
+	})
+
+	It("answers 504 with an error response when the handler outruns its budget", func() {
+		started := time.Now()
+		response := serve(e, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		Expect(time.Since(started)).To(BeNumerically("<", time.Second))
+
+		Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+		Expect(errorResponse(response).Code).To(Equal("deadline_exceeded"))
+		Expect(errorResponse(response).Message).To(Equal("request did not complete within 50ms"))
+	})
+
+	It("leaves the responses which were written before the deadline", func() {
+		response := serve(e, httptest.NewRequest(http.MethodGet, "/written", nil))
+		Expect(response.Code).To(Equal(http.StatusNoContent))
+		Expect(response.Body.Len()).To(BeZero())
+	})
+
+	It("gives the requests the budget of the first rule matching them", func() {
+		Expect(remaining(http.MethodPost, "/score/submit")).To(BeNumerically("~", submitBudget, readBudget))
+		Expect(remaining(http.MethodGet, "/leaderboard")).To(BeNumerically("~", readBudget, readBudget/2))
+		Expect(remaining(http.MethodPost, "/user/create")).To(BeNumerically("~", defaultBudget, readBudget/2))
+		Expect(remaining(http.MethodGet, "/events/global")).To(BeEquivalentTo(-1))
+	})
+
+	It("keeps the deadline of the caller when it is sooner", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		response := serve(e, httptest.NewRequest(http.MethodPost, "/score/submit", nil).WithContext(ctx))
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var remaining time.Duration
+		Expect(json.Unmarshal(response.Body.Bytes(), &remaining)).To(Succeed())
+		Expect(remaining).To(BeNumerically("<=", 10*time.Millisecond))
+	})
+})
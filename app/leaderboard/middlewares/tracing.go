@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"leaderboard/app/leaderboard/tracing"
+)
+
+// Tracing starts a server span for every request, continuing the trace of
+// the caller when the request carries a trace context. The span is put in
+// the context of the request, so the services called by the handler add
+// their spans to it.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+
+			route := c.Path()
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+
+			ctx := global.TextMapPropagator().Extract(request.Context(), request.Header)
+			ctx, span := tracing.Tracer().Start(ctx, request.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("leaderboard", route, request)...),
+			)
+			defer span.End()
+
+			c.SetRequest(request.WithContext(ctx))
+
+			err := next(c)
+
+			// the error is answered here, so its status is the one answered
+			if err != nil {
+				c.Error(err)
+				span.RecordError(ctx, err)
+			}
+			status := c.Response().Status
+
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+
+			return err
+		}
+	}
+}
@@ -1,68 +1,123 @@
 package leaderboard
 
 import (
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
+	"leaderboard/app/leaderboard/config"
 	"os"
-	"strconv"
-	"strings"
+	"time"
+	// scheduler_time_zone is loaded on images without zone info as well
+	_ "time/tzdata"
 )
 
-const DefaultLeaderboardPrefixKey = "USER_RANKING_"
-
 type Properties struct {
-	HttpPort              int
-	MysqlConnectionString string
-	RedisHost             string
-	RedisPassword         string
-	RedisDB               int
-	RedisCluster          bool
-	LeaderboardKeyPrefix  string
+	HttpPort              int           `name:"http_port" default:"1323" validate:"min=1,max=65535" usage:"port of the HTTP API"`
+	GrpcPort              int           `name:"grpc_port" default:"50051" validate:"min=1,max=65535" usage:"port of the gRPC API"`
+	MysqlConnectionString string        `name:"mysql_connection_string" secret:"true" usage:"checked for readiness when given"`
+	RedisHost             []string      `name:"redis_host" default:"localhost:6379" validate:"min=1" usage:"redis address, or the seed addresses of a cluster, or the addresses of the sentinels"`
+	RedisUsername         string        `name:"redis_username" usage:"ACL user, the default user when not given"`
+	RedisPassword         string        `name:"redis_password" secret:"true"`
+	RedisDB               int           `name:"redis_db" default:"0" validate:"min=0" usage:"database, clusters only have database 0"`
+	RedisCluster          bool          `name:"redis_cluster" default:"false"`
+	RedisSentinelMaster   string        `name:"redis_sentinel_master" usage:"name of the master monitored by the sentinels, enables failover"`
+	RedisSentinelPassword string        `name:"redis_sentinel_password" secret:"true"`
+	RedisTLS              bool          `name:"redis_tls" default:"false"`
+	RedisTLSCAFile        string        `name:"redis_tls_ca_file" usage:"PEM file of the CA verifying redis, the system roots when not given"`
+	RedisTLSCertFile      string        `name:"redis_tls_cert_file" usage:"PEM file of the client certificate"`
+	RedisTLSKeyFile       string        `name:"redis_tls_key_file" usage:"PEM file of the client key"`
+	RedisTLSServerName    string        `name:"redis_tls_server_name" usage:"name verified on the certificate of redis, the host when not given"`
+	RedisTLSSkipVerify    bool          `name:"redis_tls_insecure_skip_verify" default:"false"`
+	RedisPoolSize         int           `name:"redis_pool_size" default:"64" validate:"min=1" usage:"connections per redis node"`
+	RedisMinIdleConns     int           `name:"redis_min_idle_conns" default:"0" validate:"min=0"`
+	RedisDialTimeout      time.Duration `name:"redis_dial_timeout" default:"5s" validate:"gt=0"`
+	RedisReadTimeout      time.Duration `name:"redis_read_timeout" default:"3s" validate:"gt=0"`
+	RedisWriteTimeout     time.Duration `name:"redis_write_timeout" default:"3s" validate:"gt=0"`
+	RedisPoolTimeout      time.Duration `name:"redis_pool_timeout" default:"4s" validate:"gt=0" usage:"wait for a free connection before failing"`
+	KeyNamespace          string        `name:"key_namespace" default:"lb" validate:"required,excludesall={}" usage:"hash tag of the board and profile keys"`
+	LeaderboardKeyPrefix  string        `name:"leaderboard_key_prefix" default:"USER_RANKING_" validate:"required" usage:"prefix of the boards of the legacy key layout, read by migrate-keys"`
+	ScoreMode             string        `name:"score_mode" default:"replace" validate:"oneof=replace best increment" usage:"replace keeps the latest score, best the highest one, increment adds up the scores"`
+	RankHistoryInterval   time.Duration `name:"rank_history_interval" default:"1h" validate:"gt=0"`
+	RankHistoryTTL        time.Duration `name:"rank_history_ttl" default:"720h" validate:"gt=0"`
+	LiveFeedInterval      time.Duration `name:"live_feed_interval" default:"250ms" validate:"gt=0"`
+	ScoreStreamMaxLen     int           `name:"score_stream_max_len" default:"10000" validate:"min=1"`
+	WebhookWorkers        int           `name:"webhook_workers" default:"4" validate:"min=1"`
+	WebhookMaxAttempts    int           `name:"webhook_max_attempts" default:"5" validate:"min=1"`
+	WebhookRetryBackoff   time.Duration `name:"webhook_retry_backoff" default:"1s" validate:"gt=0"`
+	WebhookTimeout        time.Duration `name:"webhook_timeout" default:"5s" validate:"gt=0"`
+	JobWorkers            int           `name:"job_workers" default:"2" validate:"min=1" usage:"job runs the instance works on at once"`
+	JobLeaseTTL           time.Duration `name:"job_lease_ttl" default:"30s" validate:"gt=0" usage:"how long a run stays with an instance which stopped renewing its lease"`
+	JobPollInterval       time.Duration `name:"job_poll_interval" default:"1s" validate:"gt=0" usage:"how often the instance looks for queued job runs"`
+	ImportDirectory       string        `name:"import_directory" default:"imports" validate:"required" usage:"directory of the files of the import job, instances which run imports share it"`
+	ImportMaxUploadSize   int           `name:"import_max_upload_size" default:"1073741824" validate:"min=1" usage:"most bytes of a file uploaded to the import job"`
+	SnapshotDirectory     string        `name:"snapshot_directory" default:"snapshots" validate:"required" usage:"directory the snapshot job writes the snapshots of the key namespace to"`
+	SchedulesFile         string        `name:"schedules_file" usage:"JSON file of the job schedules, nothing is scheduled when not given"`
+	SchedulerTimeZone     string        `name:"scheduler_time_zone" default:"UTC" validate:"required" usage:"time zone of the cron expressions of the schedules"`
+	SchedulerInterval     time.Duration `name:"scheduler_interval" default:"1s" validate:"gt=0" usage:"how often the leading instance looks for due schedules"`
+	SchedulerLeaseTTL     time.Duration `name:"scheduler_lease_ttl" default:"15s" validate:"gtfield=SchedulerInterval" usage:"how long the scheduler stays with a leader which stopped renewing its lease"`
+	AuthProviders         []string      `name:"auth_providers" default:"api_key,jwt" validate:"min=1,dive,oneof=api_key jwt none"`
+	AuthAPIKeysFile       string        `name:"auth_api_keys_file"`
+	AuthJWKSFile          string        `name:"auth_jwks_file"`
+	AuthJWTIssuer         string        `name:"auth_jwt_issuer"`
+	AuthJWTAudience       string        `name:"auth_jwt_audience"`
+	RateLimitEnabled      bool          `name:"rate_limit_enabled" default:"true"`
+	RateLimitSubmit       int           `name:"rate_limit_submit" default:"60" validate:"min=1"`
+	RateLimitSubmitPeriod time.Duration `name:"rate_limit_submit_period" default:"1m" validate:"gt=0"`
+	RateLimitRead         int           `name:"rate_limit_read" default:"600" validate:"min=1"`
+	RateLimitReadPeriod   time.Duration `name:"rate_limit_read_period" default:"1m" validate:"gt=0"`
+	ShutdownGracePeriod   time.Duration `name:"shutdown_grace_period" default:"25s" validate:"gt=0"`
+	RequestTimeout        time.Duration `name:"request_timeout" default:"10s" validate:"gt=0"`
+	RequestTimeoutSubmit  time.Duration `name:"request_timeout_submit" default:"3s" validate:"gt=0"`
+	RequestTimeoutRead    time.Duration `name:"request_timeout_read" default:"2s" validate:"gt=0"`
+	TracingExporter       string        `name:"tracing_exporter" default:"none" validate:"oneof=none stdout otlp"`
+	TracingOTLPEndpoint   string        `name:"tracing_otlp_endpoint" default:"localhost:55680"`
+	TracingServiceName    string        `name:"tracing_service_name" default:"leaderboard" validate:"required"`
 }
 
-func LoadProperties() (*Properties, error) {
+// LoadProperties reads the properties from their defaults, the
+// configuration file, the .env file and the environment, and the command
+// line arguments, in increasing order of precedence.
+func LoadProperties(args []string) (*Properties, *config.Options, error) {
 	if _, err := os.Stat(".env"); !os.IsNotExist(err) {
 		err := godotenv.Load()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	p := &Properties{
-		HttpPort:              getInteger("HTTP_PORT", 1323),
-		MysqlConnectionString: os.Getenv("MYSQL_CONNECTION_STRING"),
-		RedisHost:             os.Getenv("REDIS_HOST"),
-		RedisPassword:         os.Getenv("REDIS_PASSWORD"),
-		RedisDB:               getInteger("REDIS_DB", 0),
-		RedisCluster:          getBool("REDIS_CLUSTER", false),
-		LeaderboardKeyPrefix:  getOrDefault("LEADERBOARD_KEY_PREFIX", DefaultLeaderboardPrefixKey),
+	p := new(Properties)
+	options, err := config.Load(p, args, os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return p, nil
+	return p, options, nil
 }
 
-func getOrDefault(key string, defaultValue string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists || len(value) == 0 {
-		return defaultValue
+func (p *Properties) Validate() error {
+	if p.RedisCluster && p.RedisDB != 0 {
+		return errors.New("redis_db must be 0 in cluster mode")
 	}
 
-	return value
-}
+	if p.RedisCluster && len(p.RedisSentinelMaster) > 0 {
+		return errors.New("redis_cluster and redis_sentinel_master can not be used together")
+	}
 
-func getInteger(key string, defaultValue int) int {
-	integerValue, err := strconv.Atoi(os.Getenv(key))
-	if err != nil {
-		return defaultValue
+	if !p.RedisCluster && len(p.RedisSentinelMaster) == 0 && len(p.RedisHost) > 1 {
+		return errors.New("redis_host takes multiple addresses in cluster or sentinel mode only")
 	}
 
-	return integerValue
-}
+	if (len(p.RedisTLSCertFile) > 0) != (len(p.RedisTLSKeyFile) > 0) {
+		return errors.New("redis_tls_cert_file and redis_tls_key_file must be given together")
+	}
+
+	if !p.RedisTLS && (len(p.RedisTLSCAFile) > 0 || len(p.RedisTLSCertFile) > 0 || p.RedisTLSSkipVerify) {
+		return errors.New("redis_tls must be enabled to use its files")
+	}
 
-func getBool(key string, defaultValue bool) bool {
-	strValue := os.Getenv(key)
-	if len(strValue) == 0 {
-		return defaultValue
+	if _, err := time.LoadLocation(p.SchedulerTimeZone); err != nil {
+		return fmt.Errorf("scheduler_time_zone: %w", err)
 	}
 
-	return strings.ToLower(strValue) == "true"
+	return nil
 }
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+)
+
+// UnaryAuthInterceptor authenticates calls with the same authenticators as
+// the HTTP API, credentials are read from the call metadata.
+func UnaryAuthInterceptor(authenticator api.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func StreamAuthInterceptor(authenticator api.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// contextStream replaces the context of a stream, interceptors use it to
+// hand values down to the handler.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, authenticator api.Authenticator) (context.Context, error) {
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	principal, err := authenticator.Authenticate(header)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	if principal != nil {
+		ctx = services.WithPrincipal(ctx, principal)
+	}
+
+	return ctx, nil
+}
+
+func authError(err error) error {
+	if errors.Is(err, services.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, services.ErrUnauthenticated) || errors.Is(err, services.ErrInvalidCredentials) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,69 @@
+package rpc_test
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"leaderboard/app/api"
+	"leaderboard/app/api/leaderboardpb"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the authentication of the calls", func() {
+	var s *server
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "server", KeySha256: services.HashAPIKey("server"), Role: services.RoleGameServer},
+			{Id: "alice", KeySha256: services.HashAPIKey("alice"), Role: services.RolePlayer},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator, nil)
+
+		for _, userId := range []string{"alice", "bob"} {
+			_, err := s.userService.Create(ctx, &api.UserProfile{UserId: userId, DisplayName: userId, Country: "TR"})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	AfterEach(func() {
+		s.close()
+	})
+
+	submit := func(ctx context.Context, userId string) error {
+		_, err := s.client.SubmitScore(ctx, &leaderboardpb.SubmitScoreRequest{
+			Submission: &leaderboardpb.ScoreSubmission{UserId: userId, Score: 10, Timestamp: time.Now().Unix()},
+		})
+		return err
+	}
+
+	DescribeTable("the rejected submissions",
+		func(callCtx context.Context, userId string, code codes.Code) {
+			Expect(status.Code(submit(callCtx, userId))).To(Equal(code))
+
+			profile, err := s.userService.GetByID(ctx, userId)
+			Expect(err).To(BeNil())
+			Expect(profile.Points).To(BeZero())
+		},
+		Entry("without credentials", ctx, "alice", codes.Unauthenticated),
+		Entry("with an unknown key", withAPIKey("mallory"), "alice", codes.Unauthenticated),
+		Entry("of a player for another user", withAPIKey("alice"), "bob", codes.PermissionDenied),
+	)
+
+	It("accepts the submissions of a player for themselves and of a game server", func() {
+		Expect(submit(withAPIKey("alice"), "alice")).To(Succeed())
+		Expect(submit(withAPIKey("server"), "bob")).To(Succeed())
+	})
+
+	It("rejects streams with an unknown key", func() {
+		stream, err := s.client.SubscribeRank(withAPIKey("mallory"), &leaderboardpb.SubscribeRankRequest{UserId: "alice"})
+		Expect(err).To(BeNil())
+
+		_, err = stream.Recv()
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+})
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"leaderboard/app/api"
+	"leaderboard/app/api/leaderboardpb"
+)
+
+func toScoreSubmission(submission *leaderboardpb.ScoreSubmission) *api.ScoreSubmission {
+	return &api.ScoreSubmission{
+		Score:     submission.GetScore(),
+		UserId:    submission.GetUserId(),
+		Timestamp: submission.GetTimestamp(),
+	}
+}
+
+func fromScoreEvents(events []*api.ScoreEvent) []*leaderboardpb.ScoreEvent {
+	converted := make([]*leaderboardpb.ScoreEvent, 0, len(events))
+	for _, event := range events {
+		converted = append(converted, &leaderboardpb.ScoreEvent{
+			UserId:       event.UserId,
+			Board:        event.Board,
+			Score:        event.Score,
+			PreviousRank: event.PreviousRank,
+			Rank:         event.Rank,
+			Timestamp:    event.Timestamp,
+		})
+	}
+
+	return converted
+}
+
+func fromLeaderboardRows(rows []*api.LeaderboardRow) []*leaderboardpb.LeaderboardRow {
+	converted := make([]*leaderboardpb.LeaderboardRow, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, &leaderboardpb.LeaderboardRow{
+			Rank:        row.Rank,
+			Points:      row.Points,
+			DisplayName: row.DisplayName,
+			Country:     row.Country,
+		})
+	}
+
+	return converted
+}
+
+func toUserProfile(profile *leaderboardpb.UserProfile) *api.UserProfile {
+	return &api.UserProfile{
+		UserId:      profile.GetUserId(),
+		DisplayName: profile.GetDisplayName(),
+		Points:      profile.GetPoints(),
+		Rank:        profile.GetRank(),
+		Country:     profile.GetCountry(),
+	}
+}
+
+func fromUserProfile(profile *api.UserProfile) *leaderboardpb.UserProfile {
+	return &leaderboardpb.UserProfile{
+		UserId:      profile.UserId,
+		DisplayName: profile.DisplayName,
+		Points:      profile.Points,
+		Rank:        profile.Rank,
+		Country:     profile.Country,
+	}
+}
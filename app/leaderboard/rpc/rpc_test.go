@@ -0,0 +1,104 @@
+package rpc_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"leaderboard/app/api"
+	"leaderboard/app/api/leaderboardpb"
+	"leaderboard/app/leaderboard/rpc"
+	"leaderboard/app/leaderboard/services"
+	"net"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+const (
+	namespace = "rpc"
+	keyPrefix = "LB_"
+)
+
+var mRedis *miniredis.Miniredis
+
+var ctx = context.Background()
+
+func TestRPC(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "rpc")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	mRedis, err = miniredis.Run()
+	Expect(err).To(BeNil())
+})
+
+var _ = AfterSuite(func() {
+	mRedis.Close()
+})
+
+var _ = AfterEach(func() {
+	mRedis.FlushAll()
+})
+
+// server is the gRPC API as bootstrap builds it, served in memory behind the
+// given authenticator, along with a client and the services its calls use.
+type server struct {
+	client            leaderboardpb.LeaderboardClient
+	userService       *services.UserService
+	leaderboardServer *rpc.LeaderboardServer
+	close             func()
+}
+
+// newServer serves the pages from leaderboardService, from the boards when
+// it is nil.
+func newServer(authenticator api.Authenticator, leaderboardService api.LeaderboardService) *server {
+	redisService := services.NewRedisService(redis.NewClient(&redis.Options{Addr: mRedis.Addr()}), namespace)
+	userService := services.NewUserService(redisService, keyPrefix)
+	if leaderboardService == nil {
+		leaderboardService = services.NewLeaderboardService(userService, redisService, keyPrefix)
+	}
+	scoreService := services.NewScoreService(userService, redisService, services.ScoreModeReplace, nil)
+	liveFeedService := services.NewLiveFeedService(leaderboardService, redisService, 10*time.Millisecond)
+	scoreService.AddListener(liveFeedService)
+	liveFeedService.Start()
+
+	leaderboardServer := rpc.NewLeaderboardServer(services.NewStructValidator(validator.New()), userService, scoreService, leaderboardService, liveFeedService)
+	grpcServer := rpc.NewServer(leaderboardServer, authenticator)
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	conn, err := grpc.DialContext(ctx, "bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	Expect(err).To(BeNil())
+
+	return &server{
+		client:            leaderboardpb.NewLeaderboardClient(conn),
+		userService:       userService,
+		leaderboardServer: leaderboardServer,
+		close: func() {
+			_ = conn.Close()
+			leaderboardServer.Stop()
+			grpcServer.Stop()
+			liveFeedService.Stop()
+		},
+	}
+}
+
+// withAPIKey carries an API key in the metadata of the calls made with the
+// context.
+func withAPIKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, services.HeaderAPIKey, key)
+}
@@ -0,0 +1,273 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"leaderboard/app/api"
+	"leaderboard/app/api/leaderboardpb"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/services"
+	"strings"
+	"sync"
+)
+
+const rankUpdateBufferSize = 16
+
+// LeaderboardServer serves the gRPC API. It shares the services and the
+// validation rules of the HTTP handlers, so both APIs behave the same way.
+type LeaderboardServer struct {
+	leaderboardpb.UnimplementedLeaderboardServer
+	validator          *services.StructValidator
+	userService        *services.UserService
+	scoreService       *services.ScoreService
+	leaderboardService api.LeaderboardService
+	liveFeedService    *services.LiveFeedService
+	stop               chan struct{}
+	stopOnce           sync.Once
+}
+
+func NewLeaderboardServer(
+	validator *services.StructValidator,
+	userService *services.UserService,
+	scoreService *services.ScoreService,
+	leaderboardService api.LeaderboardService,
+	liveFeedService *services.LiveFeedService,
+) *LeaderboardServer {
+	return &LeaderboardServer{
+		validator:          validator,
+		userService:        userService,
+		scoreService:       scoreService,
+		leaderboardService: leaderboardService,
+		liveFeedService:    liveFeedService,
+		stop:               make(chan struct{}),
+	}
+}
+
+// Stop ends the open rank subscriptions, so a graceful stop of the server
+// does not wait for clients which would listen forever.
+func (s *LeaderboardServer) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// NewServer builds a gRPC server with the leaderboard service registered
+// behind the authenticator. Calls are traced before they are authenticated,
+// so rejected calls are traced as well.
+func NewServer(leaderboardServer *LeaderboardServer, authenticator api.Authenticator, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(UnaryTracingInterceptor(), UnaryAuthInterceptor(authenticator)),
+		grpc.ChainStreamInterceptor(StreamTracingInterceptor(), StreamAuthInterceptor(authenticator)),
+	)
+	server := grpc.NewServer(opts...)
+	leaderboardpb.RegisterLeaderboardServer(server, leaderboardServer)
+
+	return server
+}
+
+func (s *LeaderboardServer) SubmitScore(ctx context.Context, request *leaderboardpb.SubmitScoreRequest) (*leaderboardpb.SubmitScoreResponse, error) {
+	submission := toScoreSubmission(request.GetSubmission())
+	if err := s.validator.Validate(submission); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("invalid").Inc()
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := services.AuthorizeSubmission(services.PrincipalFromContext(ctx), submission.UserId); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("unauthorized").Inc()
+		return nil, authError(err)
+	}
+
+	events, err := s.scoreService.Submit(ctx, submission)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &leaderboardpb.SubmitScoreResponse{Events: fromScoreEvents(events)}, nil
+}
+
+func (s *LeaderboardServer) BatchSubmit(ctx context.Context, request *leaderboardpb.BatchSubmitRequest) (*leaderboardpb.BatchSubmitResponse, error) {
+	batch := new(api.BatchScoreSubmission)
+	userIds := make([]string, 0, len(request.GetSubmissions()))
+	for _, submission := range request.GetSubmissions() {
+		batch.Submissions = append(batch.Submissions, toScoreSubmission(submission))
+		userIds = append(userIds, submission.GetUserId())
+	}
+
+	if err := s.validator.Validate(batch); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("invalid").Add(float64(len(batch.Submissions)))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := services.AuthorizeSubmission(services.PrincipalFromContext(ctx), userIds...); err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("unauthorized").Add(float64(len(userIds)))
+		return nil, authError(err)
+	}
+
+	response := new(leaderboardpb.BatchSubmitResponse)
+	for _, result := range s.scoreService.SubmitBatch(ctx, batch.Submissions) {
+		response.Results = append(response.Results, &leaderboardpb.BatchSubmitResult{
+			Events: fromScoreEvents(result.Events),
+			Error:  result.Error,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *LeaderboardServer) GetPage(ctx context.Context, request *leaderboardpb.GetPageRequest) (*leaderboardpb.GetPageResponse, error) {
+	q := &api.LeaderboardQuery{
+		Country:  boardOrGlobal(request.GetBoard()),
+		Page:     request.GetPage(),
+		PageSize: request.GetPageSize(),
+	}
+
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	if q.PageSize <= 0 {
+		q.PageSize = 10
+	}
+
+	if err := s.validator.Validate(q); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rows, err := s.leaderboardService.GetPage(ctx, q.Country, q.Page, q.PageSize)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &leaderboardpb.GetPageResponse{Rows: fromLeaderboardRows(rows)}, nil
+}
+
+func (s *LeaderboardServer) GetAroundMe(ctx context.Context, request *leaderboardpb.GetAroundMeRequest) (*leaderboardpb.GetPageResponse, error) {
+	if len(request.GetUserId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	q := &api.AroundQuery{
+		Board:  boardOrGlobal(request.GetBoard()),
+		Radius: request.GetRadius(),
+	}
+
+	if q.Radius <= 0 {
+		q.Radius = 5
+	}
+
+	if err := s.validator.Validate(q); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rows, err := s.leaderboardService.GetAround(ctx, q.Board, request.GetUserId(), q.Radius)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &leaderboardpb.GetPageResponse{Rows: fromLeaderboardRows(rows)}, nil
+}
+
+func (s *LeaderboardServer) GetProfile(ctx context.Context, request *leaderboardpb.GetProfileRequest) (*leaderboardpb.UserProfile, error) {
+	if len(request.GetUserId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	profile, err := s.userService.GetByIDWithRank(ctx, request.GetUserId(), "GLOBAL")
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return fromUserProfile(profile), nil
+}
+
+func (s *LeaderboardServer) CreateUser(ctx context.Context, request *leaderboardpb.CreateUserRequest) (*leaderboardpb.UserProfile, error) {
+	profile := toUserProfile(request.GetProfile())
+	if err := s.validator.Validate(profile); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	guid, err := s.userService.Create(ctx, profile)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	ranked, err := s.userService.GetByIDWithRank(ctx, guid, "GLOBAL")
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return fromUserProfile(ranked), nil
+}
+
+// SubscribeRank streams the rank of a user on a board through the live
+// feed, until the client cancels the call.
+func (s *LeaderboardServer) SubscribeRank(request *leaderboardpb.SubscribeRankRequest, stream leaderboardpb.Leaderboard_SubscribeRankServer) error {
+	if len(request.GetUserId()) == 0 {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	updates := make(chan *api.LiveUpdate, rankUpdateBufferSize)
+	id := s.liveFeedService.Subscribe(&api.LiveSubscriptionRequest{
+		Action: "subscribe",
+		Board:  request.GetBoard(),
+		UserId: request.GetUserId(),
+	}, updates)
+	defer s.liveFeedService.Unsubscribe(id)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stop:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case update := <-updates:
+			if update.Type != "rank" {
+				continue
+			}
+
+			err := stream.Send(&leaderboardpb.RankUpdate{
+				Board:  update.Board,
+				UserId: update.UserId,
+				Rank:   update.Rank,
+				Points: update.Points,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statusError answers errors of a kind with the code of their kind, as the
+// HTTP API answers them with the status code of their kind.
+func statusError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, api.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, api.ErrInvalid):
+		code = codes.InvalidArgument
+	case errors.Is(err, api.ErrUnavailable):
+		code = codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+
+	return status.Error(code, err.Error())
+}
+
+func boardOrGlobal(board string) string {
+	board = strings.ToUpper(board)
+	if len(board) == 0 {
+		return "GLOBAL"
+	}
+
+	return board
+}
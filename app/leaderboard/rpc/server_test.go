@@ -0,0 +1,127 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"leaderboard/app/api"
+	"leaderboard/app/api/leaderboardpb"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+// failingLeaderboard answers every page with its error.
+type failingLeaderboard struct {
+	err error
+}
+
+func (f *failingLeaderboard) GetPage(context.Context, string, int64, int64) ([]*api.LeaderboardRow, error) {
+	return nil, f.err
+}
+
+func (f *failingLeaderboard) GetAround(context.Context, string, string, int64) ([]*api.LeaderboardRow, error) {
+	return nil, f.err
+}
+
+var _ = Describe("the leaderboard server", func() {
+	var (
+		s           *server
+		leaderboard *failingLeaderboard
+	)
+
+	BeforeEach(func() {
+		leaderboard = new(failingLeaderboard)
+		s = newServer(services.InsecureAuthenticator{}, leaderboard)
+	})
+
+	AfterEach(func() {
+		s.close()
+	})
+
+	DescribeTable("the codes of the errors",
+		func(err error, code codes.Code) {
+			leaderboard.err = err
+
+			_, err = s.client.GetPage(ctx, &leaderboardpb.GetPageRequest{Board: "tr"})
+			Expect(status.Code(err)).To(Equal(code))
+		},
+		Entry("a wrapped not found error", fmt.Errorf("board: %w", api.NewError(api.ErrNotFound, "board TR is missing")), codes.NotFound),
+		Entry("a conflict", api.NewError(api.ErrConflict, "board TR is archived"), codes.Aborted),
+		Entry("an invalid request", api.NewError(api.ErrInvalid, "page is out of range"), codes.InvalidArgument),
+		Entry("an unavailable store", api.NewError(api.ErrUnavailable, "redis is down"), codes.Unavailable),
+		Entry("a deadline", context.DeadlineExceeded, codes.DeadlineExceeded),
+		Entry("an error of no kind", errors.New("boom"), codes.Internal),
+	)
+
+	DescribeTable("the calls without a user id",
+		func(call func() error) {
+			Expect(status.Code(call())).To(Equal(codes.InvalidArgument))
+		},
+		Entry("the profile", func() error {
+			_, err := s.client.GetProfile(ctx, &leaderboardpb.GetProfileRequest{})
+			return err
+		}),
+		Entry("the rows around the user", func() error {
+			_, err := s.client.GetAroundMe(ctx, &leaderboardpb.GetAroundMeRequest{Board: "tr"})
+			return err
+		}),
+	)
+})
+
+var _ = Describe("the rank subscriptions", func() {
+	var s *server
+
+	BeforeEach(func() {
+		authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{
+			{Id: "server", KeySha256: services.HashAPIKey("server"), Role: services.RoleGameServer},
+		})
+		Expect(err).To(BeNil())
+		s = newServer(authenticator, nil)
+
+		_, err = s.userService.Create(ctx, &api.UserProfile{UserId: "alice", DisplayName: "alice", Country: "TR", Points: 20})
+		Expect(err).To(BeNil())
+		_, err = s.userService.Create(ctx, &api.UserProfile{UserId: "bob", DisplayName: "bob", Country: "TR", Points: 10})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		s.close()
+	})
+
+	It("streams the rank of the user as it changes", func() {
+		stream, err := s.client.SubscribeRank(ctx, &leaderboardpb.SubscribeRankRequest{Board: "tr", UserId: "alice"})
+		Expect(err).To(BeNil())
+
+		update, err := stream.Recv()
+		Expect(err).To(BeNil())
+		Expect(update.GetBoard()).To(Equal("TR"))
+		Expect(update.GetRank()).To(BeEquivalentTo(1))
+		Expect(update.GetPoints()).To(BeEquivalentTo(20))
+
+		_, err = s.client.SubmitScore(withAPIKey("server"), &leaderboardpb.SubmitScoreRequest{
+			Submission: &leaderboardpb.ScoreSubmission{UserId: "bob", Score: 30, Timestamp: time.Now().Unix()},
+		})
+		Expect(err).To(BeNil())
+
+		update, err = stream.Recv()
+		Expect(err).To(BeNil())
+		Expect(update.GetUserId()).To(Equal("alice"))
+		Expect(update.GetRank()).To(BeEquivalentTo(2))
+	})
+
+	It("ends the streams when the server stops", func() {
+		stream, err := s.client.SubscribeRank(ctx, &leaderboardpb.SubscribeRankRequest{UserId: "alice"})
+		Expect(err).To(BeNil())
+		_, err = stream.Recv()
+		Expect(err).To(BeNil())
+
+		s.leaderboardServer.Stop()
+		_, err = stream.Recv()
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	})
+})
@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"leaderboard/app/leaderboard/tracing"
+	"strings"
+)
+
+// UnaryTracingInterceptor starts a server span for every call, continuing
+// the trace of the caller when the metadata carries a trace context.
+func UnaryTracingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		response, err := handler(ctx, req)
+		endSpan(ctx, span, err)
+
+		return response, err
+	}
+}
+
+func StreamTracingInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startSpan(stream.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+		endSpan(ctx, span, err)
+
+		return err
+	}
+}
+
+func startSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = global.TextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	attributes := []label.KeyValue{semconv.RPCSystemGRPC}
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		attributes = append(attributes,
+			semconv.RPCServiceKey.String(strings.TrimPrefix(fullMethod[:i], "/")),
+			semconv.RPCMethodKey.String(fullMethod[i+1:]),
+		)
+	}
+
+	return tracing.Tracer().Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+func endSpan(ctx context.Context, span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(label.String("rpc.grpc.status_code", code.String()))
+	if err != nil {
+		span.RecordError(ctx, err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+// metadataCarrier reads and writes trace context through the metadata of
+// a call.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
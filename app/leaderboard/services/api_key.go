@@ -0,0 +1,80 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"net/http"
+	"strings"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// APIKey describes a key without storing it, only the SHA-256 hash of the
+// key is kept in the keys file.
+type APIKey struct {
+	Id        string   `json:"id"`
+	KeySha256 string   `json:"key_sha256"`
+	Subject   string   `json:"subject"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes"`
+}
+
+type APIKeyAuthenticator struct {
+	keys map[string]*APIKey
+}
+
+func NewAPIKeyAuthenticator(keys []*APIKey) (*APIKeyAuthenticator, error) {
+	authenticator := &APIKeyAuthenticator{keys: map[string]*APIKey{}}
+	for _, key := range keys {
+		if !isRole(key.Role) {
+			return nil, fmt.Errorf("api key (%s) has unknown role %q", key.Id, key.Role)
+		}
+
+		if len(key.Subject) == 0 {
+			key.Subject = key.Id
+		}
+
+		authenticator.keys[strings.ToLower(key.KeySha256)] = key
+	}
+
+	return authenticator, nil
+}
+
+// LoadAPIKeyAuthenticator reads the keys from a JSON file holding an array
+// of APIKey objects.
+func LoadAPIKeyAuthenticator(path string) (*APIKeyAuthenticator, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("malformed api keys file (%s): %w", path, err)
+	}
+
+	return NewAPIKeyAuthenticator(keys)
+}
+
+func (ak *APIKeyAuthenticator) Authenticate(header http.Header) (*api.Principal, error) {
+	raw := header.Get(HeaderAPIKey)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	key, ok := ak.keys[HashAPIKey(raw)]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &api.Principal{Subject: key.Subject, Role: key.Role, Scopes: key.Scopes}, nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
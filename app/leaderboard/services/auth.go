@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"leaderboard/app/api"
+	"net/http"
+)
+
+const (
+	RolePlayer     = "player"
+	RoleGameServer = "game-server"
+	RoleAdmin      = "admin"
+)
+
+const (
+	ScopeScoresWrite = "scores:write"
+	ScopeUsersWrite  = "users:write"
+	ScopeWebhooks    = "webhooks"
+	ScopeActuator    = "actuator"
+)
+
+var (
+	ErrUnauthenticated    = errors.New("authentication is required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrForbidden          = errors.New("permission denied")
+)
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *api.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) *api.Principal {
+	principal, _ := ctx.Value(principalKey{}).(*api.Principal)
+	return principal
+}
+
+// Authorize checks that the principal has one of the roles. Principals with
+// scopes are restricted to those scopes, principals without any may do
+// whatever their role allows.
+func Authorize(principal *api.Principal, scope string, roles ...string) error {
+	if principal == nil {
+		return ErrUnauthenticated
+	}
+
+	if !contains(roles, principal.Role) {
+		return ErrForbidden
+	}
+
+	if len(principal.Scopes) > 0 && !contains(principal.Scopes, scope) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// AuthorizeSubmission lets game servers submit scores of any user, and
+// players only their own.
+func AuthorizeSubmission(principal *api.Principal, userIds ...string) error {
+	if err := Authorize(principal, ScopeScoresWrite, RoleGameServer, RolePlayer); err != nil {
+		return err
+	}
+
+	if principal.Role == RolePlayer {
+		for _, userId := range userIds {
+			if userId != principal.Subject {
+				return ErrForbidden
+			}
+		}
+	}
+
+	return nil
+}
+
+func isRole(role string) bool {
+	return role == RolePlayer || role == RoleGameServer || role == RoleAdmin
+}
+
+// AuthenticatorChain asks each authenticator in order, the first one that
+// recognizes the credentials decides.
+type AuthenticatorChain []api.Authenticator
+
+func (ac AuthenticatorChain) Authenticate(header http.Header) (*api.Principal, error) {
+	for _, authenticator := range ac {
+		principal, err := authenticator.Authenticate(header)
+		if err != nil || principal != nil {
+			return principal, err
+		}
+	}
+
+	return nil, nil
+}
+
+// InsecureAuthenticator grants the admin role to every request. It is only
+// meant for local development.
+type InsecureAuthenticator struct{}
+
+func (InsecureAuthenticator) Authenticate(_ http.Header) (*api.Principal, error) {
+	return &api.Principal{Subject: "anonymous", Role: RoleAdmin}, nil
+}
@@ -0,0 +1,166 @@
+package services_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("authentication", func() {
+	apiKeyHeader := func(key string) http.Header {
+		header := http.Header{}
+		header.Set(services.HeaderAPIKey, key)
+
+		return header
+	}
+
+	Context("APIKeyAuthenticator.Authenticate()", func() {
+		var authenticator *services.APIKeyAuthenticator
+
+		BeforeEach(func() {
+			var err error
+			authenticator, err = services.NewAPIKeyAuthenticator(nil)
+			Expect(err).To(BeNil())
+		})
+
+		It("resolves the principal of a known key", func() {
+			authenticator, err := services.NewAPIKeyAuthenticator([]*services.APIKey{{
+				Id:        "server-1",
+				KeySha256: services.HashAPIKey("k3y"),
+				Role:      services.RoleGameServer,
+				Scopes:    []string{services.ScopeScoresWrite},
+			}})
+			Expect(err).To(BeNil())
+
+			principal, err := authenticator.Authenticate(apiKeyHeader("k3y"))
+			Expect(err).To(BeNil())
+			Expect(principal.Subject).To(BeEquivalentTo("server-1"))
+			Expect(principal.Role).To(BeEquivalentTo(services.RoleGameServer))
+
+			_, err = authenticator.Authenticate(apiKeyHeader("wrong"))
+			Expect(err).To(MatchError(services.ErrInvalidCredentials))
+		})
+
+		It("ignores requests without a key", func() {
+			principal, err := authenticator.Authenticate(http.Header{})
+			Expect(err).To(BeNil())
+			Expect(principal).To(BeNil())
+		})
+	})
+
+	Context("JWTAuthenticator.Authenticate()", func() {
+		var (
+			privateKey    *rsa.PrivateKey
+			authenticator *services.JWTAuthenticator
+		)
+
+		sign := func(claims jwt.MapClaims) http.Header {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = "test"
+
+			signed, err := token.SignedString(privateKey)
+			Expect(err).To(BeNil())
+
+			return http.Header{"Authorization": {"Bearer " + signed}}
+		}
+
+		BeforeEach(func() {
+			var err error
+			privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).To(BeNil())
+
+			jwks, err := json.Marshal(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kid": "test",
+					"kty": "RSA",
+					"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+				}},
+			})
+			Expect(err).To(BeNil())
+
+			dir, err := ioutil.TempDir("", "jwks")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "jwks.json")
+			Expect(ioutil.WriteFile(path, jwks, 0600)).To(BeNil())
+
+			authenticator, err = services.LoadJWTAuthenticator(path, "issuer", "leaderboard")
+			Expect(err).To(BeNil())
+		})
+
+		It("resolves the principal of a valid token", func() {
+			principal, err := authenticator.Authenticate(sign(jwt.MapClaims{
+				"sub":   "a",
+				"role":  services.RolePlayer,
+				"scope": "scores:write",
+				"iss":   "issuer",
+				"aud":   []string{"leaderboard"},
+				"exp":   time.Now().Add(time.Minute).Unix(),
+			}))
+			Expect(err).To(BeNil())
+			Expect(principal.Subject).To(BeEquivalentTo("a"))
+			Expect(principal.Role).To(BeEquivalentTo(services.RolePlayer))
+			Expect(principal.Scopes).To(ConsistOf(services.ScopeScoresWrite))
+		})
+
+		It("rejects expired tokens", func() {
+			_, err := authenticator.Authenticate(sign(jwt.MapClaims{
+				"sub":  "a",
+				"role": services.RolePlayer,
+				"iss":  "issuer",
+				"aud":  "leaderboard",
+				"exp":  time.Now().Add(-time.Minute).Unix(),
+			}))
+			Expect(err).To(MatchError(services.ErrInvalidCredentials))
+		})
+
+		It("rejects tokens of another audience", func() {
+			_, err := authenticator.Authenticate(sign(jwt.MapClaims{
+				"sub":  "a",
+				"role": services.RolePlayer,
+				"iss":  "issuer",
+				"aud":  "another",
+				"exp":  time.Now().Add(time.Minute).Unix(),
+			}))
+			Expect(err).To(MatchError(services.ErrInvalidCredentials))
+		})
+	})
+
+	Context("AuthorizeSubmission()", func() {
+		It("lets players submit only their own scores", func() {
+			player := &api.Principal{Subject: "a", Role: services.RolePlayer}
+			Expect(services.AuthorizeSubmission(player, "a")).To(BeNil())
+			Expect(services.AuthorizeSubmission(player, "a", "b")).To(MatchError(services.ErrForbidden))
+		})
+
+		It("lets game servers submit scores of any user", func() {
+			server := &api.Principal{Subject: "server-1", Role: services.RoleGameServer}
+			Expect(services.AuthorizeSubmission(server, "a", "b")).To(BeNil())
+		})
+
+		It("rejects admins and anonymous requests", func() {
+			admin := &api.Principal{Subject: "root", Role: services.RoleAdmin}
+			Expect(services.AuthorizeSubmission(admin, "a")).To(MatchError(services.ErrForbidden))
+			Expect(services.AuthorizeSubmission(nil, "a")).To(MatchError(services.ErrUnauthenticated))
+		})
+
+		It("restricts scoped principals to their scopes", func() {
+			server := &api.Principal{Subject: "server-1", Role: services.RoleGameServer, Scopes: []string{services.ScopeWebhooks}}
+			Expect(services.AuthorizeSubmission(server, "a")).To(MatchError(services.ErrForbidden))
+		})
+	})
+})
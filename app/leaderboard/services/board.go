@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"leaderboard/app/api"
+	"sort"
+	"time"
+)
+
+// archiveLabelLayout labels the archives which are not given a label.
+const archiveLabelLayout = "20060102T150405Z"
+
+// BoardService lists the boards, and resets or archives them for the
+// operators.
+type BoardService struct {
+	redisService api.RedisService
+}
+
+func NewBoardService(redisService api.RedisService) *BoardService {
+	return &BoardService{redisService: redisService}
+}
+
+// List returns the boards by their names.
+func (bs *BoardService) List(ctx context.Context) ([]*api.Board, error) {
+	names, err := bs.redisService.GetBoardNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	boards := make([]*api.Board, 0, len(names))
+	for _, name := range names {
+		members, err := bs.redisService.GetSortedSetSize(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		boards = append(boards, &api.Board{Name: name, Members: members})
+	}
+
+	return boards, nil
+}
+
+// Get reports the board along with its top and bottom scores and its
+// archives. A board without members is reported as long as it has
+// archives.
+func (bs *BoardService) Get(ctx context.Context, name string) (*api.Board, error) {
+	archives, err := bs.redisService.GetArchiveLabels(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	board := &api.Board{Name: name, Archives: archives}
+	board.Members, err = bs.redisService.GetSortedSetSize(ctx, name)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) && len(archives) > 0 {
+			return board, nil
+		}
+
+		return nil, err
+	}
+
+	top, err := bs.redisService.GetPage(ctx, name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	bottom, err := bs.redisService.GetPage(ctx, name, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(top) > 0 && len(bottom) > 0 {
+		board.TopScore = top[0].Score
+		board.BottomScore = bottom[0].Score
+	}
+
+	return board, nil
+}
+
+// Reset removes every member of the board, their profiles are kept.
+func (bs *BoardService) Reset(ctx context.Context, name string) error {
+	deleted, err := bs.redisService.DeleteBoard(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return api.NewError(api.ErrNotFound, fmt.Sprintf("board is not found (%s)", name))
+	}
+
+	return nil
+}
+
+// Archive moves the members of the board to an archive under the label, or
+// under the current time when there is no label, and leaves the board
+// empty. It returns ErrArchiveExists when the label is taken.
+func (bs *BoardService) Archive(ctx context.Context, name string, label string) (*api.BoardArchive, error) {
+	if len(label) == 0 {
+		label = time.Now().UTC().Format(archiveLabelLayout)
+	}
+
+	if err := bs.redisService.ArchiveBoard(ctx, name, label); err != nil {
+		return nil, err
+	}
+
+	return &api.BoardArchive{Label: label}, nil
+}
+
+// Prune deletes the archives of the board labeled by the time they were
+// archived at, all but the keep latest ones, and returns their labels.
+// Archives labeled otherwise are kept.
+func (bs *BoardService) Prune(ctx context.Context, name string, keep int) ([]string, error) {
+	labels, err := bs.redisService.GetArchiveLabels(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// labels of times sort by the time, GetArchiveLabels sorts them
+	var timed []string
+	for _, label := range labels {
+		if _, err := time.Parse(archiveLabelLayout, label); err == nil {
+			timed = append(timed, label)
+		}
+	}
+
+	if len(timed) <= keep {
+		return nil, nil
+	}
+
+	pruned := timed[:len(timed)-keep]
+	for _, label := range pruned {
+		if err := bs.redisService.DeleteArchive(ctx, name, label); err != nil {
+			return nil, err
+		}
+	}
+
+	return pruned, nil
+}
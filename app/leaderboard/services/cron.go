@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchYears bounds the search for the next time of an expression
+// which never matches, e.g. 0 0 30 2 *.
+const cronSearchYears = 5
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is Sunday as well as 0
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// CronSchedule is a parsed cron expression of five fields, minute, hour,
+// day of month, month and day of week. Fields take *, values, ranges, steps
+// and lists of them, e.g. */15 9-17 * * mon-fri, and months and days of week
+// take their three letter names. When both the day of month and the day of
+// week are restricted, a day matching either of them matches, as in cron.
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly stand for
+// their expressions.
+type CronSchedule struct {
+	expression string
+	// bit n is set when n matches
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	anyDayOfMonth, anyDayOfWeek                bool
+}
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) == 1 {
+		descriptor, ok := cronDescriptors[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron descriptor %q", fields[0])
+		}
+		fields = strings.Fields(descriptor)
+	}
+
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expression, len(cronFields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expression, err)
+		}
+	}
+
+	// Sunday is matched as 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &CronSchedule{
+		expression:    expression,
+		minute:        bits[0],
+		hour:          bits[1],
+		dayOfMonth:    bits[2],
+		month:         bits[3],
+		dayOfWeek:     bits[4],
+		anyDayOfMonth: fields[2] == "*" || fields[2] == "?",
+		anyDayOfWeek:  fields[4] == "*" || fields[4] == "?",
+	}, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s %q", spec.name, part)
+			}
+			rangePart = part[:i]
+		}
+
+		first, last := spec.min, spec.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if first, err = parseCronValue(bounds[0], spec); err != nil {
+				return 0, err
+			}
+			if last, err = parseCronValue(bounds[1], spec); err != nil {
+				return 0, err
+			}
+			if first > last {
+				return 0, fmt.Errorf("invalid range in %s %q", spec.name, part)
+			}
+		default:
+			var err error
+			if first, err = parseCronValue(rangePart, spec); err != nil {
+				return 0, err
+			}
+			// a value with a step runs to the end of the field, e.g. 5/15
+			if !strings.Contains(part, "/") {
+				last = first
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(value string, spec cronField) (int, error) {
+	if number, ok := spec.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < spec.min || number > spec.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %q", spec.name, spec.min, spec.max, value)
+	}
+
+	return number, nil
+}
+
+func (cs *CronSchedule) String() string {
+	return cs.expression
+}
+
+// Next returns the first time after t which matches the expression, in the
+// location of t, or the zero time when none does in the next years.
+func (cs *CronSchedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		switch {
+		case cs.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		case !cs.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (cs *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := cs.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := cs.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case cs.anyDayOfMonth:
+		return dayOfWeek
+	case cs.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
@@ -0,0 +1,55 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+var _ = Describe("cron expressions", func() {
+	at := func(value string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", value)
+		Expect(err).To(BeNil())
+		return t
+	}
+
+	next := func(expression string, from string) time.Time {
+		schedule, err := services.ParseCron(expression)
+		Expect(err).To(BeNil())
+		return schedule.Next(at(from))
+	}
+
+	It("finds the next matching minute", func() {
+		Expect(next("* * * * *", "2020-11-20 10:15")).To(Equal(at("2020-11-20 10:16")))
+		Expect(next("*/15 * * * *", "2020-11-20 10:15")).To(Equal(at("2020-11-20 10:30")))
+		Expect(next("5/20 * * * *", "2020-11-20 10:30")).To(Equal(at("2020-11-20 10:45")))
+		Expect(next("0,30 9-17 * * *", "2020-11-20 17:30")).To(Equal(at("2020-11-21 09:00")))
+	})
+
+	It("rolls over days, months and years", func() {
+		Expect(next("0 3 * * *", "2020-12-31 04:00")).To(Equal(at("2021-01-01 03:00")))
+		Expect(next("0 0 31 * *", "2020-11-20 00:00")).To(Equal(at("2020-12-31 00:00")))
+		Expect(next("0 0 29 feb *", "2021-01-01 00:00")).To(Equal(at("2024-02-29 00:00")))
+		Expect(next("@yearly", "2020-11-20 00:00")).To(Equal(at("2021-01-01 00:00")))
+	})
+
+	It("matches days of the week", func() {
+		// 2020-11-20 is a Friday
+		Expect(next("0 9 * * mon-fri", "2020-11-20 10:00")).To(Equal(at("2020-11-23 09:00")))
+		Expect(next("0 0 * * 7", "2020-11-20 10:00")).To(Equal(at("2020-11-22 00:00")))
+		// either the day of the month or the day of the week
+		Expect(next("0 0 1 * sat", "2020-11-20 10:00")).To(Equal(at("2020-11-21 00:00")))
+	})
+
+	It("never matches impossible dates", func() {
+		Expect(next("0 0 30 2 *", "2020-11-20 00:00").IsZero()).To(BeTrue())
+	})
+
+	It("rejects malformed expressions", func() {
+		for _, expression := range []string{"* * * *", "60 * * * *", "* * * * mon-", "*/0 * * * *", "5-1 * * * *", "@often"} {
+			_, err := services.ParseCron(expression)
+			Expect(err).NotTo(BeNil(), expression)
+		}
+	})
+})
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	HealthStatusOk          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	HealthStatusDraining    = "draining"
+)
+
+// HealthCheck reports whether a dependency of the instance is reachable.
+type HealthCheck func(ctx context.Context) error
+
+// HealthService reports the readiness of the instance to serve traffic.
+// The instance is ready while every check passes and it has not started
+// to drain.
+type HealthService struct {
+	checks   map[string]HealthCheck
+	draining int32
+}
+
+func NewHealthService() *HealthService {
+	return &HealthService{checks: map[string]HealthCheck{}}
+}
+
+// AddCheck is not safe to call once the instance serves traffic.
+func (hs *HealthService) AddCheck(name string, check HealthCheck) {
+	hs.checks[name] = check
+}
+
+// Drain makes the instance report that it is not ready from then on, so
+// load balancers stop routing to it while in-flight requests complete.
+func (hs *HealthService) Drain() {
+	atomic.StoreInt32(&hs.draining, 1)
+}
+
+func (hs *HealthService) Draining() bool {
+	return atomic.LoadInt32(&hs.draining) == 1
+}
+
+// Ready runs every check concurrently and reports whether all of them
+// passed, along with the result of each one.
+func (hs *HealthService) Ready(ctx context.Context) (map[string]string, bool) {
+	names := make([]string, 0, len(hs.checks))
+	for name := range hs.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}(i, hs.checks[name])
+	}
+	wg.Wait()
+
+	ready := !hs.Draining()
+	checks := make(map[string]string, len(names))
+	for i, name := range names {
+		if results[i] != nil {
+			checks[name] = results[i].Error()
+			ready = false
+		} else {
+			checks[name] = HealthStatusOk
+		}
+	}
+
+	return checks, ready
+}
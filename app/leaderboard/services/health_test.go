@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/leaderboard/services"
+)
+
+var _ = Describe("the health service", func() {
+	var healthService *services.HealthService
+
+	JustBeforeEach(func() {
+		_, redisService := buildDependencies(mRedis.Addr())
+		healthService = services.NewHealthService()
+		healthService.AddCheck("redis", redisService.Ping)
+	})
+
+	Context("HealthService.Ready()", func() {
+		When("every check passes", func() {
+			It("reports ready", func() {
+				checks, ready := healthService.Ready(ctx)
+				Expect(ready).To(BeTrue())
+				Expect(checks).To(HaveKeyWithValue("redis", services.HealthStatusOk))
+			})
+		})
+
+		When("a check fails", func() {
+			It("reports not ready with the error of the check", func() {
+				healthService.AddCheck("mysql", func(context.Context) error {
+					return errors.New("connection refused")
+				})
+
+				checks, ready := healthService.Ready(ctx)
+				Expect(ready).To(BeFalse())
+				Expect(checks).To(HaveKeyWithValue("redis", services.HealthStatusOk))
+				Expect(checks).To(HaveKeyWithValue("mysql", "connection refused"))
+			})
+		})
+
+		When("the instance drains", func() {
+			It("reports not ready", func() {
+				healthService.Drain()
+
+				_, ready := healthService.Ready(ctx)
+				Expect(ready).To(BeFalse())
+				Expect(healthService.Draining()).To(BeTrue())
+			})
+		})
+	})
+})
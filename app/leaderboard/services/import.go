@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"io"
+	"leaderboard/app/api"
+	"os"
+	"path/filepath"
+)
+
+// ImportService keeps the files of the import job in a directory and reports
+// the runs of the job. Runs are worked on by whichever instance claims them,
+// so instances which run imports share the directory.
+type ImportService struct {
+	redisService api.RedisService
+	directory    string
+}
+
+func NewImportService(redisService api.RedisService, directory string) *ImportService {
+	return &ImportService{redisService: redisService, directory: directory}
+}
+
+// Open opens a file of the directory. Paths are taken relative to the
+// directory, they do not lead out of it.
+func (is *ImportService) Open(path string) (*os.File, error) {
+	return os.Open(is.resolve(path))
+}
+
+func (is *ImportService) Remove(path string) error {
+	return os.Remove(is.resolve(path))
+}
+
+// Store writes an upload to the directory and returns its path there. The
+// format is kept as the extension of the path, when it is given.
+func (is *ImportService) Store(body io.Reader, format string) (string, error) {
+	if err := os.MkdirAll(is.directory, 0o750); err != nil {
+		return "", err
+	}
+
+	path := "upload-" + uuid.New().String()
+	if len(format) > 0 {
+		path += "." + format
+	}
+
+	file, err := os.OpenFile(is.resolve(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(is.resolve(path))
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Report reports the rows a run of the import job read, imported and
+// rejected so far.
+func (is *ImportService) Report(ctx context.Context, run *api.JobRun) (*api.ImportReport, error) {
+	report, err := is.redisService.GetImport(ctx, run.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Run = run
+	return report, nil
+}
+
+func (is *ImportService) resolve(path string) string {
+	return filepath.Join(is.directory, filepath.Clean("/"+path))
+}
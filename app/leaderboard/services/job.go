@@ -0,0 +1,831 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/api"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// States of a job run. Queued runs wait for an instance to claim them,
+// paused runs stay with their instance until they are resumed, the last
+// three states are final.
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStatePaused    = "paused"
+	JobStateDone      = "done"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+var JobStates = []string{JobStateQueued, JobStateRunning, JobStatePaused, JobStateDone, JobStateFailed, JobStateCancelled}
+
+const (
+	jobRunsLen      = 20
+	jobRunRetention = 7 * 24 * time.Hour
+)
+
+var (
+	ErrJobNotFound    = api.NewError(api.ErrNotFound, "job is not found")
+	ErrJobRunNotFound = api.NewError(api.ErrNotFound, "job run is not found")
+)
+
+// startJobScript queues a run, a singleton job is refused while another run
+// of it is not finished.
+//
+// KEYS[1] run, KEYS[2] runs of the job, KEYS[3] queue, KEYS[4] active run
+// of the job, ARGV[1] id, ARGV[2] job, ARGV[3] parameters, ARGV[4] now,
+// ARGV[5] 1 for singleton jobs, ARGV[6] number of runs listed per job
+var startJobScript = redis.NewScript(`
+if ARGV[5] == '1' then
+	local active = redis.call('GET', KEYS[4])
+	if active then
+		return redis.error_reply('JOB_ACTIVE ' .. active)
+	end
+	redis.call('SET', KEYS[4], ARGV[1])
+end
+
+redis.call('HSET', KEYS[1], 'id', ARGV[1], 'job', ARGV[2], 'state', 'queued', 'parameters', ARGV[3], 'done', 0, 'total', 0, 'queued_at', ARGV[4])
+redis.call('LPUSH', KEYS[2], ARGV[1])
+redis.call('LTRIM', KEYS[2], 0, tonumber(ARGV[6]) - 1)
+redis.call('RPUSH', KEYS[3], ARGV[1])
+return 1
+`)
+
+// claimJobScript takes a run off the queue and leases it to an instance,
+// a run which was cancelled while queued is dropped. The run counts the
+// fencing tokens of its leases.
+//
+// KEYS[1] queue, KEYS[2] run, KEYS[3] lock, ARGV[1] id, ARGV[2] instance,
+// ARGV[3] lease token, ARGV[4] lease in milliseconds, ARGV[5] now
+//
+// returns the fencing token of the lease, nil when the run was taken off
+// the queue by another instance or dropped
+var claimJobScript = redis.NewScript(leaseLua + `
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return false
+end
+
+if redis.call('HGET', KEYS[2], 'state') ~= 'queued' then
+	return false
+end
+
+local fence = acquire_lease(KEYS[3], KEYS[2], ARGV[2], ARGV[3], ARGV[4])
+if not fence then
+	return false
+end
+
+redis.call('HSET', KEYS[2], 'state', 'running', 'owner', ARGV[2], 'updated_at', ARGV[5])
+redis.call('HSETNX', KEYS[2], 'started_at', ARGV[5])
+return fence
+`)
+
+// renewJobScript extends the lease of a run and records its progress,
+// progress of a lease which was taken over is refused by its fence.
+//
+// KEYS[1] run, KEYS[2] lock, ARGV[1] lease token, ARGV[2] lease in
+// milliseconds, ARGV[3] done, ARGV[4] total, ARGV[5] now, ARGV[6] fencing
+// token
+//
+// returns the state of the run, which may have been changed through the API
+var renewJobScript = redis.NewScript(leaseLua + `
+if not holds_lease(KEYS[2], ARGV[1]) or not fence_resource(KEYS[1], ARGV[6]) then
+	return redis.error_reply('LEASE_LOST')
+end
+
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+redis.call('HSET', KEYS[1], 'done', ARGV[3], 'total', ARGV[4], 'updated_at', ARGV[5])
+return redis.call('HGET', KEYS[1], 'state')
+`)
+
+// finishJobScript records the outcome of a run, cancelled runs stay
+// cancelled whatever their job returned.
+//
+// KEYS[1] run, KEYS[2] lock, KEYS[3] active run of the job, ARGV[1] lease
+// token, ARGV[2] id, ARGV[3] state, ARGV[4] error, ARGV[5] done, ARGV[6]
+// total, ARGV[7] now, ARGV[8] retention in seconds, ARGV[9] fencing token
+var finishJobScript = redis.NewScript(leaseLua + `
+if not holds_lease(KEYS[2], ARGV[1]) or not fence_resource(KEYS[1], ARGV[9]) then
+	return redis.error_reply('LEASE_LOST')
+end
+
+local state = redis.call('HGET', KEYS[1], 'state')
+local message = ARGV[4]
+if state == 'cancelled' then
+	message = ''
+else
+	state = ARGV[3]
+end
+
+redis.call('HSET', KEYS[1], 'state', state, 'error', message, 'done', ARGV[5], 'total', ARGV[6], 'owner', '', 'updated_at', ARGV[7], 'finished_at', ARGV[7])
+redis.call('EXPIRE', KEYS[1], ARGV[8])
+redis.call('DEL', KEYS[2])
+if redis.call('GET', KEYS[3]) == ARGV[2] then
+	redis.call('DEL', KEYS[3])
+end
+return state
+`)
+
+// releaseJobScript gives up the lease of a run, by its instance when it
+// stops or by any instance once the lease lapsed. Running runs are queued
+// again to be resumed, paused ones wait to be resumed, cancelled ones are
+// finished.
+//
+// KEYS[1] run, KEYS[2] lock, KEYS[3] queue, KEYS[4] active run of the job,
+// ARGV[1] lease token, empty for lapsed leases, ARGV[2] id, ARGV[3] now,
+// ARGV[4] retention in seconds
+var releaseJobScript = redis.NewScript(`
+local token = redis.call('HGET', KEYS[2], 'token')
+if token and token ~= ARGV[1] then
+	return 0
+end
+
+redis.call('DEL', KEYS[2])
+local state = redis.call('HGET', KEYS[1], 'state')
+if state == 'running' then
+	redis.call('HSET', KEYS[1], 'state', 'queued', 'owner', '', 'updated_at', ARGV[3])
+	redis.call('RPUSH', KEYS[3], ARGV[2])
+	return 1
+elseif state == 'paused' then
+	redis.call('HSET', KEYS[1], 'owner', '')
+elseif state == 'cancelled' and redis.call('HEXISTS', KEYS[1], 'finished_at') == 0 then
+	redis.call('HSET', KEYS[1], 'owner', '', 'updated_at', ARGV[3], 'finished_at', ARGV[3])
+	redis.call('EXPIRE', KEYS[1], ARGV[4])
+	if redis.call('GET', KEYS[4]) == ARGV[2] then
+		redis.call('DEL', KEYS[4])
+	end
+end
+return 0
+`)
+
+// transitionJobScript stops, pauses or resumes a run. Runs with an instance
+// are left to it to act on the new state when it renews its lease, the
+// others are finished or queued right away.
+//
+// KEYS[1] run, KEYS[2] lock, KEYS[3] queue, KEYS[4] active run of the job,
+// ARGV[1] stop, pause or resume, ARGV[2] id, ARGV[3] now, ARGV[4]
+// retention in seconds
+var transitionJobScript = redis.NewScript(`
+local state = redis.call('HGET', KEYS[1], 'state')
+if not state then
+	return redis.error_reply('RUN_NOT_FOUND')
+end
+
+local owned = redis.call('EXISTS', KEYS[2]) == 1
+local action = ARGV[1]
+if action == 'stop' and (state == 'queued' or state == 'running' or state == 'paused') then
+	if owned then
+		redis.call('HSET', KEYS[1], 'state', 'cancelled', 'updated_at', ARGV[3])
+	else
+		redis.call('HSET', KEYS[1], 'state', 'cancelled', 'owner', '', 'updated_at', ARGV[3], 'finished_at', ARGV[3])
+		redis.call('EXPIRE', KEYS[1], ARGV[4])
+		if redis.call('GET', KEYS[4]) == ARGV[2] then
+			redis.call('DEL', KEYS[4])
+		end
+	end
+elseif action == 'pause' and state == 'running' then
+	redis.call('HSET', KEYS[1], 'state', 'paused', 'updated_at', ARGV[3])
+elseif action == 'resume' and state == 'paused' then
+	if owned then
+		redis.call('HSET', KEYS[1], 'state', 'running', 'updated_at', ARGV[3])
+	else
+		redis.call('HSET', KEYS[1], 'state', 'queued', 'updated_at', ARGV[3])
+		redis.call('RPUSH', KEYS[3], ARGV[2])
+	end
+else
+	return redis.error_reply('INVALID_TRANSITION ' .. state)
+end
+
+return redis.call('HGET', KEYS[1], 'state')
+`)
+
+// Job is a kind of background work, which is started through the API and
+// run by whichever instance claims it.
+type Job interface {
+	Name() string
+	// Singleton jobs have one run at a time across the instances.
+	Singleton() bool
+	// Parameters returns a new value the parameters of a run are decoded
+	// into and validated, nil for jobs without parameters.
+	Parameters() interface{}
+	// Run works until the run is done or ctx is cancelled. A run is resumed
+	// by another instance when its instance stops, it continues from
+	// RunningJob.Done then.
+	Run(ctx context.Context, run *RunningJob) error
+}
+
+// RunningJob is the handle of a run for its job. The job reports its
+// progress through it and waits on it while the run is paused.
+type RunningJob struct {
+	id         string
+	parameters interface{}
+	done       int64
+	total      int64
+	fence      int64
+	pauseMux   sync.Mutex
+	paused     chan struct{}
+}
+
+func (r *RunningJob) Id() string {
+	return r.id
+}
+
+// Parameters returns the value of Job.Parameters the parameters of the run
+// are decoded into.
+func (r *RunningJob) Parameters() interface{} {
+	return r.parameters
+}
+
+// Done is the progress of the run, including what previous instances did.
+func (r *RunningJob) Done() int64 {
+	return atomic.LoadInt64(&r.done)
+}
+
+// Fence is the fencing token of the lease the instance holds on the run.
+// Jobs pass it along writes which must be refused once another instance
+// took the run over.
+func (r *RunningJob) Fence() int64 {
+	return r.fence
+}
+
+func (r *RunningJob) Total() int64 {
+	return atomic.LoadInt64(&r.total)
+}
+
+func (r *RunningJob) SetTotal(total int64) {
+	atomic.StoreInt64(&r.total, total)
+}
+
+// Progress adds n to Done.
+func (r *RunningJob) Progress(n int64) {
+	atomic.AddInt64(&r.done, n)
+}
+
+// Wait returns once the run is not paused, or the error of ctx once it is
+// done. Jobs wait between their units of work.
+func (r *RunningJob) Wait(ctx context.Context) error {
+	r.pauseMux.Lock()
+	paused := r.paused
+	r.pauseMux.Unlock()
+
+	if paused != nil {
+		select {
+		case <-paused:
+		case <-ctx.Done():
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (r *RunningJob) setPaused(pause bool) {
+	r.pauseMux.Lock()
+	defer r.pauseMux.Unlock()
+
+	if pause && r.paused == nil {
+		r.paused = make(chan struct{})
+	} else if !pause && r.paused != nil {
+		close(r.paused)
+		r.paused = nil
+	}
+}
+
+type JobConfiguration struct {
+	// Instance names this instance as the owner of its runs, the host name
+	// and the pid when not given.
+	Instance string
+	// Workers is the number of runs the instance works on at once.
+	Workers int
+	// LeaseTTL is how long a run stays with an instance which stopped
+	// renewing its lease, before another instance resumes it.
+	LeaseTTL time.Duration
+	// PollInterval is how often the instance looks for queued runs.
+	PollInterval time.Duration
+}
+
+// JobService keeps the registry of jobs and their runs. Runs are queued in
+// redis, any instance with a free worker claims the next one and holds a
+// lease on it while it works, so a run is worked on by one instance at a
+// time. Runs of an instance which is gone are resumed by another one once
+// its lease lapses, the progress of the lapsed lease is fenced off.
+type JobService struct {
+	redisService  api.RedisService
+	keys          *KeySchema
+	configuration *JobConfiguration
+	jobs          map[string]Job
+	slots         chan struct{}
+	// context of the runs, cancelled when the instance stops
+	context    context.Context
+	cancel     context.CancelFunc
+	stop       chan struct{}
+	stopOnce   sync.Once
+	dispatcher sync.WaitGroup
+	runs       sync.WaitGroup
+	now        func() time.Time
+}
+
+func NewJobService(redisService api.RedisService, keys *KeySchema, configuration *JobConfiguration) *JobService {
+	if len(configuration.Instance) == 0 {
+		configuration.Instance = instanceName()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &JobService{
+		redisService:  redisService,
+		keys:          keys,
+		configuration: configuration,
+		jobs:          map[string]Job{},
+		slots:         make(chan struct{}, configuration.Workers),
+		context:       ctx,
+		cancel:        cancel,
+		stop:          make(chan struct{}),
+		now:           time.Now,
+	}
+}
+
+// Register adds jobs to the registry, before the service is started.
+func (js *JobService) Register(jobs ...Job) {
+	for _, job := range jobs {
+		js.jobs[job.Name()] = job
+	}
+}
+
+func (js *JobService) Start() {
+	js.dispatcher.Add(1)
+	go js.dispatch()
+}
+
+// Stop stops claiming runs and cancels the runs of the instance, they are
+// queued again for another instance to resume.
+func (js *JobService) Stop() {
+	js.stopOnce.Do(func() {
+		close(js.stop)
+	})
+	js.dispatcher.Wait()
+
+	js.cancel()
+	js.runs.Wait()
+}
+
+// Jobs lists the registered jobs by name, along with their latest runs.
+func (js *JobService) Jobs(ctx context.Context) ([]*api.Job, error) {
+	names := make([]string, 0, len(js.jobs))
+	for name := range js.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	jobs := make([]*api.Job, 0, len(names))
+	for _, name := range names {
+		runs, err := js.Runs(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		job := &api.Job{Name: name, Singleton: js.jobs[name].Singleton()}
+		if len(runs) > 0 {
+			job.LastRun = runs[0]
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Parameters returns a new value for the parameters of a run of the job,
+// nil when the job has none.
+func (js *JobService) Parameters(name string) (interface{}, error) {
+	job, ok := js.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return job.Parameters(), nil
+}
+
+// StartRun queues a run of the job. A singleton job is refused with an
+// api.ErrConflict error while another run of it is not finished.
+func (js *JobService) StartRun(ctx context.Context, name string, parameters interface{}) (*api.JobRun, error) {
+	job, ok := js.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	data := []byte("{}")
+	if parameters != nil {
+		var err error
+		if data, err = json.Marshal(parameters); err != nil {
+			return nil, err
+		}
+	}
+
+	singleton := 0
+	if job.Singleton() {
+		singleton = 1
+	}
+
+	id := uuid.New().String()
+	_, err := js.redisService.RunScript(ctx, startJobScript,
+		[]string{js.keys.JobRun(id), js.keys.JobRuns(name), js.keys.JobQueue(), js.keys.JobActive(name)},
+		id, name, data, js.now().Unix(), singleton, jobRunsLen,
+	)
+	if err != nil && strings.HasPrefix(err.Error(), "JOB_ACTIVE ") {
+		return nil, api.NewError(api.ErrConflict, fmt.Sprintf("%s is running already as %s", name, strings.TrimPrefix(err.Error(), "JOB_ACTIVE ")))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return js.GetRun(ctx, name, id)
+}
+
+// Runs lists the latest runs of the job, newest first.
+func (js *JobService) Runs(ctx context.Context, name string) ([]*api.JobRun, error) {
+	if _, ok := js.jobs[name]; !ok {
+		return nil, ErrJobNotFound
+	}
+
+	ids, err := js.redisService.GetList(ctx, js.keys.JobRuns(name), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*api.JobRun, 0, len(ids))
+	for _, id := range ids {
+		run, _, err := js.readRun(ctx, id)
+		if errors.Is(err, ErrJobRunNotFound) {
+			// finished runs expire after their retention
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+func (js *JobService) GetRun(ctx context.Context, name string, id string) (*api.JobRun, error) {
+	if _, ok := js.jobs[name]; !ok {
+		return nil, ErrJobNotFound
+	}
+
+	run, _, err := js.readRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Job != name {
+		return nil, ErrJobRunNotFound
+	}
+
+	return run, nil
+}
+
+// StopRun cancels a run, its context is cancelled when its instance renews
+// its lease.
+func (js *JobService) StopRun(ctx context.Context, name string, id string) (*api.JobRun, error) {
+	return js.transition(ctx, name, id, "stop")
+}
+
+// PauseRun pauses a running run, it stays with its instance until it is
+// resumed.
+func (js *JobService) PauseRun(ctx context.Context, name string, id string) (*api.JobRun, error) {
+	return js.transition(ctx, name, id, "pause")
+}
+
+func (js *JobService) ResumeRun(ctx context.Context, name string, id string) (*api.JobRun, error) {
+	return js.transition(ctx, name, id, "resume")
+}
+
+func (js *JobService) transition(ctx context.Context, name string, id string, action string) (*api.JobRun, error) {
+	if _, err := js.GetRun(ctx, name, id); err != nil {
+		return nil, err
+	}
+
+	_, err := js.redisService.RunScript(ctx, transitionJobScript,
+		[]string{js.keys.JobRun(id), js.keys.JobRunLock(id), js.keys.JobQueue(), js.keys.JobActive(name)},
+		action, id, js.now().Unix(), int64(jobRunRetention/time.Second),
+	)
+	if err != nil {
+		switch {
+		case err.Error() == "RUN_NOT_FOUND":
+			return nil, ErrJobRunNotFound
+		case strings.HasPrefix(err.Error(), "INVALID_TRANSITION "):
+			state := strings.TrimPrefix(err.Error(), "INVALID_TRANSITION ")
+			return nil, api.NewError(api.ErrConflict, fmt.Sprintf("can not %s a run which is %s", action, state))
+		default:
+			return nil, err
+		}
+	}
+
+	return js.GetRun(ctx, name, id)
+}
+
+// readRun returns a run along with its parameters as they are stored.
+func (js *JobService) readRun(ctx context.Context, id string) (*api.JobRun, string, error) {
+	fields, err := js.redisService.HGetAll(ctx, js.keys.JobRun(id)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(fields) == 0 {
+		return nil, "", ErrJobRunNotFound
+	}
+
+	run := &api.JobRun{
+		Id:    fields["id"],
+		Job:   fields["job"],
+		State: fields["state"],
+		Error: fields["error"],
+		Owner: fields["owner"],
+	}
+
+	numbers := map[string]*int64{
+		"done":        &run.Done,
+		"total":       &run.Total,
+		"queued_at":   &run.QueuedAt,
+		"started_at":  &run.StartedAt,
+		"updated_at":  &run.UpdatedAt,
+		"finished_at": &run.FinishedAt,
+	}
+	for field, number := range numbers {
+		if len(fields[field]) == 0 {
+			continue
+		}
+
+		if *number, err = strconv.ParseInt(fields[field], 10, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid %s of job run %s: %w", field, id, err)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(fields["parameters"]), &run.Parameters); err != nil {
+		return nil, "", fmt.Errorf("invalid parameters of job run %s: %w", id, err)
+	}
+
+	return run, fields["parameters"], nil
+}
+
+// instanceName names the instance by its host name and pid.
+func instanceName() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func (js *JobService) dispatch() {
+	defer js.dispatcher.Done()
+
+	poll := time.NewTicker(js.configuration.PollInterval)
+	defer poll.Stop()
+	recovery := time.NewTicker(js.configuration.LeaseTTL)
+	defer recovery.Stop()
+
+	for {
+		select {
+		case <-js.stop:
+			return
+		case <-recovery.C:
+			js.recoverRuns()
+		case <-poll.C:
+			js.claimRuns()
+		}
+	}
+}
+
+// claimRuns claims queued runs while the instance has free workers.
+func (js *JobService) claimRuns() {
+	for {
+		select {
+		case js.slots <- struct{}{}:
+		default:
+			return
+		}
+
+		token := uuid.New().String()
+		id, fence, err := js.claim(token)
+		if err != nil {
+			<-js.slots
+			if err != redis.Nil {
+				log.Error(err)
+			}
+			return
+		}
+
+		lease := &Lease{
+			lock:  NewLeaseLock(js.redisService, js.keys.JobRunLock(id), js.configuration.LeaseTTL),
+			owner: js.configuration.Instance,
+			token: token,
+			fence: fence,
+		}
+
+		js.runs.Add(1)
+		go js.execute(id, lease)
+	}
+}
+
+// claim leases the first queued run which is left to claim, with its id
+// read ahead of claimJobScript so the script is given the keys of the run.
+// It returns redis.Nil when the queue is empty.
+func (js *JobService) claim(token string) (string, int64, error) {
+	for {
+		ids, err := js.redisService.GetList(js.context, js.keys.JobQueue(), 0, 0)
+		if err != nil {
+			return "", 0, err
+		}
+		if len(ids) == 0 {
+			return "", 0, redis.Nil
+		}
+
+		id := ids[0]
+		fence, err := js.redisService.RunScript(js.context, claimJobScript,
+			[]string{js.keys.JobQueue(), js.keys.JobRun(id), js.keys.JobRunLock(id)},
+			id, js.configuration.Instance, token, js.configuration.LeaseTTL.Milliseconds(), js.now().Unix(),
+		)
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return "", 0, err
+		}
+
+		return id, fence.(int64), nil
+	}
+}
+
+// recoverRuns releases the runs whose lease lapsed, their instances are
+// gone. One instance recovers the runs per lease, the lease is left to
+// lapse.
+func (js *JobService) recoverRuns() {
+	recovery := NewLeaseLock(js.redisService, js.keys.Lock("jobs:recovery"), js.configuration.LeaseTTL)
+	if _, err := recovery.TryAcquire(js.context, js.configuration.Instance); err != nil {
+		if err != ErrLockHeld {
+			log.Error(err)
+		}
+		return
+	}
+
+	for name := range js.jobs {
+		ids, err := js.redisService.GetList(js.context, js.keys.JobRuns(name), 0, -1)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		for _, id := range ids {
+			if err := js.release(id, name, ""); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+func (js *JobService) execute(id string, lease *Lease) {
+	defer js.runs.Done()
+	defer func() { <-js.slots }()
+
+	// a run claimed while the instance stops is still read to release it
+	readCtx, cancelRead := context.WithTimeout(context.Background(), js.configuration.LeaseTTL/3)
+	run, parameters, err := js.readRun(readCtx, id)
+	cancelRead()
+	if err != nil {
+		// the lease lapses and another instance tries again
+		log.Error(err)
+		return
+	}
+
+	running := &RunningJob{id: id, done: run.Done, total: run.Total, fence: lease.fence}
+	job, ok := js.jobs[run.Job]
+	if !ok {
+		js.finish(id, lease, run.Job, running, fmt.Errorf("%s is not registered on %s", run.Job, js.configuration.Instance))
+		return
+	}
+
+	if running.parameters = job.Parameters(); running.parameters != nil {
+		if err := json.Unmarshal([]byte(parameters), running.parameters); err != nil {
+			js.finish(id, lease, run.Job, running, fmt.Errorf("invalid parameters: %w", err))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(js.context)
+	defer cancel()
+
+	var lost int32
+	finished := make(chan struct{})
+	var heartbeat sync.WaitGroup
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+
+		ticker := time.NewTicker(js.configuration.LeaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-finished:
+				return
+			case <-ticker.C:
+			}
+
+			state, err := js.renew(id, lease, running)
+			switch {
+			case err == ErrLeaseLost:
+				atomic.StoreInt32(&lost, 1)
+				cancel()
+				return
+			case err != nil:
+				// the lease may still be renewed before it lapses
+				log.Error(err)
+			case state == JobStateCancelled:
+				cancel()
+			default:
+				running.setPaused(state == JobStatePaused)
+			}
+		}
+	}()
+
+	err = job.Run(ctx, running)
+	close(finished)
+	heartbeat.Wait()
+
+	switch {
+	case atomic.LoadInt32(&lost) == 1:
+		log.Errorf("job run %s of %s is taken over by another instance", id, run.Job)
+	case js.context.Err() != nil:
+		// the instance stops, another one resumes the run
+		if _, err := js.renew(id, lease, running); err != nil {
+			log.Error(err)
+		}
+		if err := js.release(id, run.Job, lease.token); err != nil {
+			log.Error(err)
+		}
+	default:
+		js.finish(id, lease, run.Job, running, err)
+	}
+}
+
+func (js *JobService) renew(id string, lease *Lease, running *RunningJob) (string, error) {
+	// progress is recorded even while the instance stops
+	ctx, cancel := context.WithTimeout(context.Background(), js.configuration.LeaseTTL/3)
+	defer cancel()
+
+	state, err := js.redisService.RunScript(ctx, renewJobScript,
+		[]string{js.keys.JobRun(id), js.keys.JobRunLock(id)},
+		lease.token, js.configuration.LeaseTTL.Milliseconds(), running.Done(), running.Total(), js.now().Unix(), lease.fence,
+	)
+	if err != nil && err.Error() == "LEASE_LOST" {
+		return "", ErrLeaseLost
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprint(state), nil
+}
+
+func (js *JobService) finish(id string, lease *Lease, name string, running *RunningJob, err error) {
+	state, message := JobStateDone, ""
+	if err != nil {
+		state, message = JobStateFailed, err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), js.configuration.LeaseTTL)
+	defer cancel()
+
+	_, err = js.redisService.RunScript(ctx, finishJobScript,
+		[]string{js.keys.JobRun(id), js.keys.JobRunLock(id), js.keys.JobActive(name)},
+		lease.token, id, state, message, running.Done(), running.Total(), js.now().Unix(), int64(jobRunRetention/time.Second), lease.fence,
+	)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (js *JobService) release(id string, name string, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), js.configuration.LeaseTTL)
+	defer cancel()
+
+	_, err := js.redisService.RunScript(ctx, releaseJobScript,
+		[]string{js.keys.JobRun(id), js.keys.JobRunLock(id), js.keys.JobQueue(), js.keys.JobActive(name)},
+		token, id, js.now().Unix(), int64(jobRunRetention/time.Second),
+	)
+
+	return err
+}
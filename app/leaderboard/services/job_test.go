@@ -0,0 +1,348 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const testLeaseTTL = 300 * time.Millisecond
+
+type countParameters struct {
+	Count int64 `json:"count" validate:"gte=0"`
+}
+
+// countJob counts up to its count, forever when the count is 0.
+type countJob struct {
+	name       string
+	concurrent bool
+	steps      int64
+	err        error
+}
+
+func (j *countJob) Name() string {
+	return j.name
+}
+
+func (j *countJob) Singleton() bool {
+	return !j.concurrent
+}
+
+func (j *countJob) Parameters() interface{} {
+	return new(countParameters)
+}
+
+func (j *countJob) Run(ctx context.Context, run *services.RunningJob) error {
+	parameters := run.Parameters().(*countParameters)
+	run.SetTotal(parameters.Count)
+
+	for parameters.Count == 0 || run.Done() < parameters.Count {
+		if err := run.Wait(ctx); err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&j.steps, 1)
+		run.Progress(1)
+		if parameters.Count == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	return j.err
+}
+
+// undeclaredKeys records the keys which scripts create without declaring
+// them. The commands are run one at a time, so the keys which show up while
+// a script runs are its own.
+type undeclaredKeys struct {
+	sync.Mutex
+	before map[string]bool
+	keys   []string
+}
+
+func (u *undeclaredKeys) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	u.Lock()
+	u.before = map[string]bool{}
+	for _, key := range mRedis.Keys() {
+		u.before[key] = true
+	}
+
+	return ctx, nil
+}
+
+func (u *undeclaredKeys) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	defer u.Unlock()
+
+	args := cmd.Args()
+	if name := cmd.Name(); (name != "evalsha" && name != "eval") || len(args) < 3 {
+		return nil
+	}
+	declared := scriptKeys{}
+	keys, _ := args[2].(int)
+	for _, key := range args[3 : 3+keys] {
+		declared[fmt.Sprint(key)] = true
+	}
+	for _, key := range mRedis.Keys() {
+		if !u.before[key] && !declared[key] {
+			u.keys = append(u.keys, key)
+		}
+	}
+
+	return nil
+}
+
+func (*undeclaredKeys) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (*undeclaredKeys) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+var _ = Describe("the job service", func() {
+	var (
+		redisService api.RedisService
+		job          *countJob
+		started      []*services.JobService
+	)
+
+	newJobService := func(instance string) *services.JobService {
+		jobService := services.NewJobService(redisService, services.NewKeySchema(KeyPrefix), &services.JobConfiguration{
+			Instance:     instance,
+			Workers:      1,
+			LeaseTTL:     testLeaseTTL,
+			PollInterval: 10 * time.Millisecond,
+		})
+		jobService.Register(job)
+
+		return jobService
+	}
+
+	start := func(jobService *services.JobService) {
+		jobService.Start()
+		started = append(started, jobService)
+	}
+
+	state := func(jobService *services.JobService, id string) func() string {
+		return func() string {
+			run, err := jobService.GetRun(ctx, job.name, id)
+			Expect(err).To(BeNil())
+			return run.State
+		}
+	}
+
+	BeforeEach(func() {
+		_, redisService = buildDependencies(mRedis.Addr())
+		job = &countJob{name: "count"}
+		started = nil
+	})
+
+	AfterEach(func() {
+		for _, jobService := range started {
+			jobService.Stop()
+		}
+		mRedis.FlushAll()
+	})
+
+	It("runs a run to the end and records its progress", func() {
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{Count: 5})
+		Expect(err).To(BeNil())
+		Expect(run.State).To(Equal(services.JobStateQueued))
+		Expect(run.Parameters).To(HaveKeyWithValue("count", BeEquivalentTo(5)))
+
+		Eventually(state(jobService, run.Id)).Should(Equal(services.JobStateDone))
+
+		run, err = jobService.GetRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+		Expect(run.Done).To(BeEquivalentTo(5))
+		Expect(run.Total).To(BeEquivalentTo(5))
+		Expect(run.StartedAt).NotTo(BeZero())
+		Expect(run.FinishedAt).NotTo(BeZero())
+
+		jobs, err := jobService.Jobs(ctx)
+		Expect(err).To(BeNil())
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].LastRun.Id).To(Equal(run.Id))
+	})
+
+	It("fails a run whose job returns an error", func() {
+		job.err = errors.New("out of users")
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{Count: 1})
+		Expect(err).To(BeNil())
+
+		Eventually(state(jobService, run.Id)).Should(Equal(services.JobStateFailed))
+		run, err = jobService.GetRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+		Expect(run.Error).To(Equal("out of users"))
+	})
+
+	It("declares the keys of the runs it claims", func() {
+		undeclared := new(undeclaredKeys)
+		client := redis.NewClient(&redis.Options{Addr: mRedis.Addr()})
+		client.AddHook(undeclared)
+		redisService = services.NewRedisService(client, KeyPrefix)
+
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{Count: 1})
+		Expect(err).To(BeNil())
+
+		Eventually(state(jobService, run.Id)).Should(Equal(services.JobStateDone))
+		undeclared.Lock()
+		defer undeclared.Unlock()
+		Expect(undeclared.keys).To(BeEmpty())
+	})
+
+	It("refuses a second run of a singleton job until the first one is finished", func() {
+		jobService := newJobService("first")
+
+		first, err := jobService.StartRun(ctx, job.name, &countParameters{Count: 1})
+		Expect(err).To(BeNil())
+
+		_, err = jobService.StartRun(ctx, job.name, &countParameters{Count: 1})
+		Expect(errors.Is(err, api.ErrConflict)).To(BeTrue())
+
+		stopped, err := jobService.StopRun(ctx, job.name, first.Id)
+		Expect(err).To(BeNil())
+		Expect(stopped.State).To(Equal(services.JobStateCancelled))
+
+		_, err = jobService.StartRun(ctx, job.name, &countParameters{Count: 1})
+		Expect(err).To(BeNil())
+	})
+
+	It("cancels the context of a stopped run", func() {
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{})
+		Expect(err).To(BeNil())
+		Eventually(state(jobService, run.Id)).Should(Equal(services.JobStateRunning))
+
+		_, err = jobService.StopRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+
+		Eventually(func() int64 {
+			run, err := jobService.GetRun(ctx, job.name, run.Id)
+			Expect(err).To(BeNil())
+			return run.FinishedAt
+		}).ShouldNot(BeZero())
+
+		run, err = jobService.GetRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+		Expect(run.State).To(Equal(services.JobStateCancelled))
+		Expect(run.Error).To(BeEmpty())
+	})
+
+	It("pauses and resumes a run", func() {
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{})
+		Expect(err).To(BeNil())
+		Eventually(state(jobService, run.Id)).Should(Equal(services.JobStateRunning))
+
+		_, err = jobService.PauseRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+
+		// the instance learns about the pause when it renews the lease
+		time.Sleep(testLeaseTTL)
+		steps := atomic.LoadInt64(&job.steps)
+		Consistently(func() int64 {
+			return atomic.LoadInt64(&job.steps)
+		}, testLeaseTTL).Should(Equal(steps))
+
+		_, err = jobService.PauseRun(ctx, job.name, run.Id)
+		Expect(errors.Is(err, api.ErrConflict)).To(BeTrue())
+
+		_, err = jobService.ResumeRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+		Eventually(func() int64 {
+			return atomic.LoadInt64(&job.steps)
+		}).Should(BeNumerically(">", steps))
+	})
+
+	It("queues the runs of a stopping instance for another one to resume", func() {
+		first := newJobService("first")
+		first.Start()
+
+		run, err := first.StartRun(ctx, job.name, &countParameters{})
+		Expect(err).To(BeNil())
+		Eventually(func() int64 {
+			return atomic.LoadInt64(&job.steps)
+		}).Should(BeNumerically(">", 0))
+
+		first.Stop()
+		run, err = first.GetRun(ctx, job.name, run.Id)
+		Expect(err).To(BeNil())
+		Expect(run.State).To(Equal(services.JobStateQueued))
+		Expect(run.Done).To(BeNumerically(">", 0))
+
+		second := newJobService("second")
+		start(second)
+		Eventually(func() string {
+			run, err := second.GetRun(ctx, job.name, run.Id)
+			Expect(err).To(BeNil())
+			return run.Owner
+		}).Should(Equal("second"))
+	})
+
+	It("resumes the runs of an instance which is gone once their lease lapsed", func() {
+		jobService := newJobService("second")
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{Count: 3})
+		Expect(err).To(BeNil())
+
+		// an instance claimed the run and vanished without a lease
+		_, err = mRedis.Lpop(services.NewKeySchema(KeyPrefix).JobQueue())
+		Expect(err).To(BeNil())
+		mRedis.HSet(services.NewKeySchema(KeyPrefix).JobRun(run.Id), "state", services.JobStateRunning)
+		mRedis.HSet(services.NewKeySchema(KeyPrefix).JobRun(run.Id), "owner", "first")
+
+		start(jobService)
+		Eventually(state(jobService, run.Id), 3*testLeaseTTL).Should(Equal(services.JobStateDone))
+	})
+
+	It("refuses the progress of a lease which was fenced off", func() {
+		jobService := newJobService("first")
+		start(jobService)
+
+		run, err := jobService.StartRun(ctx, job.name, &countParameters{})
+		Expect(err).To(BeNil())
+		Eventually(func() int64 {
+			run, err := jobService.GetRun(ctx, job.name, run.Id)
+			Expect(err).To(BeNil())
+			return run.Done
+		}).Should(BeNumerically(">", 0))
+
+		// a later lease wrote to the run
+		key := services.NewKeySchema(KeyPrefix).JobRun(run.Id)
+		mRedis.HSet(key, "fence", "100")
+		mRedis.HSet(key, "done", "0")
+
+		Consistently(func() int64 {
+			run, err := jobService.GetRun(ctx, job.name, run.Id)
+			Expect(err).To(BeNil())
+			return run.Done
+		}, testLeaseTTL).Should(BeZero())
+	})
+
+	It("does not know unregistered jobs", func() {
+		_, err := newJobService("first").StartRun(ctx, "unknown", nil)
+		Expect(err).To(MatchError(services.ErrJobNotFound))
+	})
+})
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []*jsonWebKey `json:"keys"`
+}
+
+// JWTAuthenticator validates bearer tokens signed with one of the RSA or
+// EC keys of a JWKS. The role of the principal is read from the role
+// claim and its scopes from the space separated scope claim.
+type JWTAuthenticator struct {
+	keys     map[string]interface{}
+	issuer   string
+	audience string
+	parser   *jwt.Parser
+}
+
+func LoadJWTAuthenticator(jwksPath string, issuer string, audience string) (*JWTAuthenticator, error) {
+	data, err := ioutil.ReadFile(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := new(jsonWebKeySet)
+	if err := json.Unmarshal(data, keySet); err != nil {
+		return nil, fmt.Errorf("malformed jwks file (%s): %w", jwksPath, err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, key := range keySet.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwk (%s): %w", key.Kid, err)
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		parser: &jwt.Parser{
+			ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		},
+	}, nil
+}
+
+func (ja *JWTAuthenticator) Authenticate(header http.Header) (*api.Principal, error) {
+	authorization := header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := ja.parser.ParseWithClaims(strings.TrimPrefix(authorization, "Bearer "), claims, ja.getKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidCredentials)
+	}
+
+	if len(ja.issuer) > 0 && !claims.VerifyIssuer(ja.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+
+	if len(ja.audience) > 0 && !hasAudience(claims, ja.audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+
+	subject, _ := claims["sub"].(string)
+	role, _ := claims["role"].(string)
+	if len(subject) == 0 || !isRole(role) {
+		return nil, fmt.Errorf("%w: missing subject or role", ErrInvalidCredentials)
+	}
+
+	scope, _ := claims["scope"].(string)
+
+	return &api.Principal{Subject: subject, Role: role, Scopes: strings.Fields(scope)}, nil
+}
+
+func (ja *JWTAuthenticator) getKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ja.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// the key type must match the algorithm, otherwise the token is forged
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	}
+
+	return key, nil
+}
+
+// hasAudience accepts the aud claim both as a string and as an array.
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"strings"
+)
+
+const keyMigrationBatchSize = 1000
+
+// KeyMigrationReport counts the keys a migration step went through, Removed
+// counts the members and the profiles Sync removed.
+type KeyMigrationReport struct {
+	Boards   int
+	Members  int
+	Profiles int
+	Removed  int
+}
+
+// KeyMigrator moves the legacy layout, boards keyed as <prefix><board> and
+// profiles keyed by the bare user id, to the key schema. Profiles are found
+// through the members of the legacy boards, every user is ranked on one.
+//
+// Copying only adds what the new layout misses, so it runs online and as
+// often as needed, while the old version serves and during the rollout. It
+// records the legacy scores and profiles as it first copies them. Once the
+// old instances are gone, Sync carries over what they changed after the
+// copy: scores and profiles which differ from the recorded ones overwrite
+// the new layout, members and profiles they removed are removed from it.
+// What the new instances wrote for the others is kept. Cleanup deletes the
+// legacy keys and the records once every one of them is copied.
+type KeyMigrator struct {
+	client       redis.UniversalClient
+	legacyPrefix string
+	keys         *KeySchema
+}
+
+func NewKeyMigrator(client redis.UniversalClient, legacyPrefix string, keys *KeySchema) *KeyMigrator {
+	return &KeyMigrator{client: client, legacyPrefix: legacyPrefix, keys: keys}
+}
+
+func (km *KeyMigrator) Copy(ctx context.Context) (*KeyMigrationReport, error) {
+	report := new(KeyMigrationReport)
+	err := km.forEachLegacyBoard(ctx, func(board string, members []*redis.Z) error {
+		writes := km.client.Pipeline()
+		writes.ZAddNX(ctx, km.keys.Board(board), members...)
+		writes.ZAddNX(ctx, km.keys.MigrationBoard(board), members...)
+		if _, err := writes.Exec(ctx); err != nil {
+			return err
+		}
+
+		profiles, err := km.copyProfiles(ctx, members)
+		if err != nil {
+			return err
+		}
+
+		report.Members += len(members)
+		report.Profiles += profiles
+		return nil
+	}, func(string) error {
+		report.Boards++
+		return nil
+	})
+
+	return report, err
+}
+
+// Sync carries over what the old instances changed on the legacy keys after
+// Copy recorded them. It runs once they no longer write, Members and
+// Profiles count what it overwrote.
+func (km *KeyMigrator) Sync(ctx context.Context) (*KeyMigrationReport, error) {
+	report := new(KeyMigrationReport)
+	err := km.forEachLegacyBoard(ctx, func(board string, members []*redis.Z) error {
+		changed, err := km.syncMembers(ctx, board, members)
+		if err != nil {
+			return err
+		}
+
+		profiles, err := km.syncProfiles(ctx, members)
+		if err != nil {
+			return err
+		}
+
+		report.Members += changed
+		report.Profiles += profiles
+		return nil
+	}, func(string) error {
+		report.Boards++
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	removed, err := km.removeMembers(ctx)
+	report.Removed += removed
+	if err != nil {
+		return report, err
+	}
+
+	removed, err = km.removeProfiles(ctx)
+	report.Removed += removed
+
+	return report, err
+}
+
+func (km *KeyMigrator) Cleanup(ctx context.Context) (*KeyMigrationReport, error) {
+	report := new(KeyMigrationReport)
+	err := km.forEachLegacyBoard(ctx, func(board string, members []*redis.Z) error {
+		profiles, err := km.deleteProfiles(ctx, board, members)
+		if err != nil {
+			return err
+		}
+
+		report.Members += len(members)
+		report.Profiles += profiles
+		return nil
+	}, func(board string) error {
+		if err := km.client.Del(ctx, km.legacyPrefix+board).Err(); err != nil {
+			return err
+		}
+
+		report.Boards++
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	// the records share the hash tag, they are deleted at once
+	records, err := scanKeys(ctx, km.client, km.keys.MigrationPrefix()+"*")
+	if err != nil || len(records) == 0 {
+		return report, err
+	}
+
+	return report, km.client.Del(ctx, records...).Err()
+}
+
+// forEachLegacyBoard passes the members of every legacy board in batches
+// to onMembers, then calls onBoard once the board is done.
+func (km *KeyMigrator) forEachLegacyBoard(ctx context.Context, onMembers func(string, []*redis.Z) error, onBoard func(string) error) error {
+	legacyKeys, err := scanKeys(ctx, km.client, km.legacyPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	for _, legacyKey := range legacyKeys {
+		board := strings.TrimPrefix(legacyKey, km.legacyPrefix)
+
+		members := make([]*redis.Z, 0, keyMigrationBatchSize)
+		iter := km.client.ZScan(ctx, legacyKey, 0, "", keyMigrationBatchSize).Iterator()
+		for iter.Next(ctx) {
+			member := iter.Val()
+			if !iter.Next(ctx) {
+				break
+			}
+
+			score, err := strconv.ParseFloat(iter.Val(), 64)
+			if err != nil {
+				return fmt.Errorf("malformed score of %s on %s: %w", member, legacyKey, err)
+			}
+
+			members = append(members, &redis.Z{Member: member, Score: score})
+			if len(members) == keyMigrationBatchSize {
+				if err := onMembers(board, members); err != nil {
+					return err
+				}
+				members = members[:0]
+			}
+		}
+
+		if err := iter.Err(); err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			if err := onMembers(board, members); err != nil {
+				return err
+			}
+		}
+
+		if err := onBoard(board); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyProfiles sets the fields the new profiles miss, and returns the
+// number of legacy profiles found.
+func (km *KeyMigrator) copyProfiles(ctx context.Context, members []*redis.Z) (int, error) {
+	reads := km.client.Pipeline()
+	legacyProfiles := make([]*redis.StringStringMapCmd, len(members))
+	for i, member := range members {
+		legacyProfiles[i] = reads.HGetAll(ctx, member.Member.(string))
+	}
+	// errors are checked per command, members may share their id with a
+	// key which is not a profile
+	_, _ = reads.Exec(ctx)
+
+	found := 0
+	writes := km.client.Pipeline()
+	for i, member := range members {
+		fields, err := legacyProfiles[i].Result()
+		if isWrongType(err) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if len(fields["display_name"]) == 0 {
+			continue
+		}
+
+		record, err := json.Marshal(fields)
+		if err != nil {
+			return 0, err
+		}
+
+		found++
+		id := member.Member.(string)
+		for field, value := range fields {
+			writes.HSetNX(ctx, km.keys.Profile(id), field, value)
+		}
+		writes.HSetNX(ctx, km.keys.MigrationProfiles(), id, record)
+	}
+
+	if found == 0 {
+		return 0, nil
+	}
+
+	_, err := writes.Exec(ctx)
+	return found, err
+}
+
+// syncMembers overwrites the members whose legacy score differs from the
+// recorded one, or which were not recorded, and returns how many.
+func (km *KeyMigrator) syncMembers(ctx context.Context, board string, members []*redis.Z) (int, error) {
+	reads := km.client.Pipeline()
+	recorded := make([]*redis.FloatCmd, len(members))
+	for i, member := range members {
+		recorded[i] = reads.ZScore(ctx, km.keys.MigrationBoard(board), member.Member.(string))
+	}
+	// a member which was not recorded fails its command with redis.Nil
+	_, _ = reads.Exec(ctx)
+
+	var changed []*redis.Z
+	for i, member := range members {
+		score, err := recorded[i].Result()
+		if err != nil && err != redis.Nil {
+			return 0, err
+		}
+
+		if err == redis.Nil || score != member.Score {
+			changed = append(changed, member)
+		}
+	}
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	writes := km.client.Pipeline()
+	writes.ZAdd(ctx, km.keys.Board(board), changed...)
+	writes.ZAdd(ctx, km.keys.MigrationBoard(board), changed...)
+	_, err := writes.Exec(ctx)
+
+	return len(changed), err
+}
+
+// syncProfiles overwrites the profiles whose legacy fields differ from the
+// recorded ones, or which were not recorded, and returns how many.
+func (km *KeyMigrator) syncProfiles(ctx context.Context, members []*redis.Z) (int, error) {
+	reads := km.client.Pipeline()
+	legacyProfiles := make([]*redis.StringStringMapCmd, len(members))
+	recorded := make([]*redis.StringCmd, len(members))
+	for i, member := range members {
+		id := member.Member.(string)
+		legacyProfiles[i] = reads.HGetAll(ctx, id)
+		recorded[i] = reads.HGet(ctx, km.keys.MigrationProfiles(), id)
+	}
+	_, _ = reads.Exec(ctx)
+
+	synced := 0
+	writes := km.client.Pipeline()
+	for i, member := range members {
+		fields, err := legacyProfiles[i].Result()
+		if isWrongType(err) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if len(fields["display_name"]) == 0 {
+			continue
+		}
+
+		record, err := json.Marshal(fields)
+		if err != nil {
+			return 0, err
+		}
+
+		previous, err := recorded[i].Result()
+		if err != nil && err != redis.Nil {
+			return 0, err
+		}
+
+		if err == nil && previous == string(record) {
+			continue
+		}
+
+		id := member.Member.(string)
+		values := make([]interface{}, 0, 2*len(fields))
+		for field, value := range fields {
+			values = append(values, field, value)
+		}
+		writes.HSet(ctx, km.keys.Profile(id), values...)
+		writes.HSet(ctx, km.keys.MigrationProfiles(), id, record)
+		synced++
+	}
+
+	if synced == 0 {
+		return 0, nil
+	}
+
+	_, err := writes.Exec(ctx)
+	return synced, err
+}
+
+// removeMembers removes the recorded members which are not on their legacy
+// board anymore, and returns how many.
+func (km *KeyMigrator) removeMembers(ctx context.Context) (int, error) {
+	recordedBoards, err := scanKeys(ctx, km.client, km.keys.MigrationBoardPattern())
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, recordedBoard := range recordedBoards {
+		board := km.keys.MigrationBoardName(recordedBoard)
+
+		var ids []string
+		iter := km.client.ZScan(ctx, recordedBoard, 0, "", keyMigrationBatchSize).Iterator()
+		for iter.Next(ctx) {
+			ids = append(ids, iter.Val())
+			// the score is not needed
+			if !iter.Next(ctx) {
+				break
+			}
+		}
+
+		if err := iter.Err(); err != nil {
+			return removed, err
+		}
+
+		for start := 0; start < len(ids); start += keyMigrationBatchSize {
+			end := start + keyMigrationBatchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+
+			count, err := km.removeBatch(ctx, board, ids[start:end])
+			removed += count
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+func (km *KeyMigrator) removeBatch(ctx context.Context, board string, ids []string) (int, error) {
+	reads := km.client.Pipeline()
+	legacyScores := make([]*redis.FloatCmd, len(ids))
+	for i, id := range ids {
+		legacyScores[i] = reads.ZScore(ctx, km.legacyPrefix+board, id)
+	}
+	// a member which is gone fails its command with redis.Nil
+	_, _ = reads.Exec(ctx)
+
+	var gone []interface{}
+	for i, id := range ids {
+		if err := legacyScores[i].Err(); err == redis.Nil {
+			gone = append(gone, id)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(gone) == 0 {
+		return 0, nil
+	}
+
+	writes := km.client.Pipeline()
+	writes.ZRem(ctx, km.keys.Board(board), gone...)
+	writes.ZRem(ctx, km.keys.MigrationBoard(board), gone...)
+	_, err := writes.Exec(ctx)
+
+	return len(gone), err
+}
+
+// removeProfiles deletes the recorded profiles which are not on the legacy
+// layout anymore, and returns how many.
+func (km *KeyMigrator) removeProfiles(ctx context.Context) (int, error) {
+	var ids []string
+	iter := km.client.HScan(ctx, km.keys.MigrationProfiles(), 0, "", keyMigrationBatchSize).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, iter.Val())
+		// the record is not needed
+		if !iter.Next(ctx) {
+			break
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for start := 0; start < len(ids); start += keyMigrationBatchSize {
+		end := start + keyMigrationBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		reads := km.client.Pipeline()
+		exists := make([]*redis.IntCmd, end-start)
+		for i, id := range ids[start:end] {
+			exists[i] = reads.Exists(ctx, id)
+		}
+		if _, err := reads.Exec(ctx); err != nil {
+			return removed, err
+		}
+
+		writes := km.client.Pipeline()
+		gone := 0
+		for i, id := range ids[start:end] {
+			if exists[i].Val() == 0 {
+				writes.Del(ctx, km.keys.Profile(id))
+				writes.HDel(ctx, km.keys.MigrationProfiles(), id)
+				gone++
+			}
+		}
+
+		if gone > 0 {
+			if _, err := writes.Exec(ctx); err != nil {
+				return removed, err
+			}
+		}
+		removed += gone
+	}
+
+	return removed, nil
+}
+
+// deleteProfiles deletes the legacy profiles of the members, after making
+// sure the members and their profiles are copied.
+func (km *KeyMigrator) deleteProfiles(ctx context.Context, board string, members []*redis.Z) (int, error) {
+	checks := km.client.Pipeline()
+	copiedMembers := make([]*redis.FloatCmd, len(members))
+	copiedProfiles := make([]*redis.IntCmd, len(members))
+	legacyTypes := make([]*redis.StatusCmd, len(members))
+	for i, member := range members {
+		id := member.Member.(string)
+		copiedMembers[i] = checks.ZScore(ctx, km.keys.Board(board), id)
+		copiedProfiles[i] = checks.Exists(ctx, km.keys.Profile(id))
+		legacyTypes[i] = checks.Type(ctx, id)
+	}
+	// a member missing on the new board fails its command with redis.Nil
+	_, _ = checks.Exec(ctx)
+
+	deletes := km.client.Pipeline()
+	deleted := 0
+	for i, member := range members {
+		if err := copiedMembers[i].Err(); err == redis.Nil {
+			return 0, fmt.Errorf("%s is not copied to board %s yet", member.Member, board)
+		} else if err != nil {
+			return 0, err
+		}
+
+		if err := legacyTypes[i].Err(); err != nil {
+			return 0, err
+		}
+
+		if legacyTypes[i].Val() != "hash" {
+			continue
+		}
+
+		if copiedProfiles[i].Err() != nil {
+			return 0, copiedProfiles[i].Err()
+		}
+
+		if copiedProfiles[i].Val() == 0 {
+			return 0, fmt.Errorf("profile of %s is not copied yet", member.Member)
+		}
+
+		deletes.Del(ctx, member.Member.(string))
+		deleted++
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	_, err := deletes.Exec(ctx)
+	return deleted, err
+}
+
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
@@ -0,0 +1,176 @@
+package services_test
+
+import (
+	"github.com/go-redis/redis/v8"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+)
+
+const LegacyPrefix = "USER_RANKING_"
+
+var _ = Describe("the key schema", func() {
+	It("tags the boards and the profiles of a namespace alike", func() {
+		keys := services.NewKeySchema("lb")
+		Expect(keys.Board("GLOBAL")).To(Equal("{lb}:board:GLOBAL"))
+		Expect(keys.Profile("a")).To(Equal("{lb}:profile:a"))
+		Expect(keys.BoardName(keys.Board("TR"))).To(Equal("TR"))
+	})
+})
+
+var _ = Describe("the key migrator", func() {
+	var (
+		migrator     *services.KeyMigrator
+		redisService *services.RedisService
+		userService  *services.UserService
+	)
+
+	JustBeforeEach(func() {
+		userService, redisService = buildDependencies(mRedis.Addr())
+		migrator = services.NewKeyMigrator(redis.NewClient(&redis.Options{Addr: mRedis.Addr()}), LegacyPrefix, redisService.Keys())
+
+		for id, country := range map[string]string{"a": "TR", "b": "TR", "c": "DE"} {
+			mRedis.HSet(id, "display_name", id, "country", country, "points", "0")
+			_, err := mRedis.ZAdd(LegacyPrefix+"GLOBAL", float64(len(id)), id)
+			Expect(err).To(BeNil())
+			_, err = mRedis.ZAdd(LegacyPrefix+country, float64(len(id)), id)
+			Expect(err).To(BeNil())
+		}
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	Context("KeyMigrator.Copy()", func() {
+		It("copies the boards and the profiles they rank", func() {
+			report, err := migrator.Copy(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.Boards).To(Equal(3))
+			Expect(report.Members).To(Equal(6))
+
+			names, err := redisService.GetBoardNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(names).To(ConsistOf("GLOBAL", "TR", "DE"))
+
+			profile, err := userService.GetByIDWithRank(ctx, "a", "TR")
+			Expect(err).To(BeNil())
+			Expect(profile.DisplayName).To(Equal("a"))
+			Expect(profile.Country).To(Equal("TR"))
+
+			size, err := redisService.GetSortedSetSize(ctx, "TR")
+			Expect(err).To(BeNil())
+			Expect(size).To(Equal(int64(2)))
+		})
+
+		It("keeps what the new layout has already", func() {
+			_, err := userService.Create(ctx, &api.UserProfile{UserId: "a", DisplayName: "renamed", Country: "TR", Points: 100})
+			Expect(err).To(BeNil())
+
+			_, err = migrator.Copy(ctx)
+			Expect(err).To(BeNil())
+
+			profile, err := userService.GetByID(ctx, "a")
+			Expect(err).To(BeNil())
+			Expect(profile.DisplayName).To(Equal("renamed"))
+			Expect(profile.Points).To(Equal(float64(100)))
+		})
+
+		It("can run again", func() {
+			_, err := migrator.Copy(ctx)
+			Expect(err).To(BeNil())
+			_, err = migrator.Copy(ctx)
+			Expect(err).To(BeNil())
+
+			size, err := redisService.GetSortedSetSize(ctx, "GLOBAL")
+			Expect(err).To(BeNil())
+			Expect(size).To(Equal(int64(3)))
+		})
+	})
+
+	Context("KeyMigrator.Sync()", func() {
+		JustBeforeEach(func() {
+			_, err := migrator.Copy(ctx)
+			Expect(err).To(BeNil())
+		})
+
+		It("carries over what the legacy keys got after the copy", func() {
+			// a moves to DE, b is removed, c scores
+			_, err := mRedis.ZRem(LegacyPrefix+"TR", "a")
+			Expect(err).To(BeNil())
+			_, err = mRedis.ZAdd(LegacyPrefix+"DE", 1, "a")
+			Expect(err).To(BeNil())
+			mRedis.HSet("a", "country", "DE")
+			for _, board := range []string{"GLOBAL", "TR"} {
+				_, err = mRedis.ZRem(LegacyPrefix+board, "b")
+				Expect(err).To(BeNil())
+			}
+			mRedis.Del("b")
+			_, err = mRedis.ZAdd(LegacyPrefix+"GLOBAL", 50, "c")
+			Expect(err).To(BeNil())
+
+			report, err := migrator.Sync(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.Members).To(Equal(2))
+			Expect(report.Profiles).To(Equal(1))
+			Expect(report.Removed).To(Equal(4))
+
+			profile, err := userService.GetByIDWithRank(ctx, "a", "DE")
+			Expect(err).To(BeNil())
+			Expect(profile.Country).To(Equal("DE"))
+			Expect(mRedis.Exists(redisService.Keys().Board("TR"))).To(BeFalse())
+
+			_, err = userService.GetByID(ctx, "b")
+			Expect(err).NotTo(BeNil())
+
+			score, err := mRedis.ZScore(redisService.Keys().Board("GLOBAL"), "c")
+			Expect(err).To(BeNil())
+			Expect(score).To(Equal(float64(50)))
+		})
+
+		It("keeps what only the new layout changed", func() {
+			_, err := mRedis.ZAdd(redisService.Keys().Board("GLOBAL"), 100, "a")
+			Expect(err).To(BeNil())
+
+			report, err := migrator.Sync(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.Members + report.Profiles + report.Removed).To(BeZero())
+
+			score, err := mRedis.ZScore(redisService.Keys().Board("GLOBAL"), "a")
+			Expect(err).To(BeNil())
+			Expect(score).To(Equal(float64(100)))
+		})
+	})
+
+	Context("KeyMigrator.Cleanup()", func() {
+		When("the keys are copied", func() {
+			It("deletes the legacy keys", func() {
+				_, err := migrator.Copy(ctx)
+				Expect(err).To(BeNil())
+
+				report, err := migrator.Cleanup(ctx)
+				Expect(err).To(BeNil())
+				Expect(report.Boards).To(Equal(3))
+				Expect(report.Profiles).To(Equal(3))
+
+				for _, key := range []string{"a", "b", "c", LegacyPrefix + "GLOBAL", LegacyPrefix + "TR"} {
+					Expect(mRedis.Exists(key)).To(BeFalse())
+				}
+
+				_, err = userService.GetByID(ctx, "c")
+				Expect(err).To(BeNil())
+
+				Expect(mRedis.Keys()).NotTo(ContainElement(ContainSubstring(":migration:")))
+			})
+		})
+
+		When("the keys are not copied", func() {
+			It("returns error and keeps them", func() {
+				_, err := migrator.Cleanup(ctx)
+				Expect(err).NotTo(BeNil())
+				Expect(mRedis.Exists("a")).To(BeTrue())
+			})
+		})
+	})
+})
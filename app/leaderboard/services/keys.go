@@ -0,0 +1,168 @@
+package services
+
+import "strings"
+
+const DefaultKeyNamespace = "lb"
+
+// KeySchema names the keys of the boards, their archives, the profiles, the
+// rank histories, the job runs and their imports, the schedules, the locks
+// and the key migration of a namespace.
+// Every one of them carries the namespace as its hash tag, e.g.
+// {lb}:board:GLOBAL and {lb}:profile:<user id>, so a cluster keeps them in a
+// single slot and a transaction or a script may update a profile along with
+// its boards. Namespaces are the unit of sharding, separate namespaces land
+// on separate slots.
+type KeySchema struct {
+	tag string
+}
+
+func NewKeySchema(namespace string) *KeySchema {
+	return &KeySchema{tag: "{" + namespace + "}"}
+}
+
+func (ks *KeySchema) Board(name string) string {
+	return ks.tag + ":board:" + name
+}
+
+// BoardName is the reverse of Board.
+func (ks *KeySchema) BoardName(key string) string {
+	return strings.TrimPrefix(key, ks.tag+":board:")
+}
+
+// BoardPattern matches the keys of every board, for SCAN.
+func (ks *KeySchema) BoardPattern() string {
+	return ks.tag + ":board:*"
+}
+
+func (ks *KeySchema) Profile(id string) string {
+	return ks.ProfilePrefix() + id
+}
+
+// ProfilePrefix is the key of a profile without its user id.
+func (ks *KeySchema) ProfilePrefix() string {
+	return ks.tag + ":profile:"
+}
+
+// ProfileId is the reverse of Profile.
+func (ks *KeySchema) ProfileId(key string) string {
+	return strings.TrimPrefix(key, ks.ProfilePrefix())
+}
+
+// ProfilePattern matches the keys of every profile, for SCAN.
+func (ks *KeySchema) ProfilePattern() string {
+	return ks.ProfilePrefix() + "*"
+}
+
+func (ks *KeySchema) RankHistory(board string, id string) string {
+	return ks.RankHistoryPrefix() + board + ":" + id
+}
+
+// RankHistoryPrefix is the key of a rank history without its board and
+// user id.
+func (ks *KeySchema) RankHistoryPrefix() string {
+	return ks.tag + ":history:"
+}
+
+func (ks *KeySchema) JobRun(id string) string {
+	return ks.tag + ":job:" + id
+}
+
+// JobRunLock is held by the instance running the run, the run counts the
+// fencing tokens of its leases.
+func (ks *KeySchema) JobRunLock(id string) string {
+	return ks.JobRun(id) + ":lock"
+}
+
+// Import holds the progress of a run of the import job, the rows it read
+// and how many of them it imported and rejected.
+func (ks *KeySchema) Import(id string) string {
+	return ks.JobRun(id) + ":import"
+}
+
+// ImportRejections lists the first rows a run of the import job rejected.
+func (ks *KeySchema) ImportRejections(id string) string {
+	return ks.JobRun(id) + ":rejections"
+}
+
+// JobRuns lists the latest runs of a job, newest first.
+func (ks *KeySchema) JobRuns(job string) string {
+	return ks.tag + ":jobs:" + job + ":runs"
+}
+
+// JobActive holds the run of a singleton job which is not finished yet.
+func (ks *KeySchema) JobActive(job string) string {
+	return ks.tag + ":jobs:" + job + ":active"
+}
+
+func (ks *KeySchema) JobQueue() string {
+	return ks.tag + ":jobs:queue"
+}
+
+// Schedule holds the last times of a schedule of the scheduler.
+func (ks *KeySchema) Schedule(name string) string {
+	return ks.tag + ":schedule:" + name
+}
+
+// Lock is the key of a LeaseLock.
+func (ks *KeySchema) Lock(name string) string {
+	return ks.tag + ":lock:" + name
+}
+
+// Archive is the key a board is archived under, by the label of the
+// archive.
+func (ks *KeySchema) Archive(board string, label string) string {
+	return ks.tag + ":archive:" + board + ":" + label
+}
+
+// ArchiveLabel is the reverse of Archive.
+func (ks *KeySchema) ArchiveLabel(board string, key string) string {
+	return strings.TrimPrefix(key, ks.tag+":archive:"+board+":")
+}
+
+// ArchivePattern matches the keys of the archives of a board, for SCAN.
+func (ks *KeySchema) ArchivePattern(board string) string {
+	return ks.tag + ":archive:" + board + ":*"
+}
+
+// ArchivesPattern matches the keys of the archives of every board, for SCAN.
+func (ks *KeySchema) ArchivesPattern() string {
+	return ks.tag + ":archive:*"
+}
+
+// MigrationBoard holds the scores of the members of a legacy board as the
+// key migration first copied them.
+func (ks *KeySchema) MigrationBoard(board string) string {
+	return ks.MigrationPrefix() + "board:" + board
+}
+
+// MigrationBoardName is the reverse of MigrationBoard.
+func (ks *KeySchema) MigrationBoardName(key string) string {
+	return strings.TrimPrefix(key, ks.MigrationPrefix()+"board:")
+}
+
+// MigrationBoardPattern matches the keys of every MigrationBoard, for SCAN.
+func (ks *KeySchema) MigrationBoardPattern() string {
+	return ks.MigrationPrefix() + "board:*"
+}
+
+// MigrationProfiles holds the legacy profiles as the key migration first
+// copied them, by user id.
+func (ks *KeySchema) MigrationProfiles() string {
+	return ks.MigrationPrefix() + "profiles"
+}
+
+// MigrationPrefix starts the keys of the key migration.
+func (ks *KeySchema) MigrationPrefix() string {
+	return ks.tag + ":migration:"
+}
+
+// ArchiveOf is the reverse of Archive, labels do not have a colon.
+func (ks *KeySchema) ArchiveOf(key string) (board string, label string) {
+	name := strings.TrimPrefix(key, ks.tag+":archive:")
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return name, ""
+	}
+
+	return name[:i], name[i+1:]
+}
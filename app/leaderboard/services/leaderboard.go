@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
 	"leaderboard/app/api"
 )
 
@@ -14,11 +17,31 @@ func NewLeaderboardService(userService *UserService, redisService api.RedisServi
 	return &LeaderboardService{userService: userService, redisService: redisService, leaderboardKeyPrefix: leaderboardKeyPrefix}
 }
 
-func (ls *LeaderboardService) GetPage(boardName string, page int64, pageSize int64) ([]*api.LeaderboardRow, error) {
-	rankingTuples, err := ls.redisService.GetPage(
-		boardName,
-		(page-1)*pageSize, page*pageSize-1,
-	)
+func (ls *LeaderboardService) GetPage(ctx context.Context, boardName string, page int64, pageSize int64) ([]*api.LeaderboardRow, error) {
+	return ls.getRange(ctx, boardName, (page-1)*pageSize, page*pageSize-1)
+}
+
+// GetAround returns the rows ranked within radius of the given user.
+func (ls *LeaderboardService) GetAround(ctx context.Context, boardName string, guid string, radius int64) ([]*api.LeaderboardRow, error) {
+	rank, err := ls.redisService.GetRank(ctx, boardName, guid)
+	if err == redis.Nil {
+		return nil, api.NewError(api.ErrNotFound, fmt.Sprintf("user with ID(%s) is not ranked on %s", guid, boardName))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex := rank - 1 - radius
+	if startIndex < 0 {
+		startIndex = 0
+	}
+
+	return ls.getRange(ctx, boardName, startIndex, rank-1+radius)
+}
+
+func (ls *LeaderboardService) getRange(ctx context.Context, boardName string, startIndex int64, endIndex int64) ([]*api.LeaderboardRow, error) {
+	rankingTuples, err := ls.redisService.GetPage(ctx, boardName, startIndex, endIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -33,19 +56,20 @@ func (ls *LeaderboardService) GetPage(boardName string, page int64, pageSize int
 		)
 	}
 
-	profiles, err := ls.userService.GetAllByID(userIds...)
+	profiles, err := ls.userService.GetAllByID(ctx, userIds...)
 	if err != nil {
 		return nil, err
 	}
 
 	var rows []*api.LeaderboardRow
 	for _, profile := range profiles {
-		rank, err := ls.redisService.GetRank(boardName, profile.UserId)
+		rank, err := ls.redisService.GetRank(ctx, boardName, profile.UserId)
 		if err != nil {
 			return nil, err
 		}
 
 		rows = append(rows, &api.LeaderboardRow{
+			UserId:      profile.UserId,
 			Rank:        rank,
 			Points:      int64(scoreMap[profile.UserId]),
 			DisplayName: profile.DisplayName,
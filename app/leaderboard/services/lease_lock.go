@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"leaderboard/app/api"
+	"time"
+)
+
+var (
+	ErrLockHeld  = api.NewError(api.ErrConflict, "lock is held by another owner")
+	ErrLeaseLost = errors.New("lease is lost")
+)
+
+// leaseLua defines the functions of the scripts which take, check or fence
+// leases, the scripts of LeaseLock and of the job runs share them.
+//
+// A lock is a hash of its owner, the token of its lease and the fencing
+// token of its lease, which expires unless it is renewed. Fencing tokens
+// are counted by the fence field of a counter hash, which outlives the lock,
+// so every lease has a greater one than the leases before it. A resource
+// written under a lease records the fencing token of the last write in its
+// fence field and refuses writes with lower ones, the writes of a holder
+// whose lease lapsed unnoticed, e.g. during a long pause, can not overwrite
+// the ones of the next holder.
+const leaseLua = `
+local function acquire_lease(lock, counter, owner, token, ttl)
+	if redis.call('EXISTS', lock) == 1 then
+		return false
+	end
+
+	local fence = redis.call('HINCRBY', counter, 'fence', 1)
+	redis.call('HSET', lock, 'owner', owner, 'token', token, 'fence', fence)
+	redis.call('PEXPIRE', lock, ttl)
+	return fence
+end
+
+local function holds_lease(lock, token)
+	return redis.call('HGET', lock, 'token') == token
+end
+
+local function fence_resource(resource, fence)
+	local seen = tonumber(redis.call('HGET', resource, 'fence') or '0')
+	if tonumber(fence) < seen then
+		return false
+	end
+
+	redis.call('HSET', resource, 'fence', fence)
+	return true
+end
+`
+
+// KEYS[1] lock, KEYS[2] counter of the fencing tokens, ARGV[1] owner,
+// ARGV[2] token, ARGV[3] lease in milliseconds
+//
+// returns the fencing token, nil when the lock is held
+var acquireLeaseScript = redis.NewScript(leaseLua + `
+return acquire_lease(KEYS[1], KEYS[2], ARGV[1], ARGV[2], ARGV[3])
+`)
+
+// KEYS[1] lock, ARGV[1] token, ARGV[2] lease in milliseconds
+var renewLeaseScript = redis.NewScript(leaseLua + `
+if not holds_lease(KEYS[1], ARGV[1]) then
+	return redis.error_reply('LEASE_LOST')
+end
+
+return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+`)
+
+// KEYS[1] lock, ARGV[1] token
+var releaseLeaseScript = redis.NewScript(leaseLua + `
+if holds_lease(KEYS[1], ARGV[1]) then
+	return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`)
+
+// LeaseLock is a lock across the instances, which is held for a lease. A
+// holder renews its lease before it lapses, otherwise the lock is free for
+// another owner. See leaseLua for the fencing tokens of the leases.
+type LeaseLock struct {
+	redisService api.RedisService
+	key          string
+	ttl          time.Duration
+}
+
+// NewLeaseLock returns the lock of a key, e.g. KeySchema.Lock, with leases
+// of ttl. The fencing tokens of the lock are counted by the key suffixed
+// with :fence, which shares the hash tag of the key.
+func NewLeaseLock(redisService api.RedisService, key string, ttl time.Duration) *LeaseLock {
+	return &LeaseLock{redisService: redisService, key: key, ttl: ttl}
+}
+
+// TryAcquire takes a lease of the lock for owner, or returns ErrLockHeld
+// when the lock is held.
+func (l *LeaseLock) TryAcquire(ctx context.Context, owner string) (*Lease, error) {
+	token := uuid.New().String()
+	fence, err := l.redisService.RunScript(ctx, acquireLeaseScript,
+		[]string{l.key, l.key + ":fence"},
+		owner, token, l.ttl.Milliseconds(),
+	)
+	if err == redis.Nil {
+		return nil, ErrLockHeld
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lease{lock: l, owner: owner, token: token, fence: fence.(int64)}, nil
+}
+
+// Holder returns the owner holding the lock, empty when it is free.
+func (l *LeaseLock) Holder(ctx context.Context) (string, error) {
+	owner, err := l.redisService.HGet(ctx, l.key, "owner")
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	return owner, err
+}
+
+// Lease is the hold of an owner on a LeaseLock.
+type Lease struct {
+	lock  *LeaseLock
+	owner string
+	token string
+	fence int64
+}
+
+func (l *Lease) Owner() string {
+	return l.owner
+}
+
+// Fence is the fencing token of the lease, greater than the ones of the
+// leases before it.
+func (l *Lease) Fence() int64 {
+	return l.fence
+}
+
+// Renew extends the lease by the ttl of its lock, or returns ErrLeaseLost
+// when the lease lapsed.
+func (l *Lease) Renew(ctx context.Context) error {
+	_, err := l.lock.redisService.RunScript(ctx, renewLeaseScript, []string{l.lock.key}, l.token, l.lock.ttl.Milliseconds())
+	if err != nil && err.Error() == "LEASE_LOST" {
+		return ErrLeaseLost
+	}
+
+	return err
+}
+
+// Release frees the lock, unless the lease lapsed and the lock is held by
+// another lease already.
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := l.lock.redisService.RunScript(ctx, releaseLeaseScript, []string{l.lock.key}, l.token)
+
+	return err
+}
@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+var _ = Describe("the lease lock", func() {
+	var lock *services.LeaseLock
+
+	JustBeforeEach(func() {
+		_, redisService := buildDependencies(mRedis.Addr())
+		lock = services.NewLeaseLock(redisService, services.NewKeySchema(KeyPrefix).Lock("test"), time.Minute)
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	It("is held by one owner at a time", func() {
+		lease, err := lock.TryAcquire(ctx, "first")
+		Expect(err).To(BeNil())
+		Expect(lease.Owner()).To(Equal("first"))
+
+		_, err = lock.TryAcquire(ctx, "second")
+		Expect(err).To(Equal(services.ErrLockHeld))
+		Expect(errors.Is(err, api.ErrConflict)).To(BeTrue())
+
+		Expect(lease.Release(ctx)).To(BeNil())
+		_, err = lock.TryAcquire(ctx, "second")
+		Expect(err).To(BeNil())
+	})
+
+	It("gives every lease a greater fencing token", func() {
+		first, err := lock.TryAcquire(ctx, "first")
+		Expect(err).To(BeNil())
+		Expect(first.Release(ctx)).To(BeNil())
+
+		second, err := lock.TryAcquire(ctx, "second")
+		Expect(err).To(BeNil())
+		Expect(second.Fence()).To(BeNumerically(">", first.Fence()))
+	})
+
+	When("a lease lapsed", func() {
+		It("is lost to the next owner", func() {
+			first, err := lock.TryAcquire(ctx, "first")
+			Expect(err).To(BeNil())
+			Expect(first.Renew(ctx)).To(BeNil())
+
+			mRedis.FastForward(time.Minute)
+			second, err := lock.TryAcquire(ctx, "second")
+			Expect(err).To(BeNil())
+
+			Expect(first.Renew(ctx)).To(Equal(services.ErrLeaseLost))
+			Expect(first.Release(ctx)).To(BeNil())
+			Expect(second.Renew(ctx)).To(BeNil())
+
+			_, err = lock.TryAcquire(ctx, "third")
+			Expect(err).To(Equal(services.ErrLockHeld))
+		})
+	})
+})
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/api"
+	"strings"
+	"sync"
+	"time"
+)
+
+const KeyLiveFeedChannel = "LIVE_FEED"
+
+type liveSubscription struct {
+	id         string
+	board      string
+	page       int64
+	pageSize   int64
+	userId     string
+	updates    chan<- *api.LiveUpdate
+	synced     bool
+	lastRows   []*api.LeaderboardRow
+	lastRank   int64
+	lastPoints int64
+}
+
+// LiveFeedService fans score events out to live subscribers. Events are
+// published through redis pub/sub so that subscribers connected to any
+// instance receive updates, and boards are refreshed at most once per
+// interval no matter how many events arrive in between.
+type LiveFeedService struct {
+	leaderboardService api.LeaderboardService
+	redisService       api.RedisService
+	interval           time.Duration
+	subscriptions      map[string]*liveSubscription
+	subscriptionsMux   sync.Mutex
+	dirtyBoards        map[string]bool
+	dirtyBoardsMux     sync.Mutex
+	stop               chan struct{}
+	stopOnce           sync.Once
+}
+
+func NewLiveFeedService(leaderboardService api.LeaderboardService, redisService api.RedisService, interval time.Duration) *LiveFeedService {
+	return &LiveFeedService{
+		leaderboardService: leaderboardService,
+		redisService:       redisService,
+		interval:           interval,
+		subscriptions:      map[string]*liveSubscription{},
+		dirtyBoards:        map[string]bool{},
+		stop:               make(chan struct{}),
+	}
+}
+
+func (lf *LiveFeedService) Start() {
+	pubSub := lf.redisService.Subscribe(context.Background(), KeyLiveFeedChannel)
+
+	go lf.receive(pubSub)
+	go lf.flush()
+}
+
+func (lf *LiveFeedService) Stop() {
+	lf.stopOnce.Do(func() {
+		close(lf.stop)
+	})
+}
+
+// OnScoreEvents publishes score events to every instance.
+func (lf *LiveFeedService) OnScoreEvents(ctx context.Context, events []*api.ScoreEvent) {
+	payload, err := json.Marshal(events)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := lf.redisService.Publish(ctx, KeyLiveFeedChannel, payload); err != nil {
+		log.Error(err)
+	}
+}
+
+// Subscribe registers a page subscription, or a rank subscription when a
+// user id is given. The first update sent to a subscription is a snapshot,
+// following updates only carry what has changed since the previous one.
+func (lf *LiveFeedService) Subscribe(request *api.LiveSubscriptionRequest, updates chan<- *api.LiveUpdate) string {
+	subscription := &liveSubscription{
+		id:       uuid.New().String(),
+		board:    strings.ToUpper(request.Board),
+		page:     request.Page,
+		pageSize: request.PageSize,
+		userId:   request.UserId,
+		updates:  updates,
+	}
+
+	if len(subscription.board) == 0 {
+		subscription.board = "GLOBAL"
+	}
+
+	if subscription.page <= 0 {
+		subscription.page = 1
+	}
+
+	if subscription.pageSize <= 0 {
+		subscription.pageSize = 10
+	}
+
+	lf.subscriptionsMux.Lock()
+	lf.subscriptions[subscription.id] = subscription
+	lf.subscriptionsMux.Unlock()
+
+	lf.markDirty(subscription.board)
+
+	return subscription.id
+}
+
+func (lf *LiveFeedService) Unsubscribe(id string) {
+	lf.subscriptionsMux.Lock()
+	defer lf.subscriptionsMux.Unlock()
+
+	delete(lf.subscriptions, id)
+}
+
+func (lf *LiveFeedService) receive(pubSub *redis.PubSub) {
+	defer pubSub.Close()
+
+	messages := pubSub.Channel()
+	for {
+		select {
+		case <-lf.stop:
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var events []*api.ScoreEvent
+			if err := json.Unmarshal([]byte(message.Payload), &events); err != nil {
+				log.Error(err)
+				continue
+			}
+
+			for _, event := range events {
+				lf.markDirty(event.Board)
+			}
+		}
+	}
+}
+
+func (lf *LiveFeedService) markDirty(board string) {
+	lf.dirtyBoardsMux.Lock()
+	defer lf.dirtyBoardsMux.Unlock()
+
+	lf.dirtyBoards[board] = true
+}
+
+func (lf *LiveFeedService) flush() {
+	ticker := time.NewTicker(lf.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lf.stop:
+			return
+		case <-ticker.C:
+			lf.dirtyBoardsMux.Lock()
+			dirtyBoards := lf.dirtyBoards
+			lf.dirtyBoards = map[string]bool{}
+			lf.dirtyBoardsMux.Unlock()
+
+			for board := range dirtyBoards {
+				lf.refresh(context.Background(), board)
+			}
+		}
+	}
+}
+
+func (lf *LiveFeedService) refresh(ctx context.Context, board string) {
+	lf.subscriptionsMux.Lock()
+	var subscriptions []*liveSubscription
+	for _, subscription := range lf.subscriptions {
+		if subscription.board == board {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	lf.subscriptionsMux.Unlock()
+
+	pages := map[[2]int64][]*api.LeaderboardRow{}
+	for _, subscription := range subscriptions {
+		var err error
+		if len(subscription.userId) > 0 {
+			err = lf.refreshRank(ctx, subscription)
+		} else {
+			key := [2]int64{subscription.page, subscription.pageSize}
+			rows, ok := pages[key]
+			if !ok {
+				// a page which failed is read again for the next subscription,
+				// caching it would send its rows as removed
+				rows, err = lf.leaderboardService.GetPage(ctx, board, subscription.page, subscription.pageSize)
+				if err == nil {
+					pages[key] = rows
+				}
+			}
+
+			if err == nil {
+				lf.refreshPage(subscription, rows)
+			}
+		}
+
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (lf *LiveFeedService) refreshPage(subscription *liveSubscription, rows []*api.LeaderboardRow) {
+	update := &api.LiveUpdate{
+		Type:           "page",
+		SubscriptionId: subscription.id,
+		Board:          subscription.board,
+		Page:           subscription.page,
+		PageSize:       subscription.pageSize,
+	}
+
+	if !subscription.synced {
+		update.Snapshot = true
+		update.Rows = rows
+	} else {
+		for i, row := range rows {
+			if i >= len(subscription.lastRows) || *row != *subscription.lastRows[i] {
+				update.Rows = append(update.Rows, row)
+			}
+		}
+
+		for i := len(rows); i < len(subscription.lastRows); i++ {
+			update.RemovedRanks = append(update.RemovedRanks, subscription.lastRows[i].Rank)
+		}
+
+		if len(update.Rows) == 0 && len(update.RemovedRanks) == 0 {
+			return
+		}
+	}
+
+	subscription.synced = lf.send(subscription, update)
+	subscription.lastRows = rows
+}
+
+func (lf *LiveFeedService) refreshRank(ctx context.Context, subscription *liveSubscription) error {
+	rank, err := lf.redisService.GetRank(ctx, subscription.board, subscription.userId)
+	if err == redis.Nil {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	points, err := lf.redisService.GetScore(ctx, subscription.board, subscription.userId)
+	if err != nil {
+		return err
+	}
+
+	if subscription.synced && rank == subscription.lastRank && int64(points) == subscription.lastPoints {
+		return nil
+	}
+
+	subscription.synced = lf.send(subscription, &api.LiveUpdate{
+		Type:           "rank",
+		SubscriptionId: subscription.id,
+		Board:          subscription.board,
+		Snapshot:       !subscription.synced,
+		UserId:         subscription.userId,
+		Rank:           rank,
+		Points:         int64(points),
+	})
+	subscription.lastRank = rank
+	subscription.lastPoints = int64(points)
+
+	return nil
+}
+
+// send never blocks on slow subscribers. A dropped update leaves the
+// subscription out of sync, so the next update is sent as a snapshot.
+func (lf *LiveFeedService) send(subscription *liveSubscription, update *api.LiveUpdate) bool {
+	select {
+	case subscription.updates <- update:
+		return true
+	default:
+		return false
+	}
+}
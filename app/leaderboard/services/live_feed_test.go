@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+var _ = Describe("the live feed service", func() {
+	var (
+		userService     *services.UserService
+		scoreService    *services.ScoreService
+		liveFeedService *services.LiveFeedService
+		updates         chan *api.LiveUpdate
+	)
+
+	JustBeforeEach(func() {
+		var redisService *services.RedisService
+		userService, redisService = buildDependencies(mRedis.Addr())
+		leaderboardService := services.NewLeaderboardService(userService, redisService, KeyPrefix)
+		scoreService = services.NewScoreService(userService, redisService, services.ScoreModeReplace, nil)
+		liveFeedService = services.NewLiveFeedService(leaderboardService, redisService, 10*time.Millisecond)
+		scoreService.AddListener(liveFeedService)
+		liveFeedService.Start()
+
+		for _, guid := range []string{"a", "b", "c"} {
+			_, err := userService.Create(ctx, &api.UserProfile{
+				UserId:      guid,
+				DisplayName: guid,
+				Country:     "XX",
+			})
+			Expect(err).To(BeNil())
+		}
+
+		updates = make(chan *api.LiveUpdate, 16)
+	})
+
+	JustAfterEach(func() {
+		liveFeedService.Stop()
+		mRedis.FlushAll()
+	})
+
+	submit := func(guid string, score float64) {
+		_, err := scoreService.Submit(ctx, &api.ScoreSubmission{
+			UserId:    guid,
+			Score:     score,
+			Timestamp: time.Now().Unix(),
+		})
+		Expect(err).To(BeNil())
+	}
+
+	Context("LiveFeedService.Subscribe()", func() {
+		When("a page is subscribed", func() {
+			It("sends a snapshot and then diffs of the page", func() {
+				liveFeedService.Subscribe(&api.LiveSubscriptionRequest{Board: "xx", PageSize: 2}, updates)
+
+				var snapshot *api.LiveUpdate
+				Eventually(updates).Should(Receive(&snapshot))
+				Expect(snapshot.Snapshot).To(BeTrue())
+				Expect(snapshot.Board).To(BeEquivalentTo("XX"))
+				Expect(snapshot.Rows).To(HaveLen(2))
+
+				submit("c", 50)
+
+				var diff *api.LiveUpdate
+				Eventually(updates).Should(Receive(&diff))
+				Expect(diff.Snapshot).To(BeFalse())
+				Expect(diff.Rows[0].Rank).To(BeEquivalentTo(1))
+				Expect(diff.Rows[0].DisplayName).To(BeEquivalentTo("c"))
+			})
+		})
+
+		When("the rank of a user is subscribed", func() {
+			It("sends the rank whenever it changes", func() {
+				liveFeedService.Subscribe(&api.LiveSubscriptionRequest{UserId: "a"}, updates)
+
+				var update *api.LiveUpdate
+				Eventually(updates).Should(Receive(&update))
+				Expect(update.Snapshot).To(BeTrue())
+
+				submit("a", 10)
+
+				Eventually(updates).Should(Receive(&update))
+				Expect(update.Rank).To(BeEquivalentTo(1))
+				Expect(update.Points).To(BeEquivalentTo(10))
+
+				submit("b", 20)
+
+				Eventually(updates).Should(Receive(&update))
+				Expect(update.Rank).To(BeEquivalentTo(2))
+				Expect(update.Snapshot).To(BeFalse())
+			})
+		})
+	})
+
+	Context("LiveFeedService.Unsubscribe()", func() {
+		When("a subscription is removed", func() {
+			It("stops sending updates", func() {
+				id := liveFeedService.Subscribe(&api.LiveSubscriptionRequest{UserId: "a"}, updates)
+				Eventually(updates).Should(Receive())
+
+				liveFeedService.Unsubscribe(id)
+				submit("a", 10)
+
+				Consistently(updates, 100*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"leaderboard/app/api"
+	"time"
+)
+
+const rankHistoryPageSize = 1000
+
+type RankHistoryService struct {
+	redisService api.RedisService
+	interval     time.Duration
+	ttl          time.Duration
+}
+
+func NewRankHistoryService(redisService api.RedisService, interval time.Duration, ttl time.Duration) *RankHistoryService {
+	return &RankHistoryService{redisService: redisService, interval: interval, ttl: ttl}
+}
+
+func (rs *RankHistoryService) Interval() time.Duration {
+	return rs.interval
+}
+
+// sampleTimestamp aligns the time of a sample to the interval.
+func (rs *RankHistoryService) sampleTimestamp(at time.Time) int64 {
+	return at.Truncate(rs.interval).Unix()
+}
+
+// RecordAll samples the current rank of every user on every board.
+func (rs *RankHistoryService) RecordAll(ctx context.Context, at time.Time) error {
+	boardNames, err := rs.redisService.GetBoardNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, boardName := range boardNames {
+		if err := rs.RecordBoard(ctx, boardName, at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordBoard samples the current rank of every user on the given board.
+// Samples are aligned to the configured interval, so recording twice within
+// the same interval overwrites the earlier sample.
+func (rs *RankHistoryService) RecordBoard(ctx context.Context, boardName string, at time.Time) error {
+	timestamp := rs.sampleTimestamp(at)
+
+	var start int64
+	for {
+		rankingTuples, err := rs.redisService.GetPage(ctx, boardName, start, start+rankHistoryPageSize-1)
+		if err != nil {
+			return err
+		}
+
+		if len(rankingTuples) == 0 {
+			return nil
+		}
+
+		entries := make(map[string]*api.RankHistoryEntry, len(rankingTuples))
+		for i, t := range rankingTuples {
+			entries[t.Member.(string)] = &api.RankHistoryEntry{
+				Timestamp: timestamp,
+				Rank:      start + int64(i) + 1,
+			}
+		}
+
+		if err := rs.redisService.AddRankHistory(ctx, boardName, rs.ttl, entries); err != nil {
+			return err
+		}
+
+		if len(rankingTuples) < rankHistoryPageSize {
+			return nil
+		}
+
+		start += rankHistoryPageSize
+	}
+}
+
+func (rs *RankHistoryService) GetHistory(ctx context.Context, guid string, boardName string, from time.Time, to time.Time) ([]*api.RankHistoryEntry, error) {
+	return rs.redisService.GetRankHistory(ctx, boardName, guid, from.Unix(), to.Unix())
+}
@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+var _ = Describe("the rank history service", func() {
+	var (
+		userService        *services.UserService
+		rankHistoryService *services.RankHistoryService
+	)
+
+	JustBeforeEach(func() {
+		var redisService *services.RedisService
+		userService, redisService = buildDependencies(mRedis.Addr())
+		rankHistoryService = services.NewRankHistoryService(redisService, time.Hour, 24*time.Hour)
+
+		for i, points := range []float64{10, 30, 20} {
+			_, err := userService.Create(ctx, &api.UserProfile{
+				UserId:      string(rune('a' + i)),
+				DisplayName: "hi",
+				Country:     "XX",
+				Points:      points,
+			})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	Context("RankHistoryService.RecordAll()", func() {
+		When("boards are sampled", func() {
+			It("records ranks on global and country boards", func() {
+				at := time.Unix(7200, 0)
+				Expect(rankHistoryService.RecordAll(ctx, at)).To(BeNil())
+
+				for _, board := range []string{"GLOBAL", "XX"} {
+					history, err := rankHistoryService.GetHistory(ctx, "c", board, time.Unix(0, 0), at)
+					Expect(err).To(BeNil())
+					Expect(history).To(HaveLen(1))
+					Expect(history[0].Rank).To(BeEquivalentTo(2))
+					Expect(history[0].Timestamp).To(BeEquivalentTo(7200))
+				}
+			})
+		})
+
+		When("boards are sampled twice within the same interval", func() {
+			It("keeps the latest sample only", func() {
+				Expect(rankHistoryService.RecordAll(ctx, time.Unix(7200, 0))).To(BeNil())
+
+				_, err := userService.Create(ctx, &api.UserProfile{
+					UserId:      "c",
+					DisplayName: "hi",
+					Country:     "XX",
+					Points:      100,
+				})
+				Expect(err).To(BeNil())
+				Expect(rankHistoryService.RecordAll(ctx, time.Unix(7300, 0))).To(BeNil())
+
+				history, err := rankHistoryService.GetHistory(ctx, "c", "GLOBAL", time.Unix(0, 0), time.Unix(10800, 0))
+				Expect(err).To(BeNil())
+				Expect(history).To(HaveLen(1))
+				Expect(history[0].Rank).To(BeEquivalentTo(1))
+			})
+		})
+	})
+
+	Context("RankHistoryService.GetHistory()", func() {
+		When("a time range is given", func() {
+			It("returns samples within the range in chronological order", func() {
+				for hour := int64(1); hour <= 4; hour++ {
+					Expect(rankHistoryService.RecordAll(ctx, time.Unix(hour*3600, 0))).To(BeNil())
+				}
+
+				history, err := rankHistoryService.GetHistory(ctx, "a", "GLOBAL", time.Unix(2*3600, 0), time.Unix(3*3600, 0))
+				Expect(err).To(BeNil())
+				Expect(history).To(HaveLen(2))
+				Expect(history[0].Timestamp < history[1].Timestamp).To(BeTrue())
+				Expect(history[0].Rank).To(BeEquivalentTo(3))
+			})
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+	"time"
+)
+
+const KeyRateLimitPrefix = "RATE_LIMIT_"
+
+// tokenBucketScript refills the bucket for the time passed since the last
+// call and takes a token when one is available. The whole bucket lives in a
+// single hash, so every instance sees the same budget. The time is read from
+// redis rather than from the callers, whose clocks drift apart.
+//
+// KEYS[1] bucket, ARGV[1] capacity, ARGV[2] refill rate in tokens per
+// millisecond
+//
+// returns {allowed, remaining tokens, ms until a token is available, ms
+// until the bucket is full}
+var tokenBucketScript = redis.NewScript(`
+-- TIME is not deterministic, scripts calling it before writing are
+-- replicated by their effects
+redis.replicate_commands()
+
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// RateLimitBudget allows Limit requests per Period, bursts up to Limit are
+// allowed when the bucket is full.
+type RateLimitBudget struct {
+	Limit  int64
+	Period time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+type RateLimiter struct {
+	redisService api.RedisService
+}
+
+func NewRateLimiter(redisService api.RedisService) *RateLimiter {
+	return &RateLimiter{redisService: redisService}
+}
+
+// Take spends a token of the key from the budget of the group.
+func (rl *RateLimiter) Take(ctx context.Context, group string, key string, budget *RateLimitBudget) (*RateLimitResult, error) {
+	rate := float64(budget.Limit) / float64(budget.Period.Milliseconds())
+
+	result, err := rl.redisService.RunScript(ctx, tokenBucketScript, []string{rl.getKey(group, key)}, budget.Limit, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retry, _ := values[2].(int64)
+	reset, _ := values[3].(int64)
+
+	return &RateLimitResult{
+		Allowed:    allowed == 1,
+		Limit:      budget.Limit,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(retry) * time.Millisecond,
+		Reset:      time.Duration(reset) * time.Millisecond,
+	}, nil
+}
+
+func (rl *RateLimiter) getKey(group string, key string) string {
+	return KeyRateLimitPrefix + group + "_" + key
+}
@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+var _ = Describe("the rate limiter", func() {
+	var (
+		rateLimiter *services.RateLimiter
+		budget      *services.RateLimitBudget
+	)
+
+	JustBeforeEach(func() {
+		_, redisService := buildDependencies(mRedis.Addr())
+		rateLimiter = services.NewRateLimiter(redisService)
+		budget = &services.RateLimitBudget{Limit: 3, Period: time.Hour}
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	Context("RateLimiter.Take()", func() {
+		When("the budget is spent", func() {
+			It("rejects the request until a token is refilled", func() {
+				for i := 2; i >= 0; i-- {
+					result, err := rateLimiter.Take(ctx, "submit", "a", budget)
+					Expect(err).To(BeNil())
+					Expect(result.Allowed).To(BeTrue())
+					Expect(result.Remaining).To(BeEquivalentTo(i))
+				}
+
+				result, err := rateLimiter.Take(ctx, "submit", "a", budget)
+				Expect(err).To(BeNil())
+				Expect(result.Allowed).To(BeFalse())
+				Expect(result.Limit).To(BeEquivalentTo(3))
+				Expect(result.RetryAfter).To(BeNumerically(">", 19*time.Minute))
+				Expect(result.RetryAfter).To(BeNumerically("<=", 20*time.Minute))
+			})
+		})
+
+		When("another key or group is used", func() {
+			It("keeps a separate budget", func() {
+				for i := 0; i < 3; i++ {
+					_, err := rateLimiter.Take(ctx, "submit", "a", budget)
+					Expect(err).To(BeNil())
+				}
+
+				result, err := rateLimiter.Take(ctx, "submit", "b", budget)
+				Expect(err).To(BeNil())
+				Expect(result.Allowed).To(BeTrue())
+
+				result, err = rateLimiter.Take(ctx, "read", "a", budget)
+				Expect(err).To(BeNil())
+				Expect(result.Allowed).To(BeTrue())
+			})
+		})
+	})
+})
@@ -2,27 +2,36 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"leaderboard/app/api"
+	"strconv"
 	"sync"
 	"time"
 )
 
 type RedisService struct {
-	context              context.Context
-	client               redis.UniversalClient
-	leaderboardKeyPrefix string
-	leaderboardKeys      map[string]string
-	leaderboardKeysMux   sync.Mutex
+	client redis.UniversalClient
+	keys   *KeySchema
 }
 
-func NewRedisService(client redis.UniversalClient, leaderboardKeyPrefix string) *RedisService {
-	return &RedisService{client: client, context: context.Background(), leaderboardKeyPrefix: leaderboardKeyPrefix}
+// NewRedisService keeps the boards and the profiles under the given key
+// namespace, see KeySchema.
+func NewRedisService(client redis.UniversalClient, namespace string) *RedisService {
+	client.AddHook(redisMetricsHook{})
+	client.AddHook(redisTracingHook{})
+	client.AddHook(redisErrorHook{})
+
+	return &RedisService{client: client, keys: NewKeySchema(namespace)}
+}
+
+func (o *RedisService) Keys() *KeySchema {
+	return o.keys
 }
 
-func (o *RedisService) Exists(key string) (bool, error) {
-	result, err := o.client.Exists(o.context, key).Result()
+func (o *RedisService) Exists(ctx context.Context, key string) (bool, error) {
+	result, err := o.client.Exists(ctx, key).Result()
 	if err != nil {
 		return false, err
 	}
@@ -30,17 +39,17 @@ func (o *RedisService) Exists(key string) (bool, error) {
 	return result == 1, nil
 }
 
-func (o *RedisService) HSet(key string, values ...interface{}) *redis.IntCmd {
-	return o.client.HSet(o.context, key, values...)
+func (o *RedisService) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	return o.client.HSet(ctx, key, values...)
 }
 
-func (o *RedisService) HGetAll(key string) *redis.StringStringMapCmd {
-	return o.client.HGetAll(o.context, key)
+func (o *RedisService) HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd {
+	return o.client.HGetAll(ctx, key)
 }
 
-func (o *RedisService) SetProfile(profile *api.UserProfile) (err error) {
+func (o *RedisService) SetProfile(ctx context.Context, profile *api.UserProfile) (err error) {
 	_, err = o.client.HSet(
-		o.context, profile.UserId,
+		ctx, o.keys.Profile(profile.UserId),
 		"display_name", profile.DisplayName,
 		"country", profile.Country,
 		"points", profile.Points,
@@ -49,31 +58,36 @@ func (o *RedisService) SetProfile(profile *api.UserProfile) (err error) {
 	return
 }
 
-func (o *RedisService) GetProfile(id string) (*api.UserProfile, error) {
-	resultMap, err := o.client.HGetAll(o.context, id).Result()
+func (o *RedisService) GetProfile(ctx context.Context, id string) (*api.UserProfile, error) {
+	resultMap, err := o.client.HGetAll(ctx, o.keys.Profile(id)).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	if resultMap["display_name"] == "" {
-		return nil, fmt.Errorf("user is not found with id %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
 	}
 
 	profile := new(api.UserProfile)
 	profile.UserId = id
 	profile.DisplayName = resultMap["display_name"]
 	profile.Country = resultMap["country"]
-	profile.Points, _ = o.GetScore("GLOBAL", id)
+
+	// users who are not ranked yet have no points
+	profile.Points, err = o.GetScore(ctx, "GLOBAL", id)
+	if err != nil && err != redis.Nil && !errors.Is(err, api.ErrNotFound) {
+		return nil, err
+	}
 
 	return profile, nil
 }
 
-func (o *RedisService) Set(key string, value string) {
-	o.client.Set(o.context, key, value, 8*time.Hour)
+func (o *RedisService) Set(ctx context.Context, key string, value string) error {
+	return o.client.Set(ctx, key, value, 8*time.Hour).Err()
 }
 
-func (o *RedisService) Get(key string) (string, error) {
-	result, err := o.client.Get(o.context, key).Result()
+func (o *RedisService) Get(ctx context.Context, key string) (string, error) {
+	result, err := o.client.Get(ctx, key).Result()
 	if err != nil {
 		return "", err
 	}
@@ -81,8 +95,8 @@ func (o *RedisService) Get(key string) (string, error) {
 	return result, nil
 }
 
-func (o *RedisService) GetOrDefault(key string, defaultVal string) string {
-	val, err := o.Get(key)
+func (o *RedisService) GetOrDefault(ctx context.Context, key string, defaultVal string) string {
+	val, err := o.Get(ctx, key)
 	if val == "" || err != nil {
 		return defaultVal
 	}
@@ -91,44 +105,33 @@ func (o *RedisService) GetOrDefault(key string, defaultVal string) string {
 }
 
 func (o *RedisService) getBoardKey(name string) string {
-	o.leaderboardKeysMux.Lock()
-	defer o.leaderboardKeysMux.Unlock()
-
-	if o.leaderboardKeys == nil {
-		o.leaderboardKeys = map[string]string{}
-	}
-
-	var boardKey string
-	if val, ok := o.leaderboardKeys[name]; ok {
-		return val
-	}
-
-	boardKey = fmt.Sprintf("%s%s", o.leaderboardKeyPrefix, name)
-	o.leaderboardKeys[name] = boardKey
+	return o.keys.Board(name)
+}
 
-	return boardKey
+func (o *RedisService) Add(ctx context.Context, sortedSetName string, z ...*redis.Z) error {
+	return o.client.ZAdd(ctx, o.getBoardKey(sortedSetName), z...).Err()
 }
 
-func (o *RedisService) Add(sortedSetName string, z ...*redis.Z) {
-	o.client.ZAdd(o.context, o.getBoardKey(sortedSetName), z...)
+func (o *RedisService) Ping(ctx context.Context) error {
+	return o.client.Ping(ctx).Err()
 }
 
-func (o *RedisService) FlushAll() {
-	o.client.FlushAll(o.context)
+func (o *RedisService) FlushAll(ctx context.Context) error {
+	return o.client.FlushAll(ctx).Err()
 }
 
-func (o *RedisService) GetSortedSetSize(sortedSetName string) (int64, error) {
+func (o *RedisService) GetSortedSetSize(ctx context.Context, sortedSetName string) (int64, error) {
 	boardKey := o.getBoardKey(sortedSetName)
-	exists, err := o.Exists(boardKey)
+	exists, err := o.Exists(ctx, boardKey)
 	if err != nil {
 		return 0, err
 	}
 
 	if !exists {
-		return 0, fmt.Errorf("sorted set is not found (%s)", sortedSetName)
+		return 0, api.NewError(api.ErrNotFound, fmt.Sprintf("sorted set is not found (%s)", sortedSetName))
 	}
 
-	result, err := o.client.ZCard(o.context, boardKey).Result()
+	result, err := o.client.ZCard(ctx, boardKey).Result()
 	if err != nil {
 		return 0, err
 	}
@@ -136,8 +139,8 @@ func (o *RedisService) GetSortedSetSize(sortedSetName string) (int64, error) {
 	return result, nil
 }
 
-func (o *RedisService) GetRank(sortedSetName string, key string) (int64, error) {
-	result, err := o.client.ZRevRank(o.context, o.getBoardKey(sortedSetName), key).Result()
+func (o *RedisService) GetRank(ctx context.Context, sortedSetName string, key string) (int64, error) {
+	result, err := o.client.ZRevRank(ctx, o.getBoardKey(sortedSetName), key).Result()
 	if err != nil {
 		return 0, err
 	}
@@ -145,18 +148,18 @@ func (o *RedisService) GetRank(sortedSetName string, key string) (int64, error)
 	return result + 1, nil
 }
 
-func (o *RedisService) GetScore(sortedSetName string, key string) (float64, error) {
+func (o *RedisService) GetScore(ctx context.Context, sortedSetName string, key string) (float64, error) {
 	boardKey := o.getBoardKey(sortedSetName)
-	exists, err := o.Exists(boardKey)
+	exists, err := o.Exists(ctx, boardKey)
 	if err != nil {
 		return 0, err
 	}
 
 	if !exists {
-		return 0, fmt.Errorf("sorted set is not found (%s)", sortedSetName)
+		return 0, api.NewError(api.ErrNotFound, fmt.Sprintf("sorted set is not found (%s)", sortedSetName))
 	}
 
-	result, err := o.client.ZScore(o.context, o.getBoardKey(sortedSetName), key).Result()
+	result, err := o.client.ZScore(ctx, o.getBoardKey(sortedSetName), key).Result()
 	if err != nil {
 		return 0, err
 	}
@@ -164,11 +167,223 @@ func (o *RedisService) GetScore(sortedSetName string, key string) (float64, erro
 	return result, nil
 }
 
-func (o *RedisService) GetPage(sortedSetName string, startIndex int64, endIndex int64) ([]redis.Z, error) {
-	result, err := o.client.ZRevRangeWithScores(o.context, o.getBoardKey(sortedSetName), startIndex, endIndex).Result()
+func (o *RedisService) GetPage(ctx context.Context, sortedSetName string, startIndex int64, endIndex int64) ([]redis.Z, error) {
+	result, err := o.client.ZRevRangeWithScores(ctx, o.getBoardKey(sortedSetName), startIndex, endIndex).Result()
 	if err != nil {
 		return []redis.Z{}, err
 	}
 
 	return result, nil
 }
+
+func (o *RedisService) GetBoardNames(ctx context.Context) ([]string, error) {
+	keys, err := scanKeys(ctx, o.client, o.keys.BoardPattern())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, o.keys.BoardName(key))
+	}
+
+	return names, nil
+}
+
+// scanKeys returns the keys matching the pattern, from every master of a
+// cluster.
+func scanKeys(ctx context.Context, client redis.UniversalClient, match string) ([]string, error) {
+	var keys []string
+	err := scanKeyPages(ctx, client, match, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+
+	return keys, err
+}
+
+// scanKeyPages passes the keys matching the pattern to onPage as SCAN
+// returns them, from every master of a cluster, one page at a time. A key
+// may be passed twice, SCAN returns keys again when the keyspace is resized
+// meanwhile.
+func scanKeyPages(ctx context.Context, client redis.UniversalClient, match string, onPage func(keys []string) error) error {
+	scan := func(ctx context.Context, client *redis.Client, onPage func(keys []string) error) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, 1000).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				if err := onPage(keys); err != nil {
+					return err
+				}
+			}
+
+			if cursor = next; cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	switch client := client.(type) {
+	case *redis.ClusterClient:
+		var pageMux sync.Mutex
+		return client.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return scan(ctx, master, func(keys []string) error {
+				pageMux.Lock()
+				defer pageMux.Unlock()
+
+				return onPage(keys)
+			})
+		})
+	case *redis.Client:
+		return scan(ctx, client, onPage)
+	default:
+		return fmt.Errorf("unsupported redis client (%T)", client)
+	}
+}
+
+func (o *RedisService) getRankHistoryKey(sortedSetName string, key string) string {
+	return o.keys.RankHistory(sortedSetName, key)
+}
+
+func (o *RedisService) AddRankHistory(ctx context.Context, sortedSetName string, ttl time.Duration, entries map[string]*api.RankHistoryEntry) error {
+	pipe := o.client.Pipeline()
+	for key, entry := range entries {
+		historyKey := o.getRankHistoryKey(sortedSetName, key)
+		timestamp := strconv.FormatInt(entry.Timestamp, 10)
+		oldest := strconv.FormatInt(entry.Timestamp-int64(ttl/time.Second), 10)
+
+		// a single sample is kept per timestamp, and samples older than ttl are dropped
+		pipe.ZRemRangeByScore(ctx, historyKey, timestamp, timestamp)
+		pipe.ZRemRangeByScore(ctx, historyKey, "-inf", "("+oldest)
+		pipe.ZAdd(ctx, historyKey, &redis.Z{
+			Score:  float64(entry.Timestamp),
+			Member: fmt.Sprintf("%d:%d", entry.Timestamp, entry.Rank),
+		})
+		pipe.Expire(ctx, historyKey, ttl)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (o *RedisService) GetRankHistory(ctx context.Context, sortedSetName string, key string, from int64, to int64) ([]*api.RankHistoryEntry, error) {
+	members, err := o.client.ZRangeByScore(ctx, o.getRankHistoryKey(sortedSetName, key), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from, 10),
+		Max: strconv.FormatInt(to, 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*api.RankHistoryEntry, 0, len(members))
+	for _, member := range members {
+		entry := new(api.RankHistoryEntry)
+		if _, err := fmt.Sscanf(member, "%d:%d", &entry.Timestamp, &entry.Rank); err != nil {
+			return nil, fmt.Errorf("malformed rank history entry (%s): %w", member, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (o *RedisService) Publish(ctx context.Context, channel string, message interface{}) error {
+	return o.client.Publish(ctx, channel, message).Err()
+}
+
+func (o *RedisService) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return o.client.Subscribe(ctx, channels...)
+}
+
+func (o *RedisService) AddToStream(ctx context.Context, stream string, maxLen int64, entries ...map[string]interface{}) error {
+	pipe := o.client.Pipeline()
+	for _, values := range entries {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream:       stream,
+			MaxLenApprox: maxLen,
+			Values:       values,
+		})
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (o *RedisService) ReadStream(ctx context.Context, stream string, lastId string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	result, err := o.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{stream, lastId},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if err == redis.Nil {
+		return []redis.XMessage{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []redis.XMessage
+	for _, s := range result {
+		messages = append(messages, s.Messages...)
+	}
+
+	return messages, nil
+}
+
+func (o *RedisService) RangeStream(ctx context.Context, stream string, start string, end string, count int64) ([]redis.XMessage, error) {
+	if count <= 0 {
+		return o.client.XRange(ctx, stream, start, end).Result()
+	}
+
+	return o.client.XRangeN(ctx, stream, start, end, count).Result()
+}
+
+func (o *RedisService) GetLastStreamId(ctx context.Context, stream string) (string, error) {
+	messages, err := o.client.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if len(messages) == 0 {
+		return "0-0", nil
+	}
+
+	return messages[0].ID, nil
+}
+
+func (o *RedisService) HGet(ctx context.Context, key string, field string) (string, error) {
+	return o.client.HGet(ctx, key, field).Result()
+}
+
+func (o *RedisService) HDel(ctx context.Context, key string, fields ...string) error {
+	return o.client.HDel(ctx, key, fields...).Err()
+}
+
+func (o *RedisService) Del(ctx context.Context, keys ...string) error {
+	return o.client.Del(ctx, keys...).Err()
+}
+
+// PushToList prepends values to the list and trims it to maxLen entries.
+func (o *RedisService) PushToList(ctx context.Context, key string, maxLen int64, values ...interface{}) error {
+	pipe := o.client.Pipeline()
+	pipe.LPush(ctx, key, values...)
+	pipe.LTrim(ctx, key, 0, maxLen-1)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (o *RedisService) GetList(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	return o.client.LRange(ctx, key, start, end).Result()
+}
+
+// RunScript runs the script with EVALSHA and falls back to EVAL when the
+// script is not cached on the server yet.
+func (o *RedisService) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.Run(ctx, o.client, keys, args...).Result()
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+	"sort"
+)
+
+var ErrArchiveExists = api.NewError(api.ErrConflict, "an archive of the board with this label exists")
+
+// archiveBoardScript renames a board to its archive, unless the board is
+// missing or the archive exists.
+//
+// KEYS[1] board, KEYS[2] archive
+var archiveBoardScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return redis.error_reply('BOARD_NOT_FOUND')
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return redis.error_reply('ARCHIVE_EXISTS')
+end
+
+redis.call('RENAME', KEYS[1], KEYS[2])
+return 1
+`)
+
+// DeleteBoard removes every member of the board, it answers whether there
+// was a board to remove.
+func (o *RedisService) DeleteBoard(ctx context.Context, name string) (bool, error) {
+	deleted, err := o.client.Del(ctx, o.getBoardKey(name)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
+
+// ArchiveBoard moves the board to its archive under the label, the board
+// starts out empty afterwards.
+func (o *RedisService) ArchiveBoard(ctx context.Context, name string, label string) error {
+	_, err := o.RunScript(ctx, archiveBoardScript, []string{o.getBoardKey(name), o.keys.Archive(name, label)})
+	if err == nil {
+		return nil
+	}
+
+	switch err.Error() {
+	case "BOARD_NOT_FOUND":
+		return api.NewError(api.ErrNotFound, fmt.Sprintf("board is not found (%s)", name))
+	case "ARCHIVE_EXISTS":
+		return ErrArchiveExists
+	default:
+		return err
+	}
+}
+
+// GetArchiveLabels returns the labels of the archives of the board, sorted.
+func (o *RedisService) GetArchiveLabels(ctx context.Context, name string) ([]string, error) {
+	keys, err := scanKeys(ctx, o.client, o.keys.ArchivePattern(name))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, o.keys.ArchiveLabel(name, key))
+	}
+	sort.Strings(labels)
+
+	return labels, nil
+}
+
+// DeleteArchive removes an archive of the board, a missing one is left as
+// it is.
+func (o *RedisService) DeleteArchive(ctx context.Context, name string, label string) error {
+	return o.client.Del(ctx, o.keys.Archive(name, label)).Err()
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"io/ioutil"
+)
+
+// RedisTLSFiles locates the PEM files of a TLS connection to redis. The
+// system roots verify the server when no CA file is given, and a client
+// certificate is presented only when both of its files are given.
+type RedisTLSFiles struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+func LoadRedisTLSConfig(files *RedisTLSFiles) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         files.ServerName,
+		InsecureSkipVerify: files.InsecureSkipVerify,
+	}
+
+	if len(files.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate is found in %s", files.CAFile)
+		}
+	}
+
+	if len(files.CertFile) > 0 || len(files.KeyFile) > 0 {
+		if len(files.CertFile) == 0 || len(files.KeyFile) == 0 {
+			return nil, errors.New("client certificate needs both its certificate and key files")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// NewRedisClient connects to a cluster through its seed addresses when
+// cluster is set, to the master monitored by the sentinels at the
+// addresses when a master name is given, or to a single node otherwise.
+// A cluster is never guessed from the number of addresses, one seed is
+// enough to discover it.
+func NewRedisClient(options *redis.UniversalOptions, cluster bool) redis.UniversalClient {
+	if cluster {
+		return redis.NewClusterClient(options.Cluster())
+	}
+
+	if len(options.MasterName) > 0 {
+		return redis.NewFailoverClient(options.Failover())
+	}
+
+	return redis.NewClient(options.Simple())
+}
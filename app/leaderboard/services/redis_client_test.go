@@ -0,0 +1,175 @@
+package services_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"leaderboard/app/leaderboard/services"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	der         []byte
+}
+
+func issueCertificate(template *x509.Certificate, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Minute)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	Expect(err).To(BeNil())
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+
+	return &testCertificate{certificate: certificate, key: key, der: der}
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func (c *testCertificate) writeFiles(dir string, name string) (string, string) {
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).To(BeNil())
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	Expect(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).To(Succeed())
+
+	return certFile, keyFile
+}
+
+var _ = Describe("the redis client", func() {
+	When("redis requires TLS with client certificates and an ACL user", func() {
+		var (
+			tlsRedis *miniredis.Miniredis
+			dir      string
+			caFile   string
+			certFile string
+			keyFile  string
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "redis-tls")
+			Expect(err).To(BeNil())
+
+			ca := issueCertificate(&x509.Certificate{
+				Subject:               pkix.Name{CommonName: "test ca"},
+				IsCA:                  true,
+				BasicConstraintsValid: true,
+				KeyUsage:              x509.KeyUsageCertSign,
+			}, nil)
+			server := issueCertificate(&x509.Certificate{
+				Subject:     pkix.Name{CommonName: "redis"},
+				IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}, ca)
+			client := issueCertificate(&x509.Certificate{
+				Subject:     pkix.Name{CommonName: "leaderboard"},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}, ca)
+
+			caFile, _ = ca.writeFiles(dir, "ca")
+			certFile, keyFile = client.writeFiles(dir, "client")
+
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(ca.certificate)
+
+			tlsRedis = miniredis.NewMiniRedis()
+			tlsRedis.RequireUserAuth("leaderboard", "secret")
+			Expect(tlsRedis.StartTLS(&tls.Config{
+				Certificates: []tls.Certificate{server.tlsCertificate()},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAs,
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			tlsRedis.Close()
+			_ = os.RemoveAll(dir)
+		})
+
+		connect := func(files *services.RedisTLSFiles, username string) error {
+			tlsConfig, err := services.LoadRedisTLSConfig(files)
+			if err != nil {
+				return err
+			}
+
+			client := services.NewRedisClient(&redis.UniversalOptions{
+				Addrs:     []string{tlsRedis.Addr()},
+				Username:  username,
+				Password:  "secret",
+				TLSConfig: tlsConfig,
+			}, false)
+			defer client.Close()
+
+			return client.Ping(ctx).Err()
+		}
+
+		It("connects with the CA and the client certificate", func() {
+			Expect(connect(&services.RedisTLSFiles{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, "leaderboard")).To(Succeed())
+		})
+
+		It("rejects a server which the CA did not sign", func() {
+			Expect(connect(&services.RedisTLSFiles{CertFile: certFile, KeyFile: keyFile}, "leaderboard")).NotTo(Succeed())
+		})
+
+		It("fails without a client certificate", func() {
+			Expect(connect(&services.RedisTLSFiles{CAFile: caFile}, "leaderboard")).NotTo(Succeed())
+		})
+
+		It("fails as an unknown user", func() {
+			Expect(connect(&services.RedisTLSFiles{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, "default")).NotTo(Succeed())
+		})
+
+		It("rejects a client certificate without its key", func() {
+			_, err := services.LoadRedisTLSConfig(&services.RedisTLSFiles{CertFile: certFile})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	When("some seeds of a cluster are unreachable", func() {
+		It("discovers the cluster through the others", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			unreachable := listener.Addr().String()
+			Expect(listener.Close()).To(Succeed())
+
+			client := services.NewRedisClient(&redis.UniversalOptions{
+				Addrs:       []string{unreachable, mRedis.Addr()},
+				DialTimeout: time.Second,
+			}, true)
+			defer client.Close()
+
+			Expect(client.Set(ctx, "key", "value", 0).Err()).To(Succeed())
+			Expect(mRedis.Get("key")).To(Equal("value"))
+			mRedis.FlushAll()
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"io"
+	"leaderboard/app/api"
+	"net"
+	"strings"
+)
+
+// replies of a server which can not serve for now, a retry may succeed
+var unavailableReplies = []string{"LOADING", "CLUSTERDOWN", "MASTERDOWN", "TRYAGAIN", "READONLY"}
+
+// redisErrorHook marks the errors of commands which failed because redis
+// could not be reached or could not serve, as api.ErrUnavailable. Replies
+// of redis, redis.Nil among them, are left as they are.
+type redisErrorHook struct{}
+
+func (redisErrorHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (redisErrorHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	if err := cmd.Err(); isRedisUnavailable(err) {
+		cmd.SetErr(api.WrapError(api.ErrUnavailable, err))
+	}
+
+	return nil
+}
+
+func (redisErrorHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterProcessPipeline returns the marked error, so it is returned by Exec
+// as well.
+func (redisErrorHook) AfterProcessPipeline(_ context.Context, cmds []redis.Cmder) error {
+	var unavailable error
+	for _, cmd := range cmds {
+		if err := cmd.Err(); isRedisUnavailable(err) {
+			cmd.SetErr(api.WrapError(api.ErrUnavailable, err))
+			if unavailable == nil {
+				unavailable = cmd.Err()
+			}
+		}
+	}
+
+	return unavailable
+}
+
+func isRedisUnavailable(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, api.ErrUnavailable) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || err == redis.ErrClosed {
+		return true
+	}
+
+	// the pool of go-redis does not export its timeout error
+	if err.Error() == "redis: connection pool timeout" {
+		return true
+	}
+
+	for _, reply := range unavailableReplies {
+		if strings.HasPrefix(err.Error(), reply+" ") {
+			return true
+		}
+	}
+
+	return false
+}
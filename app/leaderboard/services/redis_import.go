@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+	"strconv"
+)
+
+// importRejectionsLen is the most rejected rows kept per run of the import
+// job, the others are only counted.
+const importRejectionsLen = 1000
+
+// importBatchScript writes the profiles of a batch of an import to their
+// boards and records the progress of the import along, so a resumed run
+// carries on right after the last batch written. A user who moved to
+// another country leaves the board of its previous country and its rank
+// history there. Batches of a lease which was taken over, and batches which
+// were written already, are left out. Nothing is written when a profile
+// moved to another country since its country was read.
+//
+// KEYS[1] import, KEYS[2] rejections, KEYS[3] global board, then the
+// profile, the country board, the previous country board and its rank
+// history for every profile, ARGV[1] fencing token, ARGV[2] rows read up to
+// the end of the batch, ARGV[3] rejections kept at most, ARGV[4] retention
+// in seconds, ARGV[5] number of profiles, then the user id, display name,
+// country, points and previous country of every profile, the previous
+// country is empty for a new profile, then the rejections
+//
+// returns the rows the import read
+var importBatchScript = redis.NewScript(leaseLua + `
+if not fence_resource(KEYS[1], ARGV[1]) then
+	return redis.error_reply('LEASE_LOST')
+end
+
+local rows = tonumber(redis.call('HGET', KEYS[1], 'rows') or '0')
+if tonumber(ARGV[2]) <= rows then
+	return rows
+end
+
+-- a user may come twice in a batch, the second time from the country of
+-- the first
+local profiles = tonumber(ARGV[5])
+local countries = {}
+for p = 0, profiles - 1 do
+	local a = 6 + p * 5
+	local id = ARGV[a]
+	local country = countries[id] or redis.call('HGET', KEYS[4 + p * 4], 'country') or ''
+	if country ~= ARGV[a + 4] then
+		return redis.error_reply('PROFILE_CHANGED')
+	end
+	countries[id] = ARGV[a + 2]
+end
+
+for p = 0, profiles - 1 do
+	local k, a = 4 + p * 4, 6 + p * 5
+	local id, country, points, previous = ARGV[a], ARGV[a + 2], ARGV[a + 3], ARGV[a + 4]
+	if previous ~= '' and previous ~= country then
+		redis.call('ZREM', KEYS[k + 2], id)
+		redis.call('DEL', KEYS[k + 3])
+	end
+
+	redis.call('HSET', KEYS[k], 'display_name', ARGV[a + 1], 'country', country, 'points', points)
+	redis.call('ZADD', KEYS[3], points, id)
+	redis.call('ZADD', KEYS[k + 1], points, id)
+end
+
+local last = 5 + profiles * 5
+local kept = redis.call('LLEN', KEYS[2])
+for i = last + 1, #ARGV do
+	if kept >= tonumber(ARGV[3]) then
+		break
+	end
+	redis.call('RPUSH', KEYS[2], ARGV[i])
+	kept = kept + 1
+end
+
+redis.call('HSET', KEYS[1], 'rows', ARGV[2])
+redis.call('HINCRBY', KEYS[1], 'imported', profiles)
+redis.call('HINCRBY', KEYS[1], 'rejected', #ARGV - last)
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+if kept > 0 then
+	redis.call('EXPIRE', KEYS[2], ARGV[4])
+end
+return tonumber(ARGV[2])
+`)
+
+// WriteImportBatch reads the countries of the profiles of a batch and runs
+// importBatchScript with their boards. A batch whose profiles moved
+// meanwhile is read again once. It returns ErrLeaseLost when the run was
+// taken over by another instance.
+func (o *RedisService) WriteImportBatch(ctx context.Context, batch *api.ImportBatch) error {
+	rejections := make([]interface{}, 0, len(batch.Rejections))
+	for _, rejection := range batch.Rejections {
+		data, err := json.Marshal(rejection)
+		if err != nil {
+			return err
+		}
+		rejections = append(rejections, data)
+	}
+
+	for attempt := 0; ; attempt++ {
+		previous, err := o.getImportedCountries(ctx, batch.Profiles)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, 3+len(batch.Profiles)*4)
+		keys = append(keys, o.keys.Import(batch.RunId), o.keys.ImportRejections(batch.RunId), o.getBoardKey("GLOBAL"))
+		args := make([]interface{}, 0, 5+len(batch.Profiles)*5+len(rejections))
+		args = append(args,
+			batch.Fence,
+			batch.Rows,
+			importRejectionsLen,
+			int64(jobRunRetention.Seconds()),
+			len(batch.Profiles),
+		)
+		for i, profile := range batch.Profiles {
+			// a new profile has no previous board, its own stands in
+			board := previous[i]
+			if board == "" {
+				board = profile.Country
+			}
+
+			keys = append(keys,
+				o.keys.Profile(profile.UserId),
+				o.getBoardKey(profile.Country),
+				o.getBoardKey(board),
+				o.getRankHistoryKey(board, profile.UserId),
+			)
+			args = append(args, profile.UserId, profile.DisplayName, profile.Country, profile.Points, previous[i])
+		}
+		args = append(args, rejections...)
+
+		_, err = o.RunScript(ctx, importBatchScript, keys, args...)
+		if err == nil {
+			return nil
+		}
+
+		switch err.Error() {
+		case "LEASE_LOST":
+			return ErrLeaseLost
+		case "PROFILE_CHANGED":
+			if attempt == 0 {
+				continue
+			}
+			return ErrProfileChanged
+		default:
+			return err
+		}
+	}
+}
+
+// getImportedCountries answers the country each profile of a batch has
+// before the batch is written, empty for a profile which is new. A user
+// who comes again in the batch comes from the country it was given before.
+func (o *RedisService) getImportedCountries(ctx context.Context, profiles []*api.UserProfile) ([]string, error) {
+	pipe := o.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(profiles))
+	for i, profile := range profiles {
+		cmds[i] = pipe.HGet(ctx, o.keys.Profile(profile.UserId), "country")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	countries := make([]string, len(profiles))
+	given := map[string]string{}
+	for i, profile := range profiles {
+		if country, ok := given[profile.UserId]; ok {
+			countries[i] = country
+		} else {
+			countries[i] = cmds[i].Val()
+		}
+		given[profile.UserId] = profile.Country
+	}
+
+	return countries, nil
+}
+
+// GetImport returns the progress of a run of the import job along with its
+// first rejected rows, a report of nothing read for runs which did not
+// write a batch yet. The run of the report is left to the caller.
+func (o *RedisService) GetImport(ctx context.Context, id string) (*api.ImportReport, error) {
+	fields, err := o.client.HGetAll(ctx, o.keys.Import(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rejections, err := o.client.LRange(ctx, o.keys.ImportRejections(id), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &api.ImportReport{Rejections: make([]*api.ImportRejection, 0, len(rejections))}
+	report.Rows, _ = strconv.ParseInt(fields["rows"], 10, 64)
+	report.Imported, _ = strconv.ParseInt(fields["imported"], 10, 64)
+	report.Rejected, _ = strconv.ParseInt(fields["rejected"], 10, 64)
+	for _, data := range rejections {
+		rejection := new(api.ImportRejection)
+		if err := json.Unmarshal([]byte(data), rejection); err != nil {
+			return nil, err
+		}
+		report.Rejections = append(report.Rejections, rejection)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/leaderboard/metrics"
+	"time"
+)
+
+type redisStartKey struct{}
+
+// redisMetricsHook observes the latency and the errors of every command
+// sent through the client of a RedisService.
+type redisMetricsHook struct{}
+
+func (redisMetricsHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisMetricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedisCommand(ctx, cmd.Name())
+	countRedisError(cmd)
+
+	return nil
+}
+
+func (redisMetricsHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisMetricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	observeRedisCommand(ctx, "pipeline")
+	for _, cmd := range cmds {
+		countRedisError(cmd)
+	}
+
+	return nil
+}
+
+func observeRedisCommand(ctx context.Context, name string) {
+	if start, ok := ctx.Value(redisStartKey{}).(time.Time); ok {
+		metrics.RedisCommandDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
+
+func countRedisError(cmd redis.Cmder) {
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		metrics.RedisCommandErrors.WithLabelValues(cmd.Name()).Inc()
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+)
+
+// updateProfileScript writes a profile and moves its score from the board of
+// its previous country to the board of its new one, all at once. Nothing is
+// written when the profile is missing or moved to another country since it
+// was read. The rank history on the previous board is dropped.
+//
+// KEYS[1] profile, KEYS[2] previous country board, KEYS[3] its rank
+// history, KEYS[4] new country board, ARGV[1] user id, ARGV[2] previous
+// country, ARGV[3] display name, ARGV[4] country
+var updateProfileScript = redis.NewScript(`
+local country = redis.call('HGET', KEYS[1], 'country')
+if not country then
+	return redis.error_reply('USER_NOT_FOUND')
+end
+if country ~= ARGV[2] then
+	return redis.error_reply('PROFILE_CHANGED')
+end
+
+redis.call('HSET', KEYS[1], 'display_name', ARGV[3], 'country', ARGV[4])
+if KEYS[2] ~= KEYS[4] then
+	local score = redis.call('ZSCORE', KEYS[2], ARGV[1])
+	if score then
+		redis.call('ZREM', KEYS[2], ARGV[1])
+		redis.call('ZADD', KEYS[4], score, ARGV[1])
+	end
+	redis.call('DEL', KEYS[3])
+end
+
+return 1
+`)
+
+// deleteProfileScript removes a profile from its boards and drops it along
+// with its rank histories, unless it moved to another country since it was
+// read.
+//
+// KEYS[1] profile, then the board and its rank history for every board,
+// ARGV[1] user id, ARGV[2] country of the profile
+var deleteProfileScript = redis.NewScript(`
+local country = redis.call('HGET', KEYS[1], 'country')
+if not country then
+	return redis.error_reply('USER_NOT_FOUND')
+end
+if country ~= ARGV[2] then
+	return redis.error_reply('PROFILE_CHANGED')
+end
+
+for i = 2, #KEYS, 2 do
+	redis.call('ZREM', KEYS[i], ARGV[1])
+	redis.call('DEL', KEYS[i + 1])
+end
+redis.call('DEL', KEYS[1])
+
+return 1
+`)
+
+// UpdateProfile runs updateProfileScript, previousCountry is the country the
+// profile was read with. It returns ErrUserNotFound when the profile is
+// missing and ErrProfileChanged when its country is not previousCountry.
+func (o *RedisService) UpdateProfile(ctx context.Context, previousCountry string, profile *api.UserProfile) error {
+	keys := []string{
+		o.keys.Profile(profile.UserId),
+		o.getBoardKey(previousCountry),
+		o.getRankHistoryKey(previousCountry, profile.UserId),
+		o.getBoardKey(profile.Country),
+	}
+
+	_, err := o.RunScript(ctx, updateProfileScript, keys, profile.UserId, previousCountry, profile.DisplayName, profile.Country)
+	return profileScriptError(err, profile.UserId)
+}
+
+// DeleteProfile runs deleteProfileScript with the boards of the profile. It
+// returns ErrUserNotFound when the profile is missing and ErrProfileChanged
+// when it is from another country than the given profile.
+func (o *RedisService) DeleteProfile(ctx context.Context, profile *api.UserProfile) error {
+	keys := []string{o.keys.Profile(profile.UserId)}
+	for _, board := range []string{"GLOBAL", profile.Country} {
+		keys = append(keys, o.getBoardKey(board), o.getRankHistoryKey(board, profile.UserId))
+	}
+
+	_, err := o.RunScript(ctx, deleteProfileScript, keys, profile.UserId, profile.Country)
+	return profileScriptError(err, profile.UserId)
+}
+
+func profileScriptError(err error, id string) error {
+	if err == nil {
+		return nil
+	}
+
+	switch err.Error() {
+	case "USER_NOT_FOUND":
+		return fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	case "PROFILE_CHANGED":
+		return ErrProfileChanged
+	default:
+		return err
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+	"strconv"
+	"time"
+)
+
+// Scoring modes decide what a submission does to the score on a board.
+const (
+	ScoreModeReplace   = "replace"
+	ScoreModeBest      = "best"
+	ScoreModeIncrement = "increment"
+)
+
+var ErrProfileChanged = api.NewError(api.ErrConflict, "profile changed while the score was written")
+
+// writeScoreScript writes a score to the boards of a user, and samples the
+// resulting ranks into the rank histories, all at once. Nothing is written
+// when the profile is missing or moved to another country since the boards
+// were chosen.
+//
+// KEYS as deleteProfileScript takes them, ARGV[1] user id, ARGV[2] country
+// of the profile, ARGV[3] scoring mode, ARGV[4] score, ARGV[5] history
+// timestamp, 0 to record no sample, ARGV[6] history ttl in seconds
+//
+// returns {previous rank, rank, score} for every board, ranks are 0 when
+// the user is not ranked and the score is a string to keep its fraction
+var writeScoreScript = redis.NewScript(`
+local country = redis.call('HGET', KEYS[1], 'country')
+if not country then
+	return redis.error_reply('USER_NOT_FOUND')
+end
+if country ~= ARGV[2] then
+	return redis.error_reply('PROFILE_CHANGED')
+end
+
+local id = ARGV[1]
+local mode = ARGV[3]
+local score = tonumber(ARGV[4])
+local timestamp = tonumber(ARGV[5])
+local ttl = tonumber(ARGV[6])
+
+local function rank(board)
+	local r = redis.call('ZREVRANK', board, id)
+	if r then
+		return r + 1
+	end
+	return 0
+end
+
+local results = {}
+for i = 2, #KEYS, 2 do
+	local board = KEYS[i]
+	local previous = rank(board)
+
+	if mode == 'increment' then
+		redis.call('ZINCRBY', board, score, id)
+	elseif mode == 'best' then
+		local current = redis.call('ZSCORE', board, id)
+		if not current or score > tonumber(current) then
+			redis.call('ZADD', board, score, id)
+		end
+	else
+		redis.call('ZADD', board, score, id)
+	end
+
+	local current = rank(board)
+	if timestamp > 0 then
+		local history = KEYS[i + 1]
+		redis.call('ZREMRANGEBYSCORE', history, timestamp, timestamp)
+		redis.call('ZREMRANGEBYSCORE', history, '-inf', '(' .. (timestamp - ttl))
+		redis.call('ZADD', history, timestamp, timestamp .. ':' .. current)
+		redis.call('EXPIRE', history, ttl)
+	end
+
+	results[#results + 1] = {previous, current, redis.call('ZSCORE', board, id)}
+end
+
+return results
+`)
+
+func IsScoreMode(mode string) bool {
+	return mode == ScoreModeReplace || mode == ScoreModeBest || mode == ScoreModeIncrement
+}
+
+// WriteScore runs writeScoreScript, it returns ErrUserNotFound when the
+// profile is missing and ErrProfileChanged when it is from another
+// country than the write.
+func (o *RedisService) WriteScore(ctx context.Context, write *api.ScoreWrite) ([]*api.ScoreEvent, error) {
+	if !IsScoreMode(write.Mode) {
+		return nil, api.NewError(api.ErrInvalid, fmt.Sprintf("unknown scoring mode %q", write.Mode))
+	}
+
+	keys := []string{o.keys.Profile(write.UserId)}
+	for _, board := range write.Boards {
+		keys = append(keys, o.getBoardKey(board), o.getRankHistoryKey(board, write.UserId))
+	}
+
+	result, err := o.RunScript(ctx, writeScoreScript, keys,
+		write.UserId,
+		write.Country,
+		write.Mode,
+		strconv.FormatFloat(write.Score, 'f', -1, 64),
+		write.HistoryTimestamp,
+		int64(write.HistoryTTL/time.Second),
+	)
+	if err != nil {
+		switch err.Error() {
+		case "USER_NOT_FOUND":
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, write.UserId)
+		case "PROFILE_CHANGED":
+			return nil, ErrProfileChanged
+		default:
+			return nil, err
+		}
+	}
+
+	rows, ok := result.([]interface{})
+	if !ok || len(rows) != len(write.Boards) {
+		return nil, fmt.Errorf("unexpected reply of the score script: %v", result)
+	}
+
+	events := make([]*api.ScoreEvent, 0, len(rows))
+	for i, row := range rows {
+		values, ok := row.([]interface{})
+		if !ok || len(values) != 3 {
+			return nil, fmt.Errorf("unexpected reply of the score script: %v", row)
+		}
+
+		previousRank, previousOk := values[0].(int64)
+		rank, rankOk := values[1].(int64)
+		score, err := strconv.ParseFloat(fmt.Sprint(values[2]), 64)
+		if !previousOk || !rankOk || err != nil {
+			return nil, fmt.Errorf("unexpected reply of the score script: %v", row)
+		}
+
+		events = append(events, &api.ScoreEvent{
+			UserId:       write.UserId,
+			Board:        write.Boards[i],
+			Score:        score,
+			PreviousRank: previousRank,
+			Rank:         rank,
+		})
+	}
+
+	return events, nil
+}
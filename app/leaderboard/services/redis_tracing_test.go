@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tracing"
+	"sync"
+)
+
+type recordingExporter struct {
+	spans    []*export.SpanData
+	spansMux sync.Mutex
+}
+
+func (r *recordingExporter) ExportSpans(_ context.Context, spans []*export.SpanData) error {
+	r.spansMux.Lock()
+	defer r.spansMux.Unlock()
+
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recordingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func (r *recordingExporter) named(name string) []*export.SpanData {
+	r.spansMux.Lock()
+	defer r.spansMux.Unlock()
+
+	var spans []*export.SpanData
+	for _, span := range r.spans {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+var _ = Describe("redis tracing", func() {
+	var (
+		exporter    *recordingExporter
+		userService *services.UserService
+	)
+
+	JustBeforeEach(func() {
+		exporter = &recordingExporter{}
+		global.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		userService, _ = buildDependencies(mRedis.Addr())
+	})
+
+	JustAfterEach(func() {
+		global.SetTracerProvider(trace.NoopTracerProvider())
+		mRedis.FlushAll()
+	})
+
+	When("a command is sent within a span", func() {
+		It("records the command as a child span", func() {
+			spanCtx, span := tracing.Tracer().Start(ctx, "request")
+			_, err := userService.GetByID(spanCtx, "missing")
+			span.End()
+			Expect(err).NotTo(BeNil())
+
+			commands := exporter.named("redis hgetall")
+			Expect(commands).To(HaveLen(1))
+			Expect(commands[0].SpanKind).To(Equal(trace.SpanKindClient))
+			Expect(commands[0].ParentSpanID).To(Equal(span.SpanContext().SpanID))
+			Expect(commands[0].SpanContext.TraceID).To(Equal(span.SpanContext().TraceID))
+		})
+	})
+
+	When("a profile is created", func() {
+		It("records a span per command", func() {
+			_, err := userService.Create(ctx, &api.UserProfile{DisplayName: "a", Country: "TR", Points: 1})
+			Expect(err).To(BeNil())
+
+			Expect(exporter.named("redis hset")).NotTo(BeEmpty())
+			Expect(exporter.named("redis zadd")).NotTo(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/gommon/log"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Catch-up policies, what the scheduler does about the times of a schedule
+// which were missed while no instance led it.
+const (
+	// CatchUpSkip drops the missed times, the schedule waits for its next
+	// time.
+	CatchUpSkip = "skip"
+	// CatchUpOnce starts one run for all of the missed times.
+	CatchUpOnce = "once"
+	// CatchUpAll starts a run for every missed time, runs of a singleton
+	// job after the first one are refused while it is not finished.
+	CatchUpAll = "all"
+)
+
+const (
+	// scheduleMisfireGrace is how late a time may be handled before it
+	// counts as missed.
+	scheduleMisfireGrace = time.Minute
+	// maxCatchUpRuns bounds the runs started for a schedule at once, the
+	// remaining missed times are caught up with on the next ticks.
+	maxCatchUpRuns = 10
+)
+
+var ErrScheduleNotFound = api.NewError(api.ErrNotFound, "schedule is not found")
+
+// claimScheduleScript records the last time of a schedule the leader
+// handled, unless it lost its lead.
+//
+// KEYS[1] schedule, KEYS[2] lock of the leader, ARGV[1] lease token, ARGV[2]
+// fencing token, ARGV[3] last time
+var claimScheduleScript = redis.NewScript(leaseLua + `
+if not holds_lease(KEYS[2], ARGV[1]) or not fence_resource(KEYS[1], ARGV[2]) then
+	return redis.error_reply('LEASE_LOST')
+end
+
+redis.call('HSET', KEYS[1], 'last_at', ARGV[3])
+return 1
+`)
+
+// Schedule starts runs of a job at the times of a cron expression.
+type Schedule struct {
+	Name string `json:"name"`
+	Job  string `json:"job"`
+	Cron string `json:"cron"`
+	// CatchUp is one of the catch-up policies, CatchUpSkip when not given.
+	CatchUp    string          `json:"catch_up"`
+	Parameters json.RawMessage `json:"parameters"`
+	cron       *CronSchedule
+	parameters interface{}
+}
+
+// LoadSchedules reads the schedules from a JSON file holding an array of
+// Schedule objects.
+func LoadSchedules(path string) ([]*Schedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []*Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("malformed schedules file (%s): %w", path, err)
+	}
+
+	return schedules, nil
+}
+
+type SchedulerConfiguration struct {
+	// Instance names this instance as the leader, the host name and the pid
+	// when not given.
+	Instance string
+	// Location is the time zone of the cron expressions.
+	Location *time.Location
+	// Interval is how often the leader looks for due schedules.
+	Interval time.Duration
+	// LeaseTTL is how long the scheduler stays with a leader which stopped
+	// renewing its lease, before another instance takes the lead.
+	LeaseTTL time.Duration
+}
+
+// SchedulerService starts the runs of the schedules as their times come.
+// Every instance runs the scheduler and one of them leads it, the one
+// holding the lease of the scheduler lock, so a time is handled once across
+// the instances. The last handled times are kept in redis, a new leader
+// catches up with the times missed meanwhile by the policies of the
+// schedules.
+type SchedulerService struct {
+	redisService  api.RedisService
+	keys          *KeySchema
+	jobService    *JobService
+	configuration *SchedulerConfiguration
+	schedules     []*Schedule
+	byName        map[string]*Schedule
+	lock          *LeaseLock
+	// lease of the lead, used by the loop only
+	lease    *Lease
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+	now      func() time.Time
+}
+
+// NewSchedulerService checks the schedules, their jobs must be registered
+// to jobService and their parameters must be valid for them.
+func NewSchedulerService(redisService api.RedisService, keys *KeySchema, jobService *JobService, structValidator *StructValidator, schedules []*Schedule, configuration *SchedulerConfiguration) (*SchedulerService, error) {
+	if len(configuration.Instance) == 0 {
+		configuration.Instance = instanceName()
+	}
+
+	if configuration.Location == nil {
+		configuration.Location = time.UTC
+	}
+
+	byName := map[string]*Schedule{}
+	for _, schedule := range schedules {
+		if err := checkSchedule(schedule, jobService, structValidator); err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+
+		if _, ok := byName[schedule.Name]; ok {
+			return nil, fmt.Errorf("schedule %s is defined twice", schedule.Name)
+		}
+		byName[schedule.Name] = schedule
+	}
+
+	sorted := append([]*Schedule(nil), schedules...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return &SchedulerService{
+		redisService:  redisService,
+		keys:          keys,
+		jobService:    jobService,
+		configuration: configuration,
+		schedules:     sorted,
+		byName:        byName,
+		lock:          NewLeaseLock(redisService, keys.Lock("scheduler"), configuration.LeaseTTL),
+		stop:          make(chan struct{}),
+		now:           time.Now,
+	}, nil
+}
+
+func checkSchedule(schedule *Schedule, jobService *JobService, structValidator *StructValidator) error {
+	if len(schedule.Name) == 0 {
+		return errors.New("name is missing")
+	}
+
+	var err error
+	if schedule.cron, err = ParseCron(schedule.Cron); err != nil {
+		return err
+	}
+
+	if schedule.cron.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron expression %q never matches", schedule.Cron)
+	}
+
+	switch schedule.CatchUp {
+	case "":
+		schedule.CatchUp = CatchUpSkip
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return fmt.Errorf("catch_up must be one of %s, %s and %s", CatchUpSkip, CatchUpOnce, CatchUpAll)
+	}
+
+	if schedule.parameters, err = jobService.Parameters(schedule.Job); err != nil {
+		return fmt.Errorf("%s: %w", schedule.Job, err)
+	}
+
+	if schedule.parameters == nil {
+		return nil
+	}
+
+	if len(schedule.Parameters) > 0 {
+		if err := json.Unmarshal(schedule.Parameters, schedule.parameters); err != nil {
+			return fmt.Errorf("malformed parameters: %w", err)
+		}
+	}
+
+	return structValidator.Validate(schedule.parameters)
+}
+
+// Start starts the loop of the scheduler, which does nothing without
+// schedules.
+func (ss *SchedulerService) Start() {
+	if len(ss.schedules) == 0 {
+		return
+	}
+
+	ss.done.Add(1)
+	go ss.run()
+}
+
+// Stop stops the loop and gives up the lead, another instance takes it
+// right away.
+func (ss *SchedulerService) Stop() {
+	ss.stopOnce.Do(func() {
+		close(ss.stop)
+	})
+	ss.done.Wait()
+}
+
+// Schedules lists the schedules by name along with their last and next
+// times.
+func (ss *SchedulerService) Schedules(ctx context.Context) (*api.SchedulerStatus, error) {
+	leader, err := ss.lock.Holder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &api.SchedulerStatus{
+		Leader:    leader,
+		TimeZone:  ss.configuration.Location.String(),
+		Schedules: make([]*api.Schedule, 0, len(ss.schedules)),
+	}
+	now := ss.now().In(ss.configuration.Location)
+	for _, schedule := range ss.schedules {
+		fields, err := ss.redisService.HGetAll(ctx, ss.keys.Schedule(schedule.Name)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		reported := &api.Schedule{
+			Name:      schedule.Name,
+			Job:       schedule.Job,
+			Cron:      schedule.Cron,
+			CatchUp:   schedule.CatchUp,
+			LastRunId: fields["last_run_id"],
+			LastError: fields["last_error"],
+		}
+		reported.LastAt, _ = strconv.ParseInt(fields["last_at"], 10, 64)
+		reported.LastRunAt, _ = strconv.ParseInt(fields["last_run_at"], 10, 64)
+
+		from := now
+		if reported.LastAt > now.Unix() {
+			from = time.Unix(reported.LastAt, 0).In(ss.configuration.Location)
+		}
+		reported.NextAt = schedule.cron.Next(from).Unix()
+
+		status.Schedules = append(status.Schedules, reported)
+	}
+
+	return status, nil
+}
+
+// Trigger starts a run of the schedule right away, the times of the
+// schedule are not affected.
+func (ss *SchedulerService) Trigger(ctx context.Context, name string) (*api.JobRun, error) {
+	schedule, ok := ss.byName[name]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+
+	return ss.start(ctx, schedule)
+}
+
+func (ss *SchedulerService) run() {
+	defer ss.done.Done()
+
+	ticker := time.NewTicker(ss.configuration.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ss.stop:
+			ss.resign()
+			return
+		case <-ticker.C:
+			ss.tick()
+		}
+	}
+}
+
+func (ss *SchedulerService) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), ss.configuration.LeaseTTL)
+	defer cancel()
+
+	if !ss.lead(ctx) {
+		return
+	}
+
+	now := ss.now().In(ss.configuration.Location)
+	for _, schedule := range ss.schedules {
+		err := ss.fire(ctx, schedule, now)
+		if err == ErrLeaseLost {
+			log.Printf("%s lost the lead of the scheduler", ss.configuration.Instance)
+			ss.lease = nil
+			return
+		}
+
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// lead renews the lease of the leader, or takes the lead when no instance
+// holds it.
+func (ss *SchedulerService) lead(ctx context.Context) bool {
+	if ss.lease != nil {
+		err := ss.lease.Renew(ctx)
+		if err == nil {
+			return true
+		}
+
+		if err != ErrLeaseLost {
+			// kept for the next tick, as a run of a job keeps its lease
+			log.Error(err)
+			return false
+		}
+
+		log.Printf("%s lost the lead of the scheduler", ss.configuration.Instance)
+		ss.lease = nil
+	}
+
+	lease, err := ss.lock.TryAcquire(ctx, ss.configuration.Instance)
+	if err != nil {
+		if err != ErrLockHeld {
+			log.Error(err)
+		}
+		return false
+	}
+
+	log.Printf("%s leads the scheduler", ss.configuration.Instance)
+	ss.lease = lease
+	return true
+}
+
+func (ss *SchedulerService) resign() {
+	if ss.lease == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ss.configuration.LeaseTTL)
+	defer cancel()
+
+	if err := ss.lease.Release(ctx); err != nil {
+		log.Error(err)
+	}
+	ss.lease = nil
+}
+
+// fire starts the runs of the times of a schedule which came since the
+// last handled one. A schedule seen for the first time starts from now.
+func (ss *SchedulerService) fire(ctx context.Context, schedule *Schedule, now time.Time) error {
+	lastAt, err := ss.redisService.HGet(ctx, ss.keys.Schedule(schedule.Name), "last_at")
+	if err == redis.Nil {
+		return ss.claim(ctx, schedule, now)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	unix, err := strconv.ParseInt(lastAt, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid last time of schedule %s: %w", schedule.Name, err)
+	}
+	last := time.Unix(unix, 0).In(ss.configuration.Location)
+
+	due := schedule.cron.Next(last)
+	if due.IsZero() || due.After(now) {
+		return nil
+	}
+
+	runs, claimed := 0, now
+	switch schedule.CatchUp {
+	case CatchUpAll:
+		for !due.IsZero() && !due.After(now) && runs < maxCatchUpRuns {
+			runs, claimed = runs+1, due
+			due = schedule.cron.Next(due)
+		}
+	case CatchUpOnce:
+		runs = 1
+	default:
+		// a time within the grace is not missed yet
+		from := now.Add(-ss.configuration.Interval - scheduleMisfireGrace)
+		if last.After(from) {
+			from = last
+		}
+		if onTime := schedule.cron.Next(from); !onTime.IsZero() && !onTime.After(now) {
+			runs = 1
+		}
+	}
+
+	// the times are claimed before their runs are started, a leader which
+	// lost its lead meanwhile starts none
+	if err := ss.claim(ctx, schedule, claimed); err != nil {
+		return err
+	}
+
+	for i := 0; i < runs; i++ {
+		if _, err := ss.start(ctx, schedule); err != nil {
+			log.Errorf("schedule %s: %v", schedule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (ss *SchedulerService) claim(ctx context.Context, schedule *Schedule, last time.Time) error {
+	_, err := ss.redisService.RunScript(ctx, claimScheduleScript,
+		[]string{ss.keys.Schedule(schedule.Name), ss.lock.key},
+		ss.lease.token, ss.lease.fence, last.Unix(),
+	)
+	if err != nil && err.Error() == "LEASE_LOST" {
+		return ErrLeaseLost
+	}
+
+	return err
+}
+
+// start starts a run of the schedule and records it as the last run.
+func (ss *SchedulerService) start(ctx context.Context, schedule *Schedule) (*api.JobRun, error) {
+	run, err := ss.jobService.StartRun(ctx, schedule.Job, schedule.parameters)
+
+	fields := []interface{}{"last_run_at", ss.now().Unix(), "last_error", ""}
+	if err != nil {
+		fields[3] = err.Error()
+	} else {
+		fields = append(fields, "last_run_id", run.Id)
+	}
+
+	recordErr := ss.redisService.HSet(ctx, ss.keys.Schedule(schedule.Name), fields...).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return run, recordErr
+}
@@ -0,0 +1,197 @@
+package services_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"strconv"
+	"time"
+)
+
+var _ = Describe("the scheduler", func() {
+	var (
+		keys         = services.NewKeySchema(KeyPrefix)
+		redisService api.RedisService
+		jobService   *services.JobService
+		job          *countJob
+		started      []*services.SchedulerService
+	)
+
+	schedule := func(name string, cron string, catchUp string) *services.Schedule {
+		return &services.Schedule{
+			Name:       name,
+			Job:        job.name,
+			Cron:       cron,
+			CatchUp:    catchUp,
+			Parameters: json.RawMessage(`{"count": 1}`),
+		}
+	}
+
+	newScheduler := func(instance string, schedules ...*services.Schedule) (*services.SchedulerService, error) {
+		return services.NewSchedulerService(redisService, keys, jobService, services.NewStructValidator(validator.New()), schedules, &services.SchedulerConfiguration{
+			Instance: instance,
+			Interval: 10 * time.Millisecond,
+			LeaseTTL: time.Minute,
+		})
+	}
+
+	start := func(instance string, schedules ...*services.Schedule) *services.SchedulerService {
+		scheduler, err := newScheduler(instance, schedules...)
+		Expect(err).To(BeNil())
+		scheduler.Start()
+		started = append(started, scheduler)
+
+		return scheduler
+	}
+
+	runs := func() int {
+		runs, err := jobService.Runs(ctx, job.name)
+		Expect(err).To(BeNil())
+		return len(runs)
+	}
+
+	lastAt := func(scheduler *services.SchedulerService) func() int64 {
+		return func() int64 {
+			status, err := scheduler.Schedules(ctx)
+			Expect(err).To(BeNil())
+			return status.Schedules[0].LastAt
+		}
+	}
+
+	handledAt := func(name string, at time.Time) {
+		mRedis.HSet(keys.Schedule(name), "last_at", strconv.FormatInt(at.Unix(), 10))
+	}
+
+	BeforeEach(func() {
+		_, redisService = buildDependencies(mRedis.Addr())
+		job = &countJob{name: "count", concurrent: true}
+		// runs are left queued
+		jobService = services.NewJobService(redisService, keys, &services.JobConfiguration{Instance: "jobs", Workers: 1})
+		jobService.Register(job)
+		started = nil
+	})
+
+	AfterEach(func() {
+		for _, scheduler := range started {
+			scheduler.Stop()
+		}
+		mRedis.FlushAll()
+	})
+
+	It("starts a new schedule from now", func() {
+		scheduler := start("first", schedule("yearly", "@yearly", services.CatchUpAll))
+
+		Eventually(lastAt(scheduler)).Should(BeNumerically("~", time.Now().Unix(), 1))
+		Consistently(runs, 100*time.Millisecond).Should(BeZero())
+
+		status, err := scheduler.Schedules(ctx)
+		Expect(err).To(BeNil())
+		Expect(status.Leader).To(Equal("first"))
+		Expect(status.TimeZone).To(Equal("UTC"))
+		Expect(status.Schedules[0].NextAt).To(Equal(time.Date(time.Now().UTC().Year()+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()))
+	})
+
+	Context("catching up with missed times", func() {
+		twoYearsAgo := func() time.Time {
+			return time.Now().AddDate(-2, 0, 0)
+		}
+
+		It("skips them", func() {
+			handledAt("yearly", twoYearsAgo())
+			scheduler := start("first", schedule("yearly", "@yearly", services.CatchUpSkip))
+
+			Eventually(lastAt(scheduler)).Should(BeNumerically("~", time.Now().Unix(), 1))
+			Consistently(runs, 100*time.Millisecond).Should(BeZero())
+		})
+
+		It("starts a run for all of them", func() {
+			handledAt("yearly", twoYearsAgo())
+			start("first", schedule("yearly", "@yearly", services.CatchUpOnce))
+
+			Eventually(runs).Should(Equal(1))
+			Consistently(runs, 100*time.Millisecond).Should(Equal(1))
+		})
+
+		It("starts a run for each of them", func() {
+			handledAt("yearly", twoYearsAgo())
+			scheduler := start("first", schedule("yearly", "@yearly", services.CatchUpAll))
+
+			Eventually(runs).Should(Equal(2))
+			newYear := time.Date(time.Now().UTC().Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+			Expect(lastAt(scheduler)()).To(Equal(newYear.Unix()))
+		})
+
+		It("does not skip a time which is due within the grace", func() {
+			handledAt("minutely", time.Now().Add(-10*time.Minute))
+			start("first", schedule("minutely", "* * * * *", services.CatchUpSkip))
+
+			Eventually(runs).Should(Equal(1))
+		})
+	})
+
+	It("is led by one instance at a time", func() {
+		handledAt("yearly", time.Now().AddDate(-2, 0, 0))
+		first := start("first", schedule("yearly", "@yearly", services.CatchUpAll))
+		second := start("second", schedule("yearly", "@yearly", services.CatchUpAll))
+
+		Eventually(runs).Should(Equal(2))
+		Consistently(runs, 100*time.Millisecond).Should(Equal(2))
+
+		status, err := first.Schedules(ctx)
+		Expect(err).To(BeNil())
+		leader, follower := first, "second"
+		if status.Leader == "second" {
+			leader, follower = second, "first"
+		}
+
+		leader.Stop()
+		Eventually(func() string {
+			status, err := first.Schedules(ctx)
+			Expect(err).To(BeNil())
+			return status.Leader
+		}).Should(Equal(follower))
+	})
+
+	It("triggers a schedule right away", func() {
+		scheduler, err := newScheduler("first", schedule("yearly", "@yearly", services.CatchUpSkip))
+		Expect(err).To(BeNil())
+
+		run, err := scheduler.Trigger(ctx, "yearly")
+		Expect(err).To(BeNil())
+		Expect(run.Parameters).To(HaveKeyWithValue("count", BeEquivalentTo(1)))
+
+		status, err := scheduler.Schedules(ctx)
+		Expect(err).To(BeNil())
+		Expect(status.Schedules[0].LastRunId).To(Equal(run.Id))
+		Expect(status.Schedules[0].LastRunAt).NotTo(BeZero())
+		Expect(status.Schedules[0].LastAt).To(BeZero())
+
+		_, err = scheduler.Trigger(ctx, "hourly")
+		Expect(err).To(Equal(services.ErrScheduleNotFound))
+	})
+
+	It("rejects invalid schedules", func() {
+		_, err := newScheduler("first", schedule("yearly", "@yearly", "sometimes"))
+		Expect(err).NotTo(BeNil())
+
+		_, err = newScheduler("first", schedule("yearly", "0 0 30 2 *", services.CatchUpSkip))
+		Expect(err).NotTo(BeNil())
+
+		_, err = newScheduler("first", schedule("yearly", "@yearly", ""), schedule("yearly", "@daily", ""))
+		Expect(err).NotTo(BeNil())
+
+		unknown := schedule("yearly", "@yearly", "")
+		unknown.Job = "unknown"
+		_, err = newScheduler("first", unknown)
+		Expect(errors.Is(err, services.ErrJobNotFound)).To(BeTrue())
+
+		invalid := schedule("yearly", "@yearly", "")
+		invalid.Parameters = json.RawMessage(`{"count": -1}`)
+		_, err = newScheduler("first", invalid)
+		Expect(errors.Is(err, api.ErrInvalid)).To(BeTrue())
+	})
+})
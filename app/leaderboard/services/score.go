@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"sync"
+	"time"
+)
+
+var ErrUserNotFound = api.NewError(api.ErrNotFound, "user is not found")
+
+type ScoreService struct {
+	userService        *UserService
+	redisService       api.RedisService
+	mode               string
+	rankHistoryService *RankHistoryService
+	listeners          []api.ScoreListener
+	listenersMux       sync.RWMutex
+}
+
+// NewScoreService writes submissions in the given scoring mode. Every
+// submission samples the ranks of the user into the rank history as well,
+// unless rankHistoryService is nil.
+func NewScoreService(userService *UserService, redisService api.RedisService, mode string, rankHistoryService *RankHistoryService) *ScoreService {
+	return &ScoreService{
+		userService:        userService,
+		redisService:       redisService,
+		mode:               mode,
+		rankHistoryService: rankHistoryService,
+	}
+}
+
+func (ss *ScoreService) AddListener(listener api.ScoreListener) {
+	ss.listenersMux.Lock()
+	defer ss.listenersMux.Unlock()
+
+	ss.listeners = append(ss.listeners, listener)
+}
+
+// Submit writes the score to the global and the country leaderboards of the
+// user at once and notifies listeners with the resulting rank changes.
+func (ss *ScoreService) Submit(ctx context.Context, submission *api.ScoreSubmission) ([]*api.ScoreEvent, error) {
+	events, err := ss.submit(ctx, submission)
+	if errors.Is(err, ErrUserNotFound) {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("user_not_found").Inc()
+	} else if err != nil {
+		metrics.ScoreSubmissionsRejected.WithLabelValues("error").Inc()
+	} else {
+		metrics.ScoreSubmissionsAccepted.WithLabelValues().Inc()
+	}
+
+	return events, err
+}
+
+func (ss *ScoreService) submit(ctx context.Context, submission *api.ScoreSubmission) ([]*api.ScoreEvent, error) {
+	for attempt := 0; ; attempt++ {
+		user, err := ss.userService.GetByID(ctx, submission.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		write := &api.ScoreWrite{
+			UserId:  submission.UserId,
+			Country: user.Country,
+			Boards:  scoreBoards(user.Country),
+			Score:   submission.Score,
+			Mode:    ss.mode,
+		}
+		if ss.rankHistoryService != nil {
+			write.HistoryTimestamp = ss.rankHistoryService.sampleTimestamp(time.Now())
+			write.HistoryTTL = ss.rankHistoryService.ttl
+		}
+
+		// the boards follow the country of the profile, a profile moved
+		// meanwhile is read again to write to its new boards
+		events, err := ss.redisService.WriteScore(ctx, write)
+		if errors.Is(err, ErrProfileChanged) && attempt == 0 {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			event.Timestamp = submission.Timestamp
+		}
+		ss.notify(ctx, events)
+
+		return events, nil
+	}
+}
+
+// scoreBoards are the boards a score of a user from country is written to.
+// A country named like the global board is left out, the score would be
+// added to it twice in the increment mode.
+func scoreBoards(country string) []string {
+	if country == "GLOBAL" {
+		return []string{"GLOBAL"}
+	}
+
+	return []string{"GLOBAL", country}
+}
+
+// SubmitBatch submits every score on its own, so a failing submission does
+// not prevent the rest of the batch from being written. Once the context is
+// done the submissions left fail with its error, the ones before it stay
+// written.
+func (ss *ScoreService) SubmitBatch(ctx context.Context, submissions []*api.ScoreSubmission) []*api.BatchScoreResult {
+	results := make([]*api.BatchScoreResult, 0, len(submissions))
+	for _, submission := range submissions {
+		if err := ctx.Err(); err != nil {
+			results = append(results, &api.BatchScoreResult{Error: err.Error()})
+			continue
+		}
+
+		events, err := ss.Submit(ctx, submission)
+		result := &api.BatchScoreResult{Events: events}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func (ss *ScoreService) notify(ctx context.Context, events []*api.ScoreEvent) {
+	ss.listenersMux.RLock()
+	defer ss.listenersMux.RUnlock()
+
+	for _, listener := range ss.listeners {
+		listener.OnScoreEvents(ctx, events)
+	}
+}
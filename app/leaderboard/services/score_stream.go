@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/api"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const KeyScoreStreamPrefix = "SCORE_EVENTS_"
+
+var ErrInvalidStreamId = api.NewError(api.ErrInvalid, "invalid stream id")
+var ErrScoreStreamStopped = api.NewError(api.ErrUnavailable, "score stream is stopped")
+
+const (
+	ScoreStreamEventSubmission = "submission"
+	ScoreStreamEventRankChange = "rank_change"
+)
+
+const (
+	scoreStreamBufferSize   = 256
+	scoreStreamReadCount    = 100
+	scoreStreamBlock        = 5 * time.Second
+	scoreStreamIdleWait     = 50 * time.Millisecond
+	scoreStreamErrorBackoff = time.Second
+)
+
+// ScoreStreamService appends score events to a redis stream per board and
+// tails those streams for subscribers. A single tail is kept per board on
+// each instance, no matter how many subscribers are connected.
+type ScoreStreamService struct {
+	redisService api.RedisService
+	maxLen       int64
+	tails        map[string]*scoreStreamTail
+	tailsMux     sync.Mutex
+	stopped      bool
+}
+
+// scoreStreamTail reads a board's stream from the id that was last in it
+// when the tail started. started is closed once that id is known.
+type scoreStreamTail struct {
+	board       string
+	subscribers map[chan *api.StreamEvent]bool
+	started     chan struct{}
+	stop        chan struct{}
+}
+
+type ScoreStreamSubscription struct {
+	service *ScoreStreamService
+	board   string
+	raw     chan *api.StreamEvent
+	events  chan *api.StreamEvent
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewScoreStreamService(redisService api.RedisService, maxLen int64) *ScoreStreamService {
+	return &ScoreStreamService{redisService: redisService, maxLen: maxLen, tails: map[string]*scoreStreamTail{}}
+}
+
+// OnScoreEvents appends a submission entry for every event, and a rank
+// change entry for the events that moved the user on the board.
+func (ss *ScoreStreamService) OnScoreEvents(ctx context.Context, events []*api.ScoreEvent) {
+	entries := map[string][]map[string]interface{}{}
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		entries[event.Board] = append(entries[event.Board], map[string]interface{}{
+			"type": ScoreStreamEventSubmission,
+			"data": data,
+		})
+
+		if event.PreviousRank != event.Rank {
+			entries[event.Board] = append(entries[event.Board], map[string]interface{}{
+				"type": ScoreStreamEventRankChange,
+				"data": data,
+			})
+		}
+	}
+
+	for board, boardEntries := range entries {
+		if err := ss.redisService.AddToStream(ctx, ss.getStreamKey(board), ss.maxLen, boardEntries...); err != nil {
+
+			log.Error(err)
+		}
+	}
+}
+
+// Subscribe follows the stream of the given board. When lastEventId is
+// given, events after it which are still retained in the stream are
+// delivered before the live ones.
+func (ss *ScoreStreamService) Subscribe(ctx context.Context, board string, lastEventId string) (*ScoreStreamSubscription, error) {
+	subscription := &ScoreStreamSubscription{
+		service: ss,
+		board:   board,
+		raw:     make(chan *api.StreamEvent, scoreStreamBufferSize),
+		events:  make(chan *api.StreamEvent, scoreStreamBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	// the tail delivers what follows its start id, the backlog is read up to
+	// the end of the stream once that id is fixed, so the two overlap rather
+	// than leave a gap and forward drops what was delivered twice
+	tail, err := ss.join(board, subscription.raw)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-tail.started:
+	case <-tail.stop:
+	case <-ctx.Done():
+		subscription.Close()
+		return nil, ctx.Err()
+	}
+
+	var backlog []*api.StreamEvent
+	if len(lastEventId) > 0 {
+		if _, _, err := parseStreamId(lastEventId); err != nil {
+			subscription.Close()
+			return nil, err
+		}
+
+		messages, err := ss.redisService.RangeStream(ctx, ss.getStreamKey(board), lastEventId, "+", -1)
+		if err != nil {
+			subscription.Close()
+			return nil, err
+		}
+
+		for _, message := range messages {
+			if message.ID == lastEventId {
+				continue
+			}
+
+			event, err := parseStreamMessage(message)
+			if err != nil {
+				subscription.Close()
+				return nil, err
+			}
+			backlog = append(backlog, event)
+		}
+	}
+
+	go subscription.forward(backlog, lastEventId)
+
+	return subscription, nil
+}
+
+// Events is closed when the subscription falls behind the stream or is
+// closed. Subscribers falling behind should resume from the last event id.
+func (s *ScoreStreamSubscription) Events() <-chan *api.StreamEvent {
+	return s.events
+}
+
+func (s *ScoreStreamSubscription) Close() {
+	s.once.Do(func() {
+		s.service.leave(s.board, s.raw)
+		close(s.done)
+	})
+}
+
+func (s *ScoreStreamSubscription) forward(backlog []*api.StreamEvent, lastId string) {
+	defer close(s.events)
+
+	for _, event := range backlog {
+		select {
+		case <-s.done:
+			return
+		case s.events <- event:
+			lastId = event.Id
+		}
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-s.raw:
+			if !ok {
+				return
+			}
+
+			if len(lastId) > 0 && compareStreamIds(event.Id, lastId) <= 0 {
+				continue
+			}
+
+			select {
+			case <-s.done:
+				return
+			case s.events <- event:
+			}
+		}
+	}
+}
+
+func (ss *ScoreStreamService) getStreamKey(board string) string {
+	return KeyScoreStreamPrefix + board
+}
+
+// Stop ends every subscription and refuses new ones. Subscribers see their
+// events closed, as if they had fallen behind, and resume from their last
+// event id on another instance.
+func (ss *ScoreStreamService) Stop() {
+	ss.tailsMux.Lock()
+	defer ss.tailsMux.Unlock()
+
+	ss.stopped = true
+	for board, tail := range ss.tails {
+		for subscriber := range tail.subscribers {
+			delete(tail.subscribers, subscriber)
+			close(subscriber)
+		}
+		delete(ss.tails, board)
+		close(tail.stop)
+	}
+}
+
+func (ss *ScoreStreamService) join(board string, subscriber chan *api.StreamEvent) (*scoreStreamTail, error) {
+	ss.tailsMux.Lock()
+	defer ss.tailsMux.Unlock()
+
+	if ss.stopped {
+		return nil, ErrScoreStreamStopped
+	}
+
+	tail, ok := ss.tails[board]
+	if !ok {
+		tail = &scoreStreamTail{
+			board:       board,
+			subscribers: map[chan *api.StreamEvent]bool{},
+			started:     make(chan struct{}),
+			stop:        make(chan struct{}),
+		}
+		ss.tails[board] = tail
+
+		go ss.follow(tail)
+	}
+
+	tail.subscribers[subscriber] = true
+
+	return tail, nil
+}
+
+func (ss *ScoreStreamService) leave(board string, subscriber chan *api.StreamEvent) {
+	ss.tailsMux.Lock()
+	defer ss.tailsMux.Unlock()
+
+	tail, ok := ss.tails[board]
+	if !ok || !tail.subscribers[subscriber] {
+		return
+	}
+
+	delete(tail.subscribers, subscriber)
+	if len(tail.subscribers) == 0 {
+		delete(ss.tails, board)
+		close(tail.stop)
+	}
+}
+
+func (ss *ScoreStreamService) follow(tail *scoreStreamTail) {
+	ctx := context.Background()
+	streamKey := ss.getStreamKey(tail.board)
+
+	lastId, err := ss.redisService.GetLastStreamId(ctx, streamKey)
+	for err != nil {
+		log.Error(err)
+		if !ss.wait(tail, scoreStreamErrorBackoff) {
+			return
+		}
+		lastId, err = ss.redisService.GetLastStreamId(ctx, streamKey)
+	}
+	close(tail.started)
+
+	for {
+		select {
+		case <-tail.stop:
+			return
+		default:
+		}
+
+		messages, err := ss.redisService.ReadStream(ctx, streamKey, lastId, scoreStreamReadCount, scoreStreamBlock)
+		if err != nil {
+			log.Error(err)
+			if !ss.wait(tail, scoreStreamErrorBackoff) {
+				return
+			}
+			continue
+		}
+
+		// a read without messages normally means the block timed out, the
+		// short wait protects against servers that do not support blocking
+		if len(messages) == 0 {
+			if !ss.wait(tail, scoreStreamIdleWait) {
+				return
+			}
+			continue
+		}
+
+		for _, message := range messages {
+			lastId = message.ID
+
+			event, err := parseStreamMessage(message)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+
+			ss.broadcast(tail, event)
+		}
+	}
+}
+
+func (ss *ScoreStreamService) wait(tail *scoreStreamTail, d time.Duration) bool {
+	select {
+	case <-tail.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// broadcast never blocks on slow subscribers, they are dropped instead.
+func (ss *ScoreStreamService) broadcast(tail *scoreStreamTail, event *api.StreamEvent) {
+	ss.tailsMux.Lock()
+	defer ss.tailsMux.Unlock()
+
+	for subscriber := range tail.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(tail.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+func parseStreamMessage(message redis.XMessage) (*api.StreamEvent, error) {
+	event := &api.StreamEvent{
+		Id:    message.ID,
+		Event: new(api.ScoreEvent),
+	}
+
+	event.Type, _ = message.Values["type"].(string)
+	data, _ := message.Values["data"].(string)
+	if err := json.Unmarshal([]byte(data), event.Event); err != nil {
+		return nil, fmt.Errorf("malformed stream entry (%s): %w", message.ID, err)
+	}
+
+	return event, nil
+}
+
+func parseStreamId(id string) (uint64, uint64, error) {
+	parts := strings.SplitN(id, "-", 2)
+	ms, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w (%s)", ErrInvalidStreamId, id)
+	}
+
+	var seq uint64
+	if len(parts) == 2 {
+		seq, err = strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w (%s)", ErrInvalidStreamId, id)
+		}
+	}
+
+	return ms, seq, nil
+}
+
+func compareStreamIds(a string, b string) int {
+	aMs, aSeq, _ := parseStreamId(a)
+	bMs, bSeq, _ := parseStreamId(b)
+
+	switch {
+	case aMs < bMs || (aMs == bMs && aSeq < bSeq):
+		return -1
+	case aMs == bMs && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
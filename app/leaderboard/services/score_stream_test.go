@@ -0,0 +1,169 @@
+package services_test
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+// slowStreams starts tails late and runs afterRange once a backlog is read,
+// to write between the two reads of a subscription.
+type slowStreams struct {
+	*services.RedisService
+	afterRange func()
+}
+
+func (s *slowStreams) RangeStream(ctx context.Context, stream string, start string, end string, count int64) ([]redis.XMessage, error) {
+	messages, err := s.RedisService.RangeStream(ctx, stream, start, end, count)
+	s.afterRange()
+	return messages, err
+}
+
+func (s *slowStreams) GetLastStreamId(ctx context.Context, stream string) (string, error) {
+	time.Sleep(50 * time.Millisecond)
+	return s.RedisService.GetLastStreamId(ctx, stream)
+}
+
+var _ = Describe("the score stream service", func() {
+	var (
+		redisService       *services.RedisService
+		scoreService       *services.ScoreService
+		scoreStreamService *services.ScoreStreamService
+	)
+
+	JustBeforeEach(func() {
+		var userService *services.UserService
+		userService, redisService = buildDependencies(mRedis.Addr())
+		scoreService = services.NewScoreService(userService, redisService, services.ScoreModeReplace, nil)
+		scoreStreamService = services.NewScoreStreamService(redisService, 100)
+		scoreService.AddListener(scoreStreamService)
+
+		for _, guid := range []string{"a", "b"} {
+			_, err := userService.Create(ctx, &api.UserProfile{
+				UserId:      guid,
+				DisplayName: guid,
+				Country:     "XX",
+			})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	JustAfterEach(func() {
+		// miniredis does not drop streams on FLUSHALL
+		for _, board := range []string{"GLOBAL", "XX"} {
+			mRedis.Del(services.KeyScoreStreamPrefix + board)
+		}
+		mRedis.FlushAll()
+	})
+
+	submit := func(guid string, score float64) {
+		_, err := scoreService.Submit(ctx, &api.ScoreSubmission{
+			UserId:    guid,
+			Score:     score,
+			Timestamp: time.Now().Unix(),
+		})
+		Expect(err).To(BeNil())
+	}
+
+	Context("ScoreStreamService.Subscribe()", func() {
+		When("last event id is given", func() {
+			It("resumes after the given event", func() {
+				submit("a", 10)
+				submit("b", 20)
+
+				subscription, err := scoreStreamService.Subscribe(ctx, "GLOBAL", "0-0")
+				Expect(err).To(BeNil())
+
+				var first, second *api.StreamEvent
+				Eventually(subscription.Events()).Should(Receive(&first))
+				Expect(first.Type).To(BeEquivalentTo(services.ScoreStreamEventSubmission))
+				Expect(first.Event.UserId).To(BeEquivalentTo("a"))
+				subscription.Close()
+
+				subscription, err = scoreStreamService.Subscribe(ctx, "GLOBAL", first.Id)
+				Expect(err).To(BeNil())
+				defer subscription.Close()
+
+				Eventually(subscription.Events()).Should(Receive(&second))
+				Expect(second.Id).NotTo(BeEquivalentTo(first.Id))
+			})
+		})
+
+		When("a score is submitted after subscribing", func() {
+			It("streams the submission and the rank change", func() {
+				submit("a", 10)
+
+				subscription, err := scoreStreamService.Subscribe(ctx, "XX", "")
+				Expect(err).To(BeNil())
+				defer subscription.Close()
+
+				// give the tail time to settle on the end of the stream
+				time.Sleep(100 * time.Millisecond)
+				submit("b", 20)
+
+				var event *api.StreamEvent
+				Eventually(subscription.Events(), 3*time.Second).Should(Receive(&event))
+				Expect(event.Type).To(BeEquivalentTo(services.ScoreStreamEventSubmission))
+				Expect(event.Event.UserId).To(BeEquivalentTo("b"))
+				Expect(event.Event.Board).To(BeEquivalentTo("XX"))
+
+				Eventually(subscription.Events(), 3*time.Second).Should(Receive(&event))
+				Expect(event.Type).To(BeEquivalentTo(services.ScoreStreamEventRankChange))
+				Expect(event.Event.Rank).To(BeEquivalentTo(1))
+			})
+		})
+
+		When("an event is written between the backlog and the start of the tail", func() {
+			It("streams the event", func() {
+				submit("a", 10)
+
+				streams := &slowStreams{RedisService: redisService}
+				streams.afterRange = func() {
+					streams.afterRange = func() {}
+					submit("b", 20)
+				}
+
+				subscription, err := services.NewScoreStreamService(streams, 100).Subscribe(ctx, "XX", "0-0")
+				Expect(err).To(BeNil())
+				defer subscription.Close()
+
+				var users []string
+				Eventually(func() []string {
+					select {
+					case event, ok := <-subscription.Events():
+						if ok && event.Type == services.ScoreStreamEventSubmission {
+							users = append(users, event.Event.UserId)
+						}
+					default:
+					}
+					return users
+				}, 3*time.Second).Should(Equal([]string{"a", "b"}))
+			})
+		})
+
+		When("a malformed last event id is given", func() {
+			It("returns error", func() {
+				_, err := scoreStreamService.Subscribe(ctx, "GLOBAL", "not-an-id")
+				Expect(err).To(MatchError(services.ErrInvalidStreamId))
+			})
+		})
+	})
+
+	Context("ScoreStreamService.Stop()", func() {
+		It("ends open subscriptions and refuses new ones", func() {
+			subscription, err := scoreStreamService.Subscribe(ctx, "GLOBAL", "")
+			Expect(err).To(BeNil())
+			defer subscription.Close()
+
+			scoreStreamService.Stop()
+			Eventually(subscription.Events()).Should(BeClosed())
+
+			_, err = scoreStreamService.Subscribe(ctx, "GLOBAL", "")
+			Expect(err).To(MatchError(services.ErrScoreStreamStopped))
+		})
+	})
+})
@@ -0,0 +1,220 @@
+package services_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/services"
+	"time"
+)
+
+// scoreListenerFunc listens to score events with a function.
+type scoreListenerFunc func(ctx context.Context, events []*api.ScoreEvent)
+
+func (f scoreListenerFunc) OnScoreEvents(ctx context.Context, events []*api.ScoreEvent) {
+	f(ctx, events)
+}
+
+var _ = Describe("the score service", func() {
+	var scoreService *services.ScoreService
+
+	JustBeforeEach(func() {
+		userService, redisService := buildDependencies(mRedis.Addr())
+		scoreService = services.NewScoreService(userService, redisService, services.ScoreModeReplace, nil)
+
+		_, err := userService.Create(ctx, &api.UserProfile{
+			UserId:      "a",
+			DisplayName: "a",
+			Country:     "XX",
+		})
+		Expect(err).To(BeNil())
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	Context("ScoreService.Submit()", func() {
+		It("counts accepted and rejected submissions", func() {
+			accepted := metrics.ScoreSubmissionsAccepted.WithLabelValues()
+			rejected := metrics.ScoreSubmissionsRejected.WithLabelValues("user_not_found")
+			acceptedBefore, rejectedBefore := accepted.Get(), rejected.Get()
+
+			_, err := scoreService.Submit(ctx, &api.ScoreSubmission{UserId: "a", Score: 10, Timestamp: time.Now().Unix()})
+			Expect(err).To(BeNil())
+
+			_, err = scoreService.Submit(ctx, &api.ScoreSubmission{UserId: "unknown", Score: 10, Timestamp: time.Now().Unix()})
+			Expect(err).To(MatchError(services.ErrUserNotFound))
+
+			Expect(accepted.Get() - acceptedBefore).To(BeEquivalentTo(1))
+			Expect(rejected.Get() - rejectedBefore).To(BeEquivalentTo(1))
+		})
+	})
+
+	Context("ScoreService.SubmitBatch()", func() {
+		When("a submission belongs to an unknown user", func() {
+			It("submits the rest of the batch", func() {
+				results := scoreService.SubmitBatch(ctx, []*api.ScoreSubmission{
+					{UserId: "a", Score: 10, Timestamp: time.Now().Unix()},
+					{UserId: "unknown", Score: 20, Timestamp: time.Now().Unix()},
+				})
+				Expect(results).To(HaveLen(2))
+
+				Expect(results[0].Error).To(BeEmpty())
+				Expect(results[0].Events).To(HaveLen(2))
+				Expect(results[0].Events[0].Board).To(BeEquivalentTo("GLOBAL"))
+				Expect(results[0].Events[0].Rank).To(BeEquivalentTo(1))
+
+				Expect(results[1].Error).NotTo(BeEmpty())
+				Expect(results[1].Events).To(BeEmpty())
+			})
+		})
+
+		When("the context is done in the middle of the batch", func() {
+			It("fails the submissions left and keeps the written ones", func() {
+				batchCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				scoreService.AddListener(scoreListenerFunc(func(context.Context, []*api.ScoreEvent) {
+					cancel()
+				}))
+
+				results := scoreService.SubmitBatch(batchCtx, []*api.ScoreSubmission{
+					{UserId: "a", Score: 10, Timestamp: time.Now().Unix()},
+					{UserId: "a", Score: 20, Timestamp: time.Now().Unix()},
+				})
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Error).To(BeEmpty())
+				Expect(results[0].Events).To(HaveLen(2))
+				Expect(results[1].Error).To(Equal(context.Canceled.Error()))
+				Expect(results[1].Events).To(BeEmpty())
+			})
+		})
+	})
+})
+
+var _ = Describe("the score script", func() {
+	var (
+		userService        *services.UserService
+		redisService       *services.RedisService
+		rankHistoryService *services.RankHistoryService
+	)
+
+	JustBeforeEach(func() {
+		userService, redisService = buildDependencies(mRedis.Addr())
+		rankHistoryService = services.NewRankHistoryService(redisService, time.Hour, 24*time.Hour)
+
+		for _, guid := range []string{"a", "b"} {
+			_, err := userService.Create(ctx, &api.UserProfile{UserId: guid, DisplayName: guid, Country: "XX", Points: 50})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	JustAfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	submit := func(mode string, score float64) []*api.ScoreEvent {
+		scoreService := services.NewScoreService(userService, redisService, mode, rankHistoryService)
+		events, err := scoreService.Submit(ctx, &api.ScoreSubmission{UserId: "a", Score: score, Timestamp: time.Now().Unix()})
+		Expect(err).To(BeNil())
+		Expect(events).To(HaveLen(2))
+
+		return events
+	}
+
+	expectScore := func(mode string, score float64, expected float64) {
+		events := submit(mode, score)
+		for _, event := range events {
+			Expect(event.Score).To(Equal(expected))
+		}
+
+		for _, board := range []string{"GLOBAL", "XX"} {
+			boardScore, err := redisService.GetScore(ctx, board, "a")
+			Expect(err).To(BeNil())
+			Expect(boardScore).To(Equal(expected))
+		}
+	}
+
+	Context("scoring modes", func() {
+		It("replaces the score", func() {
+			expectScore(services.ScoreModeReplace, 10, 10)
+		})
+
+		It("keeps the best score", func() {
+			expectScore(services.ScoreModeBest, 10, 50)
+			expectScore(services.ScoreModeBest, 70.5, 70.5)
+		})
+
+		It("adds up the scores", func() {
+			expectScore(services.ScoreModeIncrement, 10, 60)
+		})
+	})
+
+	It("adds the score once for a user from a country named like the global board", func() {
+		_, err := userService.Create(ctx, &api.UserProfile{UserId: "global", DisplayName: "global", Country: "GLOBAL", Points: 50})
+		Expect(err).To(BeNil())
+
+		scoreService := services.NewScoreService(userService, redisService, services.ScoreModeIncrement, rankHistoryService)
+		events, err := scoreService.Submit(ctx, &api.ScoreSubmission{UserId: "global", Score: 10, Timestamp: time.Now().Unix()})
+		Expect(err).To(BeNil())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Score).To(Equal(60.0))
+
+		score, err := redisService.GetScore(ctx, "GLOBAL", "global")
+		Expect(err).To(BeNil())
+		Expect(score).To(Equal(60.0))
+	})
+
+	It("returns the ranks before and after the submission", func() {
+		events := submit(services.ScoreModeReplace, 100)
+		Expect(events[0].Board).To(Equal("GLOBAL"))
+		Expect(events[0].PreviousRank).To(BeEquivalentTo(2))
+		Expect(events[0].Rank).To(BeEquivalentTo(1))
+	})
+
+	It("samples the new ranks into the rank history", func() {
+		submit(services.ScoreModeReplace, 100)
+
+		now := time.Now()
+		for _, board := range []string{"GLOBAL", "XX"} {
+			history, err := rankHistoryService.GetHistory(ctx, "a", board, now.Add(-time.Hour), now)
+			Expect(err).To(BeNil())
+			Expect(history).To(HaveLen(1))
+			Expect(history[0].Rank).To(BeEquivalentTo(1))
+			Expect(history[0].Timestamp).To(Equal(now.Truncate(time.Hour).Unix()))
+		}
+	})
+
+	When("the profile moved to another country", func() {
+		It("writes nothing", func() {
+			_, err := redisService.WriteScore(ctx, &api.ScoreWrite{
+				UserId:  "a",
+				Country: "YY",
+				Boards:  []string{"GLOBAL", "YY"},
+				Score:   100,
+				Mode:    services.ScoreModeReplace,
+			})
+			Expect(err).To(MatchError(services.ErrProfileChanged))
+
+			score, err := redisService.GetScore(ctx, "GLOBAL", "a")
+			Expect(err).To(BeNil())
+			Expect(score).To(Equal(50.0))
+			Expect(mRedis.Exists(redisService.Keys().Board("YY"))).To(BeFalse())
+		})
+	})
+
+	When("the profile is missing", func() {
+		It("returns ErrUserNotFound", func() {
+			_, err := redisService.WriteScore(ctx, &api.ScoreWrite{
+				UserId: "unknown",
+				Boards: []string{"GLOBAL"},
+				Score:  100,
+				Mode:   services.ScoreModeReplace,
+			})
+			Expect(err).To(MatchError(services.ErrUserNotFound))
+			Expect(mRedis.Exists(redisService.Keys().RankHistory("GLOBAL", "unknown"))).To(BeFalse())
+		})
+	})
+})
@@ -1,13 +1,18 @@
 package services_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"leaderboard/app/api"
 	"leaderboard/app/leaderboard/services"
-	"leaderboard/app/leaderboard/tasks"
+	"math/rand"
+	"net"
 	"testing"
+	"time"
 )
 import . "github.com/onsi/ginkgo"
 import . "github.com/onsi/gomega"
@@ -16,6 +21,8 @@ const KeyPrefix = "LB_"
 
 var mRedis *miniredis.Miniredis
 
+var ctx = context.Background()
+
 func TestServices(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "services")
@@ -50,7 +57,7 @@ var _ = Describe("the redis service", func() {
 	Context("RedisService.GetSortedSetSize()", func() {
 		When("given non-existent sorted set", func() {
 			It("returns error", func() {
-				_, err := redisService.GetSortedSetSize(uuid.New().String())
+				_, err := redisService.GetSortedSetSize(ctx, uuid.New().String())
 				Expect(err).NotTo(BeNil())
 			})
 		})
@@ -59,24 +66,64 @@ var _ = Describe("the redis service", func() {
 			It("returns correct size", func() {
 				generateUsers(userService, redisService, 33)
 
-				size, err := redisService.GetSortedSetSize("GLOBAL")
+				size, err := redisService.GetSortedSetSize(ctx, "GLOBAL")
 				Expect(err).To(BeNil())
 				Expect(size).To(BeEquivalentTo(33))
 			})
 		})
 	})
 
+	Context("errors of the redis service", func() {
+		When("redis can not be reached", func() {
+			It("returns unavailable errors from reads and writes", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).To(BeNil())
+				unreachable := listener.Addr().String()
+				Expect(listener.Close()).To(Succeed())
+
+				unreachableUsers, unreachableRedis := buildDependencies(unreachable)
+
+				_, err = unreachableUsers.Create(ctx, &api.UserProfile{DisplayName: "hi", Country: "XX"})
+				Expect(errors.Is(err, api.ErrUnavailable)).To(BeTrue())
+
+				_, err = unreachableUsers.GetByID(ctx, uuid.New().String())
+				Expect(errors.Is(err, api.ErrUnavailable)).To(BeTrue())
+				Expect(errors.Is(err, api.ErrNotFound)).To(BeFalse())
+
+				Expect(errors.Is(unreachableRedis.FlushAll(ctx), api.ErrUnavailable)).To(BeTrue())
+			})
+		})
+
+		When("redis can not serve for now", func() {
+			It("returns unavailable errors", func() {
+				mRedis.SetError("LOADING Redis is loading the dataset in memory")
+				defer mRedis.SetError("")
+
+				_, err := redisService.GetSortedSetSize(ctx, "GLOBAL")
+				Expect(errors.Is(err, api.ErrUnavailable)).To(BeTrue())
+			})
+		})
+
+		When("a user is missing", func() {
+			It("returns a not found error", func() {
+				_, err := userService.GetByID(ctx, uuid.New().String())
+				Expect(errors.Is(err, services.ErrUserNotFound)).To(BeTrue())
+				Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+			})
+		})
+	})
+
 	Context("RedisService.GetScore()", func() {
 		When("given non-existent sorted set", func() {
 			It("returns error", func() {
-				_, err := redisService.GetScore(uuid.New().String(), uuid.New().String())
+				_, err := redisService.GetScore(ctx, uuid.New().String(), uuid.New().String())
 				Expect(err).NotTo(BeNil())
 			})
 		})
 
 		When("given a valid sorted set", func() {
 			It("returns correct size", func() {
-				guid, err := userService.Create(&api.UserProfile{
+				guid, err := userService.Create(ctx, &api.UserProfile{
 					UserId:      "a-guid",
 					DisplayName: "hi",
 					Country:     "XX",
@@ -85,7 +132,7 @@ var _ = Describe("the redis service", func() {
 				Expect(err).To(BeNil())
 				Expect(guid).To(BeEquivalentTo("a-guid"))
 
-				score, err := redisService.GetScore("GLOBAL", "a-guid")
+				score, err := redisService.GetScore(ctx, "GLOBAL", "a-guid")
 				Expect(err).To(BeNil())
 				Expect(score).To(BeEquivalentTo(133))
 			})
@@ -112,7 +159,7 @@ var _ = Describe("the user service", func() {
 
 		When("profile is given with a non-empty UserId", func() {
 			It("does not modify given UserId", func() {
-				guid, err := userService.Create(&api.UserProfile{
+				guid, err := userService.Create(ctx, &api.UserProfile{
 					UserId:      "a-guid",
 					DisplayName: "hi",
 					Country:     "XX",
@@ -120,7 +167,7 @@ var _ = Describe("the user service", func() {
 				Expect(err).To(BeNil())
 				Expect(guid).To(BeEquivalentTo("a-guid"))
 
-				profile, err := userService.GetByID("a-guid")
+				profile, err := userService.GetByID(ctx, "a-guid")
 				Expect(err).To(BeNil())
 				Expect(profile.UserId).To(BeEquivalentTo("a-guid"))
 			})
@@ -133,7 +180,7 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 				Expect(guid).NotTo(BeEquivalentTo(""))
 			})
@@ -143,12 +190,12 @@ var _ = Describe("the user service", func() {
 	Context("UserService.GetByID()", func() {
 		When("profile with given guid does not exist", func() {
 			It("returns profile as nil", func() {
-				profile, _ := userService.GetByID(uuid.New().String())
+				profile, _ := userService.GetByID(ctx, uuid.New().String())
 				Expect(profile).To(BeNil())
 			})
 
 			It("returns error", func() {
-				_, err := userService.GetByID(uuid.New().String())
+				_, err := userService.GetByID(ctx, uuid.New().String())
 				Expect(err).NotTo(BeNil())
 			})
 		})
@@ -159,10 +206,10 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
-				_, err = userService.GetByID(guid)
+				_, err = userService.GetByID(ctx, guid)
 				Expect(err).To(BeNil())
 			})
 
@@ -172,10 +219,10 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
-				returnedProfile, err := userService.GetByID(guid)
+				returnedProfile, err := userService.GetByID(ctx, guid)
 				Expect(err).To(BeNil())
 				Expect(returnedProfile.UserId).To(BeEquivalentTo(guid))
 			})
@@ -186,10 +233,10 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
-				returnedProfile, err := userService.GetByID(guid)
+				returnedProfile, err := userService.GetByID(ctx, guid)
 				Expect(err).To(BeNil())
 				Expect(returnedProfile.Country).To(BeEquivalentTo(profile.Country))
 			})
@@ -200,10 +247,10 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
-				returnedProfile, err := userService.GetByID(guid)
+				returnedProfile, err := userService.GetByID(ctx, guid)
 				Expect(err).To(BeNil())
 				Expect(returnedProfile.DisplayName).To(BeEquivalentTo(profile.DisplayName))
 			})
@@ -213,12 +260,12 @@ var _ = Describe("the user service", func() {
 	Context("UserService.GetByIDWithRank()", func() {
 		When("profile with given guid does not exist", func() {
 			It("returns profile as nil", func() {
-				profile, _ := userService.GetByIDWithRank(uuid.New().String(), "GLOBAL")
+				profile, _ := userService.GetByIDWithRank(ctx, uuid.New().String(), "GLOBAL")
 				Expect(profile).To(BeNil())
 			})
 
 			It("returns error", func() {
-				_, err := userService.GetByIDWithRank(uuid.New().String(), "GLOBAL")
+				_, err := userService.GetByIDWithRank(ctx, uuid.New().String(), "GLOBAL")
 				Expect(err).NotTo(BeNil())
 			})
 		})
@@ -229,10 +276,10 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
-				returnedProfile, err := userService.GetByIDWithRank(guid, "GLOBAL")
+				returnedProfile, err := userService.GetByIDWithRank(ctx, guid, "GLOBAL")
 				Expect(err).To(BeNil())
 				Expect(returnedProfile.UserId).To(BeEquivalentTo(guid))
 				Expect(returnedProfile.Rank).NotTo(BeEquivalentTo(0))
@@ -250,11 +297,11 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				guid, err := userService.Create(&profile)
+				guid, err := userService.Create(ctx, &profile)
 				Expect(err).To(BeNil())
 
 				profile.UserId = guid
-				err = userService.SetRank(&profile, "GLOBAL")
+				err = userService.SetRank(ctx, &profile, "GLOBAL")
 				Expect(err).To(BeNil())
 				Expect(profile.Rank).NotTo(BeEquivalentTo(0))
 			})
@@ -268,7 +315,7 @@ var _ = Describe("the user service", func() {
 					Country:     "XX",
 				}
 
-				err := userService.SetRank(&profile, "GLOBAL")
+				err := userService.SetRank(ctx, &profile, "GLOBAL")
 				Expect(err).NotTo(BeNil())
 			})
 		})
@@ -288,11 +335,11 @@ var _ = Describe("the user service", func() {
 						Country:     "XX",
 					}
 
-					_, err := userService.Create(profile)
+					_, err := userService.Create(ctx, profile)
 					Expect(err).To(BeNil())
 				}
 
-				profiles, err := userService.GetAllByID(uuids...)
+				profiles, err := userService.GetAllByID(ctx, uuids...)
 				Expect(err).To(BeNil())
 
 				for i := 0; i < nUsers; i++ {
@@ -301,6 +348,341 @@ var _ = Describe("the user service", func() {
 			})
 		})
 	})
+
+	Context("UserService.Update()", func() {
+		When("the country changes", func() {
+			It("moves the score to the board of the new country", func() {
+				guid, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: 42, Country: "TR"})
+				Expect(err).To(BeNil())
+
+				profile, err := userService.Update(ctx, guid, &api.UserUpdate{Country: "US"})
+				Expect(err).To(BeNil())
+				Expect(profile.DisplayName).To(Equal("hi"))
+				Expect(profile.Country).To(Equal("US"))
+
+				score, err := redisService.GetScore(ctx, "US", guid)
+				Expect(err).To(BeNil())
+				Expect(score).To(BeEquivalentTo(42))
+
+				_, err = redisService.GetScore(ctx, "TR", guid)
+				Expect(err).NotTo(BeNil())
+
+				profile, err = userService.GetByID(ctx, guid)
+				Expect(err).To(BeNil())
+				Expect(profile.Country).To(Equal("US"))
+				Expect(profile.Points).To(BeEquivalentTo(42))
+			})
+		})
+
+		When("only the display name changes", func() {
+			It("keeps the country", func() {
+				guid, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: 42, Country: "XX"})
+				Expect(err).To(BeNil())
+
+				_, err = userService.Update(ctx, guid, &api.UserUpdate{DisplayName: "hello"})
+				Expect(err).To(BeNil())
+
+				profile, err := userService.GetByIDWithRank(ctx, guid, "XX")
+				Expect(err).To(BeNil())
+				Expect(profile.DisplayName).To(Equal("hello"))
+				Expect(profile.Rank).To(BeEquivalentTo(1))
+			})
+		})
+
+		When("the user does not exist", func() {
+			It("returns a not found error", func() {
+				_, err := userService.Update(ctx, uuid.New().String(), &api.UserUpdate{DisplayName: "hello"})
+				Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+			})
+		})
+	})
+
+	Context("UserService.Delete()", func() {
+		It("removes the user from its boards", func() {
+			guid, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: 42, Country: "TR"})
+			Expect(err).To(BeNil())
+
+			Expect(userService.Delete(ctx, guid)).To(BeNil())
+
+			_, err = userService.GetByID(ctx, guid)
+			Expect(errors.Is(err, services.ErrUserNotFound)).To(BeTrue())
+
+			size, err := redisService.GetSortedSetSize(ctx, "GLOBAL")
+			Expect(err).To(BeNil())
+			Expect(size).To(BeEquivalentTo(20))
+
+			err = userService.Delete(ctx, guid)
+			Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("the board service", func() {
+	var (
+		redisService *services.RedisService
+		userService  *services.UserService
+		boardService *services.BoardService
+	)
+
+	BeforeEach(func() {
+		userService, redisService = buildDependencies(mRedis.Addr())
+		boardService = services.NewBoardService(redisService)
+
+		for i, country := range []string{"TR", "TR", "US"} {
+			_, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: float64(10 * (i + 1)), Country: country})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	AfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	It("lists the boards", func() {
+		boards, err := boardService.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(boards).To(Equal([]*api.Board{
+			{Name: "GLOBAL", Members: 3},
+			{Name: "TR", Members: 2},
+			{Name: "US", Members: 1},
+		}))
+	})
+
+	It("inspects a board", func() {
+		board, err := boardService.Get(ctx, "TR")
+		Expect(err).To(BeNil())
+		Expect(board.Members).To(BeEquivalentTo(2))
+		Expect(board.TopScore).To(BeEquivalentTo(20))
+		Expect(board.BottomScore).To(BeEquivalentTo(10))
+
+		_, err = boardService.Get(ctx, "GB")
+		Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+	})
+
+	It("resets a board", func() {
+		Expect(boardService.Reset(ctx, "TR")).To(BeNil())
+
+		_, err := boardService.Get(ctx, "TR")
+		Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+
+		err = boardService.Reset(ctx, "TR")
+		Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+	})
+
+	It("archives a board", func() {
+		archive, err := boardService.Archive(ctx, "TR", "season-1")
+		Expect(err).To(BeNil())
+		Expect(archive.Label).To(Equal("season-1"))
+
+		board, err := boardService.Get(ctx, "TR")
+		Expect(err).To(BeNil())
+		Expect(board.Members).To(BeZero())
+		Expect(board.Archives).To(Equal([]string{"season-1"}))
+
+		members, err := mRedis.ZMembers(services.NewKeySchema(KeyPrefix).Archive("TR", "season-1"))
+		Expect(err).To(BeNil())
+		Expect(members).To(HaveLen(2))
+
+		// the board starts over and may be archived again, under a new label
+		_, err = userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: 5, Country: "TR"})
+		Expect(err).To(BeNil())
+		_, err = boardService.Archive(ctx, "TR", "season-1")
+		Expect(errors.Is(err, api.ErrConflict)).To(BeTrue())
+
+		archive, err = boardService.Archive(ctx, "TR", "")
+		Expect(err).To(BeNil())
+		Expect(archive.Label).NotTo(BeEmpty())
+
+		_, err = boardService.Archive(ctx, "GB", "")
+		Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+	})
+
+	It("prunes the archives labeled by their time", func() {
+		keys := services.NewKeySchema(KeyPrefix)
+		for _, label := range []string{"20201101T000000Z", "20201108T000000Z", "20201115T000000Z", "season-1"} {
+			_, err := mRedis.ZAdd(keys.Archive("TR", label), 1, "a")
+			Expect(err).To(BeNil())
+		}
+
+		pruned, err := boardService.Prune(ctx, "TR", 2)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(Equal([]string{"20201101T000000Z"}))
+
+		board, err := boardService.Get(ctx, "TR")
+		Expect(err).To(BeNil())
+		Expect(board.Archives).To(Equal([]string{"20201108T000000Z", "20201115T000000Z", "season-1"}))
+
+		pruned, err = boardService.Prune(ctx, "TR", 2)
+		Expect(err).To(BeNil())
+		Expect(pruned).To(BeEmpty())
+	})
+})
+
+// scriptKeys records the keys declared by the scripts which are run.
+type scriptKeys map[string]bool
+
+func (s scriptKeys) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	args := cmd.Args()
+	if name := cmd.Name(); (name == "evalsha" || name == "eval") && len(args) > 2 {
+		keys, _ := args[2].(int)
+		for _, key := range args[3 : 3+keys] {
+			s[fmt.Sprint(key)] = true
+		}
+	}
+
+	return ctx, nil
+}
+
+func (scriptKeys) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (scriptKeys) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (scriptKeys) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+var _ = Describe("the import batches", func() {
+	var (
+		redisService *services.RedisService
+		userService  *services.UserService
+	)
+
+	BeforeEach(func() {
+		userService, redisService = buildDependencies(mRedis.Addr())
+	})
+
+	AfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	It("writes the profiles to their boards along with the progress", func() {
+		_, err := userService.Create(ctx, &api.UserProfile{UserId: "moved", DisplayName: "before", Points: 5, Country: "TR"})
+		Expect(err).To(BeNil())
+
+		err = redisService.WriteImportBatch(ctx, &api.ImportBatch{
+			RunId: "run",
+			Fence: 1,
+			Rows:  3,
+			Profiles: []*api.UserProfile{
+				{UserId: "moved", DisplayName: "after", Points: 30, Country: "GB"},
+				{UserId: "new", DisplayName: "new", Points: 20, Country: "TR"},
+			},
+			Rejections: []*api.ImportRejection{{Row: 2, Error: "country is a required field"}},
+		})
+		Expect(err).To(BeNil())
+
+		profile, err := userService.GetByID(ctx, "moved")
+		Expect(err).To(BeNil())
+		Expect(profile.DisplayName).To(Equal("after"))
+		Expect(profile.Country).To(Equal("GB"))
+		Expect(profile.Points).To(BeEquivalentTo(30))
+
+		keys := services.NewKeySchema(KeyPrefix)
+		members, err := mRedis.ZMembers(keys.Board("TR"))
+		Expect(err).To(BeNil())
+		Expect(members).To(Equal([]string{"new"}))
+
+		report, err := redisService.GetImport(ctx, "run")
+		Expect(err).To(BeNil())
+		Expect(report.Rows).To(BeEquivalentTo(3))
+		Expect(report.Imported).To(BeEquivalentTo(2))
+		Expect(report.Rejected).To(BeEquivalentTo(1))
+		Expect(report.Rejections).To(Equal([]*api.ImportRejection{{Row: 2, Error: "country is a required field"}}))
+	})
+
+	It("declares every key it writes", func() {
+		_, err := userService.Create(ctx, &api.UserProfile{UserId: "moved", DisplayName: "before", Points: 5, Country: "TR"})
+		Expect(err).To(BeNil())
+		Expect(redisService.AddRankHistory(ctx, "TR", time.Hour, map[string]*api.RankHistoryEntry{
+			"moved": {Rank: 1, Timestamp: time.Now().Unix()},
+		})).To(Succeed())
+		before := map[string]bool{}
+		for _, key := range mRedis.Keys() {
+			before[key] = true
+		}
+
+		declared := scriptKeys{}
+		client := redis.NewClient(&redis.Options{Addr: mRedis.Addr()})
+		client.AddHook(declared)
+
+		err = services.NewRedisService(client, KeyPrefix).WriteImportBatch(ctx, &api.ImportBatch{
+			RunId: "run",
+			Fence: 1,
+			Rows:  3,
+			Profiles: []*api.UserProfile{
+				{UserId: "moved", DisplayName: "after", Points: 30, Country: "GB"},
+				{UserId: "new", DisplayName: "new", Points: 20, Country: "US"},
+			},
+			Rejections: []*api.ImportRejection{{Row: 2, Error: "country is a required field"}},
+		})
+		Expect(err).To(BeNil())
+
+		// the keys which were changed or dropped, along with the new ones
+		keys := services.NewKeySchema(KeyPrefix)
+		Expect(mRedis.Exists(keys.Board("TR"))).To(BeFalse())
+		Expect(mRedis.Exists(keys.RankHistory("TR", "moved"))).To(BeFalse())
+		written := []string{keys.Board("TR"), keys.RankHistory("TR", "moved"), keys.Board("GLOBAL"), keys.Profile("moved")}
+		for _, key := range mRedis.Keys() {
+			if !before[key] {
+				written = append(written, key)
+			}
+		}
+		Expect(written).To(HaveLen(9))
+		for _, key := range written {
+			Expect(declared).To(HaveKey(key))
+		}
+	})
+
+	It("moves a user who comes twice in a batch from the country it was given first", func() {
+		_, err := userService.Create(ctx, &api.UserProfile{UserId: "user", DisplayName: "user", Points: 5, Country: "TR"})
+		Expect(err).To(BeNil())
+
+		err = redisService.WriteImportBatch(ctx, &api.ImportBatch{
+			RunId: "run",
+			Fence: 1,
+			Rows:  2,
+			Profiles: []*api.UserProfile{
+				{UserId: "user", DisplayName: "user", Points: 10, Country: "GB"},
+				{UserId: "user", DisplayName: "user", Points: 20, Country: "US"},
+			},
+		})
+		Expect(err).To(BeNil())
+
+		keys := services.NewKeySchema(KeyPrefix)
+		Expect(mRedis.Exists(keys.Board("TR"))).To(BeFalse())
+		Expect(mRedis.Exists(keys.Board("GB"))).To(BeFalse())
+		members, err := mRedis.ZMembers(keys.Board("US"))
+		Expect(err).To(BeNil())
+		Expect(members).To(Equal([]string{"user"}))
+	})
+
+	It("leaves out batches written before and batches of a lost lease", func() {
+		batch := func(fence int64, rows int64, points float64) *api.ImportBatch {
+			return &api.ImportBatch{
+				RunId:    "run",
+				Fence:    fence,
+				Rows:     rows,
+				Profiles: []*api.UserProfile{{UserId: "user", DisplayName: "user", Points: points, Country: "TR"}},
+			}
+		}
+
+		Expect(redisService.WriteImportBatch(ctx, batch(2, 1, 10))).To(BeNil())
+		Expect(redisService.WriteImportBatch(ctx, batch(2, 1, 20))).To(BeNil())
+		Expect(redisService.WriteImportBatch(ctx, batch(1, 2, 30))).To(Equal(services.ErrLeaseLost))
+
+		profile, err := userService.GetByID(ctx, "user")
+		Expect(err).To(BeNil())
+		Expect(profile.Points).To(BeEquivalentTo(10))
+
+		report, err := redisService.GetImport(ctx, "run")
+		Expect(err).To(BeNil())
+		Expect(report.Rows).To(BeEquivalentTo(1))
+		Expect(report.Imported).To(BeEquivalentTo(1))
+	})
 })
 
 var _ = Describe("Leaderboard Service", func() {
@@ -321,13 +703,13 @@ var _ = Describe("Leaderboard Service", func() {
 		When("page=1, pageSize=10", func() {
 
 			It("returns 10 items", func() {
-				page, err := leaderboardService.GetPage("GLOBAL", 1, 10)
+				page, err := leaderboardService.GetPage(ctx, "GLOBAL", 1, 10)
 				Expect(err).To(BeNil())
 				Expect(len(page)).To(BeEquivalentTo(10))
 			})
 
 			It("returns items in ascending rank order", func() {
-				page, err := leaderboardService.GetPage("GLOBAL", 1, 10)
+				page, err := leaderboardService.GetPage(ctx, "GLOBAL", 1, 10)
 				Expect(err).To(BeNil())
 
 				for i := 0; i < len(page)-1; i++ {
@@ -336,7 +718,7 @@ var _ = Describe("Leaderboard Service", func() {
 			})
 
 			It("returns items in ascending score order", func() {
-				page, err := leaderboardService.GetPage("GLOBAL", 1, 10)
+				page, err := leaderboardService.GetPage(ctx, "GLOBAL", 1, 10)
 				Expect(err).To(BeNil())
 
 				for i := 0; i < len(page)-1; i++ {
@@ -345,12 +727,49 @@ var _ = Describe("Leaderboard Service", func() {
 			})
 
 			It("returns first page where it returns first row as top player", func() {
-				page, err := leaderboardService.GetPage("GLOBAL", 1, 10)
+				page, err := leaderboardService.GetPage(ctx, "GLOBAL", 1, 10)
 				Expect(err).To(BeNil())
 				Expect(page[0].Rank).To(BeEquivalentTo(1))
 			})
 
 		})
+
+		When("the deadline of the request has passed", func() {
+			It("returns the deadline error without querying redis", func() {
+				expired, cancel := context.WithTimeout(ctx, -time.Second)
+				defer cancel()
+
+				_, err := leaderboardService.GetPage(expired, "GLOBAL", 1, 10)
+				Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			})
+		})
+	})
+
+	Context("LeaderboardService.GetAround()", func() {
+		When("given a ranked user", func() {
+			It("returns the rows within radius of the user", func() {
+				userService, _ := buildDependencies(mRedis.Addr())
+				guid, err := userService.Create(ctx, &api.UserProfile{
+					DisplayName: "leader",
+					Points:      1_000_000_000,
+					Country:     "XX",
+				})
+				Expect(err).To(BeNil())
+
+				rows, err := leaderboardService.GetAround(ctx, "GLOBAL", guid, 2)
+				Expect(err).To(BeNil())
+				Expect(rows).To(HaveLen(3))
+				Expect(rows[0].DisplayName).To(BeEquivalentTo("leader"))
+				Expect(rows[2].Rank).To(BeEquivalentTo(3))
+			})
+		})
+
+		When("given an unranked user", func() {
+			It("returns error", func() {
+				_, err := leaderboardService.GetAround(ctx, "GLOBAL", uuid.New().String(), 2)
+				Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+			})
+		})
 	})
 })
 
@@ -374,11 +793,13 @@ func getRedisMockedLeaderboardService(redisAddr string, nPrefillUsers int) *serv
 }
 
 func generateUsers(userService *services.UserService, redisService api.RedisService, nUsers int) {
-	task := tasks.NewGenerateUsersSingletonTask(userService, redisService)
-	_, _ = task.Start(uint64(nUsers), 1)
-
-	status, _ := task.Status()
-	for status.RemainingUsers > 0 {
-		status, _ = task.Status()
+	countries := []string{"TR", "US", "GB", "CN", "JP", "AU", "NZ"}
+	for i := 0; i < nUsers; i++ {
+		_, err := userService.Create(ctx, &api.UserProfile{
+			DisplayName: fmt.Sprintf("user_%d", i),
+			Points:      rand.Float64() * 100_000,
+			Country:     countries[rand.Intn(len(countries))],
+		})
+		Expect(err).To(BeNil())
 	}
 }
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshots Export writes, Restore
+// reads the versions up to it.
+const SnapshotVersion = 1
+
+const snapshotBatchSize = 1000
+
+// Types of the records of a snapshot.
+const (
+	snapshotHeader  = "header"
+	snapshotProfile = "profile"
+	snapshotMember  = "member"
+	snapshotEnd     = "end"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// SnapshotReport counts the profiles, the boards, the archives and their
+// members a snapshot holds.
+type SnapshotReport struct {
+	Profiles int `json:"profiles"`
+	Boards   int `json:"boards"`
+	Archives int `json:"archives"`
+	Members  int `json:"members"`
+}
+
+// snapshotRecord is a line of a snapshot. The header comes first with the
+// version and the namespace of the snapshot, then a record for every
+// profile, then one for every member of a board or of an archive, and the
+// end record with the report of the snapshot last.
+type snapshotRecord struct {
+	Type      string `json:"type"`
+	Version   int    `json:"version,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+
+	UserId      string   `json:"user_id,omitempty"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Country     string   `json:"country,omitempty"`
+	Board       string   `json:"board,omitempty"`
+	Archive     string   `json:"archive,omitempty"`
+	Score       *float64 `json:"score,omitempty"`
+
+	*SnapshotReport
+}
+
+// Snapshotter writes the boards, the archives and the profiles of a key
+// namespace to a snapshot, an NDJSON file which may be gzip compressed, and
+// restores them from one, to the same namespace or to another one.
+//
+// Keys are read with SCAN and members with ZSCAN, a page at a time, so
+// redis keeps serving while a snapshot is taken. A snapshot is not taken at
+// a single point in time: what is written meanwhile may or may not be in
+// it. Rank histories, job runs and schedules are left out.
+type Snapshotter struct {
+	client    redis.UniversalClient
+	namespace string
+	keys      *KeySchema
+	now       func() time.Time
+}
+
+func NewSnapshotter(client redis.UniversalClient, namespace string) *Snapshotter {
+	return &Snapshotter{client: client, namespace: namespace, keys: NewKeySchema(namespace), now: time.Now}
+}
+
+// Export writes a snapshot of the namespace to w, compressed when asked to.
+func (s *Snapshotter) Export(ctx context.Context, w io.Writer, compress bool) (*SnapshotReport, error) {
+	var compressor *gzip.Writer
+	if compress {
+		compressor = gzip.NewWriter(w)
+		w = compressor
+	}
+
+	buffer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffer)
+	report := new(SnapshotReport)
+
+	err := encoder.Encode(&snapshotRecord{Type: snapshotHeader, Version: SnapshotVersion, Namespace: s.namespace, CreatedAt: s.now().Unix()})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.exportProfiles(ctx, encoder, report); err != nil {
+		return nil, err
+	}
+
+	if err := s.exportBoards(ctx, encoder, report); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Encode(&snapshotRecord{Type: snapshotEnd, SnapshotReport: report}); err != nil {
+		return nil, err
+	}
+
+	if err := buffer.Flush(); err != nil {
+		return nil, err
+	}
+
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// exportProfiles writes the profiles a page of keys at a time. SCAN may
+// return a key more than once, a profile is written once.
+func (s *Snapshotter) exportProfiles(ctx context.Context, encoder *json.Encoder, report *SnapshotReport) error {
+	seen := map[string]bool{}
+	return scanKeyPages(ctx, s.client, s.keys.ProfilePattern(), func(page []string) error {
+		keys := make([]string, 0, len(page))
+		for _, key := range page {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+
+		reads := s.client.Pipeline()
+		profiles := make([]*redis.SliceCmd, len(keys))
+		for i, key := range keys {
+			profiles[i] = reads.HMGet(ctx, key, "display_name", "country")
+		}
+		if _, err := reads.Exec(ctx); err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			// profiles deleted since the page was scanned have no fields
+			fields := profiles[i].Val()
+			displayName, _ := fields[0].(string)
+			country, _ := fields[1].(string)
+			if len(displayName) == 0 {
+				continue
+			}
+
+			err := encoder.Encode(&snapshotRecord{
+				Type:        snapshotProfile,
+				UserId:      s.keys.ProfileId(key),
+				DisplayName: displayName,
+				Country:     country,
+			})
+			if err != nil {
+				return err
+			}
+			report.Profiles++
+		}
+
+		return nil
+	})
+}
+
+// exportBoards writes the members of the boards, then of the archives, by
+// name. Boards are counted once they have a member written, as a restore
+// counts them.
+func (s *Snapshotter) exportBoards(ctx context.Context, encoder *json.Encoder, report *SnapshotReport) error {
+	boards, err := s.scanSorted(ctx, s.keys.BoardPattern())
+	if err != nil {
+		return err
+	}
+	archives, err := s.scanSorted(ctx, s.keys.ArchivesPattern())
+	if err != nil {
+		return err
+	}
+
+	for _, key := range boards {
+		members, err := s.exportMembers(ctx, encoder, key, s.keys.BoardName(key), "")
+		if err != nil {
+			return err
+		}
+
+		report.Members += members
+		if members > 0 {
+			report.Boards++
+		}
+	}
+
+	for _, key := range archives {
+		board, label := s.keys.ArchiveOf(key)
+		members, err := s.exportMembers(ctx, encoder, key, board, label)
+		if err != nil {
+			return err
+		}
+
+		report.Members += members
+		if members > 0 {
+			report.Archives++
+		}
+	}
+
+	return nil
+}
+
+// exportMembers writes the members of a board or an archive and returns how
+// many it wrote.
+func (s *Snapshotter) exportMembers(ctx context.Context, encoder *json.Encoder, key string, board string, archive string) (int, error) {
+	written := 0
+	iter := s.client.ZScan(ctx, key, 0, "", snapshotBatchSize).Iterator()
+	for iter.Next(ctx) {
+		member := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+
+		score, err := strconv.ParseFloat(iter.Val(), 64)
+		if err != nil {
+			return written, fmt.Errorf("malformed score of %s on %s: %w", member, key, err)
+		}
+
+		err = encoder.Encode(&snapshotRecord{Type: snapshotMember, Board: board, Archive: archive, UserId: member, Score: &score})
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, iter.Err()
+}
+
+// scanSorted returns the keys matching the pattern sorted, and once each.
+func (s *Snapshotter) scanSorted(ctx context.Context, pattern string) ([]string, error) {
+	keys, err := scanKeys(ctx, s.client, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	unique := keys[:0]
+	for _, key := range keys {
+		if len(unique) == 0 || key != unique[len(unique)-1] {
+			unique = append(unique, key)
+		}
+	}
+
+	return unique, nil
+}
+
+// Restore writes the profiles, the boards and the archives of a snapshot,
+// compressed or not, to the namespace of the snapshotter. A namespace which
+// has boards or profiles is refused unless the snapshot is merged into it,
+// its members and profiles are overwritten then and the others are kept.
+//
+// A snapshot which ends before its end record, or whose end record does not
+// count what was read, is reported as truncated along with what was
+// restored of it.
+func (s *Snapshotter) Restore(ctx context.Context, r io.Reader, merge bool) (*SnapshotReport, error) {
+	if !merge {
+		for _, pattern := range []string{s.keys.BoardPattern(), s.keys.ProfilePattern()} {
+			keys, err := s.hasKeys(ctx, pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			if keys {
+				return nil, errors.New("the namespace has boards or profiles, merge the snapshot to write over them")
+			}
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	if head, _ := reader.Peek(len(gzipMagic)); bytes.Equal(head, gzipMagic) {
+		decompressor, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer decompressor.Close()
+		reader = bufio.NewReader(decompressor)
+	}
+
+	report := new(SnapshotReport)
+	boards := map[string]bool{}
+	headerSeen := false
+	writes := s.client.Pipeline()
+	pending := 0
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+
+		pending = 0
+		_, err := writes.Exec(ctx)
+		return err
+	}
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(bytes.TrimSpace(data)) == 0 {
+			if flushErr := flush(); flushErr != nil {
+				return report, flushErr
+			}
+			return report, errors.New("the snapshot ends before its end record, it is truncated")
+		}
+		if err != nil && err != io.EOF {
+			return report, err
+		}
+
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		record := new(snapshotRecord)
+		if err := json.Unmarshal(data, record); err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// the header is the first record, blank lines may come ahead of it
+		if !headerSeen {
+			if record.Type != snapshotHeader {
+				return report, errors.New("the file is not a snapshot, it does not start with a header")
+			}
+			if record.Version < 1 || record.Version > SnapshotVersion {
+				return report, fmt.Errorf("snapshots of version %d are not supported, up to %d are", record.Version, SnapshotVersion)
+			}
+			headerSeen = true
+			continue
+		}
+
+		switch record.Type {
+		case snapshotProfile:
+			writes.HSet(ctx, s.keys.Profile(record.UserId), "display_name", record.DisplayName, "country", record.Country)
+			report.Profiles++
+		case snapshotMember:
+			if record.Score == nil {
+				return report, fmt.Errorf("line %d: member %s has no score", line, record.UserId)
+			}
+
+			key := s.keys.Board(record.Board)
+			if len(record.Archive) > 0 {
+				key = s.keys.Archive(record.Board, record.Archive)
+			}
+			writes.ZAdd(ctx, key, &redis.Z{Member: record.UserId, Score: *record.Score})
+			report.Members++
+
+			if !boards[key] {
+				boards[key] = true
+				if len(record.Archive) > 0 {
+					report.Archives++
+				} else {
+					report.Boards++
+				}
+			}
+		case snapshotEnd:
+			if err := flush(); err != nil {
+				return report, err
+			}
+
+			if record.SnapshotReport == nil || *record.SnapshotReport != *report {
+				return report, fmt.Errorf("the snapshot holds %+v but its end record counts %+v, it is truncated", *report, record.SnapshotReport)
+			}
+
+			return report, nil
+		default:
+			return report, fmt.Errorf("line %d: unknown record type %q", line, record.Type)
+		}
+
+		if pending++; pending == snapshotBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+}
+
+func (s *Snapshotter) hasKeys(ctx context.Context, pattern string) (bool, error) {
+	found := errors.New("found")
+	err := scanKeyPages(ctx, s.client, pattern, func([]string) error {
+		return found
+	})
+	if err == found {
+		return true, nil
+	}
+
+	return false, err
+}
@@ -0,0 +1,194 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/go-redis/redis/v8"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"strings"
+)
+
+// renameCommand sends a command under another name, to a command which was
+// registered on miniredis in its place.
+type renameCommand struct {
+	from string
+	to   string
+}
+
+func (r renameCommand) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if cmd.Name() == r.from {
+		cmd.Args()[0] = r.to
+	}
+
+	return ctx, nil
+}
+
+func (renameCommand) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (renameCommand) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (renameCommand) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+var _ = Describe("the snapshotter", func() {
+	const copyNamespace = "copy"
+
+	var (
+		client       redis.UniversalClient
+		redisService *services.RedisService
+		userService  *services.UserService
+	)
+
+	export := func(compress bool) (*bytes.Buffer, *services.SnapshotReport) {
+		snapshot := new(bytes.Buffer)
+		report, err := services.NewSnapshotter(client, KeyPrefix).Export(ctx, snapshot, compress)
+		Expect(err).To(BeNil())
+
+		return snapshot, report
+	}
+
+	BeforeEach(func() {
+		client = redis.NewClient(&redis.Options{Addr: mRedis.Addr()})
+		userService, redisService = buildDependencies(mRedis.Addr())
+
+		for i, country := range []string{"TR", "TR", "US"} {
+			_, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: float64(10 * i), Country: country})
+			Expect(err).To(BeNil())
+		}
+		Expect(redisService.ArchiveBoard(ctx, "US", "season-1")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		mRedis.FlushAll()
+	})
+
+	It("exports the profiles, the boards and the archives", func() {
+		snapshot, report := export(false)
+		Expect(report).To(Equal(&services.SnapshotReport{Profiles: 3, Boards: 2, Archives: 1, Members: 6}))
+
+		lines := strings.Split(strings.TrimSpace(snapshot.String()), "\n")
+		Expect(lines).To(HaveLen(11))
+		Expect(lines[0]).To(ContainSubstring(`"type":"header","version":1,"namespace":"` + KeyPrefix + `"`))
+		Expect(lines[4]).To(ContainSubstring(`"type":"member"`))
+		Expect(lines[4]).To(ContainSubstring(`"board":"GLOBAL"`))
+		Expect(lines[9]).To(ContainSubstring(`"archive":"season-1"`))
+		Expect(lines[10]).To(Equal(`{"type":"end","profiles":3,"boards":2,"archives":1,"members":6}`))
+	})
+
+	It("restores a compressed snapshot to another namespace", func() {
+		snapshot, exported := export(true)
+
+		restored, err := services.NewSnapshotter(client, copyNamespace).Restore(ctx, snapshot, false)
+		Expect(err).To(BeNil())
+		Expect(restored).To(Equal(exported))
+
+		keys := services.NewKeySchema(copyNamespace)
+		for _, key := range []string{keys.Board("GLOBAL"), keys.Board("TR"), keys.Archive("US", "season-1")} {
+			original, err := mRedis.ZMembers(strings.Replace(key, "{"+copyNamespace+"}", "{"+KeyPrefix+"}", 1))
+			Expect(err).To(BeNil())
+			Expect(mRedis.ZMembers(key)).To(Equal(original))
+		}
+
+		copied := services.NewRedisService(client, copyNamespace)
+		ids, err := mRedis.ZMembers(keys.Board("GLOBAL"))
+		Expect(err).To(BeNil())
+		for _, id := range ids {
+			profile, err := copied.GetProfile(ctx, id)
+			Expect(err).To(BeNil())
+
+			original, err := redisService.GetProfile(ctx, id)
+			Expect(err).To(BeNil())
+			Expect(profile).To(Equal(original))
+		}
+	})
+
+	It("refuses a namespace with boards unless merging", func() {
+		snapshot, _ := export(false)
+		data := snapshot.Bytes()
+
+		_, err := services.NewSnapshotter(client, KeyPrefix).Restore(ctx, bytes.NewReader(data), false)
+		Expect(err).NotTo(BeNil())
+
+		_, err = services.NewSnapshotter(client, KeyPrefix).Restore(ctx, bytes.NewReader(data), true)
+		Expect(err).To(BeNil())
+	})
+
+	It("writes the profiles SCAN returns twice once", func() {
+		repeating, err := miniredis.Run()
+		Expect(err).To(BeNil())
+		defer repeating.Close()
+
+		// every key matching is answered on the first page and again on the
+		// second one
+		err = repeating.Server().Register("RESCAN", func(c *server.Peer, _ string, args []string) {
+			var keys []string
+			for _, key := range repeating.Keys() {
+				if strings.HasPrefix(key, strings.TrimSuffix(args[2], "*")) {
+					keys = append(keys, key)
+				}
+			}
+
+			next := "1"
+			if args[0] != "0" {
+				next = "0"
+			}
+
+			c.WriteLen(2)
+			c.WriteBulk(next)
+			c.WriteLen(len(keys))
+			for _, key := range keys {
+				c.WriteBulk(key)
+			}
+		})
+		Expect(err).To(BeNil())
+
+		client := redis.NewClient(&redis.Options{Addr: repeating.Addr()})
+		client.AddHook(renameCommand{from: "scan", to: "rescan"})
+		userService := services.NewUserService(services.NewRedisService(client, KeyPrefix), KeyPrefix)
+		for _, country := range []string{"TR", "US"} {
+			_, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Country: country})
+			Expect(err).To(BeNil())
+		}
+
+		snapshot := new(bytes.Buffer)
+		report, err := services.NewSnapshotter(client, KeyPrefix).Export(ctx, snapshot, false)
+		Expect(err).To(BeNil())
+		Expect(report).To(Equal(&services.SnapshotReport{Profiles: 2, Boards: 3, Members: 4}))
+		Expect(strings.Count(snapshot.String(), `"type":"profile"`)).To(Equal(2))
+	})
+
+	It("checks the header of a snapshot after blank lines", func() {
+		snapshot, exported := export(false)
+
+		restored, err := services.NewSnapshotter(client, copyNamespace).Restore(ctx, strings.NewReader("\n \r\n"+snapshot.String()), false)
+		Expect(err).To(BeNil())
+		Expect(restored).To(Equal(exported))
+
+		mRedis.FlushAll()
+		lines := strings.SplitAfter(snapshot.String(), "\n")
+		_, err = services.NewSnapshotter(client, copyNamespace).Restore(ctx, strings.NewReader("\n"+strings.Join(lines[1:], "")), false)
+		Expect(err).To(MatchError(ContainSubstring("it does not start with a header")))
+	})
+
+	It("reports truncated snapshots and unknown versions", func() {
+		snapshot, _ := export(false)
+		lines := strings.SplitAfter(snapshot.String(), "\n")
+
+		_, err := services.NewSnapshotter(client, copyNamespace).Restore(ctx, strings.NewReader(strings.Join(lines[:5], "")), false)
+		Expect(err).To(MatchError(ContainSubstring("truncated")))
+
+		mRedis.FlushAll()
+		_, err = services.NewSnapshotter(client, copyNamespace).Restore(ctx, strings.NewReader(`{"type":"header","version":2}`+"\n"), false)
+		Expect(err).To(MatchError(ContainSubstring("version 2")))
+	})
+})
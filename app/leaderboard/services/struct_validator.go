@@ -1,15 +1,220 @@
 package services
 
-import "github.com/go-playground/validator/v10"
+import (
+	"fmt"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/tr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	esTranslations "github.com/go-playground/validator/v10/translations/es"
+	frTranslations "github.com/go-playground/validator/v10/translations/fr"
+	trTranslations "github.com/go-playground/validator/v10/translations/tr"
+	"leaderboard/app/api"
+	"reflect"
+	"strings"
+)
 
+const (
+	// the message of the envelope of validation errors
+	translationInvalidRequest = "invalid_request"
+	// the message of rules which have no translation of their own
+	translationFailedRule = "failed_rule"
+)
+
+type validationLocale struct {
+	locale   locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+	messages map[string]string
+	rules    map[string]string
+}
+
+// validationLocales are the languages of validation messages, the first one
+// is the fallback for clients which accept none of them. rules translates
+// the rules which validator has no translation for.
+var validationLocales = []validationLocale{
+	{
+		locale:   en.New(),
+		register: enTranslations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			translationInvalidRequest: "the request is invalid",
+			translationFailedRule:     "{0} fails the {1} rule",
+		},
+		rules: map[string]string{"required_if": "{0} is a required field"},
+	},
+	{
+		locale:   es.New(),
+		register: esTranslations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			translationInvalidRequest: "la solicitud no es válida",
+			translationFailedRule:     "{0} no cumple la regla {1}",
+		},
+		rules: map[string]string{"required_if": "{0} es un campo requerido"},
+	},
+	{
+		locale:   fr.New(),
+		register: frTranslations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			translationInvalidRequest: "la requête n'est pas valide",
+			translationFailedRule:     "{0} ne respecte pas la règle {1}",
+		},
+		rules: map[string]string{"required_if": "{0} est un champ obligatoire"},
+	},
+	{
+		locale:   tr.New(),
+		register: trTranslations.RegisterDefaultTranslations,
+		messages: map[string]string{
+			translationInvalidRequest: "istek geçersiz",
+			translationFailedRule:     "{0} {1} kuralını sağlamıyor",
+		},
+		rules: map[string]string{"required_if": "{0} zorunlu bir alandır"},
+	},
+}
+
+// StructValidator validates requests by their validate tags. Fields are
+// reported by their json or query names, so clients find them in their
+// requests.
 type StructValidator struct {
 	validator *validator.Validate
+	universal *ut.UniversalTranslator
 }
 
-func NewStructValidator(validator *validator.Validate) *StructValidator {
-	return &StructValidator{validator: validator}
+func NewStructValidator(validate *validator.Validate) *StructValidator {
+	validate.RegisterTagNameFunc(requestFieldName)
+
+	translators := make([]locales.Translator, 0, len(validationLocales))
+	for _, l := range validationLocales {
+		translators = append(translators, l.locale)
+	}
+	universal := ut.New(translators[0], translators...)
+
+	for _, l := range validationLocales {
+		trans, _ := universal.GetTranslator(l.locale.Locale())
+		if err := l.register(validate, trans); err != nil {
+			panic(err)
+		}
+
+		for key, text := range l.messages {
+			if err := trans.Add(key, text, true); err != nil {
+				panic(err)
+			}
+		}
+
+		for rule, text := range l.rules {
+			err := validate.RegisterTranslation(rule, trans, registerTranslation(rule, text), translateField)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return &StructValidator{validator: validate, universal: universal}
 }
 
+// Validate returns a *ValidationError when i fails its rules.
 func (cv *StructValidator) Validate(i interface{}) error {
-	return cv.validator.Struct(i)
+	err := cv.validator.Struct(i)
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		return &ValidationError{errors: validationErrors, translators: cv.universal}
+	}
+
+	return err
+}
+
+// ValidationError lists the fields of a request which fail their rules, it
+// is an api.ErrInvalid error.
+type ValidationError struct {
+	errors      validator.ValidationErrors
+	translators *ut.UniversalTranslator
+}
+
+// Error lists the fields in the fallback language.
+func (e *ValidationError) Error() string {
+	fields := e.Fields()
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == api.ErrInvalid
+}
+
+// Message describes the error as a whole, in the first of the given
+// locales which is supported.
+func (e *ValidationError) Message(locales ...string) string {
+	message, _ := e.translator(locales).T(translationInvalidRequest)
+	return message
+}
+
+// Fields describes every failing field, in the first of the given locales
+// which is supported.
+func (e *ValidationError) Fields(locales ...string) []api.FieldError {
+	trans := e.translator(locales)
+
+	fields := make([]api.FieldError, 0, len(e.errors))
+	for _, fieldError := range e.errors {
+		message := fieldError.Translate(trans)
+		if message == fieldError.Error() {
+			// validator answers its own message for rules it can not translate
+			message, _ = trans.T(translationFailedRule, fieldError.Field(), fieldError.Tag())
+		}
+
+		fields = append(fields, api.FieldError{
+			Path:    fieldPath(fieldError.Namespace()),
+			Rule:    fieldError.Tag(),
+			Message: message,
+		})
+	}
+
+	return fields
+}
+
+func (e *ValidationError) translator(locales []string) ut.Translator {
+	trans, _ := e.translators.FindTranslator(locales...)
+	return trans
+}
+
+// requestFieldName names fields as they are named in requests, by the json
+// tag of bodies or the query tag of queries.
+func requestFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// fieldPath drops the struct name from the namespace of a field, e.g.
+// BatchScoreSubmission.submissions[0].user_id is submissions[0].user_id.
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return namespace
+}
+
+func registerTranslation(rule string, text string) validator.RegisterTranslationsFunc {
+	return func(trans ut.Translator) error {
+		return trans.Add(rule, text, true)
+	}
+}
+
+func translateField(trans ut.Translator, fieldError validator.FieldError) string {
+	message, err := trans.T(fieldError.Tag(), fieldError.Field())
+	if err != nil {
+		return fmt.Sprint(fieldError)
+	}
+
+	return message
 }
@@ -0,0 +1,72 @@
+package services_test
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+)
+
+var _ = Describe("the struct validator", func() {
+	var structValidator *services.StructValidator
+
+	BeforeEach(func() {
+		structValidator = services.NewStructValidator(validator.New())
+	})
+
+	validate := func(i interface{}) *services.ValidationError {
+		err := structValidator.Validate(i)
+		Expect(errors.Is(err, api.ErrInvalid)).To(BeTrue())
+
+		var validationError *services.ValidationError
+		Expect(errors.As(err, &validationError)).To(BeTrue())
+
+		return validationError
+	}
+
+	When("a request is valid", func() {
+		It("returns no error", func() {
+			Expect(structValidator.Validate(&api.AroundQuery{Radius: 5})).To(Succeed())
+		})
+	})
+
+	When("fields of a request fail their rules", func() {
+		It("reports the fields by their paths in the request", func() {
+			fields := validate(&api.BatchScoreSubmission{
+				Submissions: []*api.ScoreSubmission{{UserId: "a-guid", Timestamp: 1, Score: 1}, {Score: 1, Timestamp: 1}},
+			}).Fields()
+
+			Expect(fields).To(Equal([]api.FieldError{{
+				Path:    "submissions[1].user_id",
+				Rule:    "required",
+				Message: "user_id is a required field",
+			}}))
+		})
+
+		It("translates the messages to the first supported locale", func() {
+			validationError := validate(&api.AroundQuery{Radius: 500})
+
+			Expect(validationError.Message("de", "tr")).To(Equal("istek geçersiz"))
+			Expect(validationError.Fields("de", "tr")[0].Message).To(Equal("radius, 100 veya daha az olmalıdır"))
+		})
+
+		It("falls back to english for unsupported locales", func() {
+			validationError := validate(&api.AroundQuery{Radius: 500})
+
+			Expect(validationError.Message("de")).To(Equal("the request is invalid"))
+			Expect(validationError.Error()).To(Equal("radius must be 100 or less"))
+		})
+
+		It("names the rule of rules without a translation", func() {
+			fields := validate(&struct {
+				Name     string `json:"name"`
+				Nickname string `json:"nickname" validate:"required_with=Name"`
+			}{Name: "a name"}).Fields("es")
+
+			Expect(fields[0].Path).To(Equal("nickname"))
+			Expect(fields[0].Message).To(Equal("nickname no cumple la regla required_with"))
+		})
+	})
+})
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"github.com/go-redis/redis/v8"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
@@ -16,40 +18,91 @@ func NewUserService(redisService api.RedisService, leaderboardKeyPrefix string)
 	return &UserService{redisService: redisService, leaderboardKeyPrefix: leaderboardKeyPrefix}
 }
 
-func (us *UserService) Create(profile *api.UserProfile) (string, error) {
+func (us *UserService) Create(ctx context.Context, profile *api.UserProfile) (string, error) {
 	if len(profile.UserId) == 0 {
 		profile.UserId = uuid.New().String()
 	}
 
-	err := us.redisService.SetProfile(profile)
+	err := us.redisService.SetProfile(ctx, profile)
 	if err != nil {
 		return "", err
 	}
 
-	us.redisService.Add("GLOBAL", &redis.Z{
-		Score:  profile.Points,
-		Member: profile.UserId,
-	})
-
-	us.redisService.Add(profile.Country, &redis.Z{
-		Score:  profile.Points,
-		Member: profile.UserId,
-	})
+	for _, board := range []string{"GLOBAL", profile.Country} {
+		err := us.redisService.Add(ctx, board, &redis.Z{
+			Score:  profile.Points,
+			Member: profile.UserId,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
 
 	return profile.UserId, nil
 }
 
-func (us *UserService) GetByID(guid string) (*api.UserProfile, error) {
-	return us.redisService.GetProfile(guid)
+// Update changes the display name or the country of a user. The score of a
+// user who moves to another country moves along to the board of that
+// country.
+func (us *UserService) Update(ctx context.Context, id string, update *api.UserUpdate) (*api.UserProfile, error) {
+	for attempt := 0; ; attempt++ {
+		profile, err := us.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		previousCountry := profile.Country
+		if len(update.DisplayName) > 0 {
+			profile.DisplayName = update.DisplayName
+		}
+		if len(update.Country) > 0 {
+			profile.Country = update.Country
+		}
+
+		// a profile moved meanwhile is read again to move it from its new
+		// board
+		err = us.redisService.UpdateProfile(ctx, previousCountry, profile)
+		if errors.Is(err, ErrProfileChanged) && attempt == 0 {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return profile, nil
+	}
+}
+
+// Delete removes a user from its boards and drops its profile and rank
+// histories.
+func (us *UserService) Delete(ctx context.Context, id string) error {
+	for attempt := 0; ; attempt++ {
+		profile, err := us.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		err = us.redisService.DeleteProfile(ctx, profile)
+		if errors.Is(err, ErrProfileChanged) && attempt == 0 {
+			continue
+		}
+
+		return err
+	}
+}
+
+func (us *UserService) GetByID(ctx context.Context, guid string) (*api.UserProfile, error) {
+	return us.redisService.GetProfile(ctx, guid)
 }
 
-func (us *UserService) GetByIDWithRank(guid string, leaderboardName string) (*api.UserProfile, error) {
-	profile, err := us.GetByID(guid)
+func (us *UserService) GetByIDWithRank(ctx context.Context, guid string, leaderboardName string) (*api.UserProfile, error) {
+	profile, err := us.GetByID(ctx, guid)
 	if err != nil {
 		return nil, err
 	}
 
-	err = us.SetRank(profile, leaderboardName)
+	err = us.SetRank(ctx, profile, leaderboardName)
 	if err != nil {
 		return nil, err
 	}
@@ -57,28 +110,25 @@ func (us *UserService) GetByIDWithRank(guid string, leaderboardName string) (*ap
 	return profile, nil
 }
 
-func (us *UserService) SetRank(profile *api.UserProfile, leaderboardName string) error {
-	rank, err := us.redisService.GetRank(leaderboardName, profile.UserId)
+func (us *UserService) SetRank(ctx context.Context, profile *api.UserProfile, leaderboardName string) error {
+	rank, err := us.redisService.GetRank(ctx, leaderboardName, profile.UserId)
 	if err != nil {
 		return err
 	}
 
 	profile.Rank = rank
-	go func() {
-		_ = us.redisService.SetProfile(profile)
-	}()
 
 	return nil
 }
 
-func (us *UserService) GetAllByID(guid ...string) ([]*api.UserProfile, error) {
+func (us *UserService) GetAllByID(ctx context.Context, guid ...string) ([]*api.UserProfile, error) {
 	if len(guid) == 0 {
 		return []*api.UserProfile{}, nil
 	}
 
 	var profiles []*api.UserProfile
 	for _, id := range guid {
-		byID, err := us.GetByID(id)
+		byID, err := us.GetByID(ctx, id)
 		if err != nil {
 			return nil, err
 		}
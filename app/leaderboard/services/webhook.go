@@ -0,0 +1,555 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/api"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const KeyWebhooks = "WEBHOOKS"
+const KeyWebhookDeliveriesPrefix = "WEBHOOK_DELIVERIES_"
+const KeyWebhookDeadLetter = "WEBHOOK_DEAD_LETTER"
+
+const (
+	WebhookTriggerTopN      = "top_n"
+	WebhookTriggerOvertaken = "overtaken"
+	WebhookTriggerNewLeader = "new_leader"
+)
+
+const (
+	HeaderWebhookSignature = "X-Leaderboard-Signature"
+	HeaderWebhookTimestamp = "X-Leaderboard-Timestamp"
+	HeaderWebhookDelivery  = "X-Leaderboard-Delivery"
+	HeaderWebhookTrigger   = "X-Leaderboard-Trigger"
+)
+
+const (
+	webhookQueueSize      = 1024
+	webhookDeliveryLogLen = 100
+	webhookDeadLetterLen  = 10_000
+	webhookCacheTTL       = 5 * time.Second
+	webhookMaxBackoff     = time.Minute
+)
+
+var ErrWebhookNotFound = api.NewError(api.ErrNotFound, "webhook is not found")
+
+var (
+	errWebhookQueueFull = errors.New("delivery queue is full")
+	errWebhooksStopped  = errors.New("the service stopped before the delivery")
+)
+
+type WebhookConfiguration struct {
+	Workers      int
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Timeout      time.Duration
+}
+
+// webhookDelivery is a payload on its way to a webhook, last is the result
+// of its last attempt.
+type webhookDelivery struct {
+	webhook *api.Webhook
+	payload *api.WebhookPayload
+	attempt int
+	last    *api.WebhookDelivery
+}
+
+// WebhookService evaluates webhook triggers on score events and delivers
+// the matching ones in the background. Deliveries are signed with the
+// secret of the webhook, retried with exponential backoff and moved to the
+// dead-letter list when every attempt fails.
+//
+// Retries wait on a timer rather than in a worker, which takes the next
+// delivery meanwhile. Deliveries which are queued or waiting for a retry
+// when the service stops are moved to the dead-letter list.
+type WebhookService struct {
+	redisService  api.RedisService
+	configuration *WebhookConfiguration
+	client        *http.Client
+	queue         chan *webhookDelivery
+	stop          chan struct{}
+	stopOnce      sync.Once
+	workers       sync.WaitGroup
+	timers        sync.WaitGroup
+	retries       map[*webhookDelivery]*time.Timer
+	stopped       bool
+	queueMux      sync.Mutex
+	cache         []*api.Webhook
+	cachedAt      time.Time
+	cacheMux      sync.Mutex
+}
+
+func NewWebhookService(redisService api.RedisService, configuration *WebhookConfiguration) *WebhookService {
+	return &WebhookService{
+		redisService:  redisService,
+		configuration: configuration,
+		client:        &http.Client{Timeout: configuration.Timeout},
+		queue:         make(chan *webhookDelivery, webhookQueueSize),
+		stop:          make(chan struct{}),
+		retries:       map[*webhookDelivery]*time.Timer{},
+	}
+}
+
+func (ws *WebhookService) Start() {
+	for i := 0; i < ws.configuration.Workers; i++ {
+		ws.workers.Add(1)
+		go ws.work()
+	}
+}
+
+// Stop waits for the deliveries in flight and moves the queued ones, and
+// the ones waiting for a retry, to the dead-letter list.
+func (ws *WebhookService) Stop() {
+	ws.stopOnce.Do(func() {
+		ws.queueMux.Lock()
+		ws.stopped = true
+		var abandoned []*webhookDelivery
+		for delivery, timer := range ws.retries {
+			if timer.Stop() {
+				ws.timers.Done()
+			}
+			abandoned = append(abandoned, delivery)
+		}
+		ws.retries = nil
+		ws.queueMux.Unlock()
+
+		close(ws.stop)
+		ws.workers.Wait()
+		ws.timers.Wait()
+
+		for drained := false; !drained; {
+			select {
+			case delivery := <-ws.queue:
+				abandoned = append(abandoned, delivery)
+			default:
+				drained = true
+			}
+		}
+
+		for _, delivery := range abandoned {
+			ws.abandon(context.Background(), delivery, errWebhooksStopped.Error())
+		}
+	})
+}
+
+func (ws *WebhookService) Create(ctx context.Context, webhook *api.Webhook) (string, error) {
+	webhook.Id = uuid.New().String()
+	webhook.Board = strings.ToUpper(webhook.Board)
+	if len(webhook.Board) == 0 {
+		webhook.Board = "GLOBAL"
+	}
+
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ws.redisService.HSet(ctx, KeyWebhooks, webhook.Id, data).Err(); err != nil {
+		return "", err
+	}
+
+	ws.invalidateCache()
+
+	return webhook.Id, nil
+}
+
+func (ws *WebhookService) GetByID(ctx context.Context, id string) (*api.Webhook, error) {
+	data, err := ws.redisService.HGet(ctx, KeyWebhooks, id)
+	if err == redis.Nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := new(api.Webhook)
+	if err := json.Unmarshal([]byte(data), webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (ws *WebhookService) GetAll(ctx context.Context) ([]*api.Webhook, error) {
+	resultMap, err := ws.redisService.HGetAll(ctx, KeyWebhooks).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*api.Webhook, 0, len(resultMap))
+	for id, data := range resultMap {
+		webhook := new(api.Webhook)
+		if err := json.Unmarshal([]byte(data), webhook); err != nil {
+			return nil, fmt.Errorf("malformed webhook (%s): %w", id, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (ws *WebhookService) Delete(ctx context.Context, id string) error {
+	if _, err := ws.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := ws.redisService.HDel(ctx, KeyWebhooks, id); err != nil {
+		return err
+	}
+
+	ws.invalidateCache()
+
+	if err := ws.redisService.Del(ctx, KeyWebhookDeliveriesPrefix+id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ws *WebhookService) GetDeliveries(ctx context.Context, id string, limit int64) ([]*api.WebhookDelivery, error) {
+	return ws.getDeliveries(ctx, KeyWebhookDeliveriesPrefix+id, limit)
+}
+
+func (ws *WebhookService) GetDeadLetters(ctx context.Context, limit int64) ([]*api.WebhookDelivery, error) {
+	return ws.getDeliveries(ctx, KeyWebhookDeadLetter, limit)
+}
+
+func (ws *WebhookService) getDeliveries(ctx context.Context, key string, limit int64) ([]*api.WebhookDelivery, error) {
+	entries, err := ws.redisService.GetList(ctx, key, 0, limit-1)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*api.WebhookDelivery, 0, len(entries))
+	for _, entry := range entries {
+		delivery := new(api.WebhookDelivery)
+		if err := json.Unmarshal([]byte(entry), delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// OnScoreEvents evaluates the triggers of every webhook against the events
+// and queues a delivery for each one that fires.
+func (ws *WebhookService) OnScoreEvents(ctx context.Context, events []*api.ScoreEvent) {
+	webhooks, err := ws.getCached(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, event := range events {
+		for _, webhook := range webhooks {
+			if webhook.Board != event.Board {
+				continue
+			}
+
+			payload, err := ws.evaluate(ctx, webhook, event)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+
+			if payload != nil {
+				ws.enqueue(ctx, &webhookDelivery{webhook: webhook, payload: payload})
+			}
+		}
+	}
+}
+
+func (ws *WebhookService) evaluate(ctx context.Context, webhook *api.Webhook, event *api.ScoreEvent) (*api.WebhookPayload, error) {
+	payload := &api.WebhookPayload{
+		Id:           uuid.New().String(),
+		WebhookId:    webhook.Id,
+		Trigger:      webhook.Trigger,
+		Board:        event.Board,
+		UserId:       event.UserId,
+		Rank:         event.Rank,
+		PreviousRank: event.PreviousRank,
+		Timestamp:    time.Now().Unix(),
+	}
+
+	switch webhook.Trigger {
+	case WebhookTriggerTopN:
+		if len(webhook.UserId) > 0 && webhook.UserId != event.UserId {
+			return nil, nil
+		}
+
+		if event.Rank <= webhook.TopN && (event.PreviousRank == 0 || event.PreviousRank > webhook.TopN) {
+			return payload, nil
+		}
+	case WebhookTriggerNewLeader:
+		if event.Rank == 1 && event.PreviousRank != 1 {
+			return payload, nil
+		}
+	case WebhookTriggerOvertaken:
+		if webhook.UserId == event.UserId || !contains(webhook.Friends, event.UserId) {
+			return nil, nil
+		}
+
+		rank, err := ws.redisService.GetRank(ctx, event.Board, webhook.UserId)
+		if err == redis.Nil {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the user was pushed down by one when the friend moved past them
+		if event.Rank < rank && (event.PreviousRank == 0 || event.PreviousRank >= rank) {
+			payload.OvertakenId = webhook.UserId
+			return payload, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (ws *WebhookService) enqueue(ctx context.Context, delivery *webhookDelivery) {
+	if err := ws.push(delivery); err != nil {
+		ws.abandon(ctx, delivery, err.Error())
+	}
+}
+
+// push queues a delivery unless the queue is full or the service stopped,
+// a delivery queued after Stop drained the queue would be lost.
+func (ws *WebhookService) push(delivery *webhookDelivery) error {
+	ws.queueMux.Lock()
+	defer ws.queueMux.Unlock()
+
+	if ws.stopped {
+		return errWebhooksStopped
+	}
+
+	select {
+	case ws.queue <- delivery:
+		return nil
+	default:
+		return errWebhookQueueFull
+	}
+}
+
+// retry queues the delivery again once its backoff passes, the backoff
+// doubles with every attempt.
+func (ws *WebhookService) retry(ctx context.Context, delivery *webhookDelivery) {
+	backoff := ws.configuration.RetryBackoff
+	for i := 1; i < delivery.attempt && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+
+	ws.queueMux.Lock()
+	if ws.stopped {
+		ws.queueMux.Unlock()
+		ws.abandon(ctx, delivery, errWebhooksStopped.Error())
+		return
+	}
+	defer ws.queueMux.Unlock()
+
+	ws.timers.Add(1)
+	ws.retries[delivery] = time.AfterFunc(backoff, func() {
+		defer ws.timers.Done()
+
+		ws.queueMux.Lock()
+		_, waiting := ws.retries[delivery]
+		delete(ws.retries, delivery)
+		ws.queueMux.Unlock()
+
+		// Stop takes over the retries which did not fire yet
+		if waiting {
+			ws.enqueue(ctx, delivery)
+		}
+	})
+}
+
+// abandon moves the delivery to the dead-letter list with the result of its
+// last attempt, or with the reason when it was never attempted.
+func (ws *WebhookService) abandon(ctx context.Context, delivery *webhookDelivery, reason string) {
+	result := delivery.last
+	if result == nil {
+		result = &api.WebhookDelivery{
+			Payload:   delivery.payload,
+			Error:     reason,
+			Timestamp: time.Now().Unix(),
+		}
+	}
+
+	ws.deadLetter(ctx, result)
+}
+
+func (ws *WebhookService) work() {
+	defer ws.workers.Done()
+
+	for {
+		// a stopped service leaves the queued deliveries to Stop, even when
+		// one is ready as well
+		select {
+		case <-ws.stop:
+			return
+		default:
+		}
+
+		select {
+		case <-ws.stop:
+			return
+		case delivery := <-ws.queue:
+			ws.deliver(delivery)
+		}
+	}
+}
+
+// deliver makes an attempt of the delivery, and schedules the next one
+// when it fails. It keeps writing the delivery log after Stop, so it does
+// not use a context which is cancelled with the service.
+func (ws *WebhookService) deliver(delivery *webhookDelivery) {
+	ctx := context.Background()
+	body, err := json.Marshal(delivery.payload)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	delivery.attempt++
+	result := ws.post(delivery, body)
+	result.Attempt = delivery.attempt
+	delivery.last = result
+
+	ws.log(ctx, delivery, result)
+	if result.Success {
+		return
+	}
+
+	if delivery.attempt >= ws.configuration.MaxAttempts {
+		ws.deadLetter(ctx, result)
+		return
+	}
+
+	ws.retry(ctx, delivery)
+}
+
+func (ws *WebhookService) post(delivery *webhookDelivery, body []byte) *api.WebhookDelivery {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	result := &api.WebhookDelivery{
+		Payload:   delivery.payload,
+		Timestamp: time.Now().Unix(),
+	}
+
+	request, err := http.NewRequest(http.MethodPost, delivery.webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderWebhookTimestamp, timestamp)
+	request.Header.Set(HeaderWebhookDelivery, delivery.payload.Id)
+	request.Header.Set(HeaderWebhookTrigger, delivery.payload.Trigger)
+	request.Header.Set(HeaderWebhookSignature, SignWebhookPayload(delivery.webhook.Secret, timestamp, body))
+
+	response, err := ws.client.Do(request)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer response.Body.Close()
+
+	result.StatusCode = response.StatusCode
+	result.Success = response.StatusCode >= 200 && response.StatusCode < 300
+	if !result.Success {
+		result.Error = response.Status
+	}
+
+	return result
+}
+
+func (ws *WebhookService) log(ctx context.Context, delivery *webhookDelivery, result *api.WebhookDelivery) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := ws.redisService.PushToList(ctx, KeyWebhookDeliveriesPrefix+delivery.webhook.Id, webhookDeliveryLogLen, data); err != nil {
+		log.Error(err)
+	}
+}
+
+func (ws *WebhookService) deadLetter(ctx context.Context, result *api.WebhookDelivery) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := ws.redisService.PushToList(ctx, KeyWebhookDeadLetter, webhookDeadLetterLen, data); err != nil {
+		log.Error(err)
+	}
+}
+
+func (ws *WebhookService) getCached(ctx context.Context) ([]*api.Webhook, error) {
+	ws.cacheMux.Lock()
+	defer ws.cacheMux.Unlock()
+
+	if ws.cache != nil && time.Since(ws.cachedAt) < webhookCacheTTL {
+		return ws.cache, nil
+	}
+
+	webhooks, err := ws.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.cache = webhooks
+	ws.cachedAt = time.Now()
+
+	return webhooks, nil
+}
+
+func (ws *WebhookService) invalidateCache() {
+	ws.cacheMux.Lock()
+	defer ws.cacheMux.Unlock()
+
+	ws.cache = nil
+}
+
+// SignWebhookPayload signs the timestamp and the body of a delivery, so
+// receivers can verify both the sender and the freshness of the request.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,275 @@
+package services_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+const webhookSecret = "s3cr3t"
+
+var _ = Describe("the webhook service", func() {
+	var (
+		scoreService   *services.ScoreService
+		webhookService *services.WebhookService
+		receiver       *httptest.Server
+		received       chan *api.WebhookPayload
+		status         int32
+		attempts       int32
+		retryBackoff   time.Duration
+		hold           chan struct{}
+	)
+
+	BeforeEach(func() {
+		received = make(chan *api.WebhookPayload, 16)
+		atomic.StoreInt32(&status, http.StatusOK)
+		atomic.StoreInt32(&attempts, 0)
+		retryBackoff = time.Millisecond
+		hold = nil
+
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			if hold != nil {
+				<-hold
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).To(BeNil())
+
+			signature := services.SignWebhookPayload(webhookSecret, r.Header.Get(services.HeaderWebhookTimestamp), body)
+			if signature != r.Header.Get(services.HeaderWebhookSignature) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			code := int(atomic.LoadInt32(&status))
+			if code == http.StatusOK {
+				payload := new(api.WebhookPayload)
+				Expect(json.Unmarshal(body, payload)).To(BeNil())
+				received <- payload
+			}
+
+			w.WriteHeader(code)
+		}))
+	})
+
+	JustBeforeEach(func() {
+		userService, redisService := buildDependencies(mRedis.Addr())
+		scoreService = services.NewScoreService(userService, redisService, services.ScoreModeReplace, nil)
+		webhookService = services.NewWebhookService(redisService, &services.WebhookConfiguration{
+			Workers:      1,
+			MaxAttempts:  3,
+			RetryBackoff: retryBackoff,
+			Timeout:      time.Second,
+		})
+		scoreService.AddListener(webhookService)
+		webhookService.Start()
+
+		for _, guid := range []string{"a", "b", "c"} {
+			_, err := userService.Create(ctx, &api.UserProfile{
+				UserId:      guid,
+				DisplayName: guid,
+				Country:     "XX",
+			})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	JustAfterEach(func() {
+		webhookService.Stop()
+		receiver.Close()
+		mRedis.FlushAll()
+	})
+
+	register := func(webhook *api.Webhook) string {
+		webhook.Url = receiver.URL
+		webhook.Secret = webhookSecret
+
+		id, err := webhookService.Create(ctx, webhook)
+		Expect(err).To(BeNil())
+
+		return id
+	}
+
+	deadLetters := func() []*api.WebhookDelivery {
+		deadLetters, err := webhookService.GetDeadLetters(ctx, 10)
+		Expect(err).To(BeNil())
+		return deadLetters
+	}
+
+	submit := func(guid string, score float64) {
+		_, err := scoreService.Submit(ctx, &api.ScoreSubmission{
+			UserId:    guid,
+			Score:     score,
+			Timestamp: time.Now().Unix(),
+		})
+		Expect(err).To(BeNil())
+	}
+
+	Context("WebhookService.OnScoreEvents()", func() {
+		When("a user enters the top of a board", func() {
+			It("delivers a signed payload", func() {
+				id := register(&api.Webhook{Trigger: services.WebhookTriggerTopN, Board: "global", TopN: 1})
+
+				submit("a", 10)
+
+				var payload *api.WebhookPayload
+				Eventually(received).Should(Receive(&payload))
+				Expect(payload.WebhookId).To(BeEquivalentTo(id))
+				Expect(payload.UserId).To(BeEquivalentTo("a"))
+				Expect(payload.Rank).To(BeEquivalentTo(1))
+
+				submit("a", 20)
+				Consistently(received, 100*time.Millisecond).ShouldNot(Receive())
+
+				Eventually(func() []*api.WebhookDelivery {
+					deliveries, _ := webhookService.GetDeliveries(ctx, id, 10)
+					return deliveries
+				}).Should(HaveLen(1))
+
+				deliveries, err := webhookService.GetDeliveries(ctx, id, 10)
+				Expect(err).To(BeNil())
+				Expect(deliveries[0].Success).To(BeTrue())
+			})
+		})
+
+		When("a friend overtakes the user", func() {
+			It("delivers the overtaken user", func() {
+				submit("a", 10)
+				submit("b", 5)
+				register(&api.Webhook{Trigger: services.WebhookTriggerOvertaken, Board: "XX", UserId: "a", Friends: []string{"b"}})
+
+				submit("c", 20)
+				Consistently(received, 100*time.Millisecond).ShouldNot(Receive())
+
+				submit("b", 15)
+
+				var payload *api.WebhookPayload
+				Eventually(received).Should(Receive(&payload))
+				Expect(payload.UserId).To(BeEquivalentTo("b"))
+				Expect(payload.OvertakenId).To(BeEquivalentTo("a"))
+				Expect(payload.Board).To(BeEquivalentTo("XX"))
+			})
+		})
+
+		When("a new user takes the lead", func() {
+			It("delivers the new leader", func() {
+				register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+
+				submit("a", 10)
+				Eventually(received).Should(Receive())
+
+				submit("a", 11)
+				Consistently(received, 100*time.Millisecond).ShouldNot(Receive())
+
+				submit("b", 12)
+
+				var payload *api.WebhookPayload
+				Eventually(received).Should(Receive(&payload))
+				Expect(payload.UserId).To(BeEquivalentTo("b"))
+			})
+		})
+
+		When("the receiver keeps failing", func() {
+			It("retries and moves the delivery to the dead-letter list", func() {
+				atomic.StoreInt32(&status, http.StatusInternalServerError)
+				id := register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+
+				submit("a", 10)
+
+				Eventually(func() int {
+					deadLetters, _ := webhookService.GetDeadLetters(ctx, 10)
+					return len(deadLetters)
+				}).Should(Equal(1))
+				Expect(atomic.LoadInt32(&attempts)).To(BeEquivalentTo(3))
+
+				deliveries, err := webhookService.GetDeliveries(ctx, id, 10)
+				Expect(err).To(BeNil())
+				Expect(deliveries).To(HaveLen(3))
+				Expect(deliveries[0].Attempt).To(BeEquivalentTo(3))
+				Expect(deliveries[0].StatusCode).To(BeEquivalentTo(http.StatusInternalServerError))
+			})
+		})
+
+		When("the service stops while deliveries wait for a retry", func() {
+			BeforeEach(func() {
+				retryBackoff = time.Hour
+			})
+
+			It("moves them to the dead-letter list", func() {
+				atomic.StoreInt32(&status, http.StatusInternalServerError)
+				id := register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+
+				submit("a", 10)
+				Eventually(func() []*api.WebhookDelivery {
+					deliveries, _ := webhookService.GetDeliveries(ctx, id, 10)
+					return deliveries
+				}).Should(HaveLen(1))
+
+				webhookService.Stop()
+
+				Expect(deadLetters()).To(HaveLen(1))
+				Expect(deadLetters()[0].Attempt).To(BeEquivalentTo(1))
+				Expect(deadLetters()[0].StatusCode).To(BeEquivalentTo(http.StatusInternalServerError))
+				Expect(atomic.LoadInt32(&attempts)).To(BeEquivalentTo(1))
+			})
+		})
+
+		When("the service stops while deliveries are queued", func() {
+			BeforeEach(func() {
+				hold = make(chan struct{})
+			})
+
+			It("moves them to the dead-letter list", func() {
+				atomic.StoreInt32(&status, http.StatusInternalServerError)
+				register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+				register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+
+				// the only worker waits on the first delivery, the second is queued
+				submit("a", 10)
+				Eventually(func() int32 { return atomic.LoadInt32(&attempts) }).Should(BeEquivalentTo(1))
+
+				stopped := make(chan struct{})
+				go func() {
+					defer close(stopped)
+					webhookService.Stop()
+				}()
+				// Stop waits for the delivery in flight
+				Consistently(stopped, 50*time.Millisecond).ShouldNot(BeClosed())
+				close(hold)
+				Eventually(stopped).Should(BeClosed())
+
+				Expect(deadLetters()).To(HaveLen(2))
+				Expect(deadLetters()).To(ContainElement(WithTransform(func(delivery *api.WebhookDelivery) string {
+					return delivery.Error
+				}, Equal("the service stopped before the delivery"))))
+				Expect(atomic.LoadInt32(&attempts)).To(BeEquivalentTo(1))
+			})
+		})
+	})
+
+	Context("WebhookService.Delete()", func() {
+		It("deletes the delivery log of the webhook", func() {
+			id := register(&api.Webhook{Trigger: services.WebhookTriggerNewLeader})
+
+			submit("a", 10)
+			Eventually(func() []*api.WebhookDelivery {
+				deliveries, _ := webhookService.GetDeliveries(ctx, id, 10)
+				return deliveries
+			}).Should(HaveLen(1))
+
+			Expect(webhookService.Delete(ctx, id)).To(Succeed())
+
+			deliveries, err := webhookService.GetDeliveries(ctx, id, 10)
+			Expect(err).To(BeNil())
+			Expect(deliveries).To(BeEmpty())
+		})
+	})
+})
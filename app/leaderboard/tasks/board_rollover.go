@@ -0,0 +1,76 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"sort"
+)
+
+const JobBoardRollover = "board-rollover"
+
+// BoardRolloverJob archives boards under the time of the run and starts them
+// over empty, e.g. for weekly boards, then deletes the oldest archives of
+// the boards beyond the ones kept. A resumed run archives the boards which
+// still have members.
+type BoardRolloverJob struct {
+	boardService *services.BoardService
+}
+
+func NewBoardRolloverJob(boardService *services.BoardService) *BoardRolloverJob {
+	return &BoardRolloverJob{boardService: boardService}
+}
+
+func (b *BoardRolloverJob) Name() string {
+	return JobBoardRollover
+}
+
+func (b *BoardRolloverJob) Singleton() bool {
+	return true
+}
+
+func (b *BoardRolloverJob) Parameters() interface{} {
+	return new(api.BoardRolloverParameters)
+}
+
+func (b *BoardRolloverJob) Run(ctx context.Context, run *services.RunningJob) error {
+	parameters := run.Parameters().(*api.BoardRolloverParameters)
+
+	names := parameters.Boards
+	if len(names) == 0 {
+		boards, err := b.boardService.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, board := range boards {
+			names = append(names, board.Name)
+		}
+	}
+	sort.Strings(names)
+	run.SetTotal(run.Done() + int64(len(names)))
+
+	for _, name := range names {
+		if err := run.Wait(ctx); err != nil {
+			return err
+		}
+
+		// a board without members has nothing to roll over, or was
+		// archived before the run was resumed
+		_, err := b.boardService.Archive(ctx, name, "")
+		if err != nil && !errors.Is(err, api.ErrNotFound) {
+			return err
+		}
+
+		if parameters.Keep > 0 {
+			if _, err := b.boardService.Prune(ctx, name, int(parameters.Keep)); err != nil {
+				return err
+			}
+		}
+
+		run.Progress(1)
+	}
+
+	return nil
+}
@@ -1,232 +1,209 @@
 package tasks
 
 import (
-	"fmt"
-	"github.com/labstack/gommon/log"
+	"context"
 	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
 	"leaderboard/app/leaderboard/services"
-	"math/rand"
-	"strconv"
 	"sync"
 	"time"
 )
 
-const KeyTask = "TASK_GU"
-const FieldStatus = "STATUS"
-const FieldConcurrency = "CONCURRENCY"
-const FieldStartedAt = "STARTED_AT"
-const FieldUserCount = "USER_COUNT"
+const JobGenerateUsers = "generate-users"
 
-type GenerateUsersSingletonTask struct {
+// GenerateUsersJob creates users drawn by a UserGenerator, for load and UX
+// testing, and then streams score submissions of them when asked to. A
+// resumed run creates what is left of its users and carries on with its
+// stream. Progress counts the users and the submissions done below the
+// first one which is not, so a run resumes from every one which may be
+// missing; the few done past it are created, or submitted, again.
+type GenerateUsersJob struct {
 	userService  *services.UserService
-	redisService api.RedisService
-	stateMux     sync.Mutex
+	scoreService *services.ScoreService
 }
 
-func NewGenerateUsersSingletonTask(userService *services.UserService, redisService api.RedisService) *GenerateUsersSingletonTask {
-	return &GenerateUsersSingletonTask{userService: userService, redisService: redisService}
+func NewGenerateUsersJob(userService *services.UserService, scoreService *services.ScoreService) *GenerateUsersJob {
+	return &GenerateUsersJob{userService: userService, scoreService: scoreService}
 }
 
-func (g *GenerateUsersSingletonTask) Initialize() {
-	g.updateStatus(&api.GenerateUserTaskStatus{
-		Status: "IDLE",
-	}, true)
+func (g *GenerateUsersJob) Name() string {
+	return JobGenerateUsers
 }
 
-func (g *GenerateUsersSingletonTask) Start(nUsers uint64, maxConcurrency uint64) (*api.GenerateUserTaskStatus, error) {
-	if g.getStatusStr(true) == "RUNNING" {
-		return g.Status()
-	}
-
-	initResult := make(chan bool, 1)
-	go g.generate(nUsers, maxConcurrency, initResult)
-
-	result := <-initResult
-	if !result {
-		return nil, fmt.Errorf("task initialization failed")
-	}
-
-	return g.Status()
-}
-
-func (g *GenerateUsersSingletonTask) Stop() error {
-	g.updateStatus(&api.GenerateUserTaskStatus{
-		Status: "CANCELLED",
-	}, true)
-
-	return nil
+func (g *GenerateUsersJob) Singleton() bool {
+	return true
 }
 
-func (g *GenerateUsersSingletonTask) Status() (*api.GenerateUserTaskStatus, error) {
-	return g.status(true)
+func (g *GenerateUsersJob) Parameters() interface{} {
+	return new(api.GenerateUsersParameters)
 }
 
-func (g *GenerateUsersSingletonTask) status(withMux bool) (*api.GenerateUserTaskStatus, error) {
-	if withMux {
-		g.stateMux.Lock()
-		defer g.stateMux.Unlock()
+func (g *GenerateUsersJob) Run(ctx context.Context, run *services.RunningJob) error {
+	parameters := run.Parameters().(*api.GenerateUsersParameters)
+	seed := parameters.Seed
+	if seed == 0 {
+		seed = SeedOf(run.Id())
 	}
+	generator := NewUserGenerator(parameters, seed)
 
-	exists, err := g.redisService.Exists(KeyTask)
-	if err != nil {
-		return nil, err
-	}
-	if !exists {
-		return &api.GenerateUserTaskStatus{
-			Status: "IDLE",
-		}, nil
+	users := int64(parameters.Users)
+	var submissions int64
+	if parameters.Stream != nil {
+		submissions = int64(parameters.Stream.Rate * float64(parameters.Stream.Seconds))
 	}
-
-	statusMap, err := g.redisService.HGetAll(KeyTask).Result()
-	if err != nil {
-		return nil, err
+	// an endless stream has no total
+	if parameters.Stream == nil || submissions > 0 {
+		run.SetTotal(users + submissions)
 	}
 
-	var concurrency uint64 = 0
-	if statusMap[FieldConcurrency] != "" {
-		concurrency, err = strconv.ParseUint(statusMap[FieldConcurrency], 10, 64)
-	}
-	if err != nil {
-		return nil, err
+	done := newWatermark(run.Done())
+	err := parallel(ctx, parameters.Concurrency, func(ctx context.Context) error {
+		return g.generate(ctx, run, generator, users, done)
+	})
+	if err != nil || parameters.Stream == nil {
+		return err
 	}
 
-	var userCount = ^uint64(0)
-	if statusMap[FieldUserCount] != "" {
-		userCount, err = strconv.ParseUint(statusMap[FieldUserCount], 10, 64)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	return &api.GenerateUserTaskStatus{
-		Status:         statusMap[FieldStatus],
-		Concurrency:    concurrency,
-		StartedAt:      statusMap[FieldStartedAt],
-		RemainingUsers: userCount,
-	}, nil
+	return g.stream(ctx, run, generator, parameters, submissions)
 }
 
-func (g *GenerateUsersSingletonTask) generate(n uint64, maxConcurrency uint64, redisInit chan bool) {
-	status, err := g.status(true)
-	if err != nil {
-		g.handleError(err, true)
-		redisInit <- false
-		return
-	}
-	status.RemainingUsers = n
-	status.Status = "RUNNING"
-	status.StartedAt = time.Now().String()
-	status.Concurrency = maxConcurrency
-	g.updateStatus(status, true)
+func (g *GenerateUsersJob) generate(ctx context.Context, run *services.RunningJob, generator *UserGenerator, users int64, done *watermark) error {
+	for {
+		if err := run.Wait(ctx); err != nil {
+			return err
+		}
 
-	countries := []string{"TR", "US", "GB", "CN", "JP", "AU", "NZ"}
+		i := done.take()
+		if i >= users {
+			return nil
+		}
 
-	redisInit <- true
+		if _, err := g.userService.Create(ctx, generator.User(i)); err != nil {
+			return err
+		}
 
-	var cpu uint64
-	for cpu = 0; cpu < maxConcurrency; cpu++ {
-		go func() {
-			statusStr := g.getStatusStr(true)
-			if statusStr == "CANCELLED" || statusStr == "DONE" || statusStr == "ERROR" {
-				return
-			}
-
-			for g.getUserCount(true) > 0 {
-				_, err := g.userService.Create(&api.UserProfile{
-					DisplayName: fmt.Sprintf("user_%d", time.Now().UnixNano()),
-					Points:      rand.Float64() * 100_000,
-					Country:     countries[rand.Intn(len(countries))],
-				})
+		metrics.UsersGenerated.WithLabelValues().Inc()
+		run.Progress(done.complete(i))
+	}
+}
 
-				if err != nil {
-					g.handleError(err, true)
-					return
+// stream submits scores at the rate of the stream until it submitted all of
+// them, or until the run is stopped when there is no end to them.
+func (g *GenerateUsersJob) stream(ctx context.Context, run *services.RunningJob, generator *UserGenerator, parameters *api.GenerateUsersParameters, submissions int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// submissions which are not taken in time are dropped, so the stream
+	// does not burst after a pause or a slow write
+	tokens := make(chan struct{}, parameters.Concurrency)
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / parameters.Stream.Rate))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case tokens <- struct{}{}:
+				default:
 				}
+			}
+		}
+	}()
+
+	done := newWatermark(run.Done() - int64(parameters.Users))
+	return parallel(ctx, parameters.Concurrency, func(ctx context.Context) error {
+		for {
+			if err := run.Wait(ctx); err != nil {
+				return err
+			}
 
-				g.decrementToGenerate()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tokens:
 			}
-		}()
-	}
-}
 
-func (g *GenerateUsersSingletonTask) handleError(err error, withMux bool) {
-	if withMux {
-		g.stateMux.Lock()
-		defer g.stateMux.Unlock()
-	}
+			k := done.take()
+			if submissions > 0 && k >= submissions {
+				return nil
+			}
 
-	g.updateStatus(&api.GenerateUserTaskStatus{
-		Status:    "ERROR",
-		StartedAt: time.Now().String(),
-	}, !withMux)
+			if _, err := g.scoreService.Submit(ctx, generator.Submission(k, time.Now())); err != nil {
+				return err
+			}
 
-	log.Error(err)
+			run.Progress(done.complete(k))
+		}
+	})
 }
 
-func (g *GenerateUsersSingletonTask) updateStatus(status *api.GenerateUserTaskStatus, withMux bool) {
-	if withMux {
-		g.stateMux.Lock()
-		defer g.stateMux.Unlock()
-	}
-
-	g.redisService.HSet(
-		KeyTask,
-		FieldConcurrency,
-		strconv.FormatUint(status.Concurrency, 10),
-		FieldUserCount,
-		strconv.FormatUint(status.RemainingUsers, 10),
-		FieldStartedAt,
-		status.StartedAt,
-		FieldStatus,
-		status.Status,
-	)
+// watermark hands out indices in order and tracks the first one which is
+// not done, indices are done in any order by concurrent workers.
+type watermark struct {
+	mux  sync.Mutex
+	next int64
+	mark int64
+	done map[int64]bool
 }
 
-func (g *GenerateUsersSingletonTask) getUserCount(withMux bool) uint64 {
-	if withMux {
-		g.stateMux.Lock()
-		defer g.stateMux.Unlock()
-	}
+func newWatermark(start int64) *watermark {
+	return &watermark{next: start, mark: start, done: map[int64]bool{}}
+}
 
-	status, err := g.status(!withMux)
-	if err != nil {
-		g.handleError(err, !withMux)
-		return 0
-	}
+// take returns the next index to work on.
+func (w *watermark) take() int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
 
-	return status.RemainingUsers
+	w.next++
+	return w.next - 1
 }
 
-func (g *GenerateUsersSingletonTask) getStatusStr(withMux bool) string {
-	if withMux {
-		g.stateMux.Lock()
-		defer g.stateMux.Unlock()
-	}
+// complete marks an index done and returns how far the first index which
+// is not done moved.
+func (w *watermark) complete(i int64) int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
 
-	status, err := g.status(!withMux)
-	if err != nil {
-		g.handleError(err, !withMux)
-		return ""
+	w.done[i] = true
+	start := w.mark
+	for w.done[w.mark] {
+		delete(w.done, w.mark)
+		w.mark++
 	}
 
-	return status.Status
+	return w.mark - start
 }
 
-func (g *GenerateUsersSingletonTask) decrementToGenerate() {
-	g.stateMux.Lock()
-
-	status, err := g.status(false)
-	if err != nil {
-		g.stateMux.Unlock()
-		g.handleError(err, true)
-		return
-	}
-	defer g.stateMux.Unlock()
+// parallel runs work on as many goroutines and answers the first error.
+// The context of the others is cancelled on the first error, so they do not
+// carry on past a failed one.
+func parallel(ctx context.Context, concurrency uint64, work func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		workers  sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := uint64(0); i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
 
-	status.RemainingUsers--
-	if status.RemainingUsers == 0 {
-		status.Status = "DONE"
+			if err := work(ctx); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
 	}
+	workers.Wait()
 
-	g.updateStatus(status, false)
+	return firstErr
 }
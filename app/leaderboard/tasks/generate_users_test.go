@@ -0,0 +1,177 @@
+package tasks_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tasks"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+// holdCommand holds the commands on a key until their context is done, as
+// if redis did not answer them.
+type holdCommand struct {
+	key string
+}
+
+func (h holdCommand) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if args := cmd.Args(); len(args) > 1 && args[1] == h.key {
+		<-ctx.Done()
+		return ctx, ctx.Err()
+	}
+
+	return ctx, nil
+}
+
+func (holdCommand) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (holdCommand) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (holdCommand) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+// failOnce fails the first script run on a key with the prefix, as if
+// redis failed it.
+type failOnce struct {
+	prefix string
+	failed *int32
+}
+
+func (f failOnce) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	args := cmd.Args()
+	if cmd.Name() == "evalsha" && len(args) > 3 && strings.HasPrefix(fmt.Sprint(args[3]), f.prefix) && atomic.CompareAndSwapInt32(f.failed, 0, 1) {
+		return ctx, errors.New("ERR failed once")
+	}
+
+	return ctx, nil
+}
+
+func (failOnce) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (failOnce) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (failOnce) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+var _ = Describe("the generate users job", func() {
+	const (
+		namespace = "generate"
+		users     = 3000
+	)
+
+	var (
+		ctx          = context.Background()
+		mRedis       *miniredis.Miniredis
+		redisService *services.RedisService
+		parameters   = &api.GenerateUsersParameters{Users: users, Concurrency: 8, Seed: 1}
+	)
+
+	newJobService := func(instance string, hooks ...redis.Hook) *services.JobService {
+		client := redis.NewClient(&redis.Options{Addr: mRedis.Addr()})
+		for _, hook := range hooks {
+			client.AddHook(hook)
+		}
+		redisService := services.NewRedisService(client, namespace)
+
+		userService := services.NewUserService(redisService, "LB_")
+		scoreService := services.NewScoreService(userService, redisService, services.ScoreModeReplace,
+			services.NewRankHistoryService(redisService, time.Minute, time.Hour))
+
+		jobService := services.NewJobService(redisService, services.NewKeySchema(namespace), &services.JobConfiguration{
+			Instance:     instance,
+			Workers:      1,
+			LeaseTTL:     300 * time.Millisecond,
+			PollInterval: 10 * time.Millisecond,
+		})
+		jobService.Register(tasks.NewGenerateUsersJob(userService, scoreService))
+
+		return jobService
+	}
+
+	generated := func() int {
+		// the board is missing until the first user is created
+		members, _ := mRedis.ZMembers(redisService.Keys().Board("GLOBAL"))
+		return len(members)
+	}
+
+	BeforeEach(func() {
+		var err error
+		mRedis, err = miniredis.Run()
+		Expect(err).To(BeNil())
+
+		redisService = services.NewRedisService(redis.NewClient(&redis.Options{Addr: mRedis.Addr()}), namespace)
+	})
+
+	AfterEach(func() {
+		mRedis.Close()
+	})
+
+	It("creates every user of a run resumed by another instance", func() {
+		// the first user is in flight until the first instance stops, while
+		// the others are created
+		firstUser := tasks.NewUserGenerator(parameters, parameters.Seed).User(0)
+		first := newJobService("first", holdCommand{key: redisService.Keys().Profile(firstUser.UserId)})
+		first.Start()
+
+		run, err := first.StartRun(ctx, tasks.JobGenerateUsers, parameters)
+		Expect(err).To(BeNil())
+
+		Eventually(generated, 10*time.Second, time.Millisecond).Should(BeNumerically(">=", users/10))
+		first.Stop()
+
+		second := newJobService("second")
+		second.Start()
+		defer second.Stop()
+
+		Eventually(func() string {
+			run, err := second.GetRun(ctx, tasks.JobGenerateUsers, run.Id)
+			Expect(err).To(BeNil())
+			return run.State
+		}, 10*time.Second).Should(Equal(services.JobStateDone))
+
+		run, err = second.GetRun(ctx, tasks.JobGenerateUsers, run.Id)
+		Expect(err).To(BeNil())
+		Expect(run.Done).To(BeEquivalentTo(users))
+		Expect(generated()).To(Equal(users))
+	})
+
+	It("ends an endless stream with the error of a submission", func() {
+		// the score script is run on the profile of the user
+		jobService := newJobService("first", failOnce{prefix: redisService.Keys().ProfilePrefix(), failed: new(int32)})
+		jobService.Start()
+		defer jobService.Stop()
+
+		run, err := jobService.StartRun(ctx, tasks.JobGenerateUsers, &api.GenerateUsersParameters{
+			Users:       10,
+			Concurrency: 4,
+			Seed:        1,
+			Stream:      &api.ScoreStreamParameters{Rate: 1000},
+		})
+		Expect(err).To(BeNil())
+
+		Eventually(func() string {
+			run, err = jobService.GetRun(ctx, tasks.JobGenerateUsers, run.Id)
+			Expect(err).To(BeNil())
+			return run.State
+		}, 5*time.Second).Should(Equal(services.JobStateFailed))
+		Expect(run.Error).To(ContainSubstring("failed once"))
+	})
+})
@@ -0,0 +1,174 @@
+package tasks
+
+import (
+	"github.com/google/uuid"
+	"hash/fnv"
+	"leaderboard/app/api"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var generatedCountries = []string{"TR", "US", "GB", "CN", "JP", "AU", "NZ"}
+
+var defaultScores = &api.ScoreDistribution{Kind: "uniform", Min: 0, Max: 100_000}
+
+// draws of the users and of the submissions do not overlap
+const (
+	userDraws uint64 = iota
+	submissionDraws
+)
+
+// UserGenerator draws the users and the score submissions of a run of the
+// generate-users job. Every user and every submission is drawn from a
+// generator of its own, seeded by the seed of the run and its index, so it
+// is the same whichever worker draws it and whenever, and a resumed run
+// overwrites the users it created before with the same ones.
+type UserGenerator struct {
+	seed      uint64
+	users     int64
+	countries []string
+	// cumulative weights of the countries
+	weights []float64
+	scores  *api.ScoreDistribution
+}
+
+func NewUserGenerator(parameters *api.GenerateUsersParameters, seed int64) *UserGenerator {
+	mixed := splitMix(seed)
+	generator := &UserGenerator{
+		seed:   mixed.Uint64(),
+		users:  int64(parameters.Users),
+		scores: parameters.Scores,
+	}
+
+	if generator.scores == nil {
+		generator.scores = defaultScores
+	}
+
+	countries := parameters.Countries
+	if len(countries) == 0 {
+		countries = map[string]float64{}
+		for _, country := range generatedCountries {
+			countries[country] = 1
+		}
+	}
+
+	// sorted, so the same seed picks the same countries
+	for country := range countries {
+		generator.countries = append(generator.countries, country)
+	}
+	sort.Strings(generator.countries)
+
+	total := 0.0
+	for _, country := range generator.countries {
+		total += countries[country]
+		generator.weights = append(generator.weights, total)
+	}
+
+	return generator
+}
+
+// SeedOf hashes the id of a run into a seed, for runs without one.
+func SeedOf(id string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(id))
+	return int64(hash.Sum64())
+}
+
+// User draws the i-th user.
+func (g *UserGenerator) User(i int64) *api.UserProfile {
+	rng := g.rand(userDraws, i)
+	// the id is drawn first, so userId draws it alone
+	id := uuid.Must(uuid.NewRandomFromReader(rng))
+
+	return &api.UserProfile{
+		UserId:      id.String(),
+		DisplayName: displayName(rng),
+		Points:      g.score(rng),
+		Country:     g.country(rng),
+	}
+}
+
+// Submission draws the k-th submission, for one of the users.
+func (g *UserGenerator) Submission(k int64, at time.Time) *api.ScoreSubmission {
+	rng := g.rand(submissionDraws, k)
+
+	return &api.ScoreSubmission{
+		UserId:    g.userId(rng.Int63n(g.users)),
+		Score:     g.score(rng),
+		Timestamp: at.Unix(),
+	}
+}
+
+func (g *UserGenerator) userId(i int64) string {
+	return uuid.Must(uuid.NewRandomFromReader(g.rand(userDraws, i))).String()
+}
+
+func (g *UserGenerator) rand(draws uint64, i int64) *rand.Rand {
+	source := splitMix(g.seed ^ (draws<<62 | uint64(i)))
+	return rand.New(&source)
+}
+
+func (g *UserGenerator) country(rng *rand.Rand) string {
+	drawn := rng.Float64() * g.weights[len(g.weights)-1]
+	i := sort.SearchFloat64s(g.weights, drawn)
+	if i == len(g.countries) {
+		i--
+	}
+
+	return g.countries[i]
+}
+
+func (g *UserGenerator) score(rng *rand.Rand) float64 {
+	d := g.scores
+
+	var score float64
+	switch d.Kind {
+	case "normal":
+		score = d.Mean + rng.NormFloat64()*d.StdDev
+	case "bimodal":
+		weight := d.Weight
+		if weight == 0 {
+			weight = 0.5
+		}
+
+		if rng.Float64() < weight {
+			score = d.Mean + rng.NormFloat64()*d.StdDev
+		} else {
+			score = d.SecondMean + rng.NormFloat64()*d.SecondStdDev
+		}
+	case "pareto":
+		// inverse of the distribution function, 1 - U is never 0
+		score = d.Scale / math.Pow(1-rng.Float64(), 1/d.Shape)
+	default:
+		score = d.Min + rng.Float64()*(d.Max-d.Min)
+	}
+
+	score = math.Max(math.Round(score), d.Min)
+	if d.Max > 0 {
+		score = math.Min(score, d.Max)
+	}
+
+	return score
+}
+
+// splitMix is the splitmix64 generator, which is cheap to seed for every
+// user and submission.
+type splitMix uint64
+
+func (s *splitMix) Uint64() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) Seed(seed int64) {
+	*s = splitMix(seed)
+}
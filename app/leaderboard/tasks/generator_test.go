@@ -0,0 +1,101 @@
+package tasks_test
+
+import (
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/tasks"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+func TestTasks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "tasks")
+}
+
+var _ = Describe("the user generator", func() {
+	const draws = 5000
+
+	points := func(scores *api.ScoreDistribution) []float64 {
+		generator := tasks.NewUserGenerator(&api.GenerateUsersParameters{Users: draws, Scores: scores}, 1)
+
+		var points []float64
+		for i := int64(0); i < draws; i++ {
+			points = append(points, generator.User(i).Points)
+		}
+
+		return points
+	}
+
+	mean := func(values []float64) float64 {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+
+		return sum / float64(len(values))
+	}
+
+	It("draws the same users and submissions from the same seed", func() {
+		parameters := &api.GenerateUsersParameters{Users: 10}
+		first := tasks.NewUserGenerator(parameters, 42)
+		second := tasks.NewUserGenerator(parameters, 42)
+		other := tasks.NewUserGenerator(parameters, 43)
+
+		Expect(first.User(3)).To(Equal(second.User(3)))
+		Expect(first.User(3)).NotTo(Equal(first.User(4)))
+		Expect(first.User(3).UserId).NotTo(Equal(other.User(3).UserId))
+
+		at := time.Now()
+		submission := first.Submission(7, at)
+		Expect(submission).To(Equal(second.Submission(7, at)))
+
+		var ids []string
+		for i := int64(0); i < 10; i++ {
+			ids = append(ids, first.User(i).UserId)
+		}
+		Expect(ids).To(ContainElement(submission.UserId))
+	})
+
+	It("weighs the countries", func() {
+		generator := tasks.NewUserGenerator(&api.GenerateUsersParameters{
+			Users:     draws,
+			Countries: map[string]float64{"TR": 3, "US": 1},
+		}, 1)
+
+		counts := map[string]int{}
+		for i := int64(0); i < draws; i++ {
+			counts[generator.User(i).Country]++
+		}
+
+		Expect(counts).To(HaveLen(2))
+		Expect(float64(counts["TR"]) / draws).To(BeNumerically("~", 0.75, 0.03))
+	})
+
+	It("draws points from the distribution", func() {
+		normal := points(&api.ScoreDistribution{Kind: "normal", Mean: 1000, StdDev: 100})
+		Expect(mean(normal)).To(BeNumerically("~", 1000, 10))
+
+		bimodal := points(&api.ScoreDistribution{Kind: "bimodal", Mean: 100, StdDev: 10, SecondMean: 900, SecondStdDev: 10, Weight: 0.25})
+		Expect(mean(bimodal)).To(BeNumerically("~", 700, 15))
+
+		pareto := points(&api.ScoreDistribution{Kind: "pareto", Scale: 100, Shape: 3})
+		for _, value := range pareto {
+			Expect(value).To(BeNumerically(">=", 100))
+		}
+		// the mean is Scale * Shape / (Shape - 1)
+		Expect(mean(pareto)).To(BeNumerically("~", 150, 10))
+
+		uniform := points(nil)
+		Expect(mean(uniform)).To(BeNumerically("~", 50_000, 2_000))
+	})
+
+	It("rounds and clamps the points", func() {
+		for _, value := range points(&api.ScoreDistribution{Kind: "normal", Min: 900, Max: 1100, Mean: 1000, StdDev: 500}) {
+			Expect(value).To(BeNumerically(">=", 900))
+			Expect(value).To(BeNumerically("<=", 1100))
+			Expect(value).To(Equal(float64(int64(value))))
+		}
+	})
+})
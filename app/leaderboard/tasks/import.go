@@ -0,0 +1,220 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"io"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/metrics"
+	"leaderboard/app/leaderboard/services"
+	"strconv"
+	"strings"
+)
+
+const (
+	JobImport = "import"
+
+	defaultImportBatchSize = 500
+)
+
+// importNamespace derives the ids of the imported users who are given none,
+// from the run and the row, so a resumed run gives them the same ids.
+var importNamespace = uuid.MustParse("8f1d6a2c-4f53-4d8e-9a57-2f0f2b5c7e31")
+
+// ImportJob imports users along with their points from a CSV or NDJSON file
+// of the import directory, users who exist are overwritten. The file is
+// counted first for the total of the run, then its rows are validated and
+// written in batches while the next batch is read. Every batch is written
+// at once with the progress of the run, so a resumed run skips the rows
+// written before and imports every row once.
+type ImportJob struct {
+	redisService    api.RedisService
+	importService   *services.ImportService
+	structValidator *services.StructValidator
+}
+
+func NewImportJob(redisService api.RedisService, importService *services.ImportService, structValidator *services.StructValidator) *ImportJob {
+	return &ImportJob{redisService: redisService, importService: importService, structValidator: structValidator}
+}
+
+func (i *ImportJob) Name() string {
+	return JobImport
+}
+
+func (i *ImportJob) Singleton() bool {
+	return false
+}
+
+func (i *ImportJob) Parameters() interface{} {
+	return new(api.ImportParameters)
+}
+
+func (i *ImportJob) Run(ctx context.Context, run *services.RunningJob) error {
+	parameters := run.Parameters().(*api.ImportParameters)
+	batchSize := int(parameters.BatchSize)
+	if batchSize == 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	rows, err := i.count(parameters)
+	if err != nil {
+		return err
+	}
+	run.SetTotal(rows)
+
+	// the run record may lag behind the batches written
+	progress, err := i.redisService.GetImport(ctx, run.Id())
+	if err != nil {
+		return err
+	}
+	run.Progress(progress.Rows - run.Done())
+
+	file, err := i.importService.Open(parameters.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := newRowReader(file, parameters.Path, parameters.Format)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan *api.ImportBatch, 1)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(batches)
+		readErr <- i.read(ctx, run, reader, progress.Rows, batchSize, batches)
+	}()
+
+	for batch := range batches {
+		if err := run.Wait(ctx); err != nil {
+			return err
+		}
+
+		if err := i.redisService.WriteImportBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		metrics.RowsImported.WithLabelValues("imported").Add(float64(len(batch.Profiles)))
+		metrics.RowsImported.WithLabelValues("rejected").Add(float64(len(batch.Rejections)))
+		run.Progress(int64(len(batch.Profiles) + len(batch.Rejections)))
+	}
+
+	if err := <-readErr; err != nil {
+		return err
+	}
+
+	if parameters.Remove {
+		return i.importService.Remove(parameters.Path)
+	}
+
+	return nil
+}
+
+// count reads the file through for its rows.
+func (i *ImportJob) count(parameters *api.ImportParameters) (int64, error) {
+	file, err := i.importService.Open(parameters.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := newRowReader(file, parameters.Path, parameters.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		var rowErr *rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return 0, err
+		}
+		rows++
+	}
+}
+
+// read hands on the rows after the first skipped ones in batches, the rows
+// which fail their rules as rejections.
+func (i *ImportJob) read(ctx context.Context, run *services.RunningJob, reader rowReader, skipped int64, batchSize int, batches chan<- *api.ImportBatch) error {
+	var batch *api.ImportBatch
+	send := func() error {
+		select {
+		case batches <- batch:
+			batch = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for rows := int64(1); ; rows++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return err
+		}
+
+		if rows <= skipped {
+			continue
+		}
+
+		if batch == nil {
+			batch = &api.ImportBatch{RunId: run.Id(), Fence: run.Fence()}
+		}
+		batch.Rows = rows
+
+		if rowErr != nil {
+			batch.Rejections = append(batch.Rejections, &api.ImportRejection{Row: rows, UserId: rowErr.userId, Error: rowErr.message})
+		} else if profile, err := i.profile(run, rows, row); err != nil {
+			batch.Rejections = append(batch.Rejections, &api.ImportRejection{Row: rows, UserId: row.UserId, Error: err.Error()})
+		} else {
+			batch.Profiles = append(batch.Profiles, profile)
+		}
+
+		if len(batch.Profiles)+len(batch.Rejections) == batchSize {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if batch == nil {
+		return nil
+	}
+
+	return send()
+}
+
+// profile validates a row into the profile it imports.
+func (i *ImportJob) profile(run *services.RunningJob, rows int64, row *api.ImportRow) (*api.UserProfile, error) {
+	row.Country = strings.ToUpper(row.Country)
+	if err := i.structValidator.Validate(row); err != nil {
+		return nil, err
+	}
+
+	if len(row.UserId) == 0 {
+		row.UserId = uuid.NewSHA1(importNamespace, []byte(run.Id()+":"+strconv.FormatInt(rows, 10))).String()
+	}
+
+	return &api.UserProfile{
+		UserId:      row.UserId,
+		DisplayName: row.DisplayName,
+		Country:     row.Country,
+		Points:      row.Points,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"leaderboard/app/api"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// rowError is a row which cannot be parsed, the rows after it are read on.
+type rowError struct {
+	userId  string
+	message string
+}
+
+func (e *rowError) Error() string {
+	return e.message
+}
+
+// rowReader reads the rows of an imported file one at a time. Read returns
+// io.EOF after the last row, and a *rowError for a row which cannot be
+// parsed.
+type rowReader interface {
+	Read() (*api.ImportRow, error)
+}
+
+// newRowReader reads the file in the format, which is told by the extension
+// of the path or else by the first character of the file when it is not
+// given.
+func newRowReader(input io.Reader, path string, format string) (rowReader, error) {
+	reader := bufio.NewReader(input)
+	api.SkipByteOrderMark(reader)
+
+	if len(format) == 0 {
+		format = detectFormat(reader, path)
+	}
+
+	switch format {
+	case "csv":
+		return newCSVRowReader(reader)
+	case "ndjson":
+		return &ndjsonRowReader{reader: reader}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func detectFormat(reader *bufio.Reader, path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+
+	head, _ := reader.Peek(4096)
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) > 0 && head[0] == '{' {
+		return "ndjson"
+	}
+
+	return "csv"
+}
+
+// csvRowReader reads a CSV file whose header names its columns, in any
+// order: user_id, display_name, country and points, score standing in for
+// points. Columns of other names are left out.
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRowReader(input io.Reader) (*csvRowReader, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "score" {
+			name = "points"
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"display_name", "country"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the header names no %s column", name)
+		}
+	}
+
+	return &csvRowReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvRowReader) Read() (*api.ImportRow, error) {
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &rowError{message: parseErr.Err.Error()}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &api.ImportRow{
+		UserId:      field("user_id"),
+		DisplayName: field("display_name"),
+		Country:     field("country"),
+	}
+	if points := field("points"); len(points) > 0 {
+		row.Points, err = strconv.ParseFloat(points, 64)
+		if err != nil || math.IsNaN(row.Points) || math.IsInf(row.Points, 0) {
+			return nil, &rowError{userId: row.UserId, message: "points is not a number"}
+		}
+	}
+
+	return row, nil
+}
+
+// ndjsonRowReader reads a row from every line which is not blank.
+type ndjsonRowReader struct {
+	reader *bufio.Reader
+}
+
+func (r *ndjsonRowReader) Read() (*api.ImportRow, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		row := new(api.ImportRow)
+		if err := json.Unmarshal(line, row); err != nil {
+			return nil, &rowError{message: err.Error()}
+		}
+
+		return row, nil
+	}
+}
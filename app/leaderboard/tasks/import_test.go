@@ -0,0 +1,145 @@
+package tasks_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redis/v8"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tasks"
+	"os"
+	"path/filepath"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the import job", func() {
+	const namespace = "import"
+
+	var (
+		ctx           = context.Background()
+		mRedis        *miniredis.Miniredis
+		redisService  *services.RedisService
+		importService *services.ImportService
+		jobService    *services.JobService
+		directory     string
+	)
+
+	write := func(name string, content string) {
+		Expect(ioutil.WriteFile(filepath.Join(directory, name), []byte(content), 0o600)).To(Succeed())
+	}
+
+	finish := func(run *api.JobRun) *api.ImportReport {
+		Eventually(func() string {
+			run, err := jobService.GetRun(ctx, tasks.JobImport, run.Id)
+			Expect(err).To(BeNil())
+			return run.State
+		}).Should(Equal(services.JobStateDone))
+
+		run, err := jobService.GetRun(ctx, tasks.JobImport, run.Id)
+		Expect(err).To(BeNil())
+
+		report, err := importService.Report(ctx, run)
+		Expect(err).To(BeNil())
+		return report
+	}
+
+	BeforeEach(func() {
+		var err error
+		mRedis, err = miniredis.Run()
+		Expect(err).To(BeNil())
+
+		directory, err = ioutil.TempDir("", "imports")
+		Expect(err).To(BeNil())
+
+		redisService = services.NewRedisService(redis.NewClient(&redis.Options{Addr: mRedis.Addr()}), namespace)
+		importService = services.NewImportService(redisService, directory)
+		jobService = services.NewJobService(redisService, services.NewKeySchema(namespace), &services.JobConfiguration{
+			Instance:     "test",
+			Workers:      1,
+			LeaseTTL:     time.Second,
+			PollInterval: 10 * time.Millisecond,
+		})
+		jobService.Register(tasks.NewImportJob(redisService, importService, services.NewStructValidator(validator.New())))
+	})
+
+	AfterEach(func() {
+		jobService.Stop()
+		mRedis.Close()
+		Expect(os.RemoveAll(directory)).To(Succeed())
+	})
+
+	It("imports the rows of a CSV file and rejects the invalid ones", func() {
+		write("users.csv", "\xef\xbb\xbfcountry,display_name,score,user_id\n"+
+			"tr,Alice,30,alice\n"+
+			"US,Bob,not a number,bob\n"+
+			"USA,Carol,10,carol\n"+
+			"GB,Dave,20,\n")
+		jobService.Start()
+
+		run, err := jobService.StartRun(ctx, tasks.JobImport, &api.ImportParameters{Path: "users.csv", BatchSize: 2})
+		Expect(err).To(BeNil())
+
+		report := finish(run)
+		Expect(report.Run.Done).To(BeEquivalentTo(4))
+		Expect(report.Run.Total).To(BeEquivalentTo(4))
+		Expect(report.Rows).To(BeEquivalentTo(4))
+		Expect(report.Imported).To(BeEquivalentTo(2))
+		Expect(report.Rejected).To(BeEquivalentTo(2))
+		Expect(report.Rejections).To(HaveLen(2))
+		Expect(report.Rejections[0]).To(Equal(&api.ImportRejection{Row: 2, UserId: "bob", Error: "points is not a number"}))
+		Expect(report.Rejections[1].Row).To(BeEquivalentTo(3))
+		Expect(report.Rejections[1].UserId).To(Equal("carol"))
+
+		alice, err := redisService.GetProfile(ctx, "alice")
+		Expect(err).To(BeNil())
+		Expect(alice.Country).To(Equal("TR"))
+		Expect(alice.Points).To(BeEquivalentTo(30))
+
+		size, err := redisService.GetSortedSetSize(ctx, "GB")
+		Expect(err).To(BeNil())
+		Expect(size).To(BeEquivalentTo(1))
+	})
+
+	It("resumes after the rows a previous instance wrote", func() {
+		write("users.ndjson", `{"user_id": "first", "display_name": "First", "country": "TR", "points": 1}
+
+{"user_id": "second", "display_name": "Second", "country": "TR", "points": 2}
+{"user_id": "third", "display_name": "Third", "country": "TR", "points": 3}
+`)
+
+		run, err := jobService.StartRun(ctx, tasks.JobImport, &api.ImportParameters{Path: "users.ndjson", Remove: true})
+		Expect(err).To(BeNil())
+
+		// the first row was written by an instance which is gone
+		Expect(redisService.WriteImportBatch(ctx, &api.ImportBatch{RunId: run.Id, Fence: 1, Rows: 1})).To(Succeed())
+		jobService.Start()
+
+		report := finish(run)
+		Expect(report.Rows).To(BeEquivalentTo(3))
+		Expect(report.Imported).To(BeEquivalentTo(2))
+		Expect(report.Run.Done).To(BeEquivalentTo(3))
+
+		_, err = redisService.GetProfile(ctx, "first")
+		Expect(err).NotTo(BeNil())
+		_, err = redisService.GetProfile(ctx, "third")
+		Expect(err).To(BeNil())
+
+		_, err = os.Stat(filepath.Join(directory, "users.ndjson"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("keeps the paths inside the import directory", func() {
+		write("users.csv", "display_name,country\nAlice,TR\n")
+		jobService.Start()
+
+		run, err := jobService.StartRun(ctx, tasks.JobImport, &api.ImportParameters{Path: "../../users.csv"})
+		Expect(err).To(BeNil())
+
+		report := finish(run)
+		Expect(report.Imported).To(BeEquivalentTo(1))
+	})
+})
@@ -0,0 +1,157 @@
+package tasks_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"leaderboard/app/leaderboard/tasks"
+	"os"
+	"path/filepath"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the maintenance jobs", func() {
+	const namespace = "maintenance"
+
+	var (
+		ctx          = context.Background()
+		mRedis       *miniredis.Miniredis
+		client       redis.UniversalClient
+		redisService *services.RedisService
+		boardService *services.BoardService
+		jobService   *services.JobService
+		directory    string
+	)
+
+	finish := func(job string, parameters interface{}) *api.JobRun {
+		run, err := jobService.StartRun(ctx, job, parameters)
+		Expect(err).To(BeNil())
+
+		Eventually(func() string {
+			run, err = jobService.GetRun(ctx, job, run.Id)
+			Expect(err).To(BeNil())
+			return run.State
+		}).Should(Equal(services.JobStateDone))
+
+		return run
+	}
+
+	BeforeEach(func() {
+		var err error
+		mRedis, err = miniredis.Run()
+		Expect(err).To(BeNil())
+
+		directory, err = ioutil.TempDir("", "snapshots")
+		Expect(err).To(BeNil())
+
+		client = redis.NewClient(&redis.Options{Addr: mRedis.Addr()})
+		redisService = services.NewRedisService(client, namespace)
+		boardService = services.NewBoardService(redisService)
+		jobService = services.NewJobService(redisService, services.NewKeySchema(namespace), &services.JobConfiguration{
+			Instance:     "test",
+			Workers:      1,
+			LeaseTTL:     time.Second,
+			PollInterval: 10 * time.Millisecond,
+		})
+		jobService.Register(
+			tasks.NewBoardRolloverJob(boardService),
+			tasks.NewSnapshotJob(services.NewSnapshotter(client, namespace), directory),
+		)
+		jobService.Start()
+
+		userService := services.NewUserService(redisService, "LB_")
+		for _, country := range []string{"TR", "US"} {
+			_, err := userService.Create(ctx, &api.UserProfile{DisplayName: "hi", Points: 10, Country: country})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	AfterEach(func() {
+		jobService.Stop()
+		mRedis.Close()
+		Expect(os.RemoveAll(directory)).To(Succeed())
+	})
+
+	Context("the board rollover", func() {
+		It("archives the boards and starts them over", func() {
+			run := finish(tasks.JobBoardRollover, &api.BoardRolloverParameters{Boards: []string{"TR", "GB"}})
+			Expect(run.Done).To(BeEquivalentTo(2))
+
+			board, err := boardService.Get(ctx, "TR")
+			Expect(err).To(BeNil())
+			Expect(board.Members).To(BeZero())
+			Expect(board.Archives).To(HaveLen(1))
+
+			board, err = boardService.Get(ctx, "US")
+			Expect(err).To(BeNil())
+			Expect(board.Members).To(BeEquivalentTo(1))
+		})
+
+		It("archives every board and keeps the latest archives", func() {
+			keys := services.NewKeySchema(namespace)
+			_, err := mRedis.ZAdd(keys.Archive("TR", "20201101T000000Z"), 1, "a")
+			Expect(err).To(BeNil())
+
+			run := finish(tasks.JobBoardRollover, &api.BoardRolloverParameters{Keep: 1})
+			Expect(run.Done).To(BeEquivalentTo(3))
+
+			names, err := redisService.GetBoardNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(names).To(BeEmpty())
+
+			board, err := boardService.Get(ctx, "TR")
+			Expect(err).To(BeNil())
+			Expect(board.Archives).To(HaveLen(1))
+			Expect(board.Archives[0]).NotTo(Equal("20201101T000000Z"))
+		})
+	})
+
+	Context("the snapshot", func() {
+		snapshots := func() []string {
+			files, err := ioutil.ReadDir(directory)
+			Expect(err).To(BeNil())
+
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name())
+			}
+			return names
+		}
+
+		It("exports the namespace to the snapshot directory", func() {
+			finish(tasks.JobSnapshot, &api.SnapshotParameters{Compress: true})
+
+			names := snapshots()
+			Expect(names).To(HaveLen(1))
+			Expect(names[0]).To(MatchRegexp(`^snapshot-\d{8}T\d{6}Z\.ndjson\.gz$`))
+
+			file, err := os.Open(filepath.Join(directory, names[0]))
+			Expect(err).To(BeNil())
+			defer file.Close()
+
+			report, err := services.NewSnapshotter(client, "copy").Restore(ctx, file, false)
+			Expect(err).To(BeNil())
+			Expect(report.Profiles).To(Equal(2))
+			Expect(report.Members).To(Equal(4))
+		})
+
+		It("keeps the latest snapshots", func() {
+			for _, name := range []string{"snapshot-20201101T000000Z.ndjson", "snapshot-20201108T000000Z.ndjson", "notes.txt"} {
+				Expect(ioutil.WriteFile(filepath.Join(directory, name), nil, 0o600)).To(Succeed())
+			}
+
+			finish(tasks.JobSnapshot, &api.SnapshotParameters{Keep: 2})
+
+			names := snapshots()
+			Expect(names).To(HaveLen(3))
+			Expect(names).To(ContainElement("notes.txt"))
+			Expect(names).To(ContainElement("snapshot-20201108T000000Z.ndjson"))
+			Expect(names).NotTo(ContainElement("snapshot-20201101T000000Z.ndjson"))
+		})
+	})
+})
@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+var (
+	firstNames = []string{
+		"Ahmet", "Alice", "Amelia", "Ana", "Arjun", "Ayşe", "Carlos", "Chen", "Chloe", "Daniel",
+		"David", "Elif", "Emma", "Ethan", "Fatma", "Grace", "Hana", "Haruto", "Isabella", "Jack",
+		"James", "Jin", "Liam", "Lucas", "Maria", "Mateo", "Mehmet", "Mia", "Noah", "Olivia",
+		"Oliver", "Priya", "Ravi", "Sakura", "Sofia", "Sophie", "Wei", "William", "Yuki", "Zeynep",
+	}
+	lastNames = []string{
+		"Anderson", "Brown", "Chen", "Clark", "Demir", "García", "Gonzalez", "Hall", "Ito", "Johnson",
+		"Kaya", "Kim", "Kumar", "Lee", "Li", "López", "Martin", "Miller", "Moore", "Nguyen",
+		"Öztürk", "Patel", "Rodriguez", "Sato", "Scott", "Silva", "Smith", "Suzuki", "Şahin", "Tanaka",
+		"Taylor", "Thomas", "Wang", "Watson", "White", "Williams", "Wilson", "Yamamoto", "Yılmaz", "Zhang",
+	}
+	tagAdjectives = []string{
+		"Angry", "Brave", "Clever", "Crimson", "Dark", "Electric", "Epic", "Frozen", "Golden", "Happy",
+		"Iron", "Lazy", "Lucky", "Mighty", "Neon", "Quiet", "Rapid", "Shadow", "Silent", "Swift",
+	}
+	tagNouns = []string{
+		"Bear", "Blade", "Dragon", "Eagle", "Falcon", "Fox", "Ghost", "Hawk", "Knight", "Ninja",
+		"Panda", "Phoenix", "Pirate", "Raven", "Rider", "Samurai", "Sniper", "Tiger", "Viking", "Wolf",
+	}
+)
+
+// displayName draws a name the way players pick them, their real names
+// in a few spellings or gamer tags.
+func displayName(rng *rand.Rand) string {
+	first := firstNames[rng.Intn(len(firstNames))]
+	last := lastNames[rng.Intn(len(lastNames))]
+	adjective := tagAdjectives[rng.Intn(len(tagAdjectives))]
+	noun := tagNouns[rng.Intn(len(tagNouns))]
+
+	switch n := rng.Intn(100); {
+	case n < 25:
+		return first + " " + last
+	case n < 40:
+		return strings.ToLower(first + "." + last)
+	case n < 55:
+		return fmt.Sprintf("%s%s%02d", first, last[:1], rng.Intn(100))
+	case n < 85:
+		return fmt.Sprintf("%s%s%d", adjective, noun, rng.Intn(1000))
+	default:
+		return strings.ToLower(adjective + "_" + noun)
+	}
+}
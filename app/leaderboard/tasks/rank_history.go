@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"context"
+	"github.com/labstack/gommon/log"
+	"leaderboard/app/leaderboard/services"
+	"sync"
+	"time"
+)
+
+type RankHistorySamplerTask struct {
+	rankHistoryService *services.RankHistoryService
+	stop               chan struct{}
+	stopOnce           sync.Once
+}
+
+func NewRankHistorySamplerTask(rankHistoryService *services.RankHistoryService) *RankHistorySamplerTask {
+	return &RankHistorySamplerTask{rankHistoryService: rankHistoryService, stop: make(chan struct{})}
+}
+
+func (r *RankHistorySamplerTask) Start() {
+	go r.run()
+}
+
+func (r *RankHistorySamplerTask) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+func (r *RankHistorySamplerTask) run() {
+	ticker := time.NewTicker(r.rankHistoryService.Interval())
+	defer ticker.Stop()
+
+	r.sample(time.Now())
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.sample(now)
+		}
+	}
+}
+
+func (r *RankHistorySamplerTask) sample(at time.Time) {
+	if err := r.rankHistoryService.RecordAll(context.Background(), at); err != nil {
+		log.Error(err)
+	}
+}
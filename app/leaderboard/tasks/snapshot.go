@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"context"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"leaderboard/app/leaderboard/services"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	JobSnapshot = "snapshot"
+
+	// snapshots are named by the time they were taken at, so their names
+	// sort by it, e.g. snapshot-20201102T030000Z.ndjson.gz
+	snapshotFilePrefix = "snapshot-"
+	snapshotFileLayout = "20060102T150405Z"
+)
+
+// SnapshotJob exports the key namespace to a snapshot of the snapshot
+// directory, see services.Snapshotter, then deletes the oldest snapshots
+// beyond the ones kept. A snapshot is written to a hidden file first and
+// named once it is complete, a resumed run takes it again.
+type SnapshotJob struct {
+	snapshotter *services.Snapshotter
+	directory   string
+}
+
+func NewSnapshotJob(snapshotter *services.Snapshotter, directory string) *SnapshotJob {
+	return &SnapshotJob{snapshotter: snapshotter, directory: directory}
+}
+
+func (s *SnapshotJob) Name() string {
+	return JobSnapshot
+}
+
+func (s *SnapshotJob) Singleton() bool {
+	return true
+}
+
+func (s *SnapshotJob) Parameters() interface{} {
+	return new(api.SnapshotParameters)
+}
+
+func (s *SnapshotJob) Run(ctx context.Context, run *services.RunningJob) error {
+	parameters := run.Parameters().(*api.SnapshotParameters)
+	run.SetTotal(1)
+
+	if err := os.MkdirAll(s.directory, 0o750); err != nil {
+		return err
+	}
+
+	name := snapshotFilePrefix + time.Now().UTC().Format(snapshotFileLayout) + ".ndjson"
+	if parameters.Compress {
+		name += ".gz"
+	}
+
+	file, err := ioutil.TempFile(s.directory, "."+snapshotFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = s.snapshotter.Export(ctx, file, parameters.Compress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), filepath.Join(s.directory, name)); err != nil {
+		return err
+	}
+
+	if parameters.Keep > 0 {
+		if err := s.prune(int(parameters.Keep)); err != nil {
+			return err
+		}
+	}
+
+	run.Progress(1)
+	return nil
+}
+
+// prune deletes the snapshots of the directory but the keep latest ones.
+func (s *SnapshotJob) prune(keep int) error {
+	files, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return err
+	}
+
+	var snapshots []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), snapshotFilePrefix) {
+			snapshots = append(snapshots, file.Name())
+		}
+	}
+	sort.Strings(snapshots)
+
+	for len(snapshots) > keep {
+		if err := os.Remove(filepath.Join(s.directory, snapshots[0])); err != nil {
+			return err
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// Package tracing sets up the OpenTelemetry tracer provider spans of the
+// leaderboard are exported through.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagators"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+const InstrumentationName = "leaderboard"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Tracer returns the tracer of the leaderboard from the global provider, so
+// spans are dropped until Setup installs an exporter.
+func Tracer() trace.Tracer {
+	return global.Tracer(InstrumentationName)
+}
+
+// Setup installs a global tracer provider which exports spans through the
+// given exporter, and the W3C trace context propagator. The returned
+// function flushes the spans which are yet to be exported.
+func Setup(exporterName string, otlpEndpoint string, serviceName string) (func(), error) {
+	global.SetTextMapPropagator(otel.NewCompositeTextMapPropagator(propagators.TraceContext{}, propagators.Baggage{}))
+
+	exporter, err := newExporter(exporterName, otlpEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter == nil {
+		return func() {}, nil
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.New(semconv.ServiceNameKey.String(serviceName))),
+	)
+	global.SetTracerProvider(provider)
+
+	return func() {
+		processor.Shutdown()
+		if err := exporter.Shutdown(context.Background()); err != nil {
+			global.Handle(err)
+		}
+	}, nil
+}
+
+func newExporter(exporterName string, otlpEndpoint string) (export.SpanExporter, error) {
+	switch exporterName {
+	case ExporterNone, "":
+		return nil, nil
+	case ExporterStdout:
+		return stdout.NewExporter(stdout.WithoutMetricExport())
+	case ExporterOTLP:
+		return otlp.NewExporter(otlp.WithInsecure(), otlp.WithAddress(otlpEndpoint))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %s", exporterName)
+	}
+}
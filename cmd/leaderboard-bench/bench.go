@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	OperationCreate      = "create"
+	OperationSubmit      = "submit"
+	OperationLeaderboard = "leaderboard"
+	OperationProfile     = "profile"
+	OperationAround      = "around"
+)
+
+var operations = []string{OperationCreate, OperationSubmit, OperationLeaderboard, OperationProfile, OperationAround}
+
+var countries = []string{"TR", "US", "GB", "CN", "JP", "AU", "NZ"}
+
+// MaxRPS bounds the target rate, requests are scheduled a microsecond apart
+// at the most.
+const MaxRPS = 1_000_000
+
+type Configuration struct {
+	BaseURL     string
+	APIKey      string
+	Token       string
+	RPS         float64
+	Concurrency int
+	Duration    time.Duration
+	Timeout     time.Duration
+	Users       int
+	Mix         Mix
+}
+
+// Validate answers why the configuration can not be run, if it can not.
+func (c *Configuration) Validate() error {
+	if math.IsNaN(c.RPS) || c.RPS < 0 || c.RPS > MaxRPS {
+		return fmt.Errorf("rps must be between 0 and %d", MaxRPS)
+	}
+
+	if c.Concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	if c.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+
+	if len(c.Mix) == 0 {
+		return errors.New("mix: no operation has a weight")
+	}
+
+	return nil
+}
+
+// Mix weighs the operations a request is drawn from.
+type Mix map[string]float64
+
+// ParseMix parses weights like "create=1,submit=6,leaderboard=2".
+func ParseMix(value string) (Mix, error) {
+	mix := Mix{}
+	total := 0.0
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("mix: %q is not operation=weight", pair)
+		}
+
+		if !knownOperation(parts[0]) {
+			return nil, fmt.Errorf("mix: unknown operation %q, one of %s", parts[0], operationNames())
+		}
+
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("mix: weight of %s must be a number of at least 0", parts[0])
+		}
+
+		mix[parts[0]] = weight
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, errors.New("mix: no operation has a weight")
+	}
+
+	return mix, nil
+}
+
+func (m Mix) pick(rng *rand.Rand) string {
+	total := 0.0
+	for _, operation := range operations {
+		total += m[operation]
+	}
+
+	drawn := rng.Float64() * total
+	for _, operation := range operations {
+		if drawn < m[operation] {
+			return operation
+		}
+		drawn -= m[operation]
+	}
+
+	return OperationCreate
+}
+
+func knownOperation(name string) bool {
+	for _, operation := range operations {
+		if operation == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func operationNames() string {
+	return strings.Join(operations, ", ")
+}
+
+// Bench sends the requests and records how they went. Submissions and reads
+// pick one of the users created before or during the run.
+type Bench struct {
+	configuration *Configuration
+	client        *http.Client
+	recorders     map[string]*recorder
+
+	usersMux sync.RWMutex
+	users    []string
+	created  int64
+	missed   int64
+}
+
+func NewBench(configuration *Configuration) *Bench {
+	recorders := map[string]*recorder{}
+	for _, operation := range operations {
+		recorders[operation] = &recorder{statuses: map[string]int64{}}
+	}
+
+	return &Bench{
+		configuration: configuration,
+		client: &http.Client{
+			Timeout: configuration.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        configuration.Concurrency,
+				MaxIdleConnsPerHost: configuration.Concurrency,
+			},
+		},
+		recorders: recorders,
+	}
+}
+
+// Prepare creates the users of the configuration, they are not measured.
+func (b *Bench) Prepare(ctx context.Context) error {
+	var (
+		workers  sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		next     = int64(-1)
+	)
+	for i := 0; i < b.configuration.Concurrency; i++ {
+		workers.Add(1)
+		go func(seed int64) {
+			defer workers.Done()
+
+			rng := rand.New(rand.NewSource(seed))
+			for atomic.AddInt64(&next, 1) < int64(b.configuration.Users) && ctx.Err() == nil {
+				if _, err := b.create(rng); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+					})
+					return
+				}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	workers.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// Run sends requests for the duration of the configuration, or until ctx is
+// done. Requests in flight when it ends are waited for.
+func (b *Bench) Run(ctx context.Context) *Result {
+	ctx, cancel := context.WithTimeout(ctx, b.configuration.Duration)
+	defer cancel()
+
+	var tokens chan time.Time
+	if b.configuration.RPS > 0 {
+		tokens = make(chan time.Time, b.configuration.Concurrency)
+		go pace(ctx, b.configuration.RPS, tokens, &b.missed)
+	}
+
+	startedAt := time.Now()
+	var workers sync.WaitGroup
+	for i := 0; i < b.configuration.Concurrency; i++ {
+		workers.Add(1)
+		go func(seed int64) {
+			defer workers.Done()
+
+			rng := rand.New(rand.NewSource(seed))
+			for {
+				scheduledAt := time.Now()
+				if tokens != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case scheduledAt = <-tokens:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+
+				b.do(b.configuration.Mix.pick(rng), rng, scheduledAt)
+			}
+		}(startedAt.UnixNano() + int64(i))
+	}
+	workers.Wait()
+
+	return b.result(startedAt, time.Since(startedAt))
+}
+
+// pace hands out the times requests are scheduled at, at the rate. Requests
+// are measured from their scheduled time, so the time they wait for a
+// worker held up by a slow service counts against the service. Times
+// which find every worker busy and the tokens full are counted as missed
+// rather than sent in a burst afterwards.
+func pace(ctx context.Context, rate float64, tokens chan<- time.Time, missed *int64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case scheduledAt := <-ticker.C:
+			select {
+			case tokens <- scheduledAt:
+			default:
+				atomic.AddInt64(missed, 1)
+			}
+		}
+	}
+}
+
+func (b *Bench) do(operation string, rng *rand.Rand, scheduledAt time.Time) {
+	user, ok := b.user(rng)
+	if !ok {
+		operation = OperationCreate
+	}
+
+	var (
+		took   time.Duration
+		status string
+	)
+	switch operation {
+	case OperationCreate:
+		took, status = b.measure(func() (int, error) {
+			return b.create(rng)
+		}, scheduledAt)
+	case OperationSubmit:
+		took, status = b.measure(func() (int, error) {
+			return b.send(http.MethodPost, "/score/submit", &api.ScoreSubmission{
+				Score:     float64(rng.Intn(100_000) + 1),
+				UserId:    user,
+				Timestamp: time.Now().Unix(),
+			}, nil)
+		}, scheduledAt)
+	case OperationLeaderboard:
+		took, status = b.measure(func() (int, error) {
+			return b.send(http.MethodGet, fmt.Sprintf("/leaderboard?page=%d&page_size=10", rng.Intn(10)+1), nil, nil)
+		}, scheduledAt)
+	case OperationProfile:
+		took, status = b.measure(func() (int, error) {
+			return b.send(http.MethodGet, "/user/profile/"+user, nil, nil)
+		}, scheduledAt)
+	case OperationAround:
+		took, status = b.measure(func() (int, error) {
+			return b.send(http.MethodGet, "/leaderboard/around/"+user+"?radius=5", nil, nil)
+		}, scheduledAt)
+	}
+
+	b.recorders[operation].record(took, status)
+}
+
+// measure answers how long the request took from its scheduled time, and
+// its status.
+func (b *Bench) measure(request func() (int, error), scheduledAt time.Time) (time.Duration, string) {
+	status, err := request()
+	took := time.Since(scheduledAt)
+	// a response which could not be read counts as no response
+	if err != nil && status/100 == 2 || status == 0 {
+		return took, statusError
+	}
+
+	return took, strconv.Itoa(status)
+}
+
+func (b *Bench) create(rng *rand.Rand) (int, error) {
+	profile := &api.UserProfile{
+		DisplayName: fmt.Sprintf("bench_%d_%d", time.Now().Unix(), atomic.AddInt64(&b.created, 1)),
+		Country:     countries[rng.Intn(len(countries))],
+	}
+
+	created := new(api.UserProfile)
+	status, err := b.send(http.MethodPost, "/user/create", profile, created)
+	if err != nil {
+		return status, err
+	}
+
+	if status != http.StatusCreated {
+		return status, fmt.Errorf("creating a user answered %d", status)
+	}
+
+	b.usersMux.Lock()
+	b.users = append(b.users, created.UserId)
+	b.usersMux.Unlock()
+
+	return status, nil
+}
+
+func (b *Bench) user(rng *rand.Rand) (string, bool) {
+	b.usersMux.RLock()
+	defer b.usersMux.RUnlock()
+
+	if len(b.users) == 0 {
+		return "", false
+	}
+
+	return b.users[rng.Intn(len(b.users))], true
+}
+
+// send answers the status of the response, whose body is decoded into
+// answer when it is successful.
+func (b *Bench) send(method string, path string, body interface{}, answer interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, strings.TrimSuffix(b.configuration.BaseURL, "/")+path, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if b.configuration.APIKey != "" {
+		request.Header.Set("X-API-Key", b.configuration.APIKey)
+	}
+	if b.configuration.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+b.configuration.Token)
+	}
+
+	response, err := b.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if answer != nil && response.StatusCode/100 == 2 {
+		return response.StatusCode, json.NewDecoder(response.Body).Decode(answer)
+	}
+
+	// drained, so the connection is reused
+	_, err = io.Copy(ioutil.Discard, response.Body)
+	return response.StatusCode, err
+}
+
+func (b *Bench) result(startedAt time.Time, took time.Duration) *Result {
+	result := &Result{
+		BaseURL:     b.configuration.BaseURL,
+		StartedAt:   startedAt.UTC(),
+		Seconds:     took.Seconds(),
+		TargetRPS:   b.configuration.RPS,
+		Concurrency: b.configuration.Concurrency,
+		Missed:      atomic.LoadInt64(&b.missed),
+	}
+
+	total := &recorder{statuses: map[string]int64{}}
+	for _, operation := range operations {
+		recorder := b.recorders[operation]
+		if len(recorder.latencies) == 0 {
+			continue
+		}
+
+		result.Operations = append(result.Operations, recorder.summary(operation, took))
+		total.merge(recorder)
+	}
+	result.Operations = append(result.Operations, total.summary(OperationTotal, took))
+
+	return result
+}
+
+const statusError = "error"
+
+// recorder keeps the latencies and the statuses of one operation.
+type recorder struct {
+	mux       sync.Mutex
+	latencies []time.Duration
+	statuses  map[string]int64
+}
+
+func (r *recorder) record(took time.Duration, status string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.latencies = append(r.latencies, took)
+	r.statuses[status]++
+}
+
+func (r *recorder) merge(other *recorder) {
+	r.latencies = append(r.latencies, other.latencies...)
+	for status, count := range other.statuses {
+		r.statuses[status] += count
+	}
+}
+
+func (r *recorder) summary(operation string, took time.Duration) *OperationResult {
+	latencies := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+
+	var errs int64
+	for status, count := range r.statuses {
+		if status == statusError || !strings.HasPrefix(status, "2") {
+			errs += count
+		}
+	}
+
+	requests := int64(len(latencies))
+	summary := &OperationResult{
+		Operation: operation,
+		Requests:  requests,
+		Errors:    errs,
+		Statuses:  r.statuses,
+	}
+	if requests == 0 {
+		return summary
+	}
+
+	summary.ErrorRate = float64(errs) / float64(requests)
+	summary.RPS = float64(requests) / took.Seconds()
+	summary.Mean = milliseconds(sum / time.Duration(requests))
+	summary.P50 = milliseconds(percentile(latencies, 0.50))
+	summary.P95 = milliseconds(percentile(latencies, 0.95))
+	summary.P99 = milliseconds(percentile(latencies, 0.99))
+	summary.Max = milliseconds(latencies[len(latencies)-1])
+
+	return summary
+}
+
+// percentile answers the nearest rank of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
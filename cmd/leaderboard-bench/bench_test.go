@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+func TestBench(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "leaderboard-bench")
+}
+
+var _ = Describe("the bench", func() {
+	Context("ParseMix()", func() {
+		It("parses the weights of the operations", func() {
+			mix, err := ParseMix("create=1, submit=6,leaderboard=2.5,profile=0")
+			Expect(err).To(BeNil())
+			Expect(mix).To(Equal(Mix{OperationCreate: 1, OperationSubmit: 6, OperationLeaderboard: 2.5, OperationProfile: 0}))
+		})
+
+		DescribeTable("refuses invalid mixes",
+			func(value string, message string) {
+				_, err := ParseMix(value)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("a pair without a weight", "create", "is not operation=weight"),
+			Entry("an unknown operation", "delete=1", `unknown operation "delete"`),
+			Entry("a weight which is not a number", "create=many", "must be a number"),
+			Entry("a negative weight", "create=-1", "must be a number of at least 0"),
+			Entry("no weight at all", "create=0,submit=0", "no operation has a weight"),
+		)
+	})
+
+	Context("Configuration.Validate()", func() {
+		valid := func() *Configuration {
+			return &Configuration{RPS: 100, Concurrency: 1, Duration: time.Second, Mix: Mix{OperationCreate: 1}}
+		}
+
+		It("accepts the rates up to the bound, and no rate", func() {
+			for _, rps := range []float64{0, 0.5, MaxRPS} {
+				configuration := valid()
+				configuration.RPS = rps
+				Expect(configuration.Validate()).To(Succeed())
+			}
+		})
+
+		It("refuses rates which can not be paced", func() {
+			for _, rps := range []float64{-1, MaxRPS + 1, 2e9, math.NaN(), math.Inf(1)} {
+				configuration := valid()
+				configuration.RPS = rps
+				Expect(configuration.Validate()).To(MatchError(ContainSubstring("rps must be between")), fmt.Sprint(rps))
+			}
+		})
+
+		It("refuses no concurrency and no duration", func() {
+			configuration := valid()
+			configuration.Concurrency = 0
+			Expect(configuration.Validate()).NotTo(Succeed())
+
+			configuration = valid()
+			configuration.Duration = 0
+			Expect(configuration.Validate()).NotTo(Succeed())
+		})
+	})
+
+	Context("percentile()", func() {
+		It("answers the nearest rank", func() {
+			var latencies []time.Duration
+			for i := 1; i <= 10; i++ {
+				latencies = append(latencies, time.Duration(i)*time.Millisecond)
+			}
+
+			Expect(percentile(latencies, 0.50)).To(Equal(5 * time.Millisecond))
+			Expect(percentile(latencies, 0.95)).To(Equal(10 * time.Millisecond))
+			Expect(percentile(latencies, 0.99)).To(Equal(10 * time.Millisecond))
+			Expect(percentile(latencies, 0)).To(Equal(time.Millisecond))
+			Expect(percentile(latencies[:1], 0.99)).To(Equal(time.Millisecond))
+		})
+	})
+
+	Context("pace()", func() {
+		It("hands out the scheduled times and counts the missed ones", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			var missed int64
+			tokens := make(chan time.Time, 1)
+			startedAt := time.Now()
+			go pace(ctx, 1000, tokens, &missed)
+
+			// nobody takes the tokens, all but the first one are missed
+			<-ctx.Done()
+			Expect(atomic.LoadInt64(&missed)).To(BeNumerically(">", 10))
+			Expect(<-tokens).To(BeTemporally("~", startedAt, 10*time.Millisecond))
+		})
+	})
+
+	Context("Bench.Run()", func() {
+		var (
+			server   *httptest.Server
+			delay    time.Duration
+			requests int64
+		)
+
+		BeforeEach(func() {
+			delay, requests = 0, 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt64(&requests, 1)
+				time.Sleep(delay)
+
+				if r.URL.Path == "/user/create" {
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(map[string]string{"user_id": fmt.Sprint(atomic.LoadInt64(&requests))})
+					return
+				}
+
+				if r.URL.Path == "/leaderboard" {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("reports every operation and their statuses", func() {
+			bench := NewBench(&Configuration{
+				BaseURL:     server.URL,
+				RPS:         0,
+				Concurrency: 2,
+				Duration:    100 * time.Millisecond,
+				Timeout:     time.Second,
+				Users:       5,
+				Mix:         Mix{OperationSubmit: 1, OperationLeaderboard: 1},
+			})
+			Expect(bench.Prepare(context.Background())).To(Succeed())
+			Expect(bench.users).To(HaveLen(5))
+
+			result := bench.Run(context.Background())
+			operations := map[string]*OperationResult{}
+			for _, operation := range result.Operations {
+				operations[operation.Operation] = operation
+			}
+
+			Expect(operations).To(HaveKey(OperationSubmit))
+			Expect(operations[OperationSubmit].Errors).To(BeZero())
+			Expect(operations[OperationLeaderboard].ErrorRate).To(Equal(1.0))
+			Expect(operations[OperationLeaderboard].Statuses).To(HaveKey("503"))
+			Expect(operations[OperationTotal].Requests).To(Equal(operations[OperationSubmit].Requests + operations[OperationLeaderboard].Requests))
+		})
+
+		It("measures from the scheduled time and reports the missed requests", func() {
+			delay = 20 * time.Millisecond
+			bench := NewBench(&Configuration{
+				BaseURL:     server.URL,
+				RPS:         500,
+				Concurrency: 1,
+				Duration:    200 * time.Millisecond,
+				Timeout:     time.Second,
+				Mix:         Mix{OperationCreate: 1},
+			})
+
+			result := bench.Run(context.Background())
+			Expect(result.Missed).To(BeNumerically(">", 0))
+
+			// a request waits for the one in flight before it is sent
+			total := result.Operations[len(result.Operations)-1]
+			Expect(total.Operation).To(Equal(OperationTotal))
+			Expect(total.Max).To(BeNumerically(">", 30))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+// leaderboard-bench drives a mix of requests against a leaderboard service at
+// a target rate and reports the latencies and the error rates of each kind
+// of request.
+//
+//	leaderboard-bench -url http://localhost:1323 -rps 500 -concurrency 32 \
+//	  -duration 1m -mix create=1,submit=6,leaderboard=2,profile=1 \
+//	  -label baseline -json baseline.json -csv runs.csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configuration := &Configuration{}
+	flag.StringVar(&configuration.BaseURL, "url", "http://localhost:1323", "base URL of the service")
+	flag.StringVar(&configuration.APIKey, "api-key", os.Getenv("LEADERBOARD_API_KEY"), "API key sent as X-API-Key")
+	flag.StringVar(&configuration.Token, "token", os.Getenv("LEADERBOARD_TOKEN"), "bearer token sent as Authorization")
+	flag.Float64Var(&configuration.RPS, "rps", 100, "target requests per second, unlimited when 0")
+	flag.IntVar(&configuration.Concurrency, "concurrency", 8, "requests in flight at most")
+	flag.DurationVar(&configuration.Duration, "duration", 30*time.Second, "how long requests are measured")
+	flag.DurationVar(&configuration.Timeout, "timeout", 5*time.Second, "timeout of a request")
+	flag.IntVar(&configuration.Users, "users", 100, "users created before requests are measured")
+	mix := flag.String("mix", "create=1,submit=6,leaderboard=2,profile=1", "weights of the operations: "+operationNames())
+	label := flag.String("label", "", "label of the run in the results")
+	jsonPath := flag.String("json", "", "file the results are written to as JSON")
+	csvPath := flag.String("csv", "", "file the results are appended to as CSV")
+	flag.Parse()
+
+	var err error
+	configuration.Mix, err = ParseMix(*mix)
+	if err != nil {
+		exit(err)
+	}
+
+	if err := configuration.Validate(); err != nil {
+		exit(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	bench := NewBench(configuration)
+	fmt.Fprintf(os.Stderr, "creating %d users\n", configuration.Users)
+	if err := bench.Prepare(ctx); err != nil {
+		exit(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "running for %s\n", configuration.Duration)
+	result := bench.Run(ctx)
+	result.Label = *label
+
+	result.Print(os.Stdout)
+
+	if *jsonPath != "" {
+		if err := result.WriteJSON(*jsonPath); err != nil {
+			exit(err)
+		}
+	}
+
+	if *csvPath != "" {
+		if err := result.AppendCSV(*csvPath); err != nil {
+			exit(err)
+		}
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "leaderboard-bench:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// OperationTotal sums up the requests of all operations.
+const OperationTotal = "total"
+
+// Result of a run, latencies are in milliseconds.
+type Result struct {
+	Label       string    `json:"label"`
+	BaseURL     string    `json:"base_url"`
+	StartedAt   time.Time `json:"started_at"`
+	Seconds     float64   `json:"seconds"`
+	TargetRPS   float64   `json:"target_rps"`
+	Concurrency int       `json:"concurrency"`
+	// Missed counts the requests of the target rate which were not sent,
+	// every worker was busy at their time.
+	Missed     int64              `json:"missed"`
+	Operations []*OperationResult `json:"operations"`
+}
+
+type OperationResult struct {
+	Operation string  `json:"operation"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	RPS       float64 `json:"rps"`
+	Mean      float64 `json:"mean_ms"`
+	P50       float64 `json:"p50_ms"`
+	P95       float64 `json:"p95_ms"`
+	P99       float64 `json:"p99_ms"`
+	Max       float64 `json:"max_ms"`
+	// Statuses counts the responses by their status code, requests which
+	// got no response are counted as "error".
+	Statuses map[string]int64 `json:"statuses"`
+}
+
+var csvHeader = []string{"label", "started_at", "operation", "requests", "errors", "error_rate", "rps", "mean_ms", "p50_ms", "p95_ms", "p99_ms", "max_ms", "missed"}
+
+func (r *Result) Print(w io.Writer) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "operation\trequests\terrors\terror rate\trps\tmean\tp50\tp95\tp99\tmax\t")
+	for _, operation := range r.Operations {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%.2f%%\t%.1f\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t\n",
+			operation.Operation, operation.Requests, operation.Errors, operation.ErrorRate*100, operation.RPS,
+			operation.Mean, operation.P50, operation.P95, operation.P99, operation.Max)
+	}
+	_ = table.Flush()
+
+	if r.Missed > 0 {
+		fmt.Fprintf(w, "%d requests of the target rate were missed, every worker was busy\n", r.Missed)
+	}
+}
+
+func (r *Result) WriteJSON(path string) error {
+	encoded, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(encoded, '\n'), 0644)
+}
+
+// AppendCSV appends a row for each operation to the file, so the runs of a
+// comparison end up in one file. The header is written to a new file, the
+// missed requests of the run are repeated on every row.
+func (r *Result) AppendCSV(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	for _, operation := range r.Operations {
+		err := writer.Write([]string{
+			r.Label,
+			r.StartedAt.Format(time.RFC3339),
+			operation.Operation,
+			strconv.FormatInt(operation.Requests, 10),
+			strconv.FormatInt(operation.Errors, 10),
+			formatFloat(operation.ErrorRate),
+			formatFloat(operation.RPS),
+			formatFloat(operation.Mean),
+			formatFloat(operation.P50),
+			formatFloat(operation.P95),
+			formatFloat(operation.P99),
+			formatFloat(operation.Max),
+			strconv.FormatInt(r.Missed, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 4, 64)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the results", func() {
+	var (
+		directory string
+		result    *Result
+	)
+
+	BeforeEach(func() {
+		var err error
+		directory, err = ioutil.TempDir("", "bench")
+		Expect(err).To(BeNil())
+
+		result = &Result{
+			Label:       "baseline",
+			BaseURL:     "http://localhost:1323",
+			StartedAt:   time.Date(2020, 11, 2, 3, 0, 0, 0, time.UTC),
+			Seconds:     10,
+			TargetRPS:   100,
+			Concurrency: 4,
+			Missed:      3,
+			Operations: []*OperationResult{
+				{Operation: OperationSubmit, Requests: 900, Errors: 9, ErrorRate: 0.01, RPS: 90, Mean: 2, P50: 1.5, P95: 4, P99: 8, Max: 12.25, Statuses: map[string]int64{"201": 891, "error": 9}},
+				{Operation: OperationTotal, Requests: 900, Errors: 9, ErrorRate: 0.01, RPS: 90, Mean: 2, P50: 1.5, P95: 4, P99: 8, Max: 12.25, Statuses: map[string]int64{"201": 891, "error": 9}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(directory)).To(Succeed())
+	})
+
+	It("prints a table along with the missed requests", func() {
+		output := new(bytes.Buffer)
+		result.Print(output)
+
+		lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(4))
+		Expect(string(lines[0])).To(ContainSubstring("operation"))
+		Expect(string(lines[1])).To(MatchRegexp(`submit\s+900\s+9\s+1\.00%\s+90\.0\s+2\.00ms\s+1\.50ms\s+4\.00ms\s+8\.00ms\s+12\.25ms`))
+		Expect(string(lines[3])).To(Equal("3 requests of the target rate were missed, every worker was busy"))
+	})
+
+	It("writes JSON", func() {
+		path := filepath.Join(directory, "result.json")
+		Expect(result.WriteJSON(path)).To(Succeed())
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(ContainSubstring(`"p99_ms": 8`))
+
+		read := new(Result)
+		Expect(json.Unmarshal(data, read)).To(Succeed())
+		Expect(read).To(Equal(result))
+	})
+
+	It("appends CSV rows under a single header", func() {
+		path := filepath.Join(directory, "runs.csv")
+		Expect(result.AppendCSV(path)).To(Succeed())
+		result.Label = "candidate"
+		Expect(result.AppendCSV(path)).To(Succeed())
+
+		file, err := os.Open(path)
+		Expect(err).To(BeNil())
+		defer file.Close()
+
+		rows, err := csv.NewReader(file).ReadAll()
+		Expect(err).To(BeNil())
+		Expect(rows).To(HaveLen(5))
+		Expect(rows[0]).To(Equal(csvHeader))
+		Expect(rows[1]).To(Equal([]string{"baseline", "2020-11-02T03:00:00Z", "submit", "900", "9", "0.0100", "90.0000", "2.0000", "1.5000", "4.0000", "8.0000", "12.2500", "3"}))
+		Expect(rows[4][0]).To(Equal("candidate"))
+		Expect(rows[4][2]).To(Equal(OperationTotal))
+	})
+})
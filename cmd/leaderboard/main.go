@@ -13,6 +13,14 @@ import "leaderboard/app/leaderboard"
 // @license.name The MIT License (MIT)
 // @license.url https://mit-license.org/
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 // @host leaderboard-v2-lb-ecs-tg-584908050.eu-central-1.elb.amazonaws.com
 func main() {
 	leaderboard.Run()
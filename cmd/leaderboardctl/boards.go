@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func listBoards(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("boards list", flag.ContinueOnError)
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var boards []*api.Board
+	if err := ctl.client.do(http.MethodGet, "/_actuator/boards", nil, &boards); err != nil {
+		return err
+	}
+
+	if err := ctl.printer.header("name", "members"); err != nil {
+		return err
+	}
+
+	for _, board := range boards {
+		if err := ctl.printer.record(board, board.Name, formatInt(board.Members)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func inspectBoard(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("boards inspect", flag.ContinueOnError)
+	names, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	board := new(api.Board)
+	if err := ctl.client.do(http.MethodGet, boardPath(names[0]), nil, board); err != nil {
+		return err
+	}
+
+	if err := ctl.printer.header("name", "members", "top_score", "bottom_score", "archives"); err != nil {
+		return err
+	}
+
+	return ctl.printer.record(board,
+		board.Name,
+		formatInt(board.Members),
+		formatFloat(board.TopScore),
+		formatFloat(board.BottomScore),
+		strings.Join(board.Archives, " "),
+	)
+}
+
+func resetBoard(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("boards reset", flag.ContinueOnError)
+	names, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	return ctl.client.do(http.MethodDelete, boardPath(names[0]), nil, nil)
+}
+
+func archiveBoard(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("boards archive", flag.ContinueOnError)
+	label := flags.String("label", "", "label of the archive, the current time when not given")
+	names, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	archive := new(api.BoardArchive)
+	if err := ctl.client.do(http.MethodPost, boardPath(names[0])+"/archive", &api.BoardArchive{Label: *label}, archive); err != nil {
+		return err
+	}
+
+	if err := ctl.printer.header("board", "archive"); err != nil {
+		return err
+	}
+
+	return ctl.printer.record(archive, strings.ToUpper(names[0]), archive.Label)
+}
+
+func boardPath(name string) string {
+	return "/_actuator/boards/" + url.PathEscape(strings.ToUpper(name))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client calls the HTTP API with the credentials of the operator.
+type client struct {
+	baseURL string
+	apiKey  string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL string, apiKey string, token string, timeout time.Duration) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		token:   token,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// do sends body as JSON and decodes a successful answer into answer. Other
+// answers are returned as a *responseError.
+func (c *client) do(method string, path string, body interface{}, answer interface{}) error {
+	if body == nil {
+		return c.send(method, path, "", nil, answer)
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return c.send(method, path, "application/json", bytes.NewReader(encoded), answer)
+}
+
+// send sends body as it is, of the content type, and decodes a successful
+// answer into answer.
+func (c *client) send(method string, path string, contentType string, body io.Reader, answer interface{}) error {
+	request, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		request.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return newResponseError(response)
+	}
+
+	if answer == nil {
+		_, err = io.Copy(ioutil.Discard, response.Body)
+		return err
+	}
+
+	return json.NewDecoder(response.Body).Decode(answer)
+}
+
+// responseError is an answer of the service other than 2xx.
+type responseError struct {
+	status   int
+	envelope *api.ErrorResponse
+}
+
+func newResponseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 64*1024))
+
+	envelope := new(api.ErrorResponse)
+	if err := json.Unmarshal(body, envelope); err != nil || envelope.Message == "" {
+		envelope = &api.ErrorResponse{Message: strings.TrimSpace(string(body))}
+	}
+
+	return &responseError{status: response.StatusCode, envelope: envelope}
+}
+
+func (e *responseError) Error() string {
+	message := fmt.Sprintf("%d %s", e.status, http.StatusText(e.status))
+	if e.envelope.Message != "" {
+		message += ": " + e.envelope.Message
+	}
+
+	if e.envelope.RequestId != "" {
+		message += fmt.Sprintf(" (request %s)", e.envelope.RequestId)
+	}
+
+	for _, field := range e.envelope.Fields {
+		message += fmt.Sprintf("\n  %s: %s", field.Path, field.Message)
+	}
+
+	return message
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+func TestLeaderboardctl(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "leaderboardctl")
+}
+
+var _ = Describe("the client", func() {
+	var (
+		server  *httptest.Server
+		handler http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the credentials and the body as JSON, and decodes the answer", func() {
+		var request *http.Request
+		var body []byte
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			request = r
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"user_id": "alice", "display_name": "Alice", "country": "TR", "points": 10}`))
+		}
+
+		profile := new(api.UserProfile)
+		c := newClient(server.URL+"/", "secret", "jwt", time.Second)
+		Expect(c.do(http.MethodPost, "/user/create", &api.UserProfile{DisplayName: "Alice", Country: "TR"}, profile)).To(Succeed())
+
+		Expect(request.Method).To(Equal(http.MethodPost))
+		Expect(request.URL.Path).To(Equal("/user/create"))
+		Expect(request.Header.Get("X-API-Key")).To(Equal("secret"))
+		Expect(request.Header.Get("Authorization")).To(Equal("Bearer jwt"))
+		Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(body).To(MatchJSON(`{"user_id": "", "display_name": "Alice", "country": "TR", "points": 0, "rank": 0}`))
+		Expect(profile).To(Equal(&api.UserProfile{UserId: "alice", DisplayName: "Alice", Country: "TR", Points: 10}))
+	})
+
+	It("leaves out the credentials and the content type which are not given", func() {
+		var request *http.Request
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			request = r
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		c := newClient(server.URL, "", "", time.Second)
+		Expect(c.do(http.MethodDelete, "/user/profile/alice", nil, nil)).To(Succeed())
+
+		Expect(request.Header).NotTo(HaveKey("X-Api-Key"))
+		Expect(request.Header).NotTo(HaveKey("Authorization"))
+		Expect(request.Header).NotTo(HaveKey("Content-Type"))
+	})
+
+	It("answers the envelope of the service as the error", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(&api.ErrorResponse{
+				Code:      "validation_failed",
+				Message:   "the request is invalid",
+				RequestId: "r-1",
+				Fields: []api.FieldError{
+					{Path: "country", Rule: "required", Message: "country is a required field"},
+					{Path: "display_name", Rule: "required", Message: "display_name is a required field"},
+				},
+			})
+		}
+
+		err := newClient(server.URL, "", "", time.Second).do(http.MethodPost, "/user/create", &api.UserProfile{}, nil)
+
+		var responseErr *responseError
+		Expect(err).To(BeAssignableToTypeOf(responseErr))
+		Expect(err.(*responseError).status).To(Equal(http.StatusBadRequest))
+		Expect(err.Error()).To(Equal("400 Bad Request: the request is invalid (request r-1)" +
+			"\n  country: country is a required field" +
+			"\n  display_name: display_name is a required field"))
+	})
+
+	It("answers the body of errors which are not an envelope", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("upstream failed\n"))
+		}
+
+		err := newClient(server.URL, "", "", time.Second).do(http.MethodGet, "/leaderboard", nil, nil)
+		Expect(err).To(MatchError("502 Bad Gateway: upstream failed"))
+	})
+
+	It("answers the status of errors without a body", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		err := newClient(server.URL, "", "", time.Second).do(http.MethodGet, "/user/profile/bob", nil, new(api.UserProfile))
+		Expect(err).To(MatchError("404 Not Found"))
+	})
+
+	It("gives up on answers which take longer than the timeout", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		err := newClient(server.URL, "", "", 50*time.Millisecond).do(http.MethodGet, "/leaderboard", nil, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(BeAssignableToTypeOf(&responseError{}))
+	})
+})
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"leaderboard/app/api"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+
+var _ = Describe("the commands", func() {
+	var (
+		server   *httptest.Server
+		handler  http.HandlerFunc
+		requests []*http.Request
+		output   *bytes.Buffer
+	)
+
+	// run runs a command of a group against the server and answers what it
+	// printed.
+	run := func(format string, cmd command, args ...string) (string, error) {
+		printer, err := newPrinter(format, output)
+		Expect(err).To(BeNil())
+
+		ctl := &ctl{client: newClient(server.URL, "admin", "", time.Second), printer: printer}
+		err = cmd(ctl, args)
+		Expect(printer.flush()).To(Succeed())
+
+		return output.String(), err
+	}
+
+	BeforeEach(func() {
+		requests = nil
+		output = new(bytes.Buffer)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			handler(w, r)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("creates a user and prints the profile", func() {
+		var created *api.UserProfile
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			created = new(api.UserProfile)
+			Expect(json.NewDecoder(r.Body).Decode(created)).To(Succeed())
+			created.UserId = "alice"
+
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(created)
+		}
+
+		printed, err := run(formatTable, createUser, "-name", "Alice", "-country", "tr", "-points", "12.5")
+		Expect(err).To(BeNil())
+		Expect(requests[0].URL.Path).To(Equal("/user/create"))
+		Expect(created.Country).To(Equal("TR"))
+		Expect(printed).To(Equal(
+			"USER_ID  DISPLAY_NAME  COUNTRY  POINTS  RANK\n" +
+				"alice    Alice         TR       12.5    0\n"))
+	})
+
+	It("refuses a user without a country before it calls the service", func() {
+		_, err := run(formatTable, createUser, "-name", "Alice")
+		Expect(err).To(MatchError("users create needs -name and -country"))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("takes flags after the arguments", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(&api.BoardArchive{Label: "season-1"})
+		}
+
+		printed, err := run(formatCSV, archiveBoard, "tr", "-label", "season-1")
+		Expect(err).To(BeNil())
+		Expect(requests[0].URL.Path).To(Equal("/_actuator/boards/TR/archive"))
+		Expect(printed).To(Equal("board,archive\nTR,season-1\n"))
+	})
+
+	It("lists the boards", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode([]*api.Board{{Name: "GLOBAL", Members: 3}, {Name: "TR", Members: 1}})
+		}
+
+		printed, err := run(formatJSON, listBoards)
+		Expect(err).To(BeNil())
+		Expect(printed).To(Equal(`{"name":"GLOBAL","members":3}` + "\n" + `{"name":"TR","members":1}` + "\n"))
+	})
+
+	It("prints the runs of a job", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode([]*api.JobRun{
+				{Id: "r1", Job: "generate-users", State: "done", Done: 10, Total: 10, Owner: "a", QueuedAt: 1604286000, FinishedAt: 1604286060},
+			})
+		}
+
+		printed, err := run(formatCSV, jobStatus, "generate-users")
+		Expect(err).To(BeNil())
+		Expect(requests[0].URL.Path).To(Equal("/_actuator/jobs/generate-users/runs"))
+		Expect(printed).To(Equal(
+			"id,job,state,done,total,owner,queued_at,finished_at,error\n" +
+				"r1,generate-users,done,10,10,a,2020-11-02T03:00:00Z,2020-11-02T03:01:00Z,\n"))
+	})
+
+	It("exports every page of a board", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			var rows []*api.LeaderboardRow
+			for rank := (page-1)*2 + 1; rank <= page*2 && rank <= 5; rank++ {
+				rows = append(rows, &api.LeaderboardRow{Rank: int64(rank), UserId: "u" + strconv.Itoa(rank), Country: "TR", Points: int64(100 - rank)})
+			}
+			_ = json.NewEncoder(w).Encode(rows)
+		}
+
+		printed, err := run(formatCSV, export, "-page-size", "2", "tr")
+		Expect(err).To(BeNil())
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[2].URL.Path).To(Equal("/leaderboard/TR"))
+		Expect(requests[2].URL.RawQuery).To(Equal("page=3&page_size=2"))
+		Expect(printed).To(Equal(
+			"rank,user_id,display_name,country,points\n" +
+				"1,u1,,TR,99\n" +
+				"2,u2,,TR,98\n" +
+				"3,u3,,TR,97\n" +
+				"4,u4,,TR,96\n" +
+				"5,u5,,TR,95\n"))
+	})
+
+	It("answers the error of the service", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(&api.ErrorResponse{Code: "not_found", Message: "user bob is missing"})
+		}
+
+		printed, err := run(formatTable, getUser, "bob")
+		Expect(err).To(MatchError("404 Not Found: user bob is missing"))
+		Expect(printed).To(BeEmpty())
+	})
+
+	It("imports scores and prints the rejected rows", func() {
+		directory, err := ioutil.TempDir("", "leaderboardctl")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(directory)
+
+		path := filepath.Join(directory, "scores.csv")
+		Expect(ioutil.WriteFile(path, []byte("score,user_id\n10,alice\nmany,bob\n20,carol\n30,\n"), 0o644)).To(Succeed())
+
+		var submitted []*api.ScoreSubmission
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			batch := new(api.BatchScoreSubmission)
+			Expect(json.NewDecoder(r.Body).Decode(batch)).To(Succeed())
+			submitted = append(submitted, batch.Submissions...)
+
+			_ = json.NewEncoder(w).Encode([]*api.BatchScoreResult{{}, {Error: "user carol is missing"}})
+		}
+
+		printed, err := run(formatCSV, importScores, path)
+		Expect(err).To(BeNil())
+		Expect(requests).To(HaveLen(1))
+		Expect(submitted).To(HaveLen(2))
+		Expect(submitted[0].UserId).To(Equal("alice"))
+		Expect(submitted[0].Score).To(Equal(10.0))
+		Expect(submitted[0].Timestamp).NotTo(BeZero())
+		Expect(printed).To(HavePrefix("line,user_id,error\n"))
+		Expect(printed).To(ContainSubstring("3,bob,score is not a number\n"))
+		Expect(printed).To(ContainSubstring("5,,user_id is missing\n"))
+		Expect(printed).To(ContainSubstring("4,carol,user carol is missing\n"))
+	})
+})
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// export pages through a board and prints every row as soon as its page is
+// read. Rows which move while the board is paged may be printed twice or
+// not at all.
+func export(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	pageSize := flags.Int64("page-size", 500, "rows read in a request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("export takes a board at most, got %d arguments", flags.NArg())
+	}
+
+	if *pageSize < 1 {
+		return fmt.Errorf("-page-size must be at least 1")
+	}
+
+	board := "GLOBAL"
+	if flags.NArg() == 1 {
+		board = strings.ToUpper(flags.Arg(0))
+	}
+
+	if err := ctl.printer.header("rank", "user_id", "display_name", "country", "points"); err != nil {
+		return err
+	}
+
+	for page := int64(1); ; page++ {
+		var rows []*api.LeaderboardRow
+		path := fmt.Sprintf("/leaderboard/%s?page=%d&page_size=%d", url.PathEscape(board), page, *pageSize)
+		if err := ctl.client.do(http.MethodGet, path, nil, &rows); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			err := ctl.printer.record(row,
+				formatInt(row.Rank),
+				row.UserId,
+				row.DisplayName,
+				row.Country,
+				formatInt(row.Points),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if int64(len(rows)) < *pageSize {
+			return nil
+		}
+	}
+}
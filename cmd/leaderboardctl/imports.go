@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// uploadImport uploads a file of users with their points to be imported by
+// the import job of the service, the file is sent as it is.
+func uploadImport(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("imports upload", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or ndjson, told by the extension of the file when not given")
+	batchSize := flags.Uint64("batch", 0, "rows written at once, 500 when not given")
+	paths, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(paths[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(paths[0])) {
+		case ".csv":
+			*format = "csv"
+		case ".ndjson", ".jsonl":
+			*format = "ndjson"
+		}
+	}
+
+	query := url.Values{}
+	contentType := "application/octet-stream"
+	switch *format {
+	case "csv":
+		contentType = "text/csv"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	}
+	if *format != "" {
+		query.Set("format", *format)
+	}
+	if *batchSize > 0 {
+		query.Set("batch_size", strconv.FormatUint(*batchSize, 10))
+	}
+
+	report := new(api.ImportReport)
+	if err := ctl.client.send(http.MethodPost, "/_actuator/imports?"+query.Encode(), contentType, file, report); err != nil {
+		return err
+	}
+
+	return printRuns(ctl, report.Run)
+}
+
+// importStatus prints the progress of an import, or the rows it rejected.
+func importStatus(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("imports status", flag.ContinueOnError)
+	rejections := flags.Bool("rejections", false, "print the first rows which were rejected rather than the progress")
+	ids, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	report := new(api.ImportReport)
+	if err := ctl.client.do(http.MethodGet, "/_actuator/imports/"+url.PathEscape(ids[0]), nil, report); err != nil {
+		return err
+	}
+
+	if *rejections {
+		if err := ctl.printer.header("row", "user_id", "error"); err != nil {
+			return err
+		}
+
+		for _, rejection := range report.Rejections {
+			if err := ctl.printer.record(rejection, formatInt(rejection.Row), rejection.UserId, rejection.Error); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := ctl.printer.header("id", "state", "rows", "total", "imported", "rejected", "error"); err != nil {
+		return err
+	}
+
+	return ctl.printer.record(report,
+		report.Run.Id,
+		report.Run.State,
+		formatInt(report.Rows),
+		formatInt(report.Run.Total),
+		formatInt(report.Imported),
+		formatInt(report.Rejected),
+		report.Run.Error,
+	)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+)
+
+// jobStatus lists the jobs, the runs of a job, or a run.
+func jobStatus(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs status", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch flags.NArg() {
+	case 0:
+		var jobs []*api.Job
+		if err := ctl.client.do(http.MethodGet, "/_actuator/jobs", nil, &jobs); err != nil {
+			return err
+		}
+
+		return printJobs(ctl, jobs)
+	case 1:
+		var runs []*api.JobRun
+		if err := ctl.client.do(http.MethodGet, runsPath(flags.Arg(0)), nil, &runs); err != nil {
+			return err
+		}
+
+		return printRuns(ctl, runs...)
+	case 2:
+		run := new(api.JobRun)
+		if err := ctl.client.do(http.MethodGet, runsPath(flags.Arg(0))+"/"+url.PathEscape(flags.Arg(1)), nil, run); err != nil {
+			return err
+		}
+
+		return printRuns(ctl, run)
+	default:
+		return fmt.Errorf("jobs status takes a job and a run at most, got %d arguments", flags.NArg())
+	}
+}
+
+func startJob(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs start", flag.ContinueOnError)
+	parameters := flags.String("parameters", "{}", `parameters of the run as JSON, e.g. {"users": 1000, "concurrency": 4}`)
+	jobs, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if !json.Valid([]byte(*parameters)) {
+		return fmt.Errorf("-parameters is not JSON: %s", *parameters)
+	}
+
+	run := new(api.JobRun)
+	if err := ctl.client.do(http.MethodPost, runsPath(jobs[0]), json.RawMessage(*parameters), run); err != nil {
+		return err
+	}
+
+	return printRuns(ctl, run)
+}
+
+func stopJob(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("jobs stop", flag.ContinueOnError)
+	ids, err := parseFlags(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	run := new(api.JobRun)
+	if err := ctl.client.do(http.MethodDelete, runsPath(ids[0])+"/"+url.PathEscape(ids[1]), nil, run); err != nil {
+		return err
+	}
+
+	return printRuns(ctl, run)
+}
+
+func runsPath(job string) string {
+	return "/_actuator/jobs/" + url.PathEscape(job) + "/runs"
+}
+
+func printJobs(ctl *ctl, jobs []*api.Job) error {
+	if err := ctl.printer.header("job", "singleton", "last_run", "state", "done", "total", "finished_at"); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		run := job.LastRun
+		if run == nil {
+			run = new(api.JobRun)
+		}
+
+		err := ctl.printer.record(job,
+			job.Name,
+			fmt.Sprint(job.Singleton),
+			run.Id,
+			run.State,
+			formatInt(run.Done),
+			formatInt(run.Total),
+			formatTime(run.FinishedAt),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printRuns(ctl *ctl, runs ...*api.JobRun) error {
+	if err := ctl.printer.header("id", "job", "state", "done", "total", "owner", "queued_at", "finished_at", "error"); err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		err := ctl.printer.record(run,
+			run.Id,
+			run.Job,
+			run.State,
+			formatInt(run.Done),
+			formatInt(run.Total),
+			run.Owner,
+			formatTime(run.QueuedAt),
+			formatTime(run.FinishedAt),
+			run.Error,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// leaderboardctl operates a leaderboard service over its HTTP API.
+//
+//	leaderboardctl [-url URL] [-api-key KEY | -token TOKEN] [-o table|json|csv] <group> <command> [flags] [args]
+//
+// The URL and the credentials default to LEADERBOARD_URL,
+// LEADERBOARD_API_KEY and LEADERBOARD_TOKEN.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usage = `usage: leaderboardctl [flags] <group> <command> [flags] [args]
+
+  users create -name NAME -country CODE [-points N]
+  users get ID
+  users update ID [-name NAME] [-country CODE]
+  users delete ID
+  scores submit -user ID -score N [-timestamp UNIX]
+  scores import [-batch N] FILE           CSV (user_id,score[,timestamp]) or NDJSON, - for stdin
+  boards list
+  boards inspect NAME
+  boards reset NAME
+  boards archive NAME [-label LABEL]
+  imports upload [-batch N] FILE          users with their points, CSV or NDJSON
+  imports status [-rejections] RUN
+  jobs status [JOB [RUN]]
+  jobs start JOB [-parameters JSON]
+  jobs stop JOB RUN
+  export [-page-size N] [BOARD]            every row of a board, GLOBAL when not given
+
+flags:
+`
+
+// command runs a command of a group with the arguments after its name.
+type command func(ctl *ctl, args []string) error
+
+var groups = map[string]map[string]command{
+	"users": {
+		"create": createUser,
+		"get":    getUser,
+		"update": updateUser,
+		"delete": deleteUser,
+	},
+	"scores": {
+		"submit": submitScore,
+		"import": importScores,
+	},
+	"boards": {
+		"list":    listBoards,
+		"inspect": inspectBoard,
+		"reset":   resetBoard,
+		"archive": archiveBoard,
+	},
+	"imports": {
+		"upload": uploadImport,
+		"status": importStatus,
+	},
+	"jobs": {
+		"status": jobStatus,
+		"start":  startJob,
+		"stop":   stopJob,
+	},
+}
+
+func main() {
+	flags := flag.NewFlagSet("leaderboardctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	url := flags.String("url", env("LEADERBOARD_URL", "http://localhost:1323"), "base URL of the service")
+	apiKey := flags.String("api-key", os.Getenv("LEADERBOARD_API_KEY"), "API key sent as X-API-Key")
+	token := flags.String("token", os.Getenv("LEADERBOARD_TOKEN"), "bearer token sent as Authorization")
+	output := flags.String("o", formatTable, "output format: table, json or csv")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of a request")
+	_ = flags.Parse(os.Args[1:])
+
+	printer, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		exit(err)
+	}
+
+	ctl := &ctl{
+		client:  newClient(*url, *apiKey, *token, *timeout),
+		printer: printer,
+	}
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var run command
+	if args[0] == "export" {
+		run, args = export, args[1:]
+	} else {
+		commands, ok := groups[args[0]]
+		if !ok || len(args) < 2 || commands[args[1]] == nil {
+			exit(fmt.Errorf("unknown command %q, one of: %s", strings.Join(args, " "), commandNames()))
+		}
+		run, args = commands[args[1]], args[2:]
+	}
+
+	err = run(ctl, args)
+	if flushErr := printer.flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		exit(err)
+	}
+}
+
+// ctl is what the commands share, the client and the printer of the output.
+type ctl struct {
+	client  *client
+	printer *printer
+}
+
+// parseFlags parses flags which may come before, between or after the
+// arguments of a command, and answers the arguments.
+func parseFlags(flags *flag.FlagSet, args []string, arguments int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if len(positional) != arguments {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", flags.Name(), arguments, len(positional))
+	}
+
+	return positional, nil
+}
+
+func commandNames() string {
+	var names []string
+	for group, commands := range groups {
+		for name := range commands {
+			names = append(names, group+" "+name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(append(names, "export"), ", ")
+}
+
+func env(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "leaderboardctl:", err)
+	os.Exit(1)
+}
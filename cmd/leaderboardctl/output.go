@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// printer writes the records of a command in the output format. A table is
+// aligned once it is flushed, JSON is written as an object per line so
+// long outputs such as exports can be streamed into other tools.
+type printer struct {
+	format string
+	table  *tabwriter.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newPrinter(format string, w io.Writer) (*printer, error) {
+	p := &printer{format: format}
+	switch format {
+	case formatTable:
+		p.table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	case formatCSV:
+		p.csv = csv.NewWriter(w)
+	case formatJSON:
+		p.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("unknown output format %q, one of table, json or csv", format)
+	}
+
+	return p, nil
+}
+
+// header names the columns, it is not written as JSON.
+func (p *printer) header(columns ...string) error {
+	switch p.format {
+	case formatTable:
+		_, err := fmt.Fprintln(p.table, strings.ToUpper(strings.Join(columns, "\t")))
+		return err
+	case formatCSV:
+		return p.csv.Write(columns)
+	default:
+		return nil
+	}
+}
+
+// record writes value as JSON, or its columns as a row.
+func (p *printer) record(value interface{}, columns ...string) error {
+	switch p.format {
+	case formatTable:
+		_, err := fmt.Fprintln(p.table, strings.Join(columns, "\t"))
+		return err
+	case formatCSV:
+		return p.csv.Write(columns)
+	default:
+		return p.json.Encode(value)
+	}
+}
+
+func (p *printer) flush() error {
+	switch p.format {
+	case formatTable:
+		return p.table.Flush()
+	case formatCSV:
+		p.csv.Flush()
+		return p.csv.Error()
+	default:
+		return nil
+	}
+}
+
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatTime formats a unix timestamp, 0 is left empty.
+func formatTime(value int64) string {
+	if value == 0 {
+		return ""
+	}
+
+	return time.Unix(value, 0).UTC().Format(time.RFC3339)
+}
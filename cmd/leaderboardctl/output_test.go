@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"leaderboard/app/api"
+)
+import . "github.com/onsi/ginkgo"
+import . "github.com/onsi/gomega"
+import . "github.com/onsi/ginkgo/extensions/table"
+
+var _ = Describe("the printer", func() {
+	rows := []*api.LeaderboardRow{
+		{Rank: 1, UserId: "alice", DisplayName: "Alice", Country: "TR", Points: 1200},
+		{Rank: 2, UserId: "bob", DisplayName: "Bob, Jr.", Country: "US", Points: 80},
+	}
+
+	printRows := func(format string) string {
+		output := new(bytes.Buffer)
+		p, err := newPrinter(format, output)
+		Expect(err).To(BeNil())
+
+		Expect(p.header("rank", "user_id", "display_name", "country", "points")).To(Succeed())
+		for _, row := range rows {
+			Expect(p.record(row, formatInt(row.Rank), row.UserId, row.DisplayName, row.Country, formatInt(row.Points))).To(Succeed())
+		}
+		Expect(p.flush()).To(Succeed())
+
+		return output.String()
+	}
+
+	DescribeTable("the formats",
+		func(format string, expected string) {
+			Expect(printRows(format)).To(Equal(expected))
+		},
+		Entry("a table aligned on flush", formatTable,
+			"RANK  USER_ID  DISPLAY_NAME  COUNTRY  POINTS\n"+
+				"1     alice    Alice         TR       1200\n"+
+				"2     bob      Bob, Jr.      US       80\n"),
+		Entry("CSV under a header", formatCSV,
+			"rank,user_id,display_name,country,points\n"+
+				"1,alice,Alice,TR,1200\n"+
+				"2,bob,\"Bob, Jr.\",US,80\n"),
+		Entry("JSON without a header, an object per line", formatJSON,
+			`{"user_id":"alice","rank":1,"points":1200,"display_name":"Alice","country":"TR"}`+"\n"+
+				`{"user_id":"bob","rank":2,"points":80,"display_name":"Bob, Jr.","country":"US"}`+"\n"),
+	)
+
+	It("refuses unknown formats", func() {
+		_, err := newPrinter("yaml", new(bytes.Buffer))
+		Expect(err).To(MatchError(`unknown output format "yaml", one of table, json or csv`))
+	})
+
+	It("formats the values of the columns", func() {
+		Expect(formatInt(-42)).To(Equal("-42"))
+		Expect(formatFloat(12.5)).To(Equal("12.5"))
+		Expect(formatFloat(3)).To(Equal("3"))
+		Expect(formatTime(1604286000)).To(Equal("2020-11-02T03:00:00Z"))
+		Expect(formatTime(0)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBatch is the most submissions the service takes in a batch.
+const maxBatch = 1000
+
+func submitScore(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("scores submit", flag.ContinueOnError)
+	user := flags.String("user", "", "user id")
+	score := flags.Float64("score", 0, "score")
+	timestamp := flags.Int64("timestamp", 0, "unix timestamp of the score, now when not given")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	if *user == "" {
+		return errors.New("scores submit needs -user")
+	}
+
+	if *timestamp == 0 {
+		*timestamp = time.Now().Unix()
+	}
+
+	err := ctl.client.do(http.MethodPost, "/score/submit", &api.ScoreSubmission{
+		UserId:    *user,
+		Score:     *score,
+		Timestamp: *timestamp,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	// the submission is not answered with the outcome, the profile tells it
+	profile := new(api.UserProfile)
+	if err := ctl.client.do(http.MethodGet, "/user/profile/"+url.PathEscape(*user), nil, profile); err != nil {
+		return err
+	}
+
+	return printProfile(ctl, profile)
+}
+
+// importedRow is a submission along with its line in the file.
+type importedRow struct {
+	line       int
+	submission *api.ScoreSubmission
+}
+
+// rejectedRow is a row which was not submitted, or whose submission failed.
+type rejectedRow struct {
+	Line   int    `json:"line"`
+	UserId string `json:"user_id,omitempty"`
+	Error  string `json:"error"`
+}
+
+// importScores submits the scores of a file in batches and prints the rows
+// which were rejected, either while they were read or by the service.
+func importScores(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("scores import", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 500, fmt.Sprintf("submissions in a request, at most %d", maxBatch))
+	format := flags.String("format", "auto", "csv, ndjson, or auto to tell them by the first character")
+	paths, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *batchSize < 1 || *batchSize > maxBatch {
+		return fmt.Errorf("-batch must be between 1 and %d", maxBatch)
+	}
+
+	input := io.Reader(os.Stdin)
+	if paths[0] != "-" {
+		file, err := os.Open(paths[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader := bufio.NewReader(input)
+	api.SkipByteOrderMark(reader)
+	if *format == "auto" {
+		*format = detectFormat(reader)
+	}
+
+	var read func(rows chan<- *importedRow, rejected chan<- *rejectedRow) error
+	switch *format {
+	case "csv":
+		read = func(rows chan<- *importedRow, rejected chan<- *rejectedRow) error {
+			return readCSV(reader, rows, rejected)
+		}
+	case "ndjson":
+		read = func(rows chan<- *importedRow, rejected chan<- *rejectedRow) error {
+			return readNDJSON(reader, rows, rejected)
+		}
+	default:
+		return fmt.Errorf("unknown format %q, one of csv, ndjson or auto", *format)
+	}
+
+	if err := ctl.printer.header("line", "user_id", "error"); err != nil {
+		return err
+	}
+
+	rows := make(chan *importedRow, *batchSize)
+	rejected := make(chan *rejectedRow, *batchSize)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- read(rows, rejected)
+		close(rows)
+	}()
+
+	var (
+		batch     []*importedRow
+		submitted int
+		failed    int
+	)
+	reject := func(row *rejectedRow) error {
+		failed++
+		return ctl.printer.record(row, strconv.Itoa(row.Line), row.UserId, row.Error)
+	}
+	submit := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		submissions := make([]*api.ScoreSubmission, 0, len(batch))
+		for _, row := range batch {
+			submissions = append(submissions, row.submission)
+		}
+
+		var results []*api.BatchScoreResult
+		err := ctl.client.do(http.MethodPost, "/score/submit-batch", &api.BatchScoreSubmission{Submissions: submissions}, &results)
+		if err != nil {
+			return fmt.Errorf("submitting the rows from line %d: %w", batch[0].line, err)
+		}
+
+		for i, result := range results {
+			if result.Error == "" {
+				submitted++
+				continue
+			}
+
+			if err := reject(&rejectedRow{Line: batch[i].line, UserId: batch[i].submission.UserId, Error: result.Error}); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for rows != nil {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				rows = nil
+				break
+			}
+
+			batch = append(batch, row)
+			if len(batch) == *batchSize {
+				if err := submit(); err != nil {
+					return err
+				}
+			}
+		case row := <-rejected:
+			if err := reject(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	// rows rejected along with the last ones which were read
+	for len(rejected) > 0 {
+		if err := reject(<-rejected); err != nil {
+			return err
+		}
+	}
+
+	if err := submit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d scores submitted, %d rows rejected\n", submitted, failed)
+	return <-readErr
+}
+
+// detectFormat peeks at the first character which is not blank, without
+// consuming it, so the lines are still counted from the start.
+func detectFormat(reader *bufio.Reader) string {
+	head, _ := reader.Peek(4096)
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) > 0 && head[0] == '{' {
+		return "ndjson"
+	}
+
+	return "csv"
+}
+
+// readCSV reads rows of user_id,score[,timestamp]. A header naming the
+// columns may come first, in which case they may come in any order.
+func readCSV(input io.Reader, rows chan<- *importedRow, rejected chan<- *rejectedRow) error {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"user_id": 0, "score": 1, "timestamp": 2}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rejected <- &rejectedRow{Line: line, Error: parseErr.Err.Error()}
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if line == 1 && hasColumn(record, "user_id") {
+			columns = map[string]int{}
+			for i, name := range record {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		submission := &api.ScoreSubmission{UserId: field("user_id")}
+		submission.Score, err = strconv.ParseFloat(field("score"), 64)
+		if err != nil {
+			rejected <- &rejectedRow{Line: line, UserId: submission.UserId, Error: "score is not a number"}
+			continue
+		}
+
+		if timestamp := field("timestamp"); timestamp != "" {
+			submission.Timestamp, err = strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				rejected <- &rejectedRow{Line: line, UserId: submission.UserId, Error: "timestamp is not a unix timestamp"}
+				continue
+			}
+		}
+
+		emit(line, submission, rows, rejected)
+	}
+}
+
+// readNDJSON reads a score submission from every line which is not blank.
+func readNDJSON(input io.Reader, rows chan<- *importedRow, rejected chan<- *rejectedRow) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		submission := new(api.ScoreSubmission)
+		if err := json.Unmarshal(scanner.Bytes(), submission); err != nil {
+			rejected <- &rejectedRow{Line: line, Error: err.Error()}
+			continue
+		}
+
+		emit(line, submission, rows, rejected)
+	}
+
+	return scanner.Err()
+}
+
+// emit hands the submission on, or rejects it when it lacks a user. A
+// submission without a timestamp is stamped with the current time.
+func emit(line int, submission *api.ScoreSubmission, rows chan<- *importedRow, rejected chan<- *rejectedRow) {
+	if submission.UserId == "" {
+		rejected <- &rejectedRow{Line: line, Error: "user_id is missing"}
+		return
+	}
+
+	if submission.Timestamp == 0 {
+		submission.Timestamp = time.Now().Unix()
+	}
+
+	rows <- &importedRow{line: line, submission: submission}
+}
+
+func hasColumn(record []string, name string) bool {
+	for _, column := range record {
+		if strings.EqualFold(strings.TrimSpace(column), name) {
+			return true
+		}
+	}
+
+	return false
+}
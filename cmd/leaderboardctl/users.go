@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"leaderboard/app/api"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func createUser(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("users create", flag.ContinueOnError)
+	name := flags.String("name", "", "display name")
+	country := flags.String("country", "", "country code")
+	points := flags.Float64("points", 0, "initial points")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	if *name == "" || *country == "" {
+		return errors.New("users create needs -name and -country")
+	}
+
+	profile := new(api.UserProfile)
+	err := ctl.client.do(http.MethodPost, "/user/create", &api.UserProfile{
+		DisplayName: *name,
+		Country:     strings.ToUpper(*country),
+		Points:      *points,
+	}, profile)
+	if err != nil {
+		return err
+	}
+
+	return printProfile(ctl, profile)
+}
+
+func getUser(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("users get", flag.ContinueOnError)
+	ids, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	profile := new(api.UserProfile)
+	if err := ctl.client.do(http.MethodGet, "/user/profile/"+url.PathEscape(ids[0]), nil, profile); err != nil {
+		return err
+	}
+
+	return printProfile(ctl, profile)
+}
+
+func updateUser(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("users update", flag.ContinueOnError)
+	name := flags.String("name", "", "new display name")
+	country := flags.String("country", "", "new country code, the score of the user moves along")
+	ids, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" && *country == "" {
+		return errors.New("users update needs -name or -country")
+	}
+
+	profile := new(api.UserProfile)
+	err = ctl.client.do(http.MethodPatch, "/user/profile/"+url.PathEscape(ids[0]), &api.UserUpdate{
+		DisplayName: *name,
+		Country:     strings.ToUpper(*country),
+	}, profile)
+	if err != nil {
+		return err
+	}
+
+	return printProfile(ctl, profile)
+}
+
+func deleteUser(ctl *ctl, args []string) error {
+	flags := flag.NewFlagSet("users delete", flag.ContinueOnError)
+	ids, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	return ctl.client.do(http.MethodDelete, "/user/profile/"+url.PathEscape(ids[0]), nil, nil)
+}
+
+func printProfile(ctl *ctl, profile *api.UserProfile) error {
+	if err := ctl.printer.header("user_id", "display_name", "country", "points", "rank"); err != nil {
+		return err
+	}
+
+	return ctl.printer.record(profile,
+		profile.UserId,
+		profile.DisplayName,
+		profile.Country,
+		formatFloat(profile.Points),
+		formatInt(profile.Rank),
+	)
+}